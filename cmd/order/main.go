@@ -1,29 +1,56 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 	"log"
+	"log/slog"
 	"net/http"
+	"order_system/custom/category"
+	"order_system/custom/currency"
 	"order_system/custom/customer"
+	"order_system/custom/fulfillment"
+	"order_system/custom/health"
+	"order_system/custom/migration"
+	"order_system/custom/notification"
 	"order_system/custom/order"
 	"order_system/custom/product"
+	"order_system/custom/promotion"
+	"order_system/custom/reservation"
+	"order_system/custom/tax"
 	"order_system/custom/util"
 	"order_system/dal"
-	"order_system/model"
+	"os"
 	"time"
 )
 
 func main() {
-	serverConfig := util.ServerConfig{}
-	serverConfig.GetConf("./config/config.yaml")
+	util.InitLogger("order_api")
+	configFile := flag.String("config", "./config/config.yaml", "Path of the config file")
+	flag.Parse()
+	serverConfig, err := util.LoadConfig(*configFile)
+	if err != nil {
+		slog.Error("Failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+	slog.Info("Effective config", "config", serverConfig.Redacted())
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		serverConfig.Postgres.Host, serverConfig.Postgres.Port, serverConfig.Postgres.Username, serverConfig.Postgres.Password, serverConfig.Postgres.Database)
+	shutdownTracer, err := util.InitTracer("order_api", serverConfig.Tracing)
+	if err != nil {
+		panic("failed to init tracer" + err.Error())
+	}
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		panic("failed to connect database" + err.Error())
 	}
+	if err = db.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+		panic("failed to init gorm tracing" + err.Error())
+	}
 	sqlDB, _ := db.DB()
 	if sqlDB != nil {
 		sqlDB.SetMaxIdleConns(10)
@@ -31,10 +58,22 @@ func main() {
 		sqlDB.SetConnMaxLifetime(time.Hour)
 	}
 
-	// Auto migrate table schemas
-	err = db.AutoMigrate(model.ALL_ORDER_TABLES...)
+	// Migrate table schemas
+	migrator, err := migration.NewMigrator(db)
 	if err != nil {
-		panic("failed to migrate database" + err.Error())
+		panic("failed to load migrations" + err.Error())
+	}
+	if flag.Arg(0) == "migrate" {
+		if err = migration.RunCommand(migrator, flag.Args()[1:], os.Stdout); err != nil {
+			slog.Error("Migrate failed", "error", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if serverConfig.Postgres.AutoMigrate {
+		if err = migrator.Up(); err != nil {
+			panic("failed to migrate database" + err.Error())
+		}
 	}
 
 	// Initialize handler contexts
@@ -43,22 +82,101 @@ func main() {
 	customerCtx.InitialHandlerContext(dal.Q)
 	productCtx := product.HandlerContext{}
 	productCtx.InitialHandlerContext(dal.Q)
+	categoryCtx := category.HandlerContext{}
+	categoryCtx.InitialHandlerContext(dal.Q)
+	promotionCtx := promotion.HandlerContext{}
+	promotionCtx.InitialHandlerContext(dal.Q)
+	reservationCtx := reservation.HandlerContext{}
+	reservationCtx.InitialHandlerContext(dal.Q)
 	orderCtx := order.HandlerContext{}
 	orderCtx.InitialHandlerContext(dal.Q, orderCtx.CallPaymentApi, serverConfig.Payment_message_queue_url)
+	orderCtx.SetFulfillmentProvider(fulfillment.NewProvider(serverConfig.Fulfillment, orderCtx.ApplyShipmentUpdate))
+	orderCtx.SetNotifier(notification.NewNotifier(serverConfig.Notification))
+	productCtx.SetRestockHook(orderCtx.Restocked)
+
+	// Reload runtime settings on config change
+	configWatcher := util.NewConfigWatcher(*configFile, serverConfig)
+	configWatcher.Subscribe(orderCtx.ApplyConfig)
+	configWatcher.Subscribe(func(c *util.ServerConfig) {
+		rates, err := currency.LoadRateFile(c.Currency.RateFile)
+		if err != nil {
+			slog.Error("Load exchange rates failed, keep using current rates", "error", err.Error())
+			return
+		}
+		orderCtx.SetRateTable(rates)
+	})
+	configWatcher.Subscribe(func(c *util.ServerConfig) {
+		rules, err := tax.LoadRuleFile(c.Tax.RuleFile)
+		if err != nil {
+			slog.Error("Load tax rules failed, keep using current rules", "error", err.Error())
+			return
+		}
+		orderCtx.SetTaxRules(rules)
+	})
+	go configWatcher.Watch(time.Duration(serverConfig.Runtime.ReloadIntervalSeconds)*time.Second, nil)
 
 	// Execute orders
 	go orderCtx.ScanPendingOrders()
 	go orderCtx.ExecuteOrders()
+	go orderCtx.SweepReservations(time.Minute)
+	go orderCtx.SweepCarts(time.Minute)
+
+	// Health checks
+	checkTimeout := time.Duration(serverConfig.Health.CheckTimeoutSeconds) * time.Second
+	healthCtx := health.HandlerContext{}
+	healthCtx.InitialHandlerContext()
+	healthCtx.AddLivenessCheck("order_executor", health.WorkerCheck(orderCtx.Worker))
+	healthCtx.AddReadinessCheck("database", health.DatabaseCheck(db, checkTimeout))
+	healthCtx.AddReadinessCheck("migration", health.MigrationCheck(migrator.Versions))
+	healthCtx.AddReadinessCheck("order_queue", health.QueueBacklogCheck(orderCtx.GetPendingOrderCount, serverConfig.Health.QueueBacklogThreshold))
+	healthCtx.AddReadinessCheck("payment_api", health.DownstreamCheck(serverConfig.Health.PaymentHealthUrl, checkTimeout))
 
 	// Start REST APIs
+	http.HandleFunc("/healthz", healthCtx.Liveness)
+	http.HandleFunc("/readyz", healthCtx.Readiness)
 
 	http.HandleFunc("/order/create_customer", customerCtx.CreateCustomers)
 	http.HandleFunc("/order/query_customer", customerCtx.QueryCustomer)
+	http.HandleFunc("/order/update_customer", customerCtx.UpdateCustomer)
+	http.HandleFunc("/order/delete_customer", customerCtx.DeleteCustomer)
+	http.HandleFunc("/order/list_customers", customerCtx.ListCustomers)
+	http.HandleFunc("/order/create_address", customerCtx.CreateAddress)
+	http.HandleFunc("/order/update_address", customerCtx.UpdateAddress)
+	http.HandleFunc("/order/delete_address", customerCtx.DeleteAddress)
+	http.HandleFunc("/order/list_addresses", customerCtx.ListAddresses)
 	http.HandleFunc("/order/create_product", productCtx.CreateProducts)
 	http.HandleFunc("/order/query_product", productCtx.QueryProduct)
+	http.HandleFunc("/order/set_product_prices", productCtx.SetProductPrices)
+	http.HandleFunc("/order/update_product", productCtx.UpdateProduct)
+	http.HandleFunc("/order/delete_product", productCtx.DeleteProduct)
+	http.HandleFunc("/order/list_products", productCtx.ListProducts)
+	http.HandleFunc("/order/create_variants", productCtx.CreateVariants)
+	http.HandleFunc("/order/update_variant", productCtx.UpdateVariant)
+	http.HandleFunc("/order/create_category", categoryCtx.CreateCategory)
+	http.HandleFunc("/order/update_category", categoryCtx.UpdateCategory)
+	http.HandleFunc("/order/delete_category", categoryCtx.DeleteCategory)
+	http.HandleFunc("/order/list_categories", categoryCtx.ListCategories)
+	http.HandleFunc("/order/set_product_tags", categoryCtx.SetProductTags)
+	http.HandleFunc("/order/list_tags", categoryCtx.ListTags)
+	http.HandleFunc("/order/create_coupon", promotionCtx.CreateCoupon)
+	http.HandleFunc("/order/update_coupon", promotionCtx.UpdateCoupon)
+	http.HandleFunc("/order/list_coupons", promotionCtx.ListCoupons)
 	http.HandleFunc("/order/create_order", orderCtx.CreateOrder)
 	http.HandleFunc("/order/query_order", orderCtx.QueryOrder)
 	http.HandleFunc("/order/payment_callback", orderCtx.PaymentCallBack)
+	http.HandleFunc("/order/create_shipment", orderCtx.CreateShipment)
+	http.HandleFunc("/order/update_shipment", orderCtx.UpdateShipment)
+	http.HandleFunc("/order/cancel_lines", orderCtx.CancelLines)
+	http.HandleFunc("/order/cancel_order", orderCtx.CancelOrder)
+	http.HandleFunc("/order/stock_metrics", reservationCtx.StockMetrics)
+	http.HandleFunc("/order/add_cart_item", orderCtx.AddCartItem)
+	http.HandleFunc("/order/update_cart_item", orderCtx.UpdateCartItem)
+	http.HandleFunc("/order/remove_cart_item", orderCtx.RemoveCartItem)
+	http.HandleFunc("/order/query_cart", orderCtx.QueryCart)
+	http.HandleFunc("/order/checkout_cart", orderCtx.CheckoutCart)
 
-	log.Fatal(http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", serverConfig.Order_port), nil))
+	handler := util.TracingMiddleware("order_api", util.RequestIdMiddleware(http.DefaultServeMux))
+	err = http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", serverConfig.Order_port), handler)
+	shutdownTracer(context.Background())
+	log.Fatal(err)
 }
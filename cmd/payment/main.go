@@ -1,29 +1,50 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	_ "github.com/lib/pq"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 	"log"
+	"log/slog"
 	"net/http"
+	"order_system/custom/health"
 	"order_system/custom/message_queue"
+	"order_system/custom/migration"
 	"order_system/custom/payment"
+	"order_system/custom/risk"
 	"order_system/custom/util"
 	"order_system/dal"
-	"order_system/model"
+	"os"
 	"time"
 )
 
 func main() {
-	serverConfig := util.ServerConfig{}
-	serverConfig.GetConf("./config/config.yaml")
+	util.InitLogger("payment_api")
+	configFile := flag.String("config", "./config/config.yaml", "Path of the config file")
+	flag.Parse()
+	serverConfig, err := util.LoadConfig(*configFile)
+	if err != nil {
+		slog.Error("Failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+	slog.Info("Effective config", "config", serverConfig.Redacted())
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		serverConfig.Postgres.Host, serverConfig.Postgres.Port, serverConfig.Postgres.Username, serverConfig.Postgres.Password, serverConfig.Postgres.Database)
+	shutdownTracer, err := util.InitTracer("payment_api", serverConfig.Tracing)
+	if err != nil {
+		panic("failed to init tracer" + err.Error())
+	}
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		panic("failed to connect database" + err.Error())
 	}
+	if err = db.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+		panic("failed to init gorm tracing" + err.Error())
+	}
 	sqlDB, _ := db.DB()
 	if sqlDB != nil {
 		sqlDB.SetMaxIdleConns(10)
@@ -32,10 +53,22 @@ func main() {
 	}
 	dal.SetDefault(db)
 
-	// Auto migrate table schemas
-	err = db.AutoMigrate(model.Payment{})
+	// Migrate table schemas
+	migrator, err := migration.NewMigrator(db)
 	if err != nil {
-		panic("failed to migrate database" + err.Error())
+		panic("failed to load migrations" + err.Error())
+	}
+	if flag.Arg(0) == "migrate" {
+		if err = migration.RunCommand(migrator, flag.Args()[1:], os.Stdout); err != nil {
+			slog.Error("Migrate failed", "error", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if serverConfig.Postgres.AutoMigrate {
+		if err = migrator.Up(); err != nil {
+			panic("failed to migrate database" + err.Error())
+		}
 	}
 
 	// Initialize handler context
@@ -46,8 +79,42 @@ func main() {
 		serverConfig.Order_payment_callback_url,
 		paymentCtx.CallPaymentCallbackAPI)
 
+	// Reload runtime settings on config change
+	configWatcher := util.NewConfigWatcher(*configFile, serverConfig)
+	configWatcher.Subscribe(paymentCtx.ApplyConfig)
+	configWatcher.Subscribe(func(c *util.ServerConfig) {
+		rules, err := risk.LoadRuleFile(c.Risk.RuleFile)
+		if err != nil {
+			slog.Error("Load risk rules failed, keep using current rules", "error", err.Error())
+			return
+		}
+		paymentCtx.SetRiskRules(rules)
+	})
+	go configWatcher.Watch(time.Duration(serverConfig.Runtime.ReloadIntervalSeconds)*time.Second, nil)
+
 	go paymentCtx.ConsumePaymentMQ()
 
+	// Health checks
+	checkTimeout := time.Duration(serverConfig.Health.CheckTimeoutSeconds) * time.Second
+	healthCtx := health.HandlerContext{}
+	healthCtx.InitialHandlerContext()
+	healthCtx.AddLivenessCheck("payment_consumer", health.WorkerCheck(paymentCtx.Worker))
+	healthCtx.AddReadinessCheck("database", health.DatabaseCheck(db, checkTimeout))
+	healthCtx.AddReadinessCheck("migration", health.MigrationCheck(migrator.Versions))
+	healthCtx.AddReadinessCheck("payment_mq", health.QueueBacklogCheck(paymentCtx.GetPaymentMQCount, serverConfig.Health.QueueBacklogThreshold))
+	healthCtx.AddReadinessCheck("order_api", health.DownstreamCheck(serverConfig.Health.OrderHealthUrl, checkTimeout))
+
+	http.HandleFunc("/healthz", healthCtx.Liveness)
+	http.HandleFunc("/readyz", healthCtx.Readiness)
 	http.HandleFunc("/payment/new_payment", paymentCtx.PublishPaymentMQ)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", serverConfig.Payment_port), nil))
+	http.HandleFunc("/payment/refund", paymentCtx.RefundPayment)
+	http.HandleFunc("/payment/capture", paymentCtx.CapturePayment)
+	http.HandleFunc("/payment/void", paymentCtx.VoidPayment)
+	http.HandleFunc("/payment/review_queue", paymentCtx.ReviewQueue)
+	http.HandleFunc("/payment/review", paymentCtx.ReviewPayment)
+	http.HandleFunc("/payment/list_reviews", paymentCtx.ListReviews)
+	handler := util.TracingMiddleware("payment_api", util.RequestIdMiddleware(http.DefaultServeMux))
+	err = http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", serverConfig.Payment_port), handler)
+	shutdownTracer(context.Background())
+	log.Fatal(err)
 }
@@ -5,9 +5,76 @@ const PAYMENT_STATE_CREATED = int8(0)
 const PAYMENT_STATE_SUCCESS = int8(1)
 const PAYMENT_STATE_FAILED = int8(2)
 const PAYMENT_STATE_REFUND = int8(3)
+const PAYMENT_STATE_AUTHORIZED = int8(4)
+const PAYMENT_STATE_REVIEW = int8(5)
+const PAYMENT_STATE_VOIDED = int8(6)
+
+// Reviewer decisions on payments held for review
+const REVIEW_DECISION_APPROVE = "APPROVE"
+const REVIEW_DECISION_REJECT = "REJECT"
+
+// Shipment State
+const SHIPMENT_STATE_CREATED = int8(0)
+const SHIPMENT_STATE_SHIPPED = int8(1)
+const SHIPMENT_STATE_DELIVERED = int8(2)
+
+// Refund State
+const REFUND_STATE_PENDING = int8(0)
+const REFUND_STATE_COMPLETED = int8(1)
+
+// Payment Action, on the authorized payment of a backorder
+const PAYMENT_ACTION_CAPTURE = "CAPTURE"
+const PAYMENT_ACTION_VOID = "VOID"
+
+// Payment Action State
+const PAYMENT_ACTION_STATE_PENDING = int8(0)
+const PAYMENT_ACTION_STATE_COMPLETED = int8(1)
+const PAYMENT_ACTION_STATE_FAILED = int8(2)
+
+// Cart State
+const CART_STATE_OPEN = int8(0)
+const CART_STATE_CHECKED_OUT = int8(1)
+const CART_STATE_EXPIRED = int8(2)
+
+// Stock Reservation State
+const RESERVATION_STATE_RESERVED = int8(0)
+const RESERVATION_STATE_SOLD = int8(1)
+const RESERVATION_STATE_RELEASED = int8(2)
+const RESERVATION_STATE_EXPIRED = int8(3)
 
 // Error responses
 const CUSTOMER_NOT_FOUND = "customer not found"
 const PRODUCT_NOT_AVAILABLE = "product not available"
 const CREATE_ORDER_FAILED = "create order failed"
 const EXCEED_PAYMENT_LIMIT = "exceed payment limit"
+const EXCEED_DAILY_LIMIT = "exceed daily limit of customer"
+const UNSUPPORTED_CURRENCY = "unsupported currency"
+const SKU_REQUIRED = "sku is required for product with variants"
+const OUT_OF_STOCK = "out of stock"
+const ADDRESS_NOT_FOUND = "address not found"
+const CUSTOMER_AMBIGUOUS = "customer is ambiguous"
+const PRODUCT_NOT_FOUND = "product not found"
+const SHIPMENT_NOT_FOUND = "shipment not found"
+const INVALID_SHIPMENT_STATE = "invalid shipment state"
+const ORDER_LINE_NOT_FOUND = "order line not found"
+const INVALID_QUANTITY = "invalid quantity"
+const ORDER_NOT_FULFILLABLE = "order is not awaiting fulfillment"
+const REFUND_FAILED = "refund failed"
+const REFUND_PENDING = "refund failed, it is pending and requested again on restart"
+const REFUND_ALREADY_MADE = "refund was made already"
+const PAYMENT_NOT_FOUND = "payment not found"
+const REFUND_EXCEEDS_PAYMENT = "refund exceeds the paid amount"
+const COUPON_NOT_FOUND = "coupon not found"
+const COUPON_NOT_APPLICABLE = "coupon not applicable"
+const COUPON_LIMIT_REACHED = "coupon usage limit reached"
+const CART_NOT_FOUND = "cart not found"
+const CART_ITEM_NOT_FOUND = "cart item not found"
+const CART_EMPTY = "cart is empty"
+const CART_CHANGED = "cart changed, review the prices and availability"
+const CART_ITEM_UNAVAILABLE = "cart item unavailable"
+const ORDER_NOT_CANCELABLE = "only unpaid orders can be canceled"
+const CAPTURE_FAILED = "capture payment failed"
+const PAYMENT_ACTION_PENDING = "payment action failed, it is pending and requested again on restart"
+const PAYMENT_NOT_AUTHORIZED = "payment is not authorized"
+const PAYMENT_CAPTURED = "payment was captured"
+const PAYMENT_NOT_IN_REVIEW = "payment is not held for review"
@@ -0,0 +1,103 @@
+package category
+
+import (
+	"context"
+	"fmt"
+	"order_system/dal"
+	"order_system/model"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const PATH_SEPARATOR = "/"
+
+// ChildPath Materialized path of a category under the parent path, root categories have parent path "/"
+func ChildPath(parentPath string, id uint) string {
+	if parentPath == "" {
+		parentPath = PATH_SEPARATOR
+	}
+	return fmt.Sprintf("%s%d%s", parentPath, id, PATH_SEPARATOR)
+}
+
+// PathIds Category ids of a materialized path from the root, e.g. /1/4/ is [1, 4]
+func PathIds(path string) ([]uint, error) {
+	ids := make([]uint, 0)
+	for _, part := range strings.Split(strings.Trim(path, PATH_SEPARATOR), PATH_SEPARATOR) {
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category path %q", path)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// Breadcrumbs Categories from the root down to the given category
+func Breadcrumbs(c context.Context, db *dal.Query, categoryId uint) ([]model.Category, error) {
+	category, err := db.Category.WithContext(c).Where(db.Category.ID.Eq(categoryId)).First()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := PathIds(category.Path)
+	if err != nil {
+		return nil, err
+	}
+	ancestors, err := db.Category.WithContext(c).Where(db.Category.ID.In(ids...)).Find()
+	if err != nil {
+		return nil, err
+	}
+	// Ancestor paths are prefixes of each other, so sorting by length gives root first
+	sort.Slice(ancestors, func(i, j int) bool {
+		return len(ancestors[i].Path) < len(ancestors[j].Path)
+	})
+	breadcrumbs := make([]model.Category, 0, len(ancestors))
+	for _, ancestor := range ancestors {
+		breadcrumbs = append(breadcrumbs, *ancestor)
+	}
+	return breadcrumbs, nil
+}
+
+// ProductTags Tag names of a product, sorted by name
+func ProductTags(c context.Context, db *dal.Query, productId uint) ([]string, error) {
+	tags := make([]string, 0)
+	err := db.Tag.WithContext(c).
+		Select(db.Tag.Name).
+		Join(db.ProductTag, db.ProductTag.TagId.EqCol(db.Tag.ID)).
+		Where(db.ProductTag.ProductId.Eq(productId)).
+		Order(db.Tag.Name).
+		Scan(&tags)
+	return tags, err
+}
+
+// ProductCategoryPaths Category ids from the root down to the category of each product, products without category are left out
+func ProductCategoryPaths(c context.Context, db *dal.Query, productIds []uint) (map[uint][]uint, error) {
+	paths := make(map[uint][]uint)
+	products, err := db.Product.WithContext(c).Where(db.Product.ID.In(productIds...), db.Product.CategoryId.IsNotNull()).Find()
+	if err != nil || len(products) == 0 {
+		return paths, err
+	}
+	categoryIds := make([]uint, 0, len(products))
+	for _, product := range products {
+		categoryIds = append(categoryIds, *product.CategoryId)
+	}
+	categories, err := db.Category.WithContext(c).Where(db.Category.ID.In(categoryIds...)).Find()
+	if err != nil {
+		return nil, err
+	}
+	categoryPaths := make(map[uint][]uint, len(categories))
+	for _, category := range categories {
+		if categoryPaths[category.ID], err = PathIds(category.Path); err != nil {
+			return nil, err
+		}
+	}
+	for _, product := range products {
+		if path, ok := categoryPaths[*product.CategoryId]; ok {
+			paths[product.ID] = path
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,167 @@
+package category
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"net/http"
+	"net/http/httptest"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"testing"
+)
+
+var (
+	categoryColumns   = []string{"id", "name", "parent_id", "path"}
+	selectCategorySQL = `^SELECT \* FROM \"categories\" WHERE \"categories\"\.\"id\" = \$1 ORDER BY .+ LIMIT .*`
+)
+
+func TestCategoryPath(t *testing.T) {
+	assert.Equal(t, "/4/", ChildPath("", 4))
+	assert.Equal(t, "/1/4/", ChildPath("/1/", 4))
+
+	ids, err := PathIds("/1/4/9/")
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{1, 4, 9}, ids)
+
+	_, err = PathIds("/1/x/")
+	assert.Error(t, err)
+}
+
+func TestCreateSubCategory(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCategorySQL).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(1, "Electronics", nil, "/1/"))
+	mock.ExpectQuery(`^INSERT INTO \"categories\" .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec(`^UPDATE \"categories\" SET \"path\"=\$1,\"updated_at\"=\$2 WHERE \"categories\"\.\"id\" = \$3`).
+		WithArgs("/1/4/", sqlmock.AnyArg(), 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"name":"Phones","parent_id":1}`)))
+	handlerCtx.CreateCategory(w, r)
+
+	actualResp := model.Category{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/1/4/", actualResp.Path)
+}
+
+func TestCreateCategoryMissingName(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"name":" "}`)))
+	handlerCtx.CreateCategory(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMoveCategorySubtree(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCategorySQL).WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(4, "Phones", 1, "/1/4/"))
+	mock.ExpectQuery(selectCategorySQL).WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(2, "Mobile", nil, "/2/"))
+	mock.ExpectExec(`^UPDATE \"categories\" SET \"parent_id\"=\$1,.+ WHERE \"categories\"\.\"id\" = \$3`).
+		WithArgs(2, sqlmock.AnyArg(), 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"categories\" SET \"path\"=REPLACE\(\"categories\"\.\"path\",\$1,\$2\),.+ WHERE \"categories\"\.\"path\" LIKE \$4`).
+		WithArgs("/1/4/", "/2/4/", sqlmock.AnyArg(), "/1/4/%").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":4,"parent_id":2}`)))
+	handlerCtx.UpdateCategory(w, r)
+
+	actualResp := model.Category{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/2/4/", actualResp.Path)
+}
+
+func TestMoveCategoryUnderItself(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCategorySQL).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(1, "Electronics", nil, "/1/"))
+	mock.ExpectQuery(selectCategorySQL).WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(4, "Phones", 1, "/1/4/"))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1,"parent_id":4}`)))
+	handlerCtx.UpdateCategory(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDeleteCategoryWithSubCategories(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT count\(\*\) FROM \"categories\" WHERE \"categories\"\.\"parent_id\" = \$1`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1}`)))
+	handlerCtx.DeleteCategory(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSetProductTags(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" = \$1 .+`).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Product"))
+	mock.ExpectExec(`^DELETE FROM \"product_tags\" WHERE \"product_tags\"\.\"product_id\" = \$1`).WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`^INSERT INTO \"tags\" .+ ON CONFLICT \(\"name\"\) DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`^SELECT \* FROM \"tags\" WHERE \"tags\"\.\"name\" IN \(\$1,\$2\)`).WithArgs("sale", "new").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "sale").AddRow(2, "new"))
+	mock.ExpectExec(`^INSERT INTO \"product_tags\" \(\"product_id\",\"tag_id\"\) VALUES \(\$1,\$2\),\(\$3,\$4\)`).
+		WithArgs(1, 1, 1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"product_id":1,"tags":["Sale"," new ","sale"]}`)))
+	handlerCtx.SetProductTags(w, r)
+
+	actualResp := SetProductTagsRequest{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"sale", "new"}, actualResp.Tags)
+}
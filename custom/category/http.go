@@ -0,0 +1,351 @@
+package category
+
+import (
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"strings"
+)
+
+type HandlerContext struct {
+	db *dal.Query
+}
+
+type CreateCategoryRequest struct {
+	Name     string `json:"name"`
+	ParentId *uint  `json:"parent_id,omitempty"`
+}
+
+// UpdateCategoryRequest Rename a category, or move it with its subtree under another parent
+type UpdateCategoryRequest struct {
+	ID       uint    `json:"id"`
+	Name     *string `json:"name,omitempty"`
+	ParentId *uint   `json:"parent_id,omitempty"`
+	// Move the category to the root, ParentId is ignored when it is true
+	MoveToRoot bool `json:"move_to_root,omitempty"`
+}
+
+// SetProductTagsRequest Replace all tags of a product, missing tags are created
+type SetProductTagsRequest struct {
+	ProductId uint     `json:"product_id"`
+	Tags      []string `json:"tags"`
+}
+
+func (ctx *HandlerContext) InitialHandlerContext(db *dal.Query) {
+	ctx.db = db
+}
+
+// CreateCategory Create a root category, or a sub category when parent id is given
+func (ctx *HandlerContext) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := CreateCategoryRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "Category name is required", http.StatusBadRequest)
+		return
+	}
+
+	newCategory := model.Category{
+		Name:     req.Name,
+		ParentId: req.ParentId,
+		Path:     PATH_SEPARATOR,
+	}
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		parentPath := PATH_SEPARATOR
+		if req.ParentId != nil {
+			parent, errTx := tx.Category.WithContext(r.Context()).Where(tx.Category.ID.Eq(*req.ParentId)).First()
+			if errTx != nil {
+				return errors.New("Parent category not found: " + errTx.Error())
+			}
+			parentPath = parent.Path
+		}
+		if errTx := tx.Category.WithContext(r.Context()).Create(&newCategory); errTx != nil {
+			return errTx
+		}
+		// The path contains the id of the category itself, so it is only known after insert
+		newCategory.Path = ChildPath(parentPath, newCategory.ID)
+		_, errTx := tx.Category.WithContext(r.Context()).Where(tx.Category.ID.Eq(newCategory.ID)).Update(tx.Category.Path, newCategory.Path)
+		return errTx
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Category was created", "category_id", newCategory.ID, "path", newCategory.Path)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(newCategory)
+	w.Write(respBody)
+}
+
+// UpdateCategory Rename or move a category, sub categories are moved together
+func (ctx *HandlerContext) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := UpdateCategoryRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "Category ID is required", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		http.Error(w, "Category name must not be empty", http.StatusBadRequest)
+		return
+	}
+	if req.Name == nil && req.ParentId == nil && !req.MoveToRoot {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var categoryInfo *model.Category
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		categoryTable := tx.Category
+		var errTx error
+		categoryInfo, errTx = categoryTable.WithContext(r.Context()).Where(categoryTable.ID.Eq(req.ID)).First()
+		if errTx != nil {
+			return errTx
+		}
+		if req.Name != nil {
+			categoryInfo.Name = strings.TrimSpace(*req.Name)
+			if _, errTx = categoryTable.WithContext(r.Context()).Where(categoryTable.ID.Eq(req.ID)).Update(categoryTable.Name, categoryInfo.Name); errTx != nil {
+				return errTx
+			}
+		}
+		if req.ParentId == nil && !req.MoveToRoot {
+			return nil
+		}
+
+		// Move the subtree by replacing the path prefix of the category and all its descendants
+		parentPath := PATH_SEPARATOR
+		var parentId *uint
+		if !req.MoveToRoot {
+			parent, errParent := categoryTable.WithContext(r.Context()).Where(categoryTable.ID.Eq(*req.ParentId)).First()
+			if errParent != nil {
+				return errors.New("Parent category not found: " + errParent.Error())
+			}
+			if strings.HasPrefix(parent.Path, categoryInfo.Path) {
+				return errors.New("Category can't be moved under itself or its sub categories")
+			}
+			parentPath, parentId = parent.Path, &parent.ID
+		}
+		oldPath, newPath := categoryInfo.Path, ChildPath(parentPath, categoryInfo.ID)
+		if _, errTx = categoryTable.WithContext(r.Context()).Where(categoryTable.ID.Eq(req.ID)).Update(categoryTable.ParentId, parentId); errTx != nil {
+			return errTx
+		}
+		_, errTx = categoryTable.WithContext(r.Context()).
+			Where(categoryTable.Path.Like(oldPath+"%")).
+			Update(categoryTable.Path, categoryTable.Path.Replace(oldPath, newPath))
+		categoryInfo.ParentId, categoryInfo.Path = parentId, newPath
+		return errTx
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Category was updated", "category_id", categoryInfo.ID, "path", categoryInfo.Path)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(*categoryInfo)
+	w.Write(respBody)
+}
+
+// DeleteCategory Delete a category which has neither sub categories nor products
+func (ctx *HandlerContext) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost, http.MethodDelete}, w, r) {
+		return
+	}
+
+	req := model.Category{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "Category ID is required", http.StatusBadRequest)
+		return
+	}
+
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		children, errTx := tx.Category.WithContext(r.Context()).Where(tx.Category.ParentId.Eq(req.ID)).Count()
+		if errTx != nil {
+			return errTx
+		}
+		if children > 0 {
+			return errors.New("Category has sub categories")
+		}
+		// Soft deleted products still refer to the category
+		products, errTx := tx.Product.WithContext(r.Context()).Unscoped().Where(tx.Product.CategoryId.Eq(req.ID)).Count()
+		if errTx != nil {
+			return errTx
+		}
+		if products > 0 {
+			return errors.New("Category has products")
+		}
+		result, errTx := tx.Category.WithContext(r.Context()).Where(tx.Category.ID.Eq(req.ID)).Delete()
+		if errTx == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return errTx
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Category was deleted", "category_id", req.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Delete category success."))
+}
+
+// ListCategories All categories ordered by path, so parents come before their sub categories
+func (ctx *HandlerContext) ListCategories(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	categories, errDb := ctx.db.Category.WithContext(r.Context()).Order(ctx.db.Category.Path).Find()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(categories)
+	w.Write(respBody)
+}
+
+// SetProductTags Replace tags of a product
+func (ctx *HandlerContext) SetProductTags(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := SetProductTagsRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ProductId == 0 {
+		http.Error(w, "Product ID is required", http.StatusBadRequest)
+		return
+	}
+	tagNames := make([]string, 0, len(req.Tags))
+	tagSet := make(map[string]bool)
+	for _, name := range req.Tags {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			http.Error(w, "Tag name must not be empty", http.StatusBadRequest)
+			return
+		}
+		if !tagSet[name] {
+			tagSet[name] = true
+			tagNames = append(tagNames, name)
+		}
+	}
+
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		if _, errTx := tx.Product.WithContext(r.Context()).Where(tx.Product.ID.Eq(req.ProductId)).First(); errTx != nil {
+			return errors.New("Product not found: " + errTx.Error())
+		}
+		if _, errTx := tx.ProductTag.WithContext(r.Context()).Where(tx.ProductTag.ProductId.Eq(req.ProductId)).Delete(); errTx != nil {
+			return errTx
+		}
+		if len(tagNames) == 0 {
+			return nil
+		}
+
+		newTags := make([]*model.Tag, 0, len(tagNames))
+		for _, name := range tagNames {
+			newTags = append(newTags, &model.Tag{Name: name})
+		}
+		errTx := tx.Tag.WithContext(r.Context()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoNothing: true,
+		}).Create(newTags...)
+		if errTx != nil {
+			return errTx
+		}
+		tags, errTx := tx.Tag.WithContext(r.Context()).Where(tx.Tag.Name.In(tagNames...)).Find()
+		if errTx != nil {
+			return errTx
+		}
+		productTags := make([]*model.ProductTag, 0, len(tags))
+		for _, tag := range tags {
+			productTags = append(productTags, &model.ProductTag{ProductId: req.ProductId, TagId: tag.ID})
+		}
+		return tx.ProductTag.WithContext(r.Context()).Create(productTags...)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(SetProductTagsRequest{ProductId: req.ProductId, Tags: tagNames})
+	w.Write(respBody)
+}
+
+// ListTags All tags ordered by name
+func (ctx *HandlerContext) ListTags(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	tags, errDb := ctx.db.Tag.WithContext(r.Context()).Order(ctx.db.Tag.Name).Find()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(tags)
+	w.Write(respBody)
+}
@@ -0,0 +1,121 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"order_system/custom/util"
+	"order_system/model"
+	"regexp"
+)
+
+// Currency of products created without one, and base currency of the default rate table
+const DEFAULT_CURRENCY = "USD"
+
+// Exchange rates are applied with 8 decimal places, same as the orders.exchange_rate column
+const RATE_SCALE = 100000000
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// RateTable Exchange rates of 1 unit of the base currency, e.g. base USD with EUR 0.92
+type RateTable struct {
+	Base  string             `yaml:"base"`
+	Rates map[string]float64 `yaml:"rates"`
+}
+
+// IsValidCode Whether code is an ISO 4217 style currency code, e.g. USD
+func IsValidCode(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+// DefaultRateTable Rate table which only supports DEFAULT_CURRENCY
+func DefaultRateTable() *RateTable {
+	return &RateTable{
+		Base:  DEFAULT_CURRENCY,
+		Rates: map[string]float64{DEFAULT_CURRENCY: 1},
+	}
+}
+
+// LoadRateFile Read a yaml rate table, the default rate table is used when fileName is empty
+func LoadRateFile(fileName string) (*RateTable, error) {
+	if fileName == "" {
+		return DefaultRateTable(), nil
+	}
+	table := &RateTable{}
+	if err := util.LoadYamlFile("rate file", fileName, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Validate Check currency codes and rates, the base currency is added with rate 1 when missing
+func (t *RateTable) Validate() error {
+	errs := make([]error, 0)
+	if !IsValidCode(t.Base) {
+		errs = append(errs, fmt.Errorf("invalid base currency %q", t.Base))
+	}
+	if t.Rates == nil {
+		t.Rates = make(map[string]float64)
+	}
+	if rate, ok := t.Rates[t.Base]; !ok {
+		t.Rates[t.Base] = 1
+	} else if rate != 1 {
+		errs = append(errs, fmt.Errorf("rate of base currency %s must be 1, got %v", t.Base, rate))
+	}
+	for code, rate := range t.Rates {
+		if !IsValidCode(code) {
+			errs = append(errs, fmt.Errorf("invalid currency %q", code))
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			errs = append(errs, fmt.Errorf("rate of %s must be positive, got %v", code, rate))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *RateTable) Supports(code string) bool {
+	_, ok := t.Rates[code]
+	return ok
+}
+
+// Rate Units of the given currency per 1 unit of the base currency
+func (t *RateTable) Rate(code string) (float64, error) {
+	rate, ok := t.Rates[code]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %q", code)
+	}
+	return rate, nil
+}
+
+// Convert Convert amount between two supported currencies
+func (t *RateTable) Convert(amount model.Money, from string, to string) (model.Money, error) {
+	fromRate, err := t.Rate(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := t.Rate(to)
+	if err != nil {
+		return 0, err
+	}
+	if from == to {
+		return amount, nil
+	}
+	return amount.MulRatio(scaleRate(toRate), scaleRate(fromRate)), nil
+}
+
+// FromBase Convert an amount of the base currency with a rate snapshot, e.g. the rate of an order
+func FromBase(amount model.Money, rate float64) model.Money {
+	return amount.MulRatio(scaleRate(rate), RATE_SCALE)
+}
+
+// ToBase Convert an amount back to the base currency with a rate snapshot
+func ToBase(amount model.Money, rate float64) model.Money {
+	if rate <= 0 {
+		return amount
+	}
+	return amount.MulRatio(RATE_SCALE, scaleRate(rate))
+}
+
+func scaleRate(rate float64) int64 {
+	return int64(math.Round(rate * RATE_SCALE))
+}
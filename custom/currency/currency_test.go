@@ -0,0 +1,68 @@
+package currency
+
+import (
+	"github.com/stretchr/testify/assert"
+	"order_system/custom/util"
+	"order_system/model"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRateFile(t *testing.T) {
+	table, err := LoadRateFile(util.WriteTestFile(t, "exchange_rates.yaml", "base: USD\nrates:\n  EUR: 0.92\n  TWD: 32.1\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "USD", table.Base)
+	assert.True(t, table.Supports("USD"))
+	rate, err := table.Rate("TWD")
+	assert.Nil(t, err)
+	assert.Equal(t, 32.1, rate)
+
+	_, err = table.Rate("JPY")
+	assert.Error(t, err)
+}
+
+func TestLoadRateFileRepoFile(t *testing.T) {
+	_, err := LoadRateFile("../../config/exchange_rates.yaml")
+	assert.Nil(t, err)
+}
+
+func TestLoadRateFileInvalid(t *testing.T) {
+	_, err := LoadRateFile(util.WriteTestFile(t, "exchange_rates.yaml", "base: usd\nrates:\n  EUR: -1\n  TW: 32.1\n"))
+	assert.Error(t, err)
+	assert.Regexp(t, "invalid base currency", err.Error())
+	assert.Regexp(t, "rate of EUR must be positive", err.Error())
+	assert.Regexp(t, `invalid currency "TW"`, err.Error())
+
+	_, err = LoadRateFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestLoadRateFileDefault(t *testing.T) {
+	table, err := LoadRateFile("")
+	assert.Nil(t, err)
+	assert.Equal(t, DEFAULT_CURRENCY, table.Base)
+	assert.True(t, table.Supports(DEFAULT_CURRENCY))
+}
+
+func TestConvert(t *testing.T) {
+	table := &RateTable{Base: "USD", Rates: map[string]float64{"USD": 1, "EUR": 0.92, "TWD": 32}}
+
+	amount, err := table.Convert(model.NewMoney(100, 0), "USD", "EUR")
+	assert.Nil(t, err)
+	assert.Equal(t, model.NewMoney(92, 0), amount)
+
+	// Cross rate through the base currency, 92 EUR is 100 USD is 3200 TWD
+	amount, err = table.Convert(model.NewMoney(92, 0), "EUR", "TWD")
+	assert.Nil(t, err)
+	assert.Equal(t, model.NewMoney(3200, 0), amount)
+
+	_, err = table.Convert(model.NewMoney(1, 0), "USD", "JPY")
+	assert.Error(t, err)
+}
+
+func TestBaseConversionWithRateSnapshot(t *testing.T) {
+	assert.Equal(t, model.NewMoney(92, 0), FromBase(model.NewMoney(100, 0), 0.92))
+	assert.Equal(t, model.NewMoney(100, 0), ToBase(model.NewMoney(92, 0), 0.92))
+	// Orders without a rate snapshot are already in the base currency
+	assert.Equal(t, model.NewMoney(92, 0), ToBase(model.NewMoney(92, 0), 0))
+}
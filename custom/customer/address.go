@@ -0,0 +1,317 @@
+package customer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+	"net/http"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"regexp"
+	"strings"
+)
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// UpdateAddressRequest Only the given fields are updated
+type UpdateAddressRequest struct {
+	ID                uint    `json:"id"`
+	Line1             *string `json:"line1,omitempty"`
+	Line2             *string `json:"line2,omitempty"`
+	City              *string `json:"city,omitempty"`
+	Region            *string `json:"region,omitempty"`
+	PostalCode        *string `json:"postal_code,omitempty"`
+	Country           *string `json:"country,omitempty"`
+	IsDefaultBilling  *bool   `json:"is_default_billing,omitempty"`
+	IsDefaultShipping *bool   `json:"is_default_shipping,omitempty"`
+}
+
+// CreateAddress Save a new address of a customer, the first address becomes the default billing and shipping address
+func (ctx *HandlerContext) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := model.Address{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	req.ID = 0
+	if req.CustomerId == 0 {
+		http.Error(w, "CustomerId is required", http.StatusBadRequest)
+		return
+	}
+	if validationErr := validateAddress(&req); validationErr != "" {
+		http.Error(w, validationErr, http.StatusBadRequest)
+		return
+	}
+
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		if _, errTx := tx.Customer.WithContext(r.Context()).Where(tx.Customer.ID.Eq(req.CustomerId)).First(); errTx != nil {
+			return gorm.ErrRecordNotFound
+		}
+		addressCount, errTx := tx.Address.WithContext(r.Context()).Where(tx.Address.CustomerId.Eq(req.CustomerId)).Count()
+		if errTx != nil {
+			return errTx
+		}
+		if addressCount == 0 {
+			req.IsDefaultBilling = true
+			req.IsDefaultShipping = true
+		}
+		if errTx = clearDefaults(r.Context(), tx, req.CustomerId, 0, req.IsDefaultBilling, req.IsDefaultShipping); errTx != nil {
+			return errTx
+		}
+		return tx.Address.WithContext(r.Context()).Create(&req)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Address was created", "customer_id", req.CustomerId, "address_id", req.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(req)
+	w.Write(respBody)
+}
+
+// UpdateAddress Update fields of an address, making it the default replaces the previous default
+func (ctx *HandlerContext) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := UpdateAddressRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "Address ID is required", http.StatusBadRequest)
+		return
+	}
+	addressTable := ctx.db.Address
+	updates := make([]field.AssignExpr, 0)
+	validationErr := ""
+	if req.Line1 != nil {
+		if strings.TrimSpace(*req.Line1) == "" {
+			validationErr += "Address line1 must not be empty."
+		}
+		updates = append(updates, addressTable.Line1.Value(*req.Line1))
+	}
+	if req.Line2 != nil {
+		updates = append(updates, addressTable.Line2.Value(*req.Line2))
+	}
+	if req.City != nil {
+		if strings.TrimSpace(*req.City) == "" {
+			validationErr += "Address city must not be empty."
+		}
+		updates = append(updates, addressTable.City.Value(*req.City))
+	}
+	if req.Region != nil {
+		updates = append(updates, addressTable.Region.Value(*req.Region))
+	}
+	if req.PostalCode != nil {
+		if strings.TrimSpace(*req.PostalCode) == "" {
+			validationErr += "Address postal code must not be empty."
+		}
+		updates = append(updates, addressTable.PostalCode.Value(*req.PostalCode))
+	}
+	if req.Country != nil {
+		country := strings.ToUpper(strings.TrimSpace(*req.Country))
+		if !countryCodePattern.MatchString(country) {
+			validationErr += "Address country must be an ISO 3166-1 alpha-2 code."
+		}
+		updates = append(updates, addressTable.Country.Value(country))
+	}
+	if req.IsDefaultBilling != nil {
+		updates = append(updates, addressTable.IsDefaultBilling.Value(*req.IsDefaultBilling))
+	}
+	if req.IsDefaultShipping != nil {
+		updates = append(updates, addressTable.IsDefaultShipping.Value(*req.IsDefaultShipping))
+	}
+	if validationErr != "" {
+		http.Error(w, validationErr, http.StatusBadRequest)
+		return
+	}
+	if len(updates) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var addressInfo *model.Address
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		current, errTx := tx.Address.WithContext(r.Context()).Where(tx.Address.ID.Eq(req.ID)).First()
+		if errTx != nil {
+			return gorm.ErrRecordNotFound
+		}
+		errTx = clearDefaults(r.Context(), tx, current.CustomerId, req.ID,
+			req.IsDefaultBilling != nil && *req.IsDefaultBilling, req.IsDefaultShipping != nil && *req.IsDefaultShipping)
+		if errTx != nil {
+			return errTx
+		}
+		if _, errTx = tx.Address.WithContext(r.Context()).Where(tx.Address.ID.Eq(req.ID)).UpdateSimple(updates...); errTx != nil {
+			return errTx
+		}
+		addressInfo, errTx = tx.Address.WithContext(r.Context()).Where(tx.Address.ID.Eq(req.ID)).First()
+		return errTx
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Address was updated", "address_id", req.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(*addressInfo)
+	w.Write(respBody)
+}
+
+// DeleteAddress Delete an address, orders keep their address snapshots
+func (ctx *HandlerContext) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost, http.MethodDelete}, w, r) {
+		return
+	}
+
+	req := model.Address{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "Address ID is required", http.StatusBadRequest)
+		return
+	}
+
+	result, errDb := ctx.db.Address.WithContext(r.Context()).Where(ctx.db.Address.ID.Eq(req.ID)).Delete()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Address was deleted", "address_id", req.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Delete address success."))
+}
+
+// ListAddresses List saved addresses of a customer
+func (ctx *HandlerContext) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	req := QueryCustomerRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.CustomerId == 0 {
+		http.Error(w, "CustomerId is required", http.StatusBadRequest)
+		return
+	}
+
+	addresses, errDb := ctx.db.Address.WithContext(r.Context()).Where(ctx.db.Address.CustomerId.Eq(req.CustomerId)).Order(ctx.db.Address.ID).Find()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(addresses)
+	w.Write(respBody)
+}
+
+// DefaultAddresses Pick the shipping and billing address of an order from the customer addresses.
+// Given address ids take precedence over the defaults, billing falls back to the shipping address.
+func DefaultAddresses(addresses []*model.Address, shippingId *uint, billingId *uint) (shipping *model.Address, billing *model.Address, err error) {
+	for _, address := range addresses {
+		if (shippingId != nil && address.ID == *shippingId) || (shippingId == nil && address.IsDefaultShipping) {
+			shipping = address
+		}
+		if (billingId != nil && address.ID == *billingId) || (billingId == nil && address.IsDefaultBilling) {
+			billing = address
+		}
+	}
+	if (shippingId != nil && shipping == nil) || (billingId != nil && billing == nil) {
+		return nil, nil, errors.New("address not found for the customer")
+	}
+	if billing == nil {
+		billing = shipping
+	}
+	return shipping, billing, nil
+}
+
+// Unset the default flags of other addresses of the customer
+func clearDefaults(c context.Context, tx *dal.Query, customerId uint, exceptId uint, billing bool, shipping bool) error {
+	addressTable := tx.Address
+	if billing {
+		_, err := addressTable.WithContext(c).
+			Where(addressTable.CustomerId.Eq(customerId), addressTable.ID.Neq(exceptId), addressTable.IsDefaultBilling.Is(true)).
+			UpdateSimple(addressTable.IsDefaultBilling.Value(false))
+		if err != nil {
+			return err
+		}
+	}
+	if shipping {
+		_, err := addressTable.WithContext(c).
+			Where(addressTable.CustomerId.Eq(customerId), addressTable.ID.Neq(exceptId), addressTable.IsDefaultShipping.Is(true)).
+			UpdateSimple(addressTable.IsDefaultShipping.Value(false))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validation errors of an address, empty when it is valid. The country is uppercased.
+func validateAddress(address *model.Address) string {
+	validationErr := ""
+	if strings.TrimSpace(address.Line1) == "" {
+		validationErr += "Address line1 is required."
+	}
+	if strings.TrimSpace(address.City) == "" {
+		validationErr += "Address city is required."
+	}
+	if strings.TrimSpace(address.PostalCode) == "" {
+		validationErr += "Address postal code is required."
+	}
+	address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+	if !countryCodePattern.MatchString(address.Country) {
+		validationErr += "Address country must be an ISO 3166-1 alpha-2 code."
+	}
+	return validationErr
+}
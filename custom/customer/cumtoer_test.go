@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 	"gorm.io/gorm"
 	"net/http"
 	"net/http/httptest"
 	"order_system/custom/util"
 	"order_system/dal"
 	"order_system/model"
+	"strings"
 	"testing"
 )
 
@@ -155,7 +157,7 @@ func TestCreatCustomerMissingName(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestCreatCustomerNameExisting(t *testing.T) {
+func TestCreatCustomerEmailExisting(t *testing.T) {
 	sqlDB, _, mock := util.DbMock(t)
 	defer sqlDB.Close()
 	handlerCtx := HandlerContext{}
@@ -173,3 +175,228 @@ func TestCreatCustomerNameExisting(t *testing.T) {
 	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestCreatCustomerInvalidEmail(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customers":[
+		{"name":"A","email":"not an email"},{"name":"B","email":"b@mail.com"},{"name":"C","email":" B@Mail.com"}]}`)))
+	handlerCtx.CreateCustomers(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "The 1 customer email is invalid.The 3 customer email is duplicated.", strings.TrimSpace(w.Body.String()))
+}
+
+func TestQueryCustomerByEmail(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	returnData, _ := util.ObjectToRows(testCustomer)
+	expectedSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"email\" = \$1 AND \"customers\"\.\"deleted_at\" IS NULL .* LIMIT .*`
+	mock.ExpectQuery(expectedSQL).WithArgs("user@mail.com", 1).WillReturnRows(returnData)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"email":" User@Mail.com "}`)))
+	handlerCtx.QueryCustomer(w, r)
+
+	actualResp := model.Customer{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testCustomer.ID, actualResp.ID)
+}
+
+func TestUpdateCustomerSuccess(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	updatedCustomer := testCustomer
+	updatedCustomer.Email = util.GetStringPtr("new@mail.com")
+	returnData, _ := util.ObjectToRows(updatedCustomer)
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"customers\" SET \"email\"=\$1,\"updated_at\"=\$2 WHERE \"customers\"\.\"id\" = \$3 AND \"customers\"\.\"deleted_at\" IS NULL`).
+		WithArgs("new@mail.com", sqlmock.AnyArg(), testCustomer.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1 .+`).WithArgs(testCustomer.ID, 1).WillReturnRows(returnData)
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1,"email":"New@Mail.com"}`)))
+	handlerCtx.UpdateCustomer(w, r)
+
+	actualResp := model.Customer{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "new@mail.com", *actualResp.Email)
+}
+
+func TestUpdateCustomerNotFound(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"customers\" SET .+`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":9,"name":"New Name"}`)))
+	handlerCtx.UpdateCustomer(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteCustomerSuccess(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"customers\" SET \"deleted_at\"=\$1 WHERE \"customers\"\.\"id\" = \$2 AND \"customers\"\.\"deleted_at\" IS NULL`).
+		WithArgs(sqlmock.AnyArg(), testCustomer.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":1}`)))
+	handlerCtx.DeleteCustomer(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestListCustomersSearch(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	returnData := sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(1, "User A", "a@mail.com").AddRow(2, "User B", "b@mail.com")
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \(LOWER\(\"customers\"\.\"name\"\) LIKE \$1 OR \"customers\"\.\"email\" LIKE \$2\) AND \"customers\"\.\"deleted_at\" IS NULL ORDER BY \"customers\"\.\"id\" LIMIT \$3`).
+		WithArgs("%user%", "%user%", 2).WillReturnRows(returnData)
+	mock.ExpectQuery(`^SELECT count\(\*\) FROM \"customers\" WHERE .+`).WithArgs("%user%", "%user%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"query":"User","page_size":2}`)))
+	handlerCtx.ListCustomers(w, r)
+
+	actualResp := ListCustomersResponse{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(3), actualResp.Total)
+	assert.Equal(t, 1, actualResp.Page)
+	assert.Equal(t, 2, len(actualResp.Customers))
+}
+
+func TestCreateFirstAddressIsDefault(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1 .+`).WithArgs(testCustomer.ID, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(`^SELECT count\(\*\) FROM \"addresses\" WHERE \"addresses\"\.\"customer_id\" = \$1`).WithArgs(testCustomer.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`^UPDATE \"addresses\" SET \"is_default_billing\"=\$1,.+`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^UPDATE \"addresses\" SET \"is_default_shipping\"=\$1,.+`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`^INSERT INTO \"addresses\" .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(
+		`{"customer_id":1,"line1":"1 Main St","city":"Springfield","postal_code":"12345","country":"us"}`)))
+	handlerCtx.CreateAddress(w, r)
+
+	actualResp := model.Address{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "US", actualResp.Country)
+	assert.True(t, actualResp.IsDefaultBilling)
+	assert.True(t, actualResp.IsDefaultShipping)
+}
+
+func TestCreateAddressInvalid(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(
+		`{"customer_id":1,"line1":"1 Main St","city":"Springfield","country":"USA"}`)))
+	handlerCtx.CreateAddress(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Address postal code is required.Address country must be an ISO 3166-1 alpha-2 code.", strings.TrimSpace(w.Body.String()))
+}
+
+func TestUpdateAddressMakeDefault(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	addressColumns := []string{"id", "customer_id", "line1", "city", "postal_code", "country", "is_default_billing", "is_default_shipping"}
+	selectAddressSQL := `^SELECT \* FROM \"addresses\" WHERE \"addresses\"\.\"id\" = \$1 .+`
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectAddressSQL).WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows(addressColumns).AddRow(2, 1, "2 Side St", "Shelbyville", "54321", "US", false, false))
+	mock.ExpectExec(`^UPDATE \"addresses\" SET \"is_default_shipping\"=\$1,\"updated_at\"=\$2 WHERE \"addresses\"\.\"customer_id\" = \$3 AND \"addresses\"\.\"id\" <> \$4 AND \"addresses\"\.\"is_default_shipping\" = \$5`).
+		WithArgs(false, sqlmock.AnyArg(), 1, 2, true).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"addresses\" SET \"is_default_shipping\"=\$1,\"updated_at\"=\$2 WHERE \"addresses\"\.\"id\" = \$3`).
+		WithArgs(true, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(selectAddressSQL).WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows(addressColumns).AddRow(2, 1, "2 Side St", "Shelbyville", "54321", "US", false, true))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":2,"is_default_shipping":true}`)))
+	handlerCtx.UpdateAddress(w, r)
+
+	actualResp := model.Address{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, actualResp.IsDefaultShipping)
+}
+
+func TestDefaultAddresses(t *testing.T) {
+	home := &model.Address{ID: 1, IsDefaultShipping: true}
+	office := &model.Address{ID: 2, IsDefaultBilling: true}
+	addresses := []*model.Address{home, office}
+
+	shipping, billing, err := DefaultAddresses(addresses, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, home, shipping)
+	assert.Equal(t, office, billing)
+
+	officeId := uint(2)
+	shipping, billing, err = DefaultAddresses([]*model.Address{home, {ID: 2}}, &officeId, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), shipping.ID)
+	assert.Equal(t, uint(2), billing.ID)
+
+	otherId := uint(9)
+	_, _, err = DefaultAddresses(addresses, nil, &otherId)
+	assert.Error(t, err)
+
+	shipping, billing, err = DefaultAddresses(nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, shipping)
+	assert.Nil(t, billing)
+}
@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
 	"net/http"
+	"net/mail"
 	"order_system/custom/util"
 	"order_system/dal"
 	"order_system/model"
+	"strings"
 )
 
 type HandlerContext struct {
@@ -18,8 +22,32 @@ type CreateCustomerRequest struct {
 	Customers *[]model.Customer `json:"customers"`
 }
 
+// QueryCustomerRequest Either the customer id or email is required
 type QueryCustomerRequest struct {
-	CustomerId uint `json:"customer_id"`
+	CustomerId uint   `json:"customer_id"`
+	Email      string `json:"email,omitempty"`
+}
+
+// UpdateCustomerRequest Only the given fields are updated, an empty email removes it
+type UpdateCustomerRequest struct {
+	ID      uint    `json:"id"`
+	Name    *string `json:"name,omitempty"`
+	Email   *string `json:"email,omitempty"`
+	Address *string `json:"address,omitempty"`
+}
+
+// ListCustomersRequest Query matches part of the name or email
+type ListCustomersRequest struct {
+	Query    string `json:"query,omitempty"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+}
+
+type ListCustomersResponse struct {
+	Customers []*model.Customer `json:"customers"`
+	Total     int64             `json:"total"`
+	Page      int               `json:"page"`
+	PageSize  int               `json:"page_size"`
 }
 
 func (ctx *HandlerContext) InitialHandlerContext(db *dal.Query) {
@@ -42,19 +70,31 @@ func (ctx *HandlerContext) CreateCustomers(w http.ResponseWriter, r *http.Reques
 
 	// Validate payload
 	validationErr := ""
+	emails := make(map[string]bool)
 	for i := range *req.Customers {
-		if (*req.Customers)[i].Name == "" {
+		customer := &(*req.Customers)[i]
+		if customer.Name == "" {
 			validationErr += fmt.Sprintf("The %d customer name is required.", i+1)
 		}
+		email, errEmail := NormalizeEmail(customer.Email)
+		if errEmail != nil {
+			validationErr += fmt.Sprintf("The %d customer email is invalid.", i+1)
+		} else if email != nil && emails[*email] {
+			validationErr += fmt.Sprintf("The %d customer email is duplicated.", i+1)
+		} else if email != nil {
+			emails[*email] = true
+		}
+		customer.Email = email
 	}
 	if validationErr != "" {
 		http.Error(w, validationErr, http.StatusBadRequest)
+		return
 	}
 
 	createCustomers := make([]model.Customer, 0)
 	err = ctx.db.Transaction(func(tx *dal.Query) error {
 		for _, customer := range *req.Customers {
-			if errCreate := tx.Customer.Create(&customer); errCreate != nil {
+			if errCreate := tx.Customer.WithContext(r.Context()).Create(&customer); errCreate != nil {
 				return errors.New(customer.Name + " : " + errCreate.Error())
 			}
 			createCustomers = append(createCustomers, customer)
@@ -86,12 +126,18 @@ func (ctx *HandlerContext) QueryCustomer(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Validate payload
-	if req.CustomerId == 0 {
-		http.Error(w, "CustomerId is required", http.StatusBadRequest)
+	if req.CustomerId == 0 && req.Email == "" {
+		http.Error(w, "CustomerId or Email is required", http.StatusBadRequest)
 		return
 	}
 
-	customerInfo, errQuery := ctx.db.Customer.Where(dal.Customer.ID.Eq(req.CustomerId)).First()
+	query := ctx.db.Customer.WithContext(r.Context())
+	if req.CustomerId != 0 {
+		query = query.Where(ctx.db.Customer.ID.Eq(req.CustomerId))
+	} else {
+		query = query.Where(ctx.db.Customer.Email.Eq(strings.ToLower(strings.TrimSpace(req.Email))))
+	}
+	customerInfo, errQuery := query.First()
 
 	if errQuery != nil {
 		http.Error(w, errQuery.Error(), http.StatusNotFound)
@@ -103,3 +149,174 @@ func (ctx *HandlerContext) QueryCustomer(w http.ResponseWriter, r *http.Request)
 	respBody, _ := json.Marshal(*customerInfo)
 	w.Write(respBody)
 }
+
+// UpdateCustomer Update fields of a customer
+func (ctx *HandlerContext) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := UpdateCustomerRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "CustomerId is required", http.StatusBadRequest)
+		return
+	}
+	customerTable := ctx.db.Customer
+	updates := make([]field.AssignExpr, 0)
+	if req.Name != nil {
+		if *req.Name == "" {
+			http.Error(w, "Customer name must not be empty", http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, customerTable.Name.Value(*req.Name))
+	}
+	if req.Email != nil {
+		email, errEmail := NormalizeEmail(req.Email)
+		if errEmail != nil {
+			http.Error(w, errEmail.Error(), http.StatusBadRequest)
+			return
+		}
+		if email == nil {
+			updates = append(updates, customerTable.Email.Null())
+		} else {
+			updates = append(updates, customerTable.Email.Value(*email))
+		}
+	}
+	if req.Address != nil {
+		updates = append(updates, customerTable.Address.Value(*req.Address))
+	}
+	if len(updates) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var customerInfo *model.Customer
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		result, errTx := tx.Customer.WithContext(r.Context()).Where(tx.Customer.ID.Eq(req.ID)).UpdateSimple(updates...)
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		customerInfo, errTx = tx.Customer.WithContext(r.Context()).Where(tx.Customer.ID.Eq(req.ID)).First()
+		return errTx
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Customer was updated", "customer_id", req.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(*customerInfo)
+	w.Write(respBody)
+}
+
+// DeleteCustomer Soft delete a customer, it can no longer place orders but existing orders keep referring to it
+func (ctx *HandlerContext) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost, http.MethodDelete}, w, r) {
+		return
+	}
+
+	req := QueryCustomerRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.CustomerId == 0 {
+		http.Error(w, "CustomerId is required", http.StatusBadRequest)
+		return
+	}
+
+	result, errDb := ctx.db.Customer.WithContext(r.Context()).Where(ctx.db.Customer.ID.Eq(req.CustomerId)).Delete()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Customer was deleted", "customer_id", req.CustomerId)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Delete customer success."))
+}
+
+// ListCustomers List customers page by page, optionally searched by name or email
+func (ctx *HandlerContext) ListCustomers(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	req := ListCustomersRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if err = util.NormalizePage(&req.Page, &req.PageSize); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	customerTable := ctx.db.Customer
+	query := customerTable.WithContext(r.Context()).Order(customerTable.ID)
+	if keyword := strings.ToLower(strings.TrimSpace(req.Query)); keyword != "" {
+		pattern := "%" + keyword + "%"
+		query = query.Where(field.Or(customerTable.Name.Lower().Like(pattern), customerTable.Email.Like(pattern)))
+	}
+
+	customers, total, errDb := query.FindByPage((req.Page-1)*req.PageSize, req.PageSize)
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(ListCustomersResponse{
+		Customers: customers,
+		Total:     total,
+		Page:      req.Page,
+		PageSize:  req.PageSize,
+	})
+	w.Write(respBody)
+}
+
+// NormalizeEmail Trimmed and lowercased email, nil when it is empty
+func NormalizeEmail(email *string) (*string, error) {
+	if email == nil {
+		return nil, nil
+	}
+	normalized := strings.ToLower(strings.TrimSpace(*email))
+	if normalized == "" {
+		return nil, nil
+	}
+	address, err := mail.ParseAddress(normalized)
+	if err != nil || address.Address != normalized {
+		return nil, fmt.Errorf("invalid email %q", *email)
+	}
+	return &normalized, nil
+}
@@ -0,0 +1,130 @@
+package fulfillment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/model"
+	"time"
+)
+
+const SIMULATOR_CARRIER = "SIMULATOR"
+
+// ErrOutOfStock The warehouse can't ship the order, providers return it wrapped. Other errors are retried.
+var ErrOutOfStock = errors.New("out of stock at warehouse")
+
+// Provider Ships paid orders, e.g. a warehouse system or a third party logistics service
+type Provider interface {
+	// CreateShipment Hand over a saved shipment of an order, carrier and tracking number are filled in when known
+	// Items not shipped now are left out of the shipment, the order is partially fulfilled then
+	CreateShipment(c context.Context, order *model.Order, shipment *model.Shipment) error
+}
+
+// ShipmentUpdate Progress of a shipment reported by warehouses or providers, State is SHIPPED or DELIVERED
+type ShipmentUpdate struct {
+	ShipmentId     uint    `json:"shipment_id"`
+	State          string  `json:"state"`
+	Carrier        *string `json:"carrier,omitempty"`
+	TrackingNumber *string `json:"tracking_number,omitempty"`
+}
+
+// UpdateMethod Apply a shipment update to the order system
+type UpdateMethod func(context.Context, ShipmentUpdate) error
+
+// NewProvider Provider selected by config, shipment updates of the simulator are applied with the update method.
+// Simulator delays are at least a second, the shipment is saved after it is handed over.
+func NewProvider(cfg util.FulfillmentConfig, update UpdateMethod) Provider {
+	if cfg.Provider == util.FULFILLMENT_PROVIDER_MANUAL {
+		return ManualProvider{}
+	}
+	return NewSimulator(time.Duration(max(cfg.SimulatorShipSeconds, 1))*time.Second, time.Duration(max(cfg.SimulatorDeliverSeconds, 1))*time.Second, update).
+		WithOutOfStock(cfg.SimulatorOutOfStockSkus...)
+}
+
+// ManualProvider Ships nothing, warehouses post their shipments and shipment updates
+type ManualProvider struct{}
+
+func (ManualProvider) CreateShipment(c context.Context, order *model.Order, shipment *model.Shipment) error {
+	shipment.Items = nil
+	return nil
+}
+
+// Simulator Local provider which ships and delivers every shipment after the delays
+type Simulator struct {
+	shipAfter    time.Duration
+	deliverAfter time.Duration
+	update       UpdateMethod
+	outOfStock   map[string]bool
+}
+
+func NewSimulator(shipAfter time.Duration, deliverAfter time.Duration, update UpdateMethod) *Simulator {
+	return &Simulator{shipAfter: shipAfter, deliverAfter: deliverAfter, update: update, outOfStock: map[string]bool{}}
+}
+
+// WithOutOfStock Report shipments with these SKUs out of stock
+func (s *Simulator) WithOutOfStock(skus ...string) *Simulator {
+	for _, sku := range skus {
+		s.outOfStock[sku] = true
+	}
+	return s
+}
+
+func (s *Simulator) CreateShipment(c context.Context, order *model.Order, shipment *model.Shipment) error {
+	for _, item := range shipment.Items {
+		if item.Sku != nil && s.outOfStock[*item.Sku] {
+			return fmt.Errorf("%w: %s", ErrOutOfStock, *item.Sku)
+		}
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	shipment.Carrier = util.GetStringPtr(SIMULATOR_CARRIER)
+	shipment.TrackingNumber = util.GetStringPtr(fmt.Sprintf("SIM%08d%s", order.ID, hex.EncodeToString(suffix)))
+	if s.update != nil {
+		go s.simulate(context.WithoutCancel(c), shipment.ID)
+	}
+	return nil
+}
+
+// Report the shipment as shipped then delivered
+func (s *Simulator) simulate(c context.Context, shipmentId uint) {
+	logger := util.GetLogger(c).With("shipment_id", shipmentId)
+	for _, step := range []struct {
+		delay time.Duration
+		state int8
+	}{{s.shipAfter, constants.SHIPMENT_STATE_SHIPPED}, {s.deliverAfter, constants.SHIPMENT_STATE_DELIVERED}} {
+		time.Sleep(step.delay)
+		if err := s.update(c, ShipmentUpdate{ShipmentId: shipmentId, State: StateToString(step.state)}); err != nil {
+			logger.Error("Simulated shipment update failed: "+err.Error(), "state", StateToString(step.state))
+			return
+		}
+	}
+}
+
+func StateToString(state int8) string {
+	switch state {
+	case constants.SHIPMENT_STATE_CREATED:
+		return "CREATED"
+	case constants.SHIPMENT_STATE_SHIPPED:
+		return "SHIPPED"
+	case constants.SHIPMENT_STATE_DELIVERED:
+		return "DELIVERED"
+	}
+	return "UNKNOWN"
+}
+
+// ParseUpdateState State code of a shipment update, only SHIPPED and DELIVERED can be reported
+func ParseUpdateState(state string) (int8, error) {
+	switch state {
+	case "SHIPPED":
+		return constants.SHIPMENT_STATE_SHIPPED, nil
+	case "DELIVERED":
+		return constants.SHIPMENT_STATE_DELIVERED, nil
+	}
+	return 0, errors.New(constants.INVALID_SHIPMENT_STATE + ": " + state)
+}
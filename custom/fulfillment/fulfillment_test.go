@@ -0,0 +1,59 @@
+package fulfillment
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulatorShipsAndDelivers(t *testing.T) {
+	updates := make(chan ShipmentUpdate, 2)
+	simulator := NewSimulator(0, 0, func(c context.Context, update ShipmentUpdate) error {
+		updates <- update
+		return nil
+	})
+
+	shipment := model.Shipment{ID: 5, OrderId: 1}
+	err := simulator.CreateShipment(context.Background(), &model.Order{ID: 1}, &shipment)
+
+	assert.Nil(t, err)
+	assert.Equal(t, SIMULATOR_CARRIER, *shipment.Carrier)
+	assert.True(t, strings.HasPrefix(*shipment.TrackingNumber, "SIM00000001"))
+	for _, state := range []string{"SHIPPED", "DELIVERED"} {
+		select {
+		case update := <-updates:
+			assert.Equal(t, ShipmentUpdate{ShipmentId: 5, State: state}, update)
+		case <-time.After(time.Second):
+			t.Fatal("Missing simulated update " + state)
+		}
+	}
+}
+
+func TestSimulatorOutOfStock(t *testing.T) {
+	simulator := NewSimulator(0, 0, nil).WithOutOfStock("TS-M-RED")
+
+	shipment := model.Shipment{ID: 5, OrderId: 1, Items: model.ShipmentItems{{ProductId: 3, Sku: util.GetStringPtr("TS-M-RED"), Quantity: 1}}}
+	err := simulator.CreateShipment(context.Background(), &model.Order{ID: 1}, &shipment)
+
+	assert.ErrorIs(t, err, ErrOutOfStock)
+	assert.Nil(t, shipment.Carrier)
+}
+
+func TestNewProvider(t *testing.T) {
+	assert.IsType(t, ManualProvider{}, NewProvider(util.FulfillmentConfig{Provider: util.FULFILLMENT_PROVIDER_MANUAL}, nil))
+	assert.IsType(t, &Simulator{}, NewProvider(util.FulfillmentConfig{}, nil))
+}
+
+func TestParseUpdateState(t *testing.T) {
+	state, err := ParseUpdateState("DELIVERED")
+	assert.Nil(t, err)
+	assert.Equal(t, constants.SHIPMENT_STATE_DELIVERED, state)
+
+	_, err = ParseUpdateState("CREATED")
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Worker Track the liveness of a long-running background goroutine
+type Worker struct {
+	name     string
+	running  atomic.Bool
+	lastBeat atomic.Int64
+}
+
+func NewWorker(name string) *Worker {
+	return &Worker{name: name}
+}
+
+// Start Should be called when the worker goroutine starts
+func (wk *Worker) Start() {
+	wk.running.Store(true)
+	wk.Beat()
+}
+
+// Stop Should be deferred by the worker goroutine, so the worker is reported dead when it exits or panics
+func (wk *Worker) Stop() {
+	wk.running.Store(false)
+}
+
+// Beat Record the worker has made progress
+func (wk *Worker) Beat() {
+	wk.lastBeat.Store(time.Now().UnixMilli())
+}
+
+func (wk *Worker) IsRunning() bool {
+	return wk.running.Load()
+}
+
+// WorkerCheck Fail when the worker goroutine is not running
+func WorkerCheck(worker *Worker) Check {
+	return func() (interface{}, error) {
+		details := map[string]interface{}{
+			"name":    worker.name,
+			"running": worker.IsRunning(),
+		}
+		if lastBeat := worker.lastBeat.Load(); lastBeat > 0 {
+			details["last_beat"] = time.UnixMilli(lastBeat).UTC().Format(time.RFC3339)
+		}
+		if !worker.IsRunning() {
+			return details, fmt.Errorf("worker %s is not running", worker.name)
+		}
+		return details, nil
+	}
+}
+
+// DatabaseCheck Ping the database and report connection pool statistics
+func DatabaseCheck(db *gorm.DB, timeout time.Duration) Check {
+	return func() (interface{}, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		c, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		start := time.Now()
+		err = sqlDB.PingContext(c)
+		stats := sqlDB.Stats()
+		details := map[string]interface{}{
+			"latency_ms":       time.Since(start).Milliseconds(),
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+		}
+		return details, err
+	}
+}
+
+// MigrationCheck Fail when the schema is not at the latest migration version
+func MigrationCheck(versions func() (uint, uint, error)) Check {
+	return func() (interface{}, error) {
+		current, latest, err := versions()
+		details := map[string]interface{}{
+			"current_version": current,
+			"latest_version":  latest,
+		}
+		if err != nil {
+			return details, err
+		}
+		if current != latest {
+			return details, fmt.Errorf("schema version %d is not the latest version %d", current, latest)
+		}
+		return details, nil
+	}
+}
+
+// QueueBacklogCheck Fail when the queue backlog reaches the threshold
+func QueueBacklogCheck(backlog func() int, threshold int) Check {
+	return func() (interface{}, error) {
+		count := backlog()
+		details := map[string]interface{}{
+			"backlog":   count,
+			"threshold": threshold,
+		}
+		if threshold > 0 && count >= threshold {
+			return details, fmt.Errorf("queue backlog %d reaches threshold %d", count, threshold)
+		}
+		return details, nil
+	}
+}
+
+// DownstreamCheck Verify the downstream service is reachable and not failing
+func DownstreamCheck(url string, timeout time.Duration) Check {
+	client := &http.Client{Timeout: timeout}
+	return func() (interface{}, error) {
+		start := time.Now()
+		details := map[string]interface{}{
+			"url": url,
+		}
+		response, err := client.Get(url)
+		details["latency_ms"] = time.Since(start).Milliseconds()
+		if err != nil {
+			return details, err
+		}
+		defer response.Body.Close()
+		details["status_code"] = response.StatusCode
+		if response.StatusCode >= http.StatusInternalServerError {
+			return details, fmt.Errorf("downstream responded with status code %d", response.StatusCode)
+		}
+		return details, nil
+	}
+}
@@ -0,0 +1,135 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"order_system/custom/util"
+	"testing"
+	"time"
+)
+
+func okCheck() (interface{}, error) {
+	return map[string]interface{}{"detail": "fine"}, nil
+}
+
+func failCheck() (interface{}, error) {
+	return nil, errors.New("something wrong")
+}
+
+func TestLivenessSuccess(t *testing.T) {
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext()
+	handlerCtx.AddLivenessCheck("worker", okCheck)
+	handlerCtx.AddReadinessCheck("database", failCheck)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts/healthz", nil)
+	handlerCtx.Liveness(w, r)
+
+	actualResp := Report{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, STATUS_OK, actualResp.Status)
+	assert.Len(t, actualResp.Checks, 1)
+}
+
+func TestReadinessFail(t *testing.T) {
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext()
+	handlerCtx.AddLivenessCheck("worker", okCheck)
+	handlerCtx.AddReadinessCheck("database", failCheck)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts/readyz", nil)
+	handlerCtx.Readiness(w, r)
+
+	actualResp := Report{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, STATUS_FAIL, actualResp.Status)
+	assert.Equal(t, STATUS_OK, actualResp.Checks["worker"].Status)
+	assert.Equal(t, STATUS_FAIL, actualResp.Checks["database"].Status)
+	assert.Equal(t, "something wrong", actualResp.Checks["database"].Error)
+}
+
+func TestHealthBadHttpMethod(t *testing.T) {
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts/readyz", nil)
+	handlerCtx.Readiness(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestWorkerCheck(t *testing.T) {
+	worker := NewWorker("test_worker")
+	check := WorkerCheck(worker)
+
+	_, err := check()
+	assert.Error(t, err)
+
+	worker.Start()
+	_, err = check()
+	assert.Nil(t, err)
+
+	worker.Stop()
+	_, err = check()
+	assert.Error(t, err)
+}
+
+func TestQueueBacklogCheck(t *testing.T) {
+	backlog := 10
+	check := QueueBacklogCheck(func() int { return backlog }, 100)
+
+	_, err := check()
+	assert.Nil(t, err)
+
+	backlog = 100
+	_, err = check()
+	assert.Error(t, err)
+}
+
+func TestDatabaseCheck(t *testing.T) {
+	sqlDB, gormDB, _ := util.DbMock(t)
+	defer sqlDB.Close()
+
+	_, err := DatabaseCheck(gormDB, time.Second)()
+	assert.Nil(t, err)
+}
+
+func TestDownstreamCheck(t *testing.T) {
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+	check := DownstreamCheck(server.URL, time.Second)
+
+	_, err := check()
+	assert.Nil(t, err)
+
+	statusCode = http.StatusServiceUnavailable
+	_, err = check()
+	assert.Error(t, err)
+
+	server.Close()
+	_, err = check()
+	assert.Error(t, err)
+}
+
+func TestMigrationCheck(t *testing.T) {
+	current := uint(1)
+	check := MigrationCheck(func() (uint, uint, error) { return current, 2, nil })
+
+	_, err := check()
+	assert.Error(t, err)
+
+	current = 2
+	_, err = check()
+	assert.Nil(t, err)
+}
@@ -0,0 +1,131 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"order_system/custom/util"
+	"sync"
+)
+
+const STATUS_OK = "ok"
+const STATUS_FAIL = "fail"
+
+// Check reports the state of one dependency, returned details will be rendered into the response body.
+type Check func() (interface{}, error)
+
+type CheckResult struct {
+	Status  string      `json:"status"`
+	Details interface{} `json:"details,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type HandlerContext struct {
+	mu              sync.RWMutex
+	livenessChecks  map[string]Check
+	readinessChecks map[string]Check
+}
+
+func (ctx *HandlerContext) InitialHandlerContext() {
+	ctx.livenessChecks = make(map[string]Check)
+	ctx.readinessChecks = make(map[string]Check)
+}
+
+// AddLivenessCheck Register a check which is used by both /healthz and /readyz
+func (ctx *HandlerContext) AddLivenessCheck(name string, check Check) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.livenessChecks[name] = check
+}
+
+// AddReadinessCheck Register a check which is only used by /readyz
+func (ctx *HandlerContext) AddReadinessCheck(name string, check Check) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.readinessChecks[name] = check
+}
+
+// Liveness Report whether the process and its background workers are alive
+func (ctx *HandlerContext) Liveness(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	ctx.mu.RLock()
+	checks := make(map[string]Check, len(ctx.livenessChecks))
+	for name, check := range ctx.livenessChecks {
+		checks[name] = check
+	}
+	ctx.mu.RUnlock()
+
+	writeReport(w, runChecks(checks))
+}
+
+// Readiness Report whether the service is able to handle requests
+func (ctx *HandlerContext) Readiness(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	ctx.mu.RLock()
+	checks := make(map[string]Check, len(ctx.livenessChecks)+len(ctx.readinessChecks))
+	for name, check := range ctx.livenessChecks {
+		checks[name] = check
+	}
+	for name, check := range ctx.readinessChecks {
+		checks[name] = check
+	}
+	ctx.mu.RUnlock()
+
+	writeReport(w, runChecks(checks))
+}
+
+// Run all checks concurrently and aggregate the results
+func runChecks(checks map[string]Check) Report {
+	report := Report{
+		Status: STATUS_OK,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			details, err := check()
+			result := CheckResult{
+				Status:  STATUS_OK,
+				Details: details,
+			}
+			if err != nil {
+				result.Status = STATUS_FAIL
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = STATUS_FAIL
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	statusCode := http.StatusOK
+	if report.Status != STATUS_OK {
+		statusCode = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	respBody, _ := json.Marshal(report)
+	w.Write(respBody)
+}
@@ -4,23 +4,36 @@ import (
 	"order_system/model"
 )
 
+// Message Payload of MQ, headers carry metadata such as request id along with the order
+type Message struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Order   *model.Order      `json:"order"`
+}
+
+func NewMessage(order *model.Order) *Message {
+	return &Message{
+		Headers: make(map[string]string),
+		Order:   order,
+	}
+}
+
 type MessageQueue struct {
-	channel chan *model.Order
+	channel chan *Message
 }
 
 // NewMessageQueue A lightweight message queue based on Golang channel, not support message persistence.
 func NewMessageQueue() *MessageQueue {
-	newChan := make(chan *model.Order, 10000)
+	newChan := make(chan *Message, 10000)
 	return &MessageQueue{
 		channel: newChan,
 	}
 }
 
-func (mq *MessageQueue) Enqueue(msg *model.Order) {
+func (mq *MessageQueue) Enqueue(msg *Message) {
 	mq.channel <- msg
 }
 
-func (mq *MessageQueue) Dequeue() *model.Order {
+func (mq *MessageQueue) Dequeue() *Message {
 	return <-mq.channel
 }
 
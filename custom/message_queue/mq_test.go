@@ -8,18 +8,27 @@ import (
 
 func TestMessageQueue_Enqueue(t *testing.T) {
 	mq := NewMessageQueue()
-	mq.Enqueue(&model.Order{})
+	mq.Enqueue(NewMessage(&model.Order{}))
 	assert.Equal(t, 1, mq.GetMsgCount())
-	mq.Enqueue(&model.Order{})
+	mq.Enqueue(NewMessage(&model.Order{}))
 	assert.Equal(t, 2, mq.GetMsgCount())
 }
 
 func TestMessageQueue_Dequeue(t *testing.T) {
 	mq := NewMessageQueue()
-	mq.Enqueue(&model.Order{})
-	mq.Enqueue(&model.Order{})
-	_ = mq.Dequeue()
+	mq.Enqueue(NewMessage(&model.Order{ID: 1}))
+	mq.Enqueue(NewMessage(&model.Order{ID: 2}))
+	msg := mq.Dequeue()
+	assert.Equal(t, uint(1), msg.Order.ID)
 	assert.Equal(t, 1, mq.GetMsgCount())
 	_ = mq.Dequeue()
 	assert.Equal(t, 0, mq.GetMsgCount())
 }
+
+func TestMessageQueue_Headers(t *testing.T) {
+	mq := NewMessageQueue()
+	msg := NewMessage(&model.Order{})
+	msg.Headers["X-Request-ID"] = "abc"
+	mq.Enqueue(msg)
+	assert.Equal(t, "abc", mq.Dequeue().Headers["X-Request-ID"])
+}
@@ -0,0 +1,65 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const USAGE = `Usage: migrate <command>
+  up              apply all pending migrations
+  down            roll back the last applied migration
+  status          list migrations and whether they are applied
+  to <version>    apply or roll back migrations to the given version`
+
+// RunCommand Execute a "migrate" sub command
+func RunCommand(m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(USAGE)
+	}
+	switch args[0] {
+	case "up":
+		if err := m.Up(); err != nil {
+			return err
+		}
+	case "down":
+		if err := m.Down(); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) != 2 {
+			return errors.New(USAGE)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err = m.To(uint(version)); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return errors.New(USAGE)
+	}
+	return printStatus(m, out)
+}
+
+func printStatus(m *Migrator, out io.Writer) error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", ""
+		if status.Applied {
+			state, appliedAt = "applied", status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
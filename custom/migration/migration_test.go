@@ -0,0 +1,123 @@
+package migration
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"order_system/custom/util"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(migrationFiles)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, uint(i+1), m.Version, "migration versions should be continuous")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func TestLoadMigrationsSorted(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"migrations/0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"migrations/0001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"migrations/0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+	}
+	migrations, err := LoadMigrations(fsys)
+
+	assert.Nil(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE b;", migrations[1].Down)
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	// Missing down sql
+	_, err := LoadMigrations(fstest.MapFS{
+		"migrations/0001_first.up.sql": {Data: []byte("CREATE TABLE a ();")},
+	})
+	assert.Error(t, err)
+
+	// Duplicated version
+	_, err = LoadMigrations(fstest.MapFS{
+		"migrations/0001_first.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"migrations/0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+		"migrations/0001_other.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"migrations/0001_other.down.sql": {Data: []byte("DROP TABLE b;")},
+	})
+	assert.Error(t, err)
+
+	// Invalid file name
+	_, err = LoadMigrations(fstest.MapFS{
+		"migrations/first.sql": {Data: []byte("CREATE TABLE a ();")},
+	})
+	assert.Error(t, err)
+}
+
+func testMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	sqlDB, gormDB, mock := util.DbMock(t)
+	t.Cleanup(func() { sqlDB.Close() })
+	return &Migrator{
+		db: gormDB,
+		migrations: []Migration{
+			{Version: 1, Name: "first", Up: "CREATE TABLE a ()", Down: "DROP TABLE a"},
+			{Version: 2, Name: "second", Up: "CREATE TABLE b ()", Down: "DROP TABLE b"},
+		},
+	}, mock
+}
+
+func TestMigratorUp(t *testing.T) {
+	migrator, mock := testMigrator(t)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "schema_migrations"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}).AddRow(1, "first", time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "schema_migrations"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Nil(t, migrator.Up())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorDown(t *testing.T) {
+	migrator, mock := testMigrator(t)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "schema_migrations"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}).
+			AddRow(1, "first", time.Now()).
+			AddRow(2, "second", time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "schema_migrations"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Nil(t, migrator.Down())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorToUnknownVersion(t *testing.T) {
+	migrator, _ := testMigrator(t)
+	assert.Error(t, migrator.To(3))
+}
+
+func TestRunCommandUsage(t *testing.T) {
+	migrator, _ := testMigrator(t)
+	out := bytes.Buffer{}
+
+	assert.EqualError(t, RunCommand(migrator, []string{}, &out), USAGE)
+	assert.EqualError(t, RunCommand(migrator, []string{"sideways"}, &out), USAGE)
+	assert.Error(t, RunCommand(migrator, []string{"to", "abc"}, &out))
+}
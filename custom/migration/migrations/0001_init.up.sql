@@ -0,0 +1,52 @@
+-- Baseline schema, equal to what AutoMigrate created before, so it is a no-op on existing databases.
+CREATE TABLE IF NOT EXISTS "customers" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "email" text,
+    "address" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_customers_name" UNIQUE ("name")
+);
+CREATE INDEX IF NOT EXISTS "idx_customers_name" ON "customers" ("name");
+
+CREATE TABLE IF NOT EXISTS "products" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "description" text,
+    "price" decimal(10,2) NOT NULL,
+    "is_available" boolean NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_products_name" UNIQUE ("name")
+);
+CREATE INDEX IF NOT EXISTS "idx_products_name" ON "products" ("name");
+
+CREATE TABLE IF NOT EXISTS "orders" (
+    "id" bigserial,
+    "customer_id" bigint,
+    "product_id" bigint NOT NULL,
+    "amount" decimal(10,2) NOT NULL,
+    "state" smallint,
+    "fail_reason" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_orders_product_id" ON "orders" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_customer_id" ON "orders" ("customer_id");
+
+CREATE TABLE IF NOT EXISTS "payments" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL,
+    "amount" decimal(10,2) NOT NULL,
+    "state" smallint NOT NULL,
+    "payment_result" text,
+    "is_notified_order" boolean NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_payments_order_id" ON "payments" ("order_id");
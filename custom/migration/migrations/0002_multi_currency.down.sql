@@ -0,0 +1,8 @@
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "currency";
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "exchange_rate";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "currency";
+
+DROP TABLE IF EXISTS "product_prices";
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "currency";
@@ -0,0 +1,17 @@
+ALTER TABLE "products" ADD COLUMN IF NOT EXISTS "currency" char(3) NOT NULL DEFAULT 'USD';
+
+CREATE TABLE IF NOT EXISTS "product_prices" (
+    "id" bigserial,
+    "product_id" bigint NOT NULL,
+    "currency" char(3) NOT NULL,
+    "price" decimal(10,2) NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_product_prices_product_currency" ON "product_prices" ("product_id","currency");
+
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "currency" char(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "exchange_rate" decimal(18,8) NOT NULL DEFAULT 1;
+
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "currency" char(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "idx_products_price";
+
+DROP INDEX IF EXISTS "idx_products_search_vector";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "search_vector";
+
+DROP INDEX IF EXISTS "idx_products_deleted_at";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "deleted_at";
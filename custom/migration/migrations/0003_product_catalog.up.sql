@@ -0,0 +1,9 @@
+ALTER TABLE "products" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_products_deleted_at" ON "products" ("deleted_at");
+
+-- Full-text search on name and description, maintained by postgres so it is not mapped in model.Product
+ALTER TABLE "products" ADD COLUMN IF NOT EXISTS "search_vector" tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce("name", '') || ' ' || coalesce("description", ''))) STORED;
+CREATE INDEX IF NOT EXISTS "idx_products_search_vector" ON "products" USING gin ("search_vector");
+
+CREATE INDEX IF NOT EXISTS "idx_products_price" ON "products" ("price");
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "product_tags";
+DROP TABLE IF EXISTS "tags";
+
+DROP INDEX IF EXISTS "idx_products_category_id";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "category_id";
+
+DROP TABLE IF EXISTS "categories";
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS "categories" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "parent_id" bigint REFERENCES "categories" ("id"),
+    "path" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+-- text_pattern_ops lets "path LIKE '/1/4/%'" subtree queries use the index
+CREATE INDEX IF NOT EXISTS "idx_categories_path" ON "categories" ("path" text_pattern_ops);
+CREATE INDEX IF NOT EXISTS "idx_categories_parent_id" ON "categories" ("parent_id");
+
+ALTER TABLE "products" ADD COLUMN IF NOT EXISTS "category_id" bigint REFERENCES "categories" ("id");
+CREATE INDEX IF NOT EXISTS "idx_products_category_id" ON "products" ("category_id");
+
+CREATE TABLE IF NOT EXISTS "tags" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tags_name" ON "tags" ("name");
+
+CREATE TABLE IF NOT EXISTS "product_tags" (
+    "product_id" bigint REFERENCES "products" ("id") ON DELETE CASCADE,
+    "tag_id" bigint REFERENCES "tags" ("id") ON DELETE CASCADE,
+    PRIMARY KEY ("product_id","tag_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_product_tags_tag_id" ON "product_tags" ("tag_id");
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "idx_orders_variant_id";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "sku";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "variant_id";
+
+DROP TABLE IF EXISTS "variants";
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "variants" (
+    "id" bigserial,
+    "product_id" bigint NOT NULL REFERENCES "products" ("id"),
+    "sku" text NOT NULL,
+    "options" jsonb NOT NULL DEFAULT '{}',
+    "price" decimal(10,2),
+    -- Decremented when a variant is ordered, never oversold
+    "stock" bigint NOT NULL DEFAULT 0 CHECK ("stock" >= 0),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_variants_sku" ON "variants" ("sku");
+CREATE INDEX IF NOT EXISTS "idx_variants_product_id" ON "variants" ("product_id");
+
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "variant_id" bigint REFERENCES "variants" ("id");
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "sku" text;
+CREATE INDEX IF NOT EXISTS "idx_orders_variant_id" ON "orders" ("variant_id");
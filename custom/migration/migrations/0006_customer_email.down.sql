@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_customers_email";
+DROP INDEX IF EXISTS "idx_customers_deleted_at";
+ALTER TABLE "customers" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "customers" ADD CONSTRAINT "uni_customers_name" UNIQUE ("name");
@@ -0,0 +1,10 @@
+-- Customers are identified by email, names may be shared
+ALTER TABLE "customers" DROP CONSTRAINT IF EXISTS "uni_customers_name";
+ALTER TABLE "customers" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_customers_deleted_at" ON "customers" ("deleted_at");
+
+-- Emails are compared lowercased. Duplicated emails must be resolved by hand before this migration,
+-- it fails and is rolled back otherwise.
+UPDATE "customers" SET "email" = lower(trim("email")) WHERE "email" IS NOT NULL;
+UPDATE "customers" SET "email" = NULL WHERE "email" = '';
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_customers_email" ON "customers" ("email") WHERE deleted_at IS NULL;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS "addresses" (
+    "id" bigserial,
+    "customer_id" bigint NOT NULL REFERENCES "customers" ("id"),
+    "line1" text NOT NULL,
+    "line2" text,
+    "city" text NOT NULL,
+    "region" text,
+    "postal_code" text NOT NULL,
+    "country" char(2) NOT NULL,
+    "is_default_billing" boolean NOT NULL DEFAULT false,
+    "is_default_shipping" boolean NOT NULL DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_addresses_customer_id" ON "addresses" ("customer_id");
+-- At most one default billing and one default shipping address per customer
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_addresses_default_billing" ON "addresses" ("customer_id") WHERE "is_default_billing";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_addresses_default_shipping" ON "addresses" ("customer_id") WHERE "is_default_shipping";
+
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "shipping_address" jsonb;
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "billing_address" jsonb;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "shipments" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL REFERENCES "orders" ("id"),
+    "carrier" text,
+    "tracking_number" text,
+    "items" jsonb NOT NULL DEFAULT '[]',
+    "state" smallint NOT NULL,
+    "shipped_at" timestamptz,
+    "delivered_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_shipments_order_id" ON "shipments" ("order_id");
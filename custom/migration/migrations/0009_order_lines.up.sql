@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS "order_lines" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL REFERENCES "orders" ("id"),
+    "product_id" bigint NOT NULL REFERENCES "products" ("id"),
+    "variant_id" bigint REFERENCES "variants" ("id"),
+    "sku" text,
+    "quantity" bigint NOT NULL CHECK ("quantity" > 0),
+    "unit_price" decimal(10,2) NOT NULL,
+    "amount" decimal(10,2) NOT NULL,
+    -- A line is never shipped or canceled beyond its quantity
+    "fulfilled_quantity" bigint NOT NULL DEFAULT 0,
+    "canceled_quantity" bigint NOT NULL DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CHECK ("fulfilled_quantity" >= 0 AND "canceled_quantity" >= 0 AND "fulfilled_quantity" + "canceled_quantity" <= "quantity")
+);
+CREATE INDEX IF NOT EXISTS "idx_order_lines_order_id" ON "order_lines" ("order_id");
+
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "refunded_amount" decimal(10,2) NOT NULL DEFAULT 0;
+
+-- Existing orders have a single line of one unit, fulfilled when the order was
+INSERT INTO "order_lines" ("order_id", "product_id", "variant_id", "sku", "quantity", "unit_price", "amount", "fulfilled_quantity", "created_at", "updated_at")
+SELECT "id", "product_id", "variant_id", "sku", 1, "amount", "amount", CASE WHEN "state" IN (3, 6, 7) THEN 1 ELSE 0 END, "created_at", "updated_at"
+FROM "orders";
@@ -0,0 +1,5 @@
+ALTER TABLE "order_lines" DROP COLUMN IF EXISTS "discount_amount";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "discount_amount";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "subtotal";
+DROP TABLE IF EXISTS "order_discounts";
+DROP TABLE IF EXISTS "coupons";
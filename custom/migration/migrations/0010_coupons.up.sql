@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS "coupons" (
+    "id" bigserial,
+    "code" text NOT NULL,
+    "discount_type" text NOT NULL CHECK ("discount_type" IN ('PERCENT', 'FIXED')),
+    "percent" bigint NOT NULL DEFAULT 0 CHECK ("percent" BETWEEN 0 AND 100),
+    "amount_off" decimal(10,2) NOT NULL DEFAULT 0 CHECK ("amount_off" >= 0),
+    "min_spend" decimal(10,2) NOT NULL DEFAULT 0 CHECK ("min_spend" >= 0),
+    "currency" char(3) NOT NULL DEFAULT 'USD',
+    "max_uses" bigint,
+    "max_uses_per_customer" bigint,
+    -- Never redeemed beyond the global limit
+    "used_count" bigint NOT NULL DEFAULT 0 CHECK ("max_uses" IS NULL OR "used_count" <= "max_uses"),
+    "starts_at" timestamptz,
+    "ends_at" timestamptz,
+    "product_ids" jsonb NOT NULL DEFAULT '[]',
+    "category_ids" jsonb NOT NULL DEFAULT '[]',
+    "is_active" boolean NOT NULL DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_coupons_code" ON "coupons" ("code");
+
+CREATE TABLE IF NOT EXISTS "order_discounts" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL REFERENCES "orders" ("id"),
+    "coupon_id" bigint NOT NULL REFERENCES "coupons" ("id"),
+    "customer_id" bigint NOT NULL,
+    "code" text NOT NULL,
+    "amount" decimal(10,2) NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_order_discounts_order_id" ON "order_discounts" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_order_discounts_coupon_customer" ON "order_discounts" ("coupon_id", "customer_id");
+
+-- Existing orders have no discount, their subtotal is the amount
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "subtotal" decimal(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "discount_amount" decimal(10,2) NOT NULL DEFAULT 0;
+UPDATE "orders" SET "subtotal" = "amount";
+ALTER TABLE "order_lines" ADD COLUMN IF NOT EXISTS "discount_amount" decimal(10,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE "order_lines" DROP COLUMN IF EXISTS "tax_amount";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "prices_include_tax";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "tax_amount";
+DROP TABLE IF EXISTS "order_taxes";
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "order_taxes" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL REFERENCES "orders" ("id"),
+    "jurisdiction" text NOT NULL,
+    "rate" decimal(7,6) NOT NULL CHECK ("rate" >= 0 AND "rate" <= 1),
+    "taxable_amount" decimal(10,2) NOT NULL,
+    "amount" decimal(10,2) NOT NULL,
+    "included" boolean NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_order_taxes_order_id" ON "order_taxes" ("order_id");
+
+-- Existing orders were charged without tax
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "tax_amount" decimal(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "prices_include_tax" boolean NOT NULL DEFAULT false;
+ALTER TABLE "order_lines" ADD COLUMN IF NOT EXISTS "tax_amount" decimal(10,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS "carts" (
+    "id" bigserial,
+    "customer_id" bigint NOT NULL REFERENCES "customers" ("id") ON DELETE CASCADE,
+    "currency" char(3) NOT NULL,
+    "state" smallint NOT NULL,
+    "order_id" bigint REFERENCES "orders" ("id"),
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_carts_expires_at" ON "carts" ("expires_at");
+-- A customer has at most one open cart
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_carts_customer_open" ON "carts" ("customer_id") WHERE state = 0;
+
+CREATE TABLE IF NOT EXISTS "cart_items" (
+    "id" bigserial,
+    "cart_id" bigint NOT NULL REFERENCES "carts" ("id") ON DELETE CASCADE,
+    "product_id" bigint NOT NULL REFERENCES "products" ("id"),
+    "variant_id" bigint REFERENCES "variants" ("id"),
+    "sku" text,
+    "quantity" bigint NOT NULL CHECK ("quantity" > 0),
+    "unit_price" decimal(10,2) NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_cart_items_cart_id" ON "cart_items" ("cart_id");
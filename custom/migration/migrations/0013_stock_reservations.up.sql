@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "stock_reservations" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL REFERENCES "orders" ("id"),
+    "product_id" bigint NOT NULL REFERENCES "products" ("id"),
+    "variant_id" bigint REFERENCES "variants" ("id"),
+    "quantity" bigint NOT NULL CHECK ("quantity" > 0),
+    "state" smallint NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_stock_reservations_order_id" ON "stock_reservations" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_reservations_state_expires" ON "stock_reservations" ("state", "expires_at");
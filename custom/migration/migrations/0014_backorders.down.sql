@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_order_lines_backordered";
+ALTER TABLE "order_lines" DROP COLUMN IF EXISTS "backordered";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "backordered";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "allow_backorder";
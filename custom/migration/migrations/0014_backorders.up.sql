@@ -0,0 +1,5 @@
+ALTER TABLE "products" ADD COLUMN IF NOT EXISTS "allow_backorder" boolean NOT NULL DEFAULT false;
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "backordered" boolean NOT NULL DEFAULT false;
+ALTER TABLE "order_lines" ADD COLUMN IF NOT EXISTS "backordered" boolean NOT NULL DEFAULT false;
+-- Backordered lines are filled in FIFO order of their orders
+CREATE INDEX IF NOT EXISTS "idx_order_lines_backordered" ON "order_lines" ("order_id") WHERE "backordered";
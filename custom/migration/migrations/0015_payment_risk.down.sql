@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_payments_customer_id";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "base_amount";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "customer_id";
@@ -0,0 +1,4 @@
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "customer_id" bigint;
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "base_amount" decimal(10,2) NOT NULL DEFAULT '0';
+-- Daily limits and velocity of the risk rules are checked per customer
+CREATE INDEX IF NOT EXISTS "idx_payments_customer_id" ON "payments" ("customer_id");
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_payments_review";
+DROP TABLE IF EXISTS "payment_reviews";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "authorize_only";
@@ -0,0 +1,16 @@
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "authorize_only" boolean NOT NULL DEFAULT false;
+CREATE TABLE IF NOT EXISTS "payment_reviews" (
+    "id" bigserial,
+    "payment_id" bigint NOT NULL REFERENCES "payments" ("id"),
+    "order_id" bigint NOT NULL,
+    "decision" text NOT NULL,
+    "reviewer" text NOT NULL,
+    "note" text,
+    "reasons" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_payment_reviews_payment_id" ON "payment_reviews" ("payment_id");
+CREATE INDEX IF NOT EXISTS "idx_payment_reviews_reviewer" ON "payment_reviews" ("reviewer");
+-- Payments held for review are listed by the review queue
+CREATE INDEX IF NOT EXISTS "idx_payments_review" ON "payments" ("id") WHERE "state" = 5;
@@ -0,0 +1,2 @@
+-- Every gateway attempt of an order payment is a payment, earlier payments are first attempts
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "attempt" integer NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_payments_refund_id";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "refund_id";
+DROP TABLE IF EXISTS "refunds";
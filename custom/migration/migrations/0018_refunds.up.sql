@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "refunds" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL REFERENCES "orders" ("id"),
+    "customer_id" bigint NOT NULL,
+    "amount" decimal(10,2) NOT NULL CHECK ("amount" > 0),
+    "currency" char(3) NOT NULL,
+    "reason" text NOT NULL,
+    "state" smallint NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_refunds_order_id" ON "refunds" ("order_id");
+-- Pending refunds are requested again on restart
+CREATE INDEX IF NOT EXISTS "idx_refunds_pending" ON "refunds" ("id") WHERE "state" = 0;
+-- A refund of Order system is made once by the payment API
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "refund_id" bigint;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payments_refund_id" ON "payments" ("refund_id");
@@ -0,0 +1 @@
+ALTER TABLE "order_lines" DROP COLUMN IF EXISTS "refunded_amount";
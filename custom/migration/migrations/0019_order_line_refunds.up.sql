@@ -0,0 +1,4 @@
+-- Refunds of the canceled quantity of a line, earlier cancellations were refunded at the net amount of the canceled quantity
+ALTER TABLE "order_lines" ADD COLUMN IF NOT EXISTS "refunded_amount" decimal(10,2) NOT NULL DEFAULT 0;
+UPDATE "order_lines" SET "refunded_amount" = ROUND(("amount" - "discount_amount" + "tax_amount") * "canceled_quantity" / "quantity", 2)
+WHERE "canceled_quantity" > 0;
@@ -0,0 +1 @@
+ALTER TABLE "order_discounts" DROP COLUMN IF EXISTS "voided_at";
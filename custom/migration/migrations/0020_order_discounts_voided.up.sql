@@ -0,0 +1,2 @@
+-- Discounts of canceled and failed orders are voided and don't count as a use of the coupon
+ALTER TABLE "order_discounts" ADD COLUMN IF NOT EXISTS "voided_at" timestamptz;
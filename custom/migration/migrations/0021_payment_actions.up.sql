@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "payment_actions" (
+    "id" bigserial,
+    "order_id" bigint NOT NULL REFERENCES "orders" ("id"),
+    "action" text NOT NULL CHECK ("action" IN ('CAPTURE', 'VOID')),
+    "state" smallint NOT NULL,
+    "result" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_payment_actions_order_id" ON "payment_actions" ("order_id");
+-- Pending captures and voids are requested again on restart
+CREATE INDEX IF NOT EXISTS "idx_payment_actions_pending" ON "payment_actions" ("id") WHERE "state" = 0;
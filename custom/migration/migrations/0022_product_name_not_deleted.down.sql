@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_products_name_not_deleted";
+ALTER TABLE "products" ADD CONSTRAINT "uni_products_name" UNIQUE ("name");
@@ -0,0 +1,3 @@
+-- Product names are unique among products which are not deleted, a deleted product's name can be reused
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "uni_products_name";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_products_name_not_deleted" ON "products" ("name") WHERE deleted_at IS NULL;
@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"order_system/custom/util"
+	"order_system/model"
+	"time"
+)
+
+// Event Types
+const EVENT_ORDER_FULFILLED = "order.fulfilled"
+const EVENT_ORDER_PARTIALLY_FULFILLED = "order.partially_fulfilled"
+const EVENT_ORDER_FULFILLMENT_FAILED = "order.fulfillment_failed"
+const EVENT_ORDER_SHIPPED = "order.shipped"
+const EVENT_ORDER_DELIVERED = "order.delivered"
+const EVENT_ORDER_REFUNDED = "order.refunded"
+const EVENT_ORDER_CANCELED = "order.canceled"
+const EVENT_ORDER_BACKORDERED = "order.backordered"
+
+// Event Change of an order the customer is notified about
+type Event struct {
+	Type       string       `json:"type"`
+	OrderId    uint         `json:"order_id"`
+	CustomerId uint         `json:"customer_id"`
+	ShipmentId uint         `json:"shipment_id,omitempty"`
+	Amount     *model.Money `json:"amount,omitempty"`
+	Currency   string       `json:"currency,omitempty"`
+	Reason     string       `json:"reason,omitempty"`
+	Time       time.Time    `json:"time"`
+}
+
+// Notifier Hook telling customers about their orders, e.g. by email or push messages
+type Notifier interface {
+	Notify(c context.Context, event Event) error
+}
+
+// NewNotifier Webhook notifier when a webhook url is configured, otherwise notifications are logged
+func NewNotifier(cfg util.NotificationConfig) Notifier {
+	if cfg.WebhookUrl == "" {
+		return LogNotifier{}
+	}
+	return NewWebhookNotifier(cfg.WebhookUrl, time.Duration(max(cfg.TimeoutSeconds, 1))*time.Second)
+}
+
+// LogNotifier Logs notifications, for development without a notification service
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(c context.Context, event Event) error {
+	util.GetLogger(c).Info("Customer notification", "type", event.Type, "order_id", event.OrderId, "customer_id", event.CustomerId)
+	return nil
+}
+
+// WebhookNotifier Posts notifications as JSON to a notification service
+type WebhookNotifier struct {
+	url     string
+	timeout time.Duration
+}
+
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{url: url, timeout: timeout}
+}
+
+func (n *WebhookNotifier) Notify(c context.Context, event Event) error {
+	reqBody, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	c, cancel := context.WithTimeout(c, n.timeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(c, http.MethodPost, n.url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return err
+	}
+	r.Header.Add("Content-Type", "application/json")
+	util.SetRequestIdHeader(c, r)
+	response, err := util.HttpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("Notification webhook responded with status code %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"order_system/custom/util"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var received Event
+	var requestId string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = r.Header.Get(util.REQUEST_ID_HEADER)
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(util.NotificationConfig{WebhookUrl: server.URL})
+	c := util.ContextWithRequestId(context.Background(), "req-1")
+	err := notifier.Notify(c, Event{Type: EVENT_ORDER_SHIPPED, OrderId: 1, CustomerId: 2, ShipmentId: 5, Time: time.Unix(0, 0).UTC()})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "req-1", requestId)
+	assert.Equal(t, Event{Type: EVENT_ORDER_SHIPPED, OrderId: 1, CustomerId: 2, ShipmentId: 5, Time: time.Unix(0, 0).UTC()}, received)
+}
+
+func TestWebhookNotifierFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL, time.Second).Notify(context.Background(), Event{Type: EVENT_ORDER_FULFILLED, OrderId: 1})
+	assert.ErrorContains(t, err, "502")
+}
+
+func TestNewNotifierWithoutWebhook(t *testing.T) {
+	assert.IsType(t, LogNotifier{}, NewNotifier(util.NotificationConfig{}))
+}
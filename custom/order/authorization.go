@@ -0,0 +1,247 @@
+package order
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gen/field"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/promotion"
+	"order_system/custom/reservation"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"time"
+)
+
+var (
+	// The capture or void was saved but the payment API failed to make it
+	errPaymentActionPending = errors.New(constants.PAYMENT_ACTION_PENDING)
+	// The payment API has no authorized payment of the order to capture or void, the action is not retried
+	errPaymentNotAuthorized = errors.New(constants.PAYMENT_NOT_AUTHORIZED)
+	// The authorized payment of a canceled backorder was captured, it is refunded instead of voided
+	errPaymentCaptured = errors.New(constants.PAYMENT_CAPTURED)
+)
+
+// CaptureRequest Capture the authorized payment of a backorder
+type CaptureRequest struct {
+	OrderId uint `json:"order_id"`
+}
+
+// VoidRequest Void the authorized payment of a canceled backorder
+type VoidRequest struct {
+	OrderId uint `json:"order_id"`
+}
+
+// SetCaptureMethod Set the method capturing authorized payments of backorders, defaults to calling the payment API
+func (ctx *HandlerContext) SetCaptureMethod(captureMethod CaptureMethod) {
+	ctx.captureMethod = captureMethod
+}
+
+// SetVoidMethod Set the method voiding authorized payments of canceled backorders, defaults to calling the payment API
+func (ctx *HandlerContext) SetVoidMethod(voidMethod VoidMethod) {
+	ctx.voidMethod = voidMethod
+}
+
+// Pending capture or void of the authorized payment of an order, saved in the transaction which changed the order state
+func newPaymentAction(orderId uint, action string) *model.PaymentAction {
+	return &model.PaymentAction{
+		OrderId: orderId,
+		Action:  action,
+		State:   constants.PAYMENT_ACTION_STATE_PENDING,
+	}
+}
+
+// Void the authorized payment of a canceled backorder in the background, the pending void is requested again on restart when it fails
+func (ctx *HandlerContext) voidInBackground(c context.Context, void *model.PaymentAction) {
+	c = context.WithoutCancel(c)
+	go func() {
+		if err := ctx.makePaymentAction(c, void); err != nil {
+			util.GetLogger(c).Error(err.Error(), "order_id", void.OrderId, "payment_action_id", void.ID)
+		}
+	}()
+}
+
+// Make a saved capture or void through the payment API, failed calls are retried after the backoff.
+// The action is COMPLETED then, it stays PENDING when the payment API keeps failing and is FAILED when there is no authorized payment.
+// A backorder whose payment can't be captured fails and gives its stock back, a canceled backorder whose payment was captured is refunded.
+func (ctx *HandlerContext) makePaymentAction(c context.Context, action *model.PaymentAction) error {
+	logger := util.GetLogger(c).With("order_id", action.OrderId, "payment_action_id", action.ID, "action", action.Action)
+	settings := ctx.getSettings()
+	var call func(context.Context, uint) error = ctx.captureMethod
+	if action.Action == constants.PAYMENT_ACTION_VOID {
+		call = ctx.voidMethod
+	}
+	err := call(c, action.OrderId)
+	for retry := 1; err != nil && !isTerminalPaymentError(err) && retry <= settings.PaymentApiRetryCount; retry++ {
+		delay := util.Backoff(retry, settings.RetryBackoff, settings.RetryMaxBackoff)
+		logger.Error("Call payment action failed: "+err.Error(), "retry", retry, "backoff", delay)
+		if errSleep := util.SleepContext(c, delay); errSleep != nil {
+			break
+		}
+		err = call(c, action.OrderId)
+	}
+	if err != nil && !isTerminalPaymentError(err) {
+		return fmt.Errorf("%w: %s %s", errPaymentActionPending, action.Action, err.Error())
+	}
+
+	action.State = constants.PAYMENT_ACTION_STATE_COMPLETED
+	if err != nil {
+		action.State = constants.PAYMENT_ACTION_STATE_FAILED
+		action.Result = util.GetStringPtr(err.Error())
+	}
+	actionTable := ctx.db.PaymentAction
+	updates := []field.AssignExpr{actionTable.State.Value(action.State)}
+	if action.Result != nil {
+		updates = append(updates, actionTable.Result.Value(*action.Result))
+	}
+	_, errDb := actionTable.WithContext(c).Where(actionTable.ID.Eq(action.ID), actionTable.State.Eq(constants.PAYMENT_ACTION_STATE_PENDING)).
+		UpdateSimple(updates...)
+	if errDb != nil {
+		// Requested again on restart, the payment API captures and voids an order once
+		logger.Error("Update payment action failed: " + errDb.Error())
+	}
+
+	switch {
+	case err == nil:
+		logger.Info("Payment action was made")
+		return nil
+	case action.Action == constants.PAYMENT_ACTION_CAPTURE:
+		return ctx.failCapture(c, action.OrderId, err)
+	case errors.Is(err, errPaymentCaptured):
+		logger.Warn("Payment of the canceled backorder was captured, refunding it")
+		orderInfo, errDb := ctx.db.Order.WithContext(c).Where(ctx.db.Order.ID.Eq(action.OrderId)).First()
+		if errDb != nil {
+			return fmt.Errorf("refund captured payment of order %d: %w", action.OrderId, errDb)
+		}
+		return ctx.refundCanceledOrder(c, orderInfo)
+	}
+	logger.Warn("Payment authorization was not voided: " + err.Error())
+	return nil
+}
+
+// The payment API can't capture or void the payment of the order however often it is requested
+func isTerminalPaymentError(err error) bool {
+	return errors.Is(err, errPaymentNotAuthorized) || errors.Is(err, errPaymentCaptured)
+}
+
+// Fail a filled backorder whose payment can't be captured, the stock sold to it and its coupon uses are given back
+func (ctx *HandlerContext) failCapture(c context.Context, orderId uint, cause error) error {
+	reason := constants.CAPTURE_FAILED + ": " + cause.Error()
+	released := 0
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		result, errTx := tx.Order.WithContext(c).Where(tx.Order.ID.Eq(orderId), tx.Order.State.Eq(ORDER_STATE_PAID)).
+			UpdateSimple(tx.Order.State.Value(ORDER_STATE_FAILED), tx.Order.FailReason.Value(reason))
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if _, errTx = promotion.Release(c, tx, orderId, time.Now()); errTx != nil {
+			return errTx
+		}
+		released, errTx = reservation.ReleaseSold(c, tx, orderId, constants.RESERVATION_STATE_RELEASED)
+		return errTx
+	})
+	if err != nil {
+		return fmt.Errorf("fail order %d: %w", orderId, err)
+	}
+	util.GetLogger(c).Info("Order state was updated", "order_id", orderId, "state", stateCodeToString(ORDER_STATE_FAILED), "reason", reason)
+	if released > 0 {
+		ctx.refillBackorders(c)
+	}
+	return errors.New(reason)
+}
+
+// Request the pending captures and voids again, e.g. when the payment API was unavailable before a restart.
+// An order is fulfilled once its payment is captured.
+func (ctx *HandlerContext) retryPaymentActions(c context.Context) {
+	logger := util.GetLogger(c)
+	actionTable := ctx.db.PaymentAction
+	actions, err := actionTable.WithContext(c).Where(actionTable.State.Eq(constants.PAYMENT_ACTION_STATE_PENDING)).Order(actionTable.ID).Find()
+	if err != nil {
+		logger.Error("Find pending payment actions failed: " + err.Error())
+		return
+	}
+	if len(actions) > 0 {
+		logger.Info("Found pending payment actions", "count", len(actions))
+	}
+	for _, action := range actions {
+		if err = ctx.makePaymentAction(c, action); err != nil {
+			logger.Error(err.Error(), "order_id", action.OrderId, "payment_action_id", action.ID)
+			continue
+		}
+		if action.Action != constants.PAYMENT_ACTION_CAPTURE {
+			continue
+		}
+		orderInfo, err := ctx.db.Order.WithContext(c).Where(ctx.db.Order.ID.Eq(action.OrderId)).First()
+		if err != nil {
+			logger.Error("Find captured order failed: "+err.Error(), "order_id", action.OrderId)
+			continue
+		}
+		ctx.enqueueOrder(c, orderInfo)
+	}
+}
+
+// Orders with a pending capture, they aren't fulfilled until their payment is captured
+func (ctx *HandlerContext) pendingCaptures(c context.Context) (map[uint]bool, error) {
+	orderIds := make([]uint, 0)
+	actionTable := ctx.db.PaymentAction
+	err := actionTable.WithContext(c).
+		Where(actionTable.Action.Eq(constants.PAYMENT_ACTION_CAPTURE), actionTable.State.Eq(constants.PAYMENT_ACTION_STATE_PENDING)).
+		Pluck(actionTable.OrderId, &orderIds)
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[uint]bool, len(orderIds))
+	for _, orderId := range orderIds {
+		pending[orderId] = true
+	}
+	return pending, nil
+}
+
+// CallCaptureApi method for asking payment API to capture the authorized payment of a backorder
+func (ctx *HandlerContext) CallCaptureApi(c context.Context, orderId uint) error {
+	return ctx.callPaymentActionApi(c, ctx.getSettings().PaymentCaptureUrl, CaptureRequest{OrderId: orderId}, orderId)
+}
+
+// CallVoidApi method for asking payment API to void the authorized payment of a canceled backorder
+func (ctx *HandlerContext) CallVoidApi(c context.Context, orderId uint) error {
+	return ctx.callPaymentActionApi(c, ctx.getSettings().PaymentVoidUrl, VoidRequest{OrderId: orderId}, orderId)
+}
+
+// Post a capture or void to the payment API, Not Found and Conflict responses are terminal
+func (ctx *HandlerContext) callPaymentActionApi(c context.Context, url string, req interface{}, orderId uint) error {
+	logger := util.GetLogger(c).With("order_id", orderId)
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	r, err := http.NewRequestWithContext(c, http.MethodPost, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	r.Header.Add("Content-Type", "application/json")
+	util.SetRequestIdHeader(c, r)
+	response, err := util.HttpClient.Do(r)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return errPaymentNotAuthorized
+	case http.StatusConflict:
+		return errPaymentCaptured
+	}
+	return errors.New(fmt.Sprintf("Payment action failed with status code %d", response.StatusCode))
+}
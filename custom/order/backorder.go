@@ -0,0 +1,167 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order_system/constants"
+	"order_system/custom/reservation"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"time"
+)
+
+var (
+	// Failures of taking a product which took nothing, the product may be backordered then
+	errProductSoldOut = errors.New(constants.PRODUCT_NOT_AVAILABLE)
+	errOutOfStock     = errors.New(constants.OUT_OF_STOCK)
+	// The backordered lines of an order are still out of stock
+	errBackorderNotFilled = errors.New("backorder is not in stock")
+)
+
+// A filled backorder and the pending capture of its payment
+type filledBackorder struct {
+	order   *model.Order
+	capture *model.PaymentAction
+}
+
+// Product or SKU of a backordered line, backorders of the same stock are filled in FIFO order
+type stockKey struct {
+	productId uint
+	variantId uint
+}
+
+func stockKeyOf(line *model.OrderLine) stockKey {
+	if line.VariantId != nil {
+		return stockKey{variantId: *line.VariantId}
+	}
+	return stockKey{productId: line.ProductId}
+}
+
+// Restocked Fill backorders in the background when the stock of a product was replenished
+func (ctx *HandlerContext) Restocked(c context.Context, productId uint) {
+	util.GetLogger(c).Info("Product was restocked, filling backorders", "product_id", productId)
+	ctx.refillBackorders(c)
+}
+
+// Fill backorders in the background, e.g. when stock was released
+func (ctx *HandlerContext) refillBackorders(c context.Context) {
+	go ctx.FillBackorders(context.WithoutCancel(c))
+}
+
+// FillBackorders Take the stock of BACKORDERED orders in FIFO order, and capture the payment of the orders which are in stock.
+// An order which can't be filled holds back the later orders of the same products, orders of other products go ahead.
+// The payments are captured after the backorders were filled, a captured order is fulfilled then.
+func (ctx *HandlerContext) FillBackorders(c context.Context) int {
+	filled := ctx.fillBackorders(c)
+	for _, backorder := range filled {
+		if err := ctx.makePaymentAction(c, backorder.capture); err != nil {
+			// The capture is pending, it is requested again on restart
+			util.GetLogger(c).Error(err.Error(), "order_id", backorder.order.ID)
+			continue
+		}
+		ctx.enqueueOrder(c, backorder.order)
+	}
+	return len(filled)
+}
+
+// Fill the backorders which are in stock one run at a time, in FIFO order
+func (ctx *HandlerContext) fillBackorders(c context.Context) []filledBackorder {
+	ctx.backorderMu.Lock()
+	defer ctx.backorderMu.Unlock()
+	logger := util.GetLogger(c)
+
+	orders, err := ctx.db.Order.WithContext(c).Where(ctx.db.Order.State.Eq(ORDER_STATE_BACKORDERED)).Order(ctx.db.Order.ID).Find()
+	if err != nil {
+		logger.Error("Find backorders failed: " + err.Error())
+		return nil
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	orderIds := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		orderIds = append(orderIds, order.ID)
+	}
+	lines, err := ctx.db.OrderLine.WithContext(c).Where(ctx.db.OrderLine.OrderId.In(orderIds...), ctx.db.OrderLine.Backordered.Is(true)).Order(ctx.db.OrderLine.ID).Find()
+	if err != nil {
+		logger.Error("Find backordered lines failed: " + err.Error())
+		return nil
+	}
+	linesOf := make(map[uint][]*model.OrderLine, len(orders))
+	for _, line := range lines {
+		linesOf[line.OrderId] = append(linesOf[line.OrderId], line)
+	}
+
+	filled := make([]filledBackorder, 0)
+	heldBack := make(map[stockKey]bool)
+	for _, order := range orders {
+		orderLines := linesOf[order.ID]
+		waiting := false
+		for _, line := range orderLines {
+			waiting = waiting || heldBack[stockKeyOf(line)]
+		}
+		if !waiting {
+			capture, err := ctx.fillBackorder(c, order, orderLines)
+			if err == nil {
+				filled = append(filled, filledBackorder{order: order, capture: capture})
+				continue
+			}
+			if !errors.Is(err, errBackorderNotFilled) {
+				logger.Error("Fill backorder failed: "+err.Error(), "order_id", order.ID)
+			}
+		}
+		for _, line := range orderLines {
+			heldBack[stockKeyOf(line)] = true
+		}
+	}
+	if len(filled) > 0 {
+		logger.Info("Backorders were filled", "count", len(filled))
+	}
+	return filled
+}
+
+// Take the stock of the backordered lines of an order, the order is PAID with a pending capture of its payment.
+// The taken stock is sold to the order, it is returned when the payment can't be captured.
+func (ctx *HandlerContext) fillBackorder(c context.Context, order *model.Order, lines []*model.OrderLine) (*model.PaymentAction, error) {
+	capture := newPaymentAction(order.ID, constants.PAYMENT_ACTION_CAPTURE)
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		for _, line := range lines {
+			sku := ""
+			if line.Sku != nil {
+				sku = *line.Sku
+			}
+			if _, _, errTx := ctx.takeProduct(c, tx, line.ProductId, sku, line.Quantity); errTx != nil {
+				return fmt.Errorf("%w: %s", errBackorderNotFilled, errTx.Error())
+			}
+			line.Backordered = false
+		}
+		_, errTx := tx.OrderLine.WithContext(c).Where(tx.OrderLine.OrderId.Eq(order.ID), tx.OrderLine.Backordered.Is(true)).
+			UpdateSimple(tx.OrderLine.Backordered.Value(false))
+		if errTx != nil {
+			return errTx
+		}
+		result, errTx := tx.Order.WithContext(c).Where(tx.Order.ID.Eq(order.ID), tx.Order.State.Eq(ORDER_STATE_BACKORDERED)).
+			UpdateSimple(tx.Order.State.Value(ORDER_STATE_PAID))
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: order is no longer BACKORDERED", errBackorderNotFilled)
+		}
+		if _, errTx = reservation.Reserve(c, tx, order.ID, lines, time.Now()); errTx != nil {
+			return errTx
+		}
+		if errTx = reservation.Sell(c, tx, order.ID); errTx != nil {
+			return errTx
+		}
+		return tx.PaymentAction.WithContext(c).Create(capture)
+	})
+	if err != nil {
+		return nil, err
+	}
+	order.State = ORDER_STATE_PAID
+	util.GetLogger(c).Info("Order state was updated", "order_id", order.ID, "state", stateCodeToString(order.State))
+	return capture, nil
+}
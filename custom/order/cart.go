@@ -0,0 +1,567 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/currency"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"time"
+)
+
+var (
+	errCartNotFound        = errors.New(constants.CART_NOT_FOUND)
+	errCartItemNotFound    = errors.New(constants.CART_ITEM_NOT_FOUND)
+	errCartItemUnavailable = errors.New(constants.CART_ITEM_UNAVAILABLE)
+	errCartChanged         = errors.New(constants.CART_CHANGED)
+	errCartCustomer        = errors.New(constants.CUSTOMER_NOT_FOUND)
+)
+
+// AddCartItemRequest Add a product or SKU to the open cart of a customer, a cart is opened when there is none
+type AddCartItemRequest struct {
+	CustomerId uint   `json:"customer_id"`
+	ProductId  uint   `json:"product_id"`
+	Sku        string `json:"sku,omitempty"`
+	// Defaults to 1, only SKUs can be added in quantity. Adding an item already in the cart adds up the quantity.
+	Quantity int `json:"quantity,omitempty"`
+	// Currency of a new cart, defaults to the product currency
+	Currency string `json:"currency,omitempty"`
+}
+
+// UpdateCartItemRequest Set the quantity of a cart item, quantity 0 removes it
+type UpdateCartItemRequest struct {
+	CustomerId uint `json:"customer_id"`
+	ItemId     uint `json:"item_id"`
+	Quantity   int  `json:"quantity"`
+}
+
+// RemoveCartItemRequest Remove an item from the cart
+type RemoveCartItemRequest struct {
+	CustomerId uint `json:"customer_id"`
+	ItemId     uint `json:"item_id"`
+}
+
+// QueryCartRequest Open cart of a customer
+type QueryCartRequest struct {
+	CustomerId uint `json:"customer_id"`
+}
+
+// CheckoutCartRequest Order the open cart of a customer
+type CheckoutCartRequest struct {
+	CustomerId uint `json:"customer_id"`
+	// Saved addresses of the customer, default to the customer default addresses
+	ShippingAddressId *uint  `json:"shipping_address_id,omitempty"`
+	BillingAddressId  *uint  `json:"billing_address_id,omitempty"`
+	CouponCode        string `json:"coupon_code,omitempty"`
+}
+
+// AddCartItem Add an item to the cart of a customer, the price is quoted and the stock checked without taking it
+func (ctx *HandlerContext) AddCartItem(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := AddCartItemRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.CustomerId == 0 {
+		http.Error(w, "Customer id is required", http.StatusBadRequest)
+		return
+	}
+	if req.ProductId == 0 && req.Sku == "" {
+		http.Error(w, "ProductId or Sku is required", http.StatusBadRequest)
+		return
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	if req.Quantity < 0 {
+		http.Error(w, constants.INVALID_QUANTITY, http.StatusBadRequest)
+		return
+	}
+	if req.Currency != "" && !ctx.rates.Load().Supports(req.Currency) {
+		http.Error(w, constants.UNSUPPORTED_CURRENCY+": "+req.Currency, http.StatusBadRequest)
+		return
+	}
+
+	cart, err := ctx.addCartItem(r.Context(), &req)
+	writeCart(w, r, cart, err, req.CustomerId)
+}
+
+// UpdateCartItem Change the quantity of a cart item, quantity 0 removes it
+func (ctx *HandlerContext) UpdateCartItem(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := UpdateCartItemRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.CustomerId == 0 || req.ItemId == 0 {
+		http.Error(w, "Customer id and item id are required", http.StatusBadRequest)
+		return
+	}
+	if req.Quantity < 0 {
+		http.Error(w, constants.INVALID_QUANTITY, http.StatusBadRequest)
+		return
+	}
+
+	cart, err := ctx.setCartItemQuantity(r.Context(), req.CustomerId, req.ItemId, req.Quantity)
+	writeCart(w, r, cart, err, req.CustomerId)
+}
+
+// RemoveCartItem Remove an item from the cart
+func (ctx *HandlerContext) RemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := RemoveCartItemRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.CustomerId == 0 || req.ItemId == 0 {
+		http.Error(w, "Customer id and item id are required", http.StatusBadRequest)
+		return
+	}
+
+	cart, err := ctx.setCartItemQuantity(r.Context(), req.CustomerId, req.ItemId, 0)
+	writeCart(w, r, cart, err, req.CustomerId)
+}
+
+// QueryCart Fetch the open cart of a customer. Prices and stock are validated again,
+// items with a new price are flagged once and saved with it, items which can't be ordered are flagged unavailable.
+func (ctx *HandlerContext) QueryCart(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	req := QueryCartRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.CustomerId == 0 {
+		http.Error(w, "Customer id is required", http.StatusBadRequest)
+		return
+	}
+
+	cart, _, err := ctx.validateCart(r.Context(), req.CustomerId)
+	writeCart(w, r, cart, err, req.CustomerId)
+}
+
+// CheckoutCart Convert the open cart of a customer into an order.
+// The cart is validated first, a cart with changed prices or unavailable items is returned with 409 to be reviewed.
+// The order is created and the cart checked out in one transaction.
+func (ctx *HandlerContext) CheckoutCart(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := CheckoutCartRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.CustomerId == 0 {
+		http.Error(w, "Customer id is required", http.StatusBadRequest)
+		return
+	}
+
+	cart, changed, err := ctx.validateCart(r.Context(), req.CustomerId)
+	if err != nil {
+		writeCart(w, r, nil, err, req.CustomerId)
+		return
+	}
+	if len(cart.Items) == 0 {
+		http.Error(w, constants.CART_EMPTY, http.StatusBadRequest)
+		return
+	}
+	if changed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		respBody, _ := json.Marshal(cart)
+		w.Write(respBody)
+		return
+	}
+
+	items := make([]CreateOrderItem, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		orderItem := CreateOrderItem{ProductId: item.ProductId, Quantity: item.Quantity}
+		if item.Sku != nil {
+			orderItem.Sku = *item.Sku
+		}
+		items = append(items, orderItem)
+	}
+	orderReq := CreateOrderRequest{
+		CustomerId:        req.CustomerId,
+		Items:             items,
+		ShippingAddressId: req.ShippingAddressId,
+		BillingAddressId:  req.BillingAddressId,
+		Currency:          cart.Currency,
+		CouponCode:        req.CouponCode,
+	}
+	newOrder, err := ctx.placeOrder(r.Context(), &orderReq, items, func(tx *dal.Query, order *model.Order) error {
+		// The order is rolled back when a price changed after the cart was validated
+		for i, line := range order.Lines {
+			if line.UnitPrice != cart.Items[i].UnitPrice {
+				return errCartChanged
+			}
+		}
+		result, errTx := tx.Cart.WithContext(r.Context()).Where(tx.Cart.ID.Eq(cart.ID), tx.Cart.State.Eq(constants.CART_STATE_OPEN)).
+			UpdateSimple(tx.Cart.State.Value(constants.CART_STATE_CHECKED_OUT), tx.Cart.OrderId.Value(order.ID))
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return errCartNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, errCartChanged) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errCartNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Cart was checked out", "cart_id", cart.ID, "order_id", newOrder.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(newOrder)
+	w.Write(respBody)
+}
+
+// ExpireCarts Expire open carts past their expiry, returns the count of expired carts
+func (ctx *HandlerContext) ExpireCarts(c context.Context) (int64, error) {
+	result, err := ctx.db.Cart.WithContext(c).Where(ctx.db.Cart.State.Eq(constants.CART_STATE_OPEN), ctx.db.Cart.ExpiresAt.Lt(time.Now())).
+		UpdateSimple(ctx.db.Cart.State.Value(constants.CART_STATE_EXPIRED))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected, nil
+}
+
+// SweepCarts Expire abandoned carts in the interval
+func (ctx *HandlerContext) SweepCarts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		count, err := ctx.ExpireCarts(util.ContextWithRequestId(context.Background(), util.NewRequestId()))
+		if err != nil {
+			slog.Error("Expire carts failed", "error", err.Error())
+			continue
+		}
+		if count > 0 {
+			slog.Info("Abandoned carts were expired", "count", count)
+		}
+	}
+}
+
+// Write the cart, or the error of changing it
+func writeCart(w http.ResponseWriter, r *http.Request, cart *model.Cart, err error, customerId uint) {
+	if errors.Is(err, errCartNotFound) || errors.Is(err, errCartItemNotFound) || errors.Is(err, errCartCustomer) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errCartItemUnavailable) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		util.GetLogger(r.Context()).Error(err.Error(), "customer_id", customerId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(cart)
+	w.Write(respBody)
+}
+
+// Add the item to the open cart of the customer, or to a new cart. The cart expiry is extended.
+func (ctx *HandlerContext) addCartItem(c context.Context, req *AddCartItemRequest) (*model.Cart, error) {
+	rates := ctx.rates.Load()
+	now := time.Now()
+	var cart *model.Cart
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		var errTx error
+		cart, errTx = openCart(c, tx, req.CustomerId, now)
+		if errors.Is(errTx, errCartNotFound) {
+			if _, errTx = tx.Customer.WithContext(c).Where(tx.Customer.ID.Eq(req.CustomerId)).First(); errTx != nil {
+				return errCartCustomer
+			}
+			cart = &model.Cart{CustomerId: req.CustomerId, Currency: req.Currency, State: constants.CART_STATE_OPEN}
+		} else if errTx != nil {
+			return errTx
+		}
+
+		// The same product or SKU adds up to one item
+		item := &model.CartItem{ProductId: req.ProductId, Quantity: req.Quantity}
+		if req.Sku != "" {
+			item.Sku = &req.Sku
+		}
+		for i := range cart.Items {
+			if cart.Items[i].Sku != nil && req.Sku == *cart.Items[i].Sku || cart.Items[i].Sku == nil && req.Sku == "" && req.ProductId == cart.Items[i].ProductId {
+				item = &cart.Items[i]
+				item.Quantity += req.Quantity
+				break
+			}
+		}
+		if errTx = quoteItem(c, tx, rates, cart, item); errTx != nil {
+			return errTx
+		}
+
+		cart.ExpiresAt = now.Add(ctx.getSettings().CartTtl)
+		if cart.ID == 0 {
+			if errTx = tx.Cart.WithContext(c).Create(cart); errTx != nil {
+				return errTx
+			}
+		} else if errTx = touchCart(c, tx, cart); errTx != nil {
+			return errTx
+		}
+		if item.ID != 0 {
+			_, errTx = tx.CartItem.WithContext(c).Where(tx.CartItem.ID.Eq(item.ID)).UpdateSimple(tx.CartItem.Quantity.Value(item.Quantity), tx.CartItem.UnitPrice.Value(item.UnitPrice))
+			return errTx
+		}
+		item.CartId = cart.ID
+		if errTx = tx.CartItem.WithContext(c).Create(item); errTx != nil {
+			return errTx
+		}
+		cart.Items = append(cart.Items, *item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// Set the quantity of an item in the open cart of the customer, quantity 0 removes it. The cart expiry is extended.
+func (ctx *HandlerContext) setCartItemQuantity(c context.Context, customerId uint, itemId uint, quantity int) (*model.Cart, error) {
+	rates := ctx.rates.Load()
+	now := time.Now()
+	var cart *model.Cart
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		var errTx error
+		cart, errTx = openCart(c, tx, customerId, now)
+		if errTx != nil {
+			return errTx
+		}
+		index := -1
+		for i := range cart.Items {
+			if cart.Items[i].ID == itemId {
+				index = i
+			}
+		}
+		if index < 0 {
+			return errCartItemNotFound
+		}
+
+		if quantity == 0 {
+			if _, errTx = tx.CartItem.WithContext(c).Where(tx.CartItem.ID.Eq(itemId)).Delete(); errTx != nil {
+				return errTx
+			}
+			cart.Items = append(cart.Items[:index], cart.Items[index+1:]...)
+		} else {
+			item := &cart.Items[index]
+			item.Quantity = quantity
+			if errTx = quoteItem(c, tx, rates, cart, item); errTx != nil {
+				return errTx
+			}
+			_, errTx = tx.CartItem.WithContext(c).Where(tx.CartItem.ID.Eq(itemId)).UpdateSimple(tx.CartItem.Quantity.Value(item.Quantity), tx.CartItem.UnitPrice.Value(item.UnitPrice))
+			if errTx != nil {
+				return errTx
+			}
+		}
+		cart.ExpiresAt = now.Add(ctx.getSettings().CartTtl)
+		return touchCart(c, tx, cart)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// Validate prices and stock of the open cart of the customer. New prices are saved and flagged,
+// changed is true when a price changed or an item can't be ordered.
+func (ctx *HandlerContext) validateCart(c context.Context, customerId uint) (*model.Cart, bool, error) {
+	rates := ctx.rates.Load()
+	var cart *model.Cart
+	changed := false
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		var errTx error
+		cart, errTx = openCart(c, tx, customerId, time.Now())
+		if errTx != nil {
+			return errTx
+		}
+		for i := range cart.Items {
+			item := &cart.Items[i]
+			price, reason, errTx := quoteCartItem(c, tx, rates, cart, item)
+			if errTx != nil {
+				return errTx
+			}
+			if reason != "" {
+				item.Unavailable = reason
+				changed = true
+				continue
+			}
+			if price == item.UnitPrice {
+				continue
+			}
+			item.UnitPrice = price
+			item.PriceChanged = true
+			changed = true
+			if _, errTx = tx.CartItem.WithContext(c).Where(tx.CartItem.ID.Eq(item.ID)).UpdateSimple(tx.CartItem.UnitPrice.Value(price)); errTx != nil {
+				return errTx
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return cart, changed, nil
+}
+
+// Find the open cart of a customer with its items. A cart past its expiry is expired and not found.
+func openCart(c context.Context, tx *dal.Query, customerId uint, now time.Time) (*model.Cart, error) {
+	carts, err := tx.Cart.WithContext(c).Where(tx.Cart.CustomerId.Eq(customerId), tx.Cart.State.Eq(constants.CART_STATE_OPEN)).Find()
+	if err != nil {
+		return nil, err
+	}
+	if len(carts) == 0 {
+		return nil, errCartNotFound
+	}
+	cart := carts[0]
+	if !cart.ExpiresAt.After(now) {
+		_, err = tx.Cart.WithContext(c).Where(tx.Cart.ID.Eq(cart.ID), tx.Cart.State.Eq(constants.CART_STATE_OPEN)).UpdateSimple(tx.Cart.State.Value(constants.CART_STATE_EXPIRED))
+		if err != nil {
+			return nil, err
+		}
+		return nil, errCartNotFound
+	}
+	items, err := tx.CartItem.WithContext(c).Where(tx.CartItem.CartId.Eq(cart.ID)).Order(tx.CartItem.ID).Find()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		cart.Items = append(cart.Items, *item)
+	}
+	return cart, nil
+}
+
+// Extend the expiry of a cart
+func touchCart(c context.Context, tx *dal.Query, cart *model.Cart) error {
+	_, err := tx.Cart.WithContext(c).Where(tx.Cart.ID.Eq(cart.ID)).UpdateSimple(tx.Cart.ExpiresAt.Value(cart.ExpiresAt))
+	return err
+}
+
+// Set the unit price of an item which is added or changed, an item which can't be ordered is rejected
+func quoteItem(c context.Context, tx *dal.Query, rates *currency.RateTable, cart *model.Cart, item *model.CartItem) error {
+	price, reason, err := quoteCartItem(c, tx, rates, cart, item)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("%w: %s", errCartItemUnavailable, reason)
+	}
+	item.UnitPrice = price
+	return nil
+}
+
+// Price one unit of a cart item in the cart currency and check it can be ordered or backordered, without taking the stock.
+// The reason is set when the item can't be ordered. A new cart takes the currency of its first product.
+func quoteCartItem(c context.Context, tx *dal.Query, rates *currency.RateTable, cart *model.Cart, item *model.CartItem) (model.Money, string, error) {
+	var variant *model.Variant
+	if item.Sku != nil {
+		variants, err := tx.Variant.WithContext(c).Where(tx.Variant.Sku.Eq(*item.Sku)).Find()
+		if err != nil {
+			return 0, "", err
+		}
+		if len(variants) == 0 || (item.ProductId != 0 && item.ProductId != variants[0].ProductId) {
+			return 0, constants.PRODUCT_NOT_AVAILABLE, nil
+		}
+		variant = variants[0]
+		item.ProductId = variant.ProductId
+		item.VariantId = &variant.ID
+	} else {
+		if item.Quantity > 1 {
+			return 0, constants.INVALID_QUANTITY + ": only SKUs can be ordered in quantity", nil
+		}
+		variantCount, err := tx.Variant.WithContext(c).Where(tx.Variant.ProductId.Eq(item.ProductId)).Count()
+		if err != nil {
+			return 0, "", err
+		}
+		if variantCount > 0 {
+			return 0, constants.SKU_REQUIRED, nil
+		}
+	}
+
+	products, err := tx.Product.WithContext(c).Where(tx.Product.ID.Eq(item.ProductId)).Find()
+	if err != nil {
+		return 0, "", err
+	}
+	if len(products) == 0 {
+		return 0, constants.PRODUCT_NOT_AVAILABLE, nil
+	}
+	// Items out of stock or not available yet are ordered on backorder when the product allows it, like in CreateOrder
+	product := products[0]
+	if variant != nil && variant.Stock < item.Quantity {
+		if !product.AllowBackorder {
+			return 0, constants.OUT_OF_STOCK + ": " + variant.Sku, nil
+		}
+	} else if !product.IsAvailable && (variant != nil || !product.AllowBackorder) {
+		return 0, constants.PRODUCT_NOT_AVAILABLE, nil
+	}
+	productCurrency := product.Currency
+	if productCurrency == "" {
+		productCurrency = currency.DEFAULT_CURRENCY
+	}
+	if cart.Currency == "" {
+		cart.Currency = productCurrency
+	}
+	price, err := unitPrice(c, tx, rates, product, variant, productCurrency, cart.Currency)
+	if err != nil {
+		return 0, err.Error(), nil
+	}
+	return price, "", nil
+}
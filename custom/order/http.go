@@ -1,30 +1,106 @@
 package order
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"github.com/romana/rlog"
+	"fmt"
 	"net/http"
 	"order_system/constants"
+	"order_system/custom/currency"
+	"order_system/custom/customer"
+	"order_system/custom/fulfillment"
+	"order_system/custom/health"
+	"order_system/custom/notification"
+	"order_system/custom/promotion"
+	"order_system/custom/reservation"
+	"order_system/custom/tax"
 	"order_system/custom/util"
 	"order_system/dal"
 	"order_system/model"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type PaymentMethod func(*model.Order) error
+var (
+	errCustomerNotFound  = errors.New(constants.CUSTOMER_NOT_FOUND)
+	errCustomerAmbiguous = errors.New(constants.CUSTOMER_AMBIGUOUS)
+	errAddressNotFound   = errors.New(constants.ADDRESS_NOT_FOUND)
+	errProductNotFound   = errors.New(constants.PRODUCT_NOT_FOUND)
+)
+
+type PaymentMethod func(context.Context, *model.Order) error
+type RefundMethod func(context.Context, RefundRequest) error
+type CaptureMethod func(c context.Context, orderId uint) error
+type VoidMethod func(c context.Context, orderId uint) error
+
+// Settings Runtime tunable values, swapped atomically when config is reloaded
+type Settings struct {
+	PaymentMQUrl         string
+	PaymentRefundUrl     string
+	PaymentCaptureUrl    string
+	PaymentVoidUrl       string
+	PaymentApiRetryCount int
+	// Failed payment api calls and hand overs are retried after the backoff, it doubles with every retry up to the max backoff
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// Failed hand overs are retried before the order fails, orders out of stock at the warehouse fail right away
+	FulfillmentRetryCount int
+	// Stock of unpaid orders is reserved for this long
+	ReservationTtl time.Duration
+	// Open carts expire when they aren't changed for this long
+	CartTtl           time.Duration
+	WorkerConcurrency int
+}
 
 type HandlerContext struct {
 	db            *dal.Query
-	orderChan     chan *model.Order
+	orderChan     chan *orderEvent
 	paymentMethod PaymentMethod
-	PaymentMQUrl  string
+	refundMethod  RefundMethod
+	captureMethod CaptureMethod
+	voidMethod    VoidMethod
+	// Backorders are filled one run at a time, in FIFO order
+	backorderMu sync.Mutex
+	settings    atomic.Pointer[Settings]
+	rates       atomic.Pointer[currency.RateTable]
+	taxRules    atomic.Pointer[tax.RuleTable]
+	fulfillment fulfillment.Provider
+	notifier    notification.Notifier
+	limiter     *util.Limiter
+	Worker      *health.Worker
 }
 
+// CreateOrderRequest The customer is identified by id, email or name and the product by id, SKU or name, in that order.
+// Several products are ordered with Items, the product fields are a single item otherwise.
 type CreateOrderRequest struct {
-	CustomerId   uint    `json:"customer_id"`
-	CustomerName *string `json:"customer_name,omitempty"`
-	ProductId    uint    `json:"product_id"`
-	ProductName  *string `json:"product_name,omitempty"`
+	CustomerId    uint    `json:"customer_id"`
+	CustomerEmail string  `json:"customer_email,omitempty"`
+	CustomerName  *string `json:"customer_name,omitempty"`
+	ProductId     uint    `json:"product_id"`
+	ProductName   *string `json:"product_name,omitempty"`
+	// Required for products with variants, ProductId may be omitted then
+	Sku string `json:"sku,omitempty"`
+	// Defaults to 1, only SKUs can be ordered in quantity
+	Quantity int               `json:"quantity,omitempty"`
+	Items    []CreateOrderItem `json:"items,omitempty"`
+	// Saved addresses of the customer, default to the customer default addresses
+	ShippingAddressId *uint `json:"shipping_address_id,omitempty"`
+	BillingAddressId  *uint `json:"billing_address_id,omitempty"`
+	// Defaults to the product currency
+	Currency string `json:"currency,omitempty"`
+	// Coupon code, the discount is taken off the order amount
+	CouponCode string `json:"coupon_code,omitempty"`
+}
+
+// CreateOrderItem A line of a new order, the product is identified by id, SKU or name
+type CreateOrderItem struct {
+	ProductId   uint    `json:"product_id"`
+	ProductName *string `json:"product_name,omitempty"`
+	Sku         string  `json:"sku,omitempty"`
+	Quantity    int     `json:"quantity,omitempty"`
 }
 
 type PaymentCallBackRequest struct {
@@ -32,11 +108,91 @@ type PaymentCallBackRequest struct {
 	PaymentDetail model.Payment `json:"payment_detail"`
 }
 
+// RefundRequest Refund a part of the payment of an order, in the order currency.
+// The refund id is the idempotency key, the payment API refunds once however often a refund is requested.
+type RefundRequest struct {
+	RefundId uint        `json:"refund_id"`
+	OrderId  uint        `json:"order_id"`
+	Amount   model.Money `json:"amount"`
+	Currency string      `json:"currency"`
+	Reason   string      `json:"reason"`
+}
+
 func (ctx *HandlerContext) InitialHandlerContext(db *dal.Query, paymentMethod PaymentMethod, paymentMQUrl string) {
 	ctx.db = db
 	ctx.paymentMethod = paymentMethod
-	ctx.orderChan = make(chan *model.Order, 10000)
-	ctx.PaymentMQUrl = paymentMQUrl
+	ctx.refundMethod = ctx.CallRefundApi
+	ctx.captureMethod = ctx.CallCaptureApi
+	ctx.voidMethod = ctx.CallVoidApi
+	ctx.orderChan = make(chan *orderEvent, 10000)
+	ctx.limiter = util.NewLimiter(0)
+	ctx.Worker = health.NewWorker("order_executor")
+	ctx.rates.Store(currency.DefaultRateTable())
+	ctx.taxRules.Store(&tax.RuleTable{})
+	ctx.fulfillment = fulfillment.ManualProvider{}
+	ctx.notifier = notification.LogNotifier{}
+	ctx.ApplySettings(Settings{
+		PaymentMQUrl:          paymentMQUrl,
+		PaymentApiRetryCount:  1,
+		FulfillmentRetryCount: 1,
+		ReservationTtl:        15 * time.Minute,
+		CartTtl:               24 * time.Hour,
+	})
+}
+
+// ApplySettings Swap runtime settings, takes effect on the next executed order
+func (ctx *HandlerContext) ApplySettings(settings Settings) {
+	ctx.settings.Store(&settings)
+	ctx.limiter.SetLimit(settings.WorkerConcurrency)
+}
+
+// ApplyConfig Apply runtime settings from (reloaded) server config
+func (ctx *HandlerContext) ApplyConfig(c *util.ServerConfig) {
+	ctx.ApplySettings(Settings{
+		PaymentMQUrl:          c.Payment_message_queue_url,
+		PaymentRefundUrl:      c.Payment_refund_url,
+		PaymentCaptureUrl:     c.Payment_capture_url,
+		PaymentVoidUrl:        c.Payment_void_url,
+		PaymentApiRetryCount:  c.Runtime.PaymentApiRetryCount,
+		RetryBackoff:          time.Duration(c.Runtime.RetryBackoffMs) * time.Millisecond,
+		RetryMaxBackoff:       time.Duration(c.Runtime.RetryMaxBackoffMs) * time.Millisecond,
+		FulfillmentRetryCount: c.Runtime.FulfillmentRetryCount,
+		ReservationTtl:        time.Duration(c.Runtime.ReservationTtlMinutes) * time.Minute,
+		CartTtl:               time.Duration(c.Runtime.CartTtlMinutes) * time.Minute,
+		WorkerConcurrency:     c.Runtime.OrderWorkerConcurrency,
+	})
+}
+
+func (ctx *HandlerContext) getSettings() *Settings {
+	return ctx.settings.Load()
+}
+
+// SetRateTable Swap exchange rates, takes effect on the next created order
+func (ctx *HandlerContext) SetRateTable(rates *currency.RateTable) {
+	ctx.rates.Store(rates)
+}
+
+// SetTaxRules Swap tax rules, takes effect on the next created order
+func (ctx *HandlerContext) SetTaxRules(rules *tax.RuleTable) {
+	ctx.taxRules.Store(rules)
+}
+
+// SetNotifier Set the hook notifying customers, defaults to logging notifications
+func (ctx *HandlerContext) SetNotifier(notifier notification.Notifier) {
+	ctx.notifier = notifier
+}
+
+// Notify the customer of an order, failures are logged and don't affect the order
+func (ctx *HandlerContext) notify(c context.Context, event notification.Event) {
+	event.Time = time.Now()
+	if err := ctx.notifier.Notify(c, event); err != nil {
+		util.GetLogger(c).Error("Notify customer failed: "+err.Error(), "order_id", event.OrderId, "type", event.Type)
+	}
+}
+
+// GetPendingOrderCount Number of orders waiting to be executed
+func (ctx *HandlerContext) GetPendingOrderCount() int {
+	return len(ctx.orderChan)
 }
 
 // CreateOrder Create a new Order
@@ -54,15 +210,55 @@ func (ctx *HandlerContext) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//Validate payload
-	if req.CustomerId == 0 {
-		http.Error(w, "CustomerId is required", http.StatusBadRequest)
+	if req.CustomerId == 0 && req.CustomerEmail == "" && isBlank(req.CustomerName) {
+		http.Error(w, "CustomerId, CustomerEmail or CustomerName is required", http.StatusBadRequest)
 		return
 	}
-	if req.ProductId == 0 {
-		http.Error(w, "ProductId is required", http.StatusBadRequest)
+	items := req.orderItems()
+	for i := range items {
+		if items[i].ProductId == 0 && items[i].Sku == "" && isBlank(items[i].ProductName) {
+			http.Error(w, "ProductId, Sku or ProductName is required", http.StatusBadRequest)
+			return
+		}
+		if items[i].Quantity == 0 {
+			items[i].Quantity = 1
+		}
+		if items[i].Quantity < 0 || (items[i].Quantity > 1 && items[i].Sku == "") {
+			http.Error(w, constants.INVALID_QUANTITY+": only SKUs can be ordered in quantity", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Currency != "" && !ctx.rates.Load().Supports(req.Currency) {
+		http.Error(w, constants.UNSUPPORTED_CURRENCY+": "+req.Currency, http.StatusBadRequest)
 		return
 	}
 
+	newOrder, err := ctx.placeOrder(r.Context(), &req, items, nil)
+	if errors.Is(err, errCustomerNotFound) || errors.Is(err, errAddressNotFound) || errors.Is(err, errProductNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errCustomerAmbiguous) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(newOrder)
+	w.Write(respBody)
+}
+
+// Create the order of the items in one transaction and queue it for payment. The hook runs in the transaction after
+// the order was created, e.g. to check out a cart with the order.
+func (ctx *HandlerContext) placeOrder(c context.Context, req *CreateOrderRequest, items []CreateOrderItem, hook func(tx *dal.Query, order *model.Order) error) (*model.Order, error) {
+	rates := ctx.rates.Load()
+	taxRules := ctx.taxRules.Load()
+
 	// Save to DB
 	newOrder := model.Order{
 		CustomerId: req.CustomerId,
@@ -71,41 +267,312 @@ func (ctx *HandlerContext) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 	errDb := ctx.db.Transaction(func(tx *dal.Query) error {
 		// Check customer existence
-		customer, errCustomer := tx.Customer.Where(tx.Customer.ID.Eq(req.CustomerId)).First()
-		if errCustomer != nil || customer == nil {
-			return errors.New(constants.CUSTOMER_NOT_FOUND)
+		customerInfo, errTx := resolveCustomer(c, tx, req)
+		if errTx != nil {
+			return errTx
 		}
+		newOrder.CustomerId = customerInfo.ID
 
-		// Update Product
-		updatedProducts := make([]model.Product, 0)
-		result, errTx := tx.Product.Returning(&updatedProducts, "price").Where(tx.Product.ID.Eq(req.ProductId), tx.Product.IsAvailable.Is(true)).Update(tx.Product.IsAvailable, false)
-		if errTx != nil || result.RowsAffected == 0 || len(updatedProducts) == 0 {
-			return errors.New(constants.PRODUCT_NOT_AVAILABLE)
+		// Snapshot the shipping and billing address
+		addresses, errTx := tx.Address.WithContext(c).Where(tx.Address.CustomerId.Eq(customerInfo.ID)).Find()
+		if errTx != nil {
+			return errTx
+		}
+		shipping, billing, errTx := customer.DefaultAddresses(addresses, req.ShippingAddressId, req.BillingAddressId)
+		if errTx != nil {
+			return errAddressNotFound
+		}
+		if shipping != nil {
+			newOrder.ShippingAddress = shipping.Snapshot()
+		}
+		if billing != nil {
+			newOrder.BillingAddress = billing.Snapshot()
+		}
+
+		// Take the products, the ordered quantity of the stock when ordering a SKU
+		lines := make([]*model.OrderLine, 0, len(items))
+		for _, item := range items {
+			if item.ProductId == 0 && !isBlank(item.ProductName) {
+				if item.ProductId, errTx = resolveProductId(c, tx, *item.ProductName); errTx != nil {
+					return errTx
+				}
+			}
+			product, variant, backordered, errTx := ctx.takeOrBackorder(c, tx, item.ProductId, item.Sku, item.Quantity)
+			if errTx != nil {
+				return errTx
+			}
+			line := &model.OrderLine{ProductId: product.ID, Quantity: item.Quantity, Backordered: backordered}
+			newOrder.Backordered = newOrder.Backordered || backordered
+			if variant != nil {
+				line.VariantId = &variant.ID
+				line.Sku = &variant.Sku
+			}
+
+			// Price in the order currency, with the exchange rate snapshot
+			errTx = ctx.priceLine(c, tx, rates, product, variant, req.Currency, &newOrder, line)
+			if errTx != nil {
+				return errTx
+			}
+			newOrder.Subtotal = newOrder.Subtotal.Add(line.Amount)
+			lines = append(lines, line)
+		}
+		newOrder.Amount = newOrder.Subtotal
+		newOrder.ProductId = lines[0].ProductId
+		newOrder.VariantId = lines[0].VariantId
+		newOrder.Sku = lines[0].Sku
+
+		// Redeem the coupon, its discount is allocated to the eligible lines
+		var discount *model.OrderDiscount
+		if req.CouponCode != "" {
+			if discount, errTx = promotion.Apply(c, tx, rates, req.CouponCode, &newOrder, lines, time.Now()); errTx != nil {
+				return errTx
+			}
+		}
+
+		// Tax the discounted lines by the jurisdiction of the shipping address
+		taxes, errTx := tax.Apply(c, tx, taxRules, &newOrder, lines)
+		if errTx != nil {
+			return errTx
 		}
-		newOrder.Amount = updatedProducts[0].Price
 
 		// Create new order
-		errTx = tx.Order.Create(&newOrder)
+		errTx = tx.Order.WithContext(c).Create(&newOrder)
 		if errTx != nil {
 			errInfo := constants.CREATE_ORDER_FAILED + ": " + errTx.Error()
 			return errors.New(errInfo)
 		}
+		for _, line := range lines {
+			line.OrderId = newOrder.ID
+		}
+		errTx = tx.OrderLine.WithContext(c).Create(lines...)
+		if errTx != nil {
+			return errors.New(constants.CREATE_ORDER_FAILED + ": " + errTx.Error())
+		}
+		for _, line := range lines {
+			newOrder.Lines = append(newOrder.Lines, *line)
+		}
+		// Hold the taken stock until the order is paid, backordered lines took none
+		if _, errTx = reservation.Reserve(c, tx, newOrder.ID, lines, time.Now().Add(ctx.getSettings().ReservationTtl)); errTx != nil {
+			return errors.New(constants.CREATE_ORDER_FAILED + ": " + errTx.Error())
+		}
+		if discount != nil {
+			discount.OrderId = newOrder.ID
+			if errTx = tx.OrderDiscount.WithContext(c).Create(discount); errTx != nil {
+				return errors.New(constants.CREATE_ORDER_FAILED + ": " + errTx.Error())
+			}
+			newOrder.Discounts = append(newOrder.Discounts, *discount)
+		}
+		if len(taxes) > 0 {
+			for _, orderTax := range taxes {
+				orderTax.OrderId = newOrder.ID
+			}
+			if errTx = tx.OrderTax.WithContext(c).Create(taxes...); errTx != nil {
+				return errors.New(constants.CREATE_ORDER_FAILED + ": " + errTx.Error())
+			}
+			for _, orderTax := range taxes {
+				newOrder.Taxes = append(newOrder.Taxes, *orderTax)
+			}
+		}
+		if hook != nil {
+			return hook(tx, &newOrder)
+		}
 		return nil
 	})
 
 	if errDb != nil {
-		http.Error(w, errDb.Error(), http.StatusInternalServerError)
-		return
+		return nil, errDb
 	}
 
 	// write to order chan
-	rlog.Infof("Order was created as state %d(%s)", ORDER_STATE_CREATED, stateCodeToString(ORDER_STATE_CREATED))
-	ctx.orderChan <- &newOrder
+	util.GetLogger(c).Info("Order was created",
+		"order_id", newOrder.ID,
+		"amount", newOrder.Amount,
+		"discount", newOrder.DiscountAmount,
+		"tax", newOrder.TaxAmount,
+		"currency", newOrder.Currency,
+		"backordered", newOrder.Backordered,
+		"state", stateCodeToString(ORDER_STATE_CREATED))
+	ctx.orderChan <- &orderEvent{ctx: context.WithoutCancel(c), order: &newOrder}
+	return &newOrder, nil
+}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	respBody, _ := json.Marshal(newOrder)
-	w.Write(respBody)
+// Find the ordering customer by id, email or name. A name shared by several customers is ambiguous.
+func resolveCustomer(c context.Context, tx *dal.Query, req *CreateOrderRequest) (*model.Customer, error) {
+	customerTable := tx.Customer
+	if req.CustomerId != 0 {
+		customerInfo, err := customerTable.WithContext(c).Where(customerTable.ID.Eq(req.CustomerId)).First()
+		if err != nil {
+			return nil, errCustomerNotFound
+		}
+		return customerInfo, nil
+	}
+	if req.CustomerEmail != "" {
+		email := strings.ToLower(strings.TrimSpace(req.CustomerEmail))
+		customerInfo, err := customerTable.WithContext(c).Where(customerTable.Email.Eq(email)).First()
+		if err != nil {
+			return nil, fmt.Errorf("%w: no customer with email %q", errCustomerNotFound, email)
+		}
+		return customerInfo, nil
+	}
+	name := strings.TrimSpace(*req.CustomerName)
+	customers, err := customerTable.WithContext(c).Where(customerTable.Name.Eq(name)).Order(customerTable.ID).Limit(2).Find()
+	if err != nil {
+		return nil, err
+	}
+	if len(customers) == 0 {
+		return nil, fmt.Errorf("%w: no customer named %q", errCustomerNotFound, name)
+	}
+	if len(customers) > 1 {
+		return nil, fmt.Errorf("%w: several customers are named %q, use customer_id or customer_email", errCustomerAmbiguous, name)
+	}
+	return customers[0], nil
+}
+
+// Find the id of a product by its unique name
+func resolveProductId(c context.Context, tx *dal.Query, name string) (uint, error) {
+	name = strings.TrimSpace(name)
+	product, err := tx.Product.WithContext(c).Select(tx.Product.ID).Where(tx.Product.Name.Eq(name)).First()
+	if err != nil {
+		return 0, fmt.Errorf("%w: no product named %q", errProductNotFound, name)
+	}
+	return product.ID, nil
+}
+
+// Items of the request, the product fields are the only item when Items is empty
+func (req *CreateOrderRequest) orderItems() []CreateOrderItem {
+	if len(req.Items) > 0 {
+		return req.Items
+	}
+	return []CreateOrderItem{{
+		ProductId:   req.ProductId,
+		ProductName: req.ProductName,
+		Sku:         req.Sku,
+		Quantity:    req.Quantity,
+	}}
+}
+
+func isBlank(s *string) bool {
+	return s == nil || strings.TrimSpace(*s) == ""
+}
+
+// Take the ordered product out of sale.
+// A product without variants is made unavailable, otherwise the stock of the SKU is decremented by quantity and the product stays available.
+// Nothing was taken when it fails with errProductSoldOut or errOutOfStock.
+func (ctx *HandlerContext) takeProduct(c context.Context, tx *dal.Query, productId uint, sku string, quantity int) (*model.Product, *model.Variant, error) {
+	if sku == "" {
+		variantCount, err := tx.Variant.WithContext(c).Where(tx.Variant.ProductId.Eq(productId)).Count()
+		if err != nil {
+			return nil, nil, err
+		}
+		if variantCount > 0 {
+			return nil, nil, errors.New(constants.SKU_REQUIRED)
+		}
+		updatedProducts := make([]model.Product, 0)
+		result, err := tx.Product.WithContext(c).Returning(&updatedProducts, "price", "currency").Where(tx.Product.ID.Eq(productId), tx.Product.IsAvailable.Is(true)).Update(tx.Product.IsAvailable, false)
+		if err != nil || result.RowsAffected == 0 || len(updatedProducts) == 0 {
+			return nil, nil, errProductSoldOut
+		}
+		updatedProducts[0].ID = productId
+		return &updatedProducts[0], nil, nil
+	}
+
+	updatedVariants := make([]model.Variant, 0)
+	result, err := tx.Variant.WithContext(c).Returning(&updatedVariants, "id", "product_id", "sku", "price").Where(tx.Variant.Sku.Eq(sku), tx.Variant.Stock.Gte(quantity)).UpdateSimple(tx.Variant.Stock.Sub(quantity))
+	if err != nil || result.RowsAffected == 0 || len(updatedVariants) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", errOutOfStock, sku)
+	}
+	variant := &updatedVariants[0]
+	if productId != 0 && productId != variant.ProductId {
+		return nil, nil, errors.New(constants.PRODUCT_NOT_AVAILABLE)
+	}
+	product, err := tx.Product.WithContext(c).Where(tx.Product.ID.Eq(variant.ProductId), tx.Product.IsAvailable.Is(true)).First()
+	if err != nil {
+		return nil, nil, errors.New(constants.PRODUCT_NOT_AVAILABLE)
+	}
+	return product, variant, nil
+}
+
+// Take the ordered product, or backorder it when nothing could be taken and the product allows backorders.
+// A backordered product takes no stock, it is taken when the backorder is filled.
+func (ctx *HandlerContext) takeOrBackorder(c context.Context, tx *dal.Query, productId uint, sku string, quantity int) (*model.Product, *model.Variant, bool, error) {
+	product, variant, err := ctx.takeProduct(c, tx, productId, sku, quantity)
+	if !errors.Is(err, errProductSoldOut) && !errors.Is(err, errOutOfStock) {
+		return product, variant, false, err
+	}
+	if sku != "" {
+		variants, errDb := tx.Variant.WithContext(c).Where(tx.Variant.Sku.Eq(sku)).Find()
+		if errDb != nil {
+			return nil, nil, false, errDb
+		}
+		if len(variants) == 0 || (productId != 0 && productId != variants[0].ProductId) {
+			return nil, nil, false, err
+		}
+		variant = variants[0]
+		productId = variant.ProductId
+	}
+	products, errDb := tx.Product.WithContext(c).Where(tx.Product.ID.Eq(productId), tx.Product.AllowBackorder.Is(true)).Find()
+	if errDb != nil {
+		return nil, nil, false, errDb
+	}
+	if len(products) == 0 {
+		return nil, nil, false, err
+	}
+	return products[0], variant, true, nil
+}
+
+// Set unit price and amount of an order line, the first line sets currency and exchange rate of the new order.
+// The price list of the product takes precedence, otherwise the product price is converted with exchange rates.
+// A variant with its own price is always converted, the price list is for the product price.
+func (ctx *HandlerContext) priceLine(c context.Context, tx *dal.Query, rates *currency.RateTable, product *model.Product, variant *model.Variant, orderCurrency string, newOrder *model.Order, line *model.OrderLine) error {
+	productCurrency := product.Currency
+	if productCurrency == "" {
+		productCurrency = currency.DEFAULT_CURRENCY
+	}
+	if newOrder.Currency == "" {
+		if orderCurrency == "" {
+			orderCurrency = productCurrency
+		}
+		rate, err := rates.Rate(orderCurrency)
+		if err != nil {
+			return errors.New(constants.UNSUPPORTED_CURRENCY + ": " + orderCurrency)
+		}
+		newOrder.Currency = orderCurrency
+		newOrder.ExchangeRate = rate
+	}
+	orderCurrency = newOrder.Currency
+	unitPrice, err := unitPrice(c, tx, rates, product, variant, productCurrency, orderCurrency)
+	if err != nil {
+		return err
+	}
+	line.UnitPrice = unitPrice
+	line.Amount = unitPrice.Mul(int64(line.Quantity))
+	return nil
+}
+
+// Price of one unit of a product or variant in the order currency
+func unitPrice(c context.Context, tx *dal.Query, rates *currency.RateTable, product *model.Product, variant *model.Variant, productCurrency string, orderCurrency string) (model.Money, error) {
+	price := product.Price
+	if variant != nil && variant.Price != nil {
+		price = *variant.Price
+	}
+	if orderCurrency == productCurrency {
+		return price, nil
+	}
+
+	if variant == nil || variant.Price == nil {
+		prices, err := tx.ProductPrice.WithContext(c).Where(tx.ProductPrice.ProductId.Eq(product.ID), tx.ProductPrice.Currency.Eq(orderCurrency)).Find()
+		if err != nil {
+			return 0, err
+		}
+		if len(prices) > 0 {
+			return prices[0].Price, nil
+		}
+	}
+	converted, err := rates.Convert(price, productCurrency, orderCurrency)
+	if err != nil {
+		return 0, errors.New(constants.UNSUPPORTED_CURRENCY + ": " + productCurrency)
+	}
+	return converted, nil
 }
 
 // Fetch order detail by order id
@@ -128,12 +595,48 @@ func (ctx *HandlerContext) QueryOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	orderDetail, errDB := ctx.db.Order.Where(ctx.db.Order.ID.Eq(req.ID)).First()
+	orderDetail, errDB := ctx.db.Order.WithContext(r.Context()).Where(ctx.db.Order.ID.Eq(req.ID)).First()
 	if errDB != nil {
-		rlog.Error(errDB.Error())
+		util.GetLogger(r.Context()).Error(errDB.Error(), "order_id", req.ID)
 		http.Error(w, errDB.Error(), http.StatusNotFound)
 		return
 	}
+	lines, errDB := ctx.db.OrderLine.WithContext(r.Context()).Where(ctx.db.OrderLine.OrderId.Eq(req.ID)).Order(ctx.db.OrderLine.ID).Find()
+	if errDB != nil {
+		util.GetLogger(r.Context()).Error(errDB.Error(), "order_id", req.ID)
+		http.Error(w, errDB.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, line := range lines {
+		orderDetail.Lines = append(orderDetail.Lines, *line)
+	}
+	discounts, errDB := ctx.db.OrderDiscount.WithContext(r.Context()).Where(ctx.db.OrderDiscount.OrderId.Eq(req.ID)).Order(ctx.db.OrderDiscount.ID).Find()
+	if errDB != nil {
+		util.GetLogger(r.Context()).Error(errDB.Error(), "order_id", req.ID)
+		http.Error(w, errDB.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, discount := range discounts {
+		orderDetail.Discounts = append(orderDetail.Discounts, *discount)
+	}
+	taxes, errDB := ctx.db.OrderTax.WithContext(r.Context()).Where(ctx.db.OrderTax.OrderId.Eq(req.ID)).Order(ctx.db.OrderTax.ID).Find()
+	if errDB != nil {
+		util.GetLogger(r.Context()).Error(errDB.Error(), "order_id", req.ID)
+		http.Error(w, errDB.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, orderTax := range taxes {
+		orderDetail.Taxes = append(orderDetail.Taxes, *orderTax)
+	}
+	shipments, errDB := ctx.db.Shipment.WithContext(r.Context()).Where(ctx.db.Shipment.OrderId.Eq(req.ID)).Order(ctx.db.Shipment.ID).Find()
+	if errDB != nil {
+		util.GetLogger(r.Context()).Error(errDB.Error(), "order_id", req.ID)
+		http.Error(w, errDB.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, shipment := range shipments {
+		orderDetail.Shipments = append(orderDetail.Shipments, *shipment)
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
 	respBody, _ := json.Marshal(*orderDetail)
@@ -151,26 +654,63 @@ func (ctx *HandlerContext) PaymentCallBack(w http.ResponseWriter, r *http.Reques
 	req := PaymentCallBackRequest{}
 	err := util.FetchReqObject(r, &req)
 	if err != nil {
-		rlog.Error(err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	logger := util.GetLogger(r.Context()).With("order_id", req.OrderId, "payment_id", req.PaymentDetail.ID)
 
 	// Fetch Order
-	orderInfo, errDB := ctx.db.Order.Where(ctx.db.Order.ID.Eq(req.OrderId)).First()
+	orderInfo, errDB := ctx.db.Order.WithContext(r.Context()).Where(ctx.db.Order.ID.Eq(req.OrderId)).First()
 	if errDB != nil || orderInfo == nil {
 		errInfo := "Order not found"
 		if errDB != nil {
 			errInfo = "Order not found: " + errDB.Error()
 		}
-		rlog.Error(errInfo)
+		logger.Error(errInfo)
 		http.Error(w, errInfo, http.StatusInternalServerError)
 		return
 	}
+	// A payment completing after the order was canceled is refunded
+	if orderInfo.State == ORDER_STATE_CANCELED && req.PaymentDetail.State == constants.PAYMENT_STATE_SUCCESS {
+		err = ctx.refundCanceledOrder(r.Context(), orderInfo)
+		if errors.Is(err, errRefundPending) {
+			// The refund is saved, it is requested again on restart
+			logger.Error(err.Error())
+			w.WriteHeader(http.StatusOK)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("Order was canceled, the refund of the payment is pending."))
+			return
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("Order was canceled, the payment was refunded."))
+		return
+	}
+
+	// A backorder payment authorized after the order was canceled is voided in the background
+	if orderInfo.State == ORDER_STATE_CANCELED && req.PaymentDetail.State == constants.PAYMENT_STATE_AUTHORIZED {
+		void := newPaymentAction(orderInfo.ID, constants.PAYMENT_ACTION_VOID)
+		if err = ctx.db.PaymentAction.WithContext(r.Context()).Create(void); err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx.voidInBackground(r.Context(), void)
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("Order was canceled, the payment authorization is voided."))
+		return
+	}
+
 	// Validate order state
 	if orderInfo.State != ORDER_STATE_AWAITPAYMENT {
 		errInfo := "Order is not AWAIT PAYMENT"
-		rlog.Error(errInfo)
+		logger.Error(errInfo)
 		http.Error(w, errInfo, http.StatusBadRequest)
 		return
 	}
@@ -181,20 +721,48 @@ func (ctx *HandlerContext) PaymentCallBack(w http.ResponseWriter, r *http.Reques
 		newOrderState = ORDER_STATE_FAILED
 		updOrderObj.FailReason = req.PaymentDetail.PaymentResult
 	}
+	if req.PaymentDetail.State == constants.PAYMENT_STATE_AUTHORIZED {
+		newOrderState = ORDER_STATE_BACKORDERED
+	}
 	updOrderObj.State = newOrderState
 
-	// update order state
-	result, errDB := ctx.db.Order.Where(ctx.db.Order.ID.Eq(req.OrderId)).Updates(updOrderObj)
-	if errDB != nil || result.RowsAffected == 0 {
-		errInfo := "Update order status fail"
+	// update order state, the stock reservations are sold with the payment or released when it failed, the coupon uses are given back then.
+	// The stock taken by a backorder is sold with the authorization.
+	released := 0
+	errDB = ctx.db.Transaction(func(tx *dal.Query) error {
+		result, errTx := tx.Order.WithContext(r.Context()).Where(tx.Order.ID.Eq(req.OrderId), tx.Order.State.Eq(ORDER_STATE_AWAITPAYMENT)).Updates(updOrderObj)
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("order is not AWAIT PAYMENT")
+		}
+		if newOrderState == ORDER_STATE_FAILED {
+			if _, errTx = promotion.Release(r.Context(), tx, req.OrderId, time.Now()); errTx != nil {
+				return errTx
+			}
+			released, errTx = reservation.Release(r.Context(), tx, req.OrderId, constants.RESERVATION_STATE_RELEASED)
+			return errTx
+		}
+		return reservation.Sell(r.Context(), tx, req.OrderId)
+	})
+	if errDB != nil {
+		errInfo := "Update order status fail: " + errDB.Error()
+		logger.Error(errInfo)
 		http.Error(w, errInfo, http.StatusInternalServerError)
 		return
 	}
 
 	// Write to order chan
 	orderInfo.State = newOrderState
-	rlog.Infof("Order %d state was seted to %d(%s)", orderInfo.ID, orderInfo.State, stateCodeToString(orderInfo.State))
-	ctx.orderChan <- orderInfo
+	logger.Info("Order state was updated", "state", stateCodeToString(orderInfo.State))
+	ctx.orderChan <- &orderEvent{ctx: context.WithoutCancel(r.Context()), order: orderInfo}
+	if newOrderState == ORDER_STATE_BACKORDERED {
+		ctx.notify(r.Context(), notification.Event{Type: notification.EVENT_ORDER_BACKORDERED, OrderId: orderInfo.ID, CustomerId: orderInfo.CustomerId})
+	}
+	if released > 0 {
+		ctx.refillBackorders(r.Context())
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
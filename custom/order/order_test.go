@@ -2,6 +2,7 @@ package order
 
 import (
 	"bytes"
+	"context"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
@@ -11,11 +12,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"order_system/constants"
+	"order_system/custom/currency"
+	"order_system/custom/fulfillment"
+	"order_system/custom/notification"
+	"order_system/custom/tax"
 	"order_system/custom/util"
 	"order_system/dal"
 	"order_system/model"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -23,25 +29,80 @@ var (
 		ID:         1,
 		CustomerId: 2,
 		ProductId:  3,
-		Amount:     100.00,
+		Amount:     model.NewMoney(100, 0),
 		State:      ORDER_STATE_CREATED,
 		FailReason: nil,
 	}
-	testCustomer = model.Customer{
-		ID:      1,
+	selectAddressesSQL      = `^SELECT \* FROM \"addresses\" WHERE \"addresses\"\.\"customer_id\" = \$1`
+	addressColumns          = []string{"id", "customer_id", "line1", "city", "postal_code", "country", "is_default_billing", "is_default_shipping"}
+	createLinesSQL          = `^INSERT INTO \"order_lines\" .+ VALUES .+`
+	createReservationsSQL   = `^INSERT INTO \"stock_reservations\" .+ VALUES .+`
+	selectLinesSQL          = `^SELECT \* FROM \"order_lines\" WHERE \"order_lines\"\.\"order_id\" = \$1`
+	lineColumns             = []string{"id", "order_id", "product_id", "quantity", "unit_price", "amount", "fulfilled_quantity", "canceled_quantity"}
+	selectShipmentsSQL      = `^SELECT \* FROM \"shipments\" WHERE \"shipments\"\.\"order_id\" = \$1`
+	updateLineSQL           = `^UPDATE \"order_lines\" SET \"fulfilled_quantity\"=\"order_lines\"\.\"fulfilled_quantity\"\+\$1,\"updated_at\"=\$2 WHERE \"order_lines\"\.\"id\" = \$3`
+	createShipmentSQL       = `^INSERT INTO \"shipments\" .+ VALUES .+`
+	createRefundSQL         = `^INSERT INTO \"refunds\" .+ VALUES .+`
+	createPaymentActionSQL  = `^INSERT INTO \"payment_actions\" .+ VALUES .+`
+	updatePaymentActionSQL  = `^UPDATE \"payment_actions\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"payment_actions\"\.\"id\" = \$3 AND \"payment_actions\"\.\"state\" = \$4`
+	failPaymentActionSQL    = `^UPDATE \"payment_actions\" SET \"state\"=\$1,\"result\"=\$2,\"updated_at\"=\$3 WHERE \"payment_actions\"\.\"id\" = \$4 AND \"payment_actions\"\.\"state\" = \$5`
+	completeRefundSQL       = `^UPDATE \"refunds\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"refunds\"\.\"id\" = \$3 AND \"refunds\"\.\"state\" = \$4`
+	deleteShipmentSQL       = `^DELETE FROM \"shipments\" WHERE \"shipments\"\.\"id\" = \$1`
+	updateFulfilledOrderSQL = `^UPDATE \"orders\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"orders\"\.\"id\" = \$3 AND \"orders\"\.\"state\" IN \(\$4,\$5\)`
+	shipmentColumns         = []string{"id", "order_id", "items", "state"}
+	selectDiscountsSQL      = `^SELECT \* FROM \"order_discounts\" WHERE \"order_discounts\"\.\"order_id\" = \$1`
+	couponColumns           = []string{"id", "code", "discount_type", "percent", "currency", "max_uses", "max_uses_per_customer", "used_count", "is_active", "product_ids", "category_ids"}
+	countVariantsSQL        = `^SELECT count\(\*\) FROM \"variants\" WHERE \"variants\"\.\"product_id\" = \$1`
+	testCustomer            = model.Customer{
+		ID:      2,
 		Name:    "Test Customer",
 		Email:   util.GetStringPtr("user@mail.com"),
 		Address: util.GetStringPtr("this is a test address"),
 	}
 )
 
-func mockPayment(order *model.Order) error {
-	if order.Amount > 1000 {
+func mockPayment(c context.Context, order *model.Order) error {
+	if order.Amount > model.NewMoney(1000, 0) {
 		return errors.New("exceed payment limit")
 	}
 	return nil
 }
 
+type failingProvider struct{}
+
+func (failingProvider) CreateShipment(c context.Context, order *model.Order, shipment *model.Shipment) error {
+	return errors.New("provider unavailable")
+}
+
+// Fails the shipment after the order is no longer processed, e.g. on shutdown
+type cancelingProvider struct {
+	cancel context.CancelFunc
+}
+
+func (p cancelingProvider) CreateShipment(c context.Context, order *model.Order, shipment *model.Shipment) error {
+	p.cancel()
+	return errors.New("provider unavailable")
+}
+
+// Records notifications instead of sending them
+type recordingNotifier struct {
+	events []notification.Event
+}
+
+func (n *recordingNotifier) Notify(c context.Context, event notification.Event) error {
+	event.Time = time.Time{}
+	n.events = append(n.events, event)
+	return nil
+}
+
+// Ships the first item only
+type firstItemProvider struct{}
+
+func (firstItemProvider) CreateShipment(c context.Context, order *model.Order, shipment *model.Shipment) error {
+	shipment.Items = shipment.Items[:1]
+	return nil
+}
+
 func TestCallPaymentSuccess(t *testing.T) {
 	db, _, mock := util.DbMock(t)
 	defer db.Close()
@@ -53,13 +114,13 @@ func TestCallPaymentSuccess(t *testing.T) {
 		CustomerId: 1,
 		ProductId:  1,
 		State:      ORDER_STATE_CREATED,
-		Amount:     1000,
+		Amount:     model.NewMoney(1000, 0),
 	}
 	updOrderSQL := "UPDATE \"orders\" SET .+"
 	mock.ExpectBegin()
 	mock.ExpectExec(updOrderSQL).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	err := orderCtx.makePayment(&testOrder)
+	err := orderCtx.makePayment(context.Background(), &testOrder)
 
 	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.Nil(t, err)
@@ -77,30 +138,318 @@ func TestCallPaymentFail(t *testing.T) {
 		CustomerId: 1,
 		ProductId:  1,
 		State:      ORDER_STATE_CREATED,
-		Amount:     1001,
+		Amount:     model.NewMoney(1001, 0),
 	}
-	orderCtx.makePayment(&testOrder)
+	orderCtx.makePayment(context.Background(), &testOrder)
 
 	assert.Nil(t, mock.ExpectationsWereMet())
 	//assert.Error(t, err)
 }
 
-func TestFulfillOrder(t *testing.T) {
+func TestFulfillOrderManual(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	// Nothing is shipped, warehouses post the shipments
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(createShipmentSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(deleteShipmentSQL).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	newOrder := testOrder
+	newOrder.State = ORDER_STATE_PAID
+	handlerCtx.fulfillOrder(context.Background(), &newOrder)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// A shipment was handed over before a crash but its shipped items weren't recorded, it isn't handed over again
+func TestFulfillOrderShipmentInFlight(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetFulfillmentProvider(fulfillment.NewSimulator(0, 0, nil))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).
+			AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0).
+			AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 0, 0))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "items", "state"}).
+			AddRow(4, testOrder.ID, `[{"line_id":11,"product_id":3,"quantity":1},{"line_id":12,"product_id":4,"quantity":2}]`, constants.SHIPMENT_STATE_CREATED))
+	mock.ExpectRollback()
+
+	newOrder := testOrder
+	newOrder.State = ORDER_STATE_PAID
+	result := handlerCtx.fulfillOrder(context.Background(), &newOrder)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Nil(t, result.err)
+	assert.Nil(t, result.shipment)
+	assert.Equal(t, ORDER_STATE_PAID, result.state)
+}
+
+func TestFulfillOrderSimulator(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetFulfillmentProvider(fulfillment.NewSimulator(0, 0, nil))
+
+	// The shipment is saved before it is handed over, the shipped items are recorded afterwards
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(createShipmentSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0))
+	mock.ExpectExec(`^UPDATE \"shipments\" SET \"carrier\"=\$1,\"tracking_number\"=\$2,\"items\"=\$3,\"updated_at\"=\$4 WHERE \"shipments\"\.\"id\" = \$5`).
+		WithArgs(fulfillment.SIMULATOR_CARRIER, sqlmock.AnyArg(), `[{"line_id":11,"product_id":3,"quantity":1}]`, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(updateLineSQL).WithArgs(1, sqlmock.AnyArg(), 11, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(updateFulfilledOrderSQL).
+		WithArgs(ORDER_STATE_FULFILLED, sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_PAID, ORDER_STATE_PARTIALLY_FULFILLED).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	newOrder := testOrder
+	newOrder.State = ORDER_STATE_PAID
+	handlerCtx.fulfillOrder(context.Background(), &newOrder)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestFulfillOrderPartially(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetFulfillmentProvider(firstItemProvider{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).
+			AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0).
+			AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 0, 0))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(createShipmentSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).
+			AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0).
+			AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 0, 0))
+	mock.ExpectExec(`^UPDATE \"shipments\" SET \"items\"=\$1,\"updated_at\"=\$2 WHERE \"shipments\"\.\"id\" = \$3`).
+		WithArgs(`[{"line_id":11,"product_id":3,"quantity":1}]`, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(updateLineSQL).WithArgs(1, sqlmock.AnyArg(), 11, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(updateFulfilledOrderSQL).
+		WithArgs(ORDER_STATE_PARTIALLY_FULFILLED, sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_PAID, ORDER_STATE_PARTIALLY_FULFILLED).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	newOrder := testOrder
+	newOrder.State = ORDER_STATE_PAID
+	handlerCtx.fulfillOrder(context.Background(), &newOrder)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestFulfillOrderNothingRemaining(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetFulfillmentProvider(failingProvider{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 1, 0))
+	mock.ExpectRollback()
+
+	newOrder := testOrder
+	newOrder.State = ORDER_STATE_PARTIALLY_FULFILLED
+	handlerCtx.fulfillOrder(context.Background(), &newOrder)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestFulfillOrderProviderFail(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetFulfillmentProvider(failingProvider{})
+	var refund RefundRequest
+	handlerCtx.SetRefundMethod(func(c context.Context, req RefundRequest) error {
+		refund = req
+		return nil
+	})
+	notifier := &recordingNotifier{}
+	handlerCtx.SetNotifier(notifier)
+
+	// The shipment is deleted and retried once
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+			WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0))
+		mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(createShipmentSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(deleteShipmentSQL).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	// Then the order lines are canceled and refunded
+	paidOrder := testOrder
+	paidOrder.State = ORDER_STATE_PAID
+	orderRows, _ := util.ObjectToRows(paidOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0))
+	mock.ExpectExec(`^UPDATE \"order_lines\" SET \"canceled_quantity\"=.+`).WithArgs(1, "100.00", sqlmock.AnyArg(), 11, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"refunded_amount\"=\$2,\"fail_reason\"=\$3,.+`).
+		WithArgs(ORDER_STATE_FULFILLMENT_FAILED, "100.00", "Fulfillment failed: provider unavailable", sqlmock.AnyArg(), testOrder.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createRefundSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(completeRefundSQL).WithArgs(constants.REFUND_STATE_COMPLETED, sqlmock.AnyArg(), 7, constants.REFUND_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	newOrder := testOrder
+	newOrder.State = ORDER_STATE_PAID
+	result := handlerCtx.fulfillOrder(context.Background(), &newOrder)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 2, result.attempts)
+	assert.Equal(t, ORDER_STATE_FULFILLMENT_FAILED, result.state)
+	assert.Equal(t, model.NewMoney(100, 0), refund.Amount)
+	amount := model.NewMoney(100, 0)
+	assert.Equal(t, []notification.Event{
+		{Type: notification.EVENT_ORDER_REFUNDED, OrderId: testOrder.ID, CustomerId: testOrder.CustomerId, Amount: &amount, Currency: testOrder.Currency, Reason: "Fulfillment failed: provider unavailable"},
+		{Type: notification.EVENT_ORDER_FULFILLMENT_FAILED, OrderId: testOrder.ID, CustomerId: testOrder.CustomerId, Reason: "Fulfillment failed: provider unavailable"},
+	}, notifier.events)
+}
+
+func TestFulfillOrderRetryBackoffCanceled(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	c, cancel := context.WithCancel(context.Background())
+	handlerCtx.SetFulfillmentProvider(cancelingProvider{cancel: cancel})
+	handlerCtx.ApplySettings(Settings{FulfillmentRetryCount: 3, RetryBackoff: time.Hour})
+
+	// The order stays PAID and isn't canceled while the retry is waited for
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(createShipmentSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(deleteShipmentSQL).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	newOrder := testOrder
+	newOrder.State = ORDER_STATE_PAID
+	result := handlerCtx.fulfillOrder(c, &newOrder)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, result.attempts)
+	assert.Equal(t, ORDER_STATE_PAID, result.state)
+	assert.Error(t, result.err)
+}
+
+func TestFulfillOrderNotRecorded(t *testing.T) {
 	sqlDB, _, mock := util.DbMock(t)
 	defer sqlDB.Close()
 	handlerCtx := HandlerContext{}
 	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetFulfillmentProvider(fulfillment.NewSimulator(0, 0, nil))
+	handlerCtx.ApplySettings(Settings{FulfillmentRetryCount: 3})
 
-	updateProductSQL := "UPDATE \"orders\" SET .+"
+	// The provider has the shipment, it is neither handed over again nor canceled
 	mock.ExpectBegin()
-	mock.ExpectExec(updateProductSQL).WithArgs(ORDER_STATE_FULFILLED, sqlmock.AnyArg(), testOrder.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(createShipmentSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
 	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
 
 	newOrder := testOrder
 	newOrder.State = ORDER_STATE_PAID
-	handlerCtx.fulfillOrder(&newOrder)
+	result := handlerCtx.fulfillOrder(context.Background(), &newOrder)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, result.attempts)
+	assert.Equal(t, ORDER_STATE_PAID, result.state)
+	assert.ErrorIs(t, result.err, errShipmentNotRecorded)
+}
+
+func TestFulfillOrderOutOfStock(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetFulfillmentProvider(fulfillment.NewSimulator(0, 0, nil).WithOutOfStock("TS-M-RED"))
+	handlerCtx.SetRefundMethod(func(c context.Context, req RefundRequest) error { return nil })
+
+	// Out of stock isn't retried, the SKU line is refunded and the order is partially fulfilled
+	skuLineColumns := append(lineColumns, "sku")
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(skuLineColumns).
+			AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 1, 0, nil).
+			AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 0, 0, "TS-M-RED"))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(createShipmentSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(deleteShipmentSQL).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	partialOrder := testOrder
+	partialOrder.State = ORDER_STATE_PARTIALLY_FULFILLED
+	orderRows, _ := util.ObjectToRows(partialOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(skuLineColumns).
+			AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 1, 0, nil).
+			AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 0, 0, "TS-M-RED"))
+	mock.ExpectExec(`^UPDATE \"order_lines\" SET \"canceled_quantity\"=.+`).WithArgs(2, "10.00", sqlmock.AnyArg(), 12, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"refunded_amount\"=\$2,\"updated_at\"=\$3 WHERE \"orders\"\.\"id\" = \$4`).
+		WithArgs(ORDER_STATE_FULFILLED, "10.00", sqlmock.AnyArg(), testOrder.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createRefundSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(completeRefundSQL).WithArgs(constants.REFUND_STATE_COMPLETED, sqlmock.AnyArg(), 7, constants.REFUND_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result := handlerCtx.fulfillOrder(context.Background(), &partialOrder)
 
 	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, result.attempts)
+	assert.ErrorIs(t, result.err, fulfillment.ErrOutOfStock)
+	assert.Equal(t, ORDER_STATE_FULFILLED, result.state)
 }
 
 func TestQueryOrderSuccess(t *testing.T) {
@@ -112,6 +461,14 @@ func TestQueryOrderSuccess(t *testing.T) {
 	returnData, _ := util.ObjectToRows(testOrder)
 	expectedSQL := `^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" \= .* .* LIMIT .*`
 	mock.ExpectQuery(expectedSQL).WithArgs(testOrder.ID, 1).WillReturnRows(returnData)
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 1, 0))
+	mock.ExpectQuery(selectDiscountsSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "coupon_id", "customer_id", "code", "amount"}))
+	mock.ExpectQuery(`^SELECT \* FROM \"order_taxes\" WHERE \"order_taxes\"\.\"order_id\" = \$1`).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "jurisdiction", "rate", "taxable_amount", "amount", "included"}))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(shipmentColumns).AddRow(5, testOrder.ID, `[{"product_id":3,"quantity":1}]`, constants.SHIPMENT_STATE_SHIPPED))
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1}`)))
@@ -120,9 +477,25 @@ func TestQueryOrderSuccess(t *testing.T) {
 	acutalResp := model.Order{}
 	json.Unmarshal(w.Body.Bytes(), &acutalResp)
 
+	expected := testOrder
+	expected.Lines = []model.OrderLine{{
+		ID:                11,
+		OrderId:           testOrder.ID,
+		ProductId:         testOrder.ProductId,
+		Quantity:          1,
+		UnitPrice:         model.NewMoney(100, 0),
+		Amount:            model.NewMoney(100, 0),
+		FulfilledQuantity: 1,
+	}}
+	expected.Shipments = []model.Shipment{{
+		ID:      5,
+		OrderId: testOrder.ID,
+		Items:   model.ShipmentItems{{ProductId: 3, Quantity: 1}},
+		State:   constants.SHIPMENT_STATE_SHIPPED,
+	}}
 	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.EqualValues(t, testOrder, acutalResp, "Unexpected result")
+	assert.EqualValues(t, expected, acutalResp, "Unexpected result")
 }
 
 func TestQueryOrderBadHttpMethod(t *testing.T) {
@@ -183,10 +556,14 @@ func TestCreatOrderSuccess(t *testing.T) {
 	customerRows, _ := util.ObjectToRows(testCustomer)
 	mock.ExpectBegin()
 	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery(updateProductSQL).
 		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
 		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(driver.Value(100.00)))
 	mock.ExpectQuery(creatSQL).WillReturnRows(orderRows)
+	mock.ExpectQuery(createLinesSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	w := httptest.NewRecorder()
@@ -283,7 +660,7 @@ func TestCreatOrderProductCustomerNotFound(t *testing.T) {
 	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
 	handlerCtx.CreateOrder(w, r)
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 	assert.Equal(t, constants.CUSTOMER_NOT_FOUND, strings.TrimSpace(w.Body.String()))
 }
 
@@ -300,9 +677,12 @@ func TestCreatOrderProductNotAvailable(t *testing.T) {
 	customerRows, _ := util.ObjectToRows(testCustomer)
 	mock.ExpectBegin()
 	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery(updateProductSQL).
 		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
 		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(selectBackorderProductSQL).WithArgs(testOrder.ProductId, true).WillReturnRows(sqlmock.NewRows(productColumns))
 	mock.ExpectQuery(creatSQL).WillReturnRows(orderRows)
 	mock.ExpectCommit()
 
@@ -331,6 +711,8 @@ func TestCreatOrderInsertFailure(t *testing.T) {
 	customerRows, _ := util.ObjectToRows(testCustomer)
 	mock.ExpectBegin()
 	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery(updateProductSQL).
 		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
 		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(driver.Value(100.00)))
@@ -348,3 +730,1780 @@ func TestCreatOrderInsertFailure(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.Regexp(t, constants.CREATE_ORDER_FAILED, strings.TrimSpace(w.Body.String()))
 }
+
+func TestCallPaymentApiPropagateRequestId(t *testing.T) {
+	requestId := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = r.Header.Get(util.REQUEST_ID_HEADER)
+	}))
+	defer server.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, server.URL)
+
+	c := util.ContextWithRequestId(context.Background(), "test-request-id")
+	err := handlerCtx.CallPaymentApi(c, &testOrder)
+	assert.Nil(t, err)
+	assert.Equal(t, "test-request-id", requestId)
+}
+
+func TestCallPaymentRetryCountFromSettings(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	calls := 0
+	failingPayment := func(c context.Context, order *model.Order) error {
+		calls++
+		return errors.New("payment api unavailable")
+	}
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, failingPayment, "")
+	handlerCtx.ApplySettings(Settings{PaymentApiRetryCount: 3})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"orders\" SET .+").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	err := handlerCtx.makePayment(context.Background(), &testOrder)
+
+	assert.Error(t, err)
+	assert.Equal(t, 4, calls)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCallPaymentRetryBackoffCanceled(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	calls := 0
+	failingPayment := func(c context.Context, order *model.Order) error {
+		calls++
+		return errors.New("payment api unavailable")
+	}
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, failingPayment, "")
+	handlerCtx.ApplySettings(Settings{PaymentApiRetryCount: 3, RetryBackoff: time.Hour})
+
+	// Retries aren't waited for once the order is no longer processed
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"orders\" SET .+").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := handlerCtx.makePayment(c, &testOrder)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestCreatOrderConvertCurrency(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetRateTable(&currency.RateTable{Base: "USD", Rates: map[string]float64{"USD": 1, "EUR": 0.92}})
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" \= .* .* LIMIT .*`
+	updateProductSQL := "UPDATE \"products\" SET .+"
+	selectPriceSQL := `^SELECT \* FROM \"product_prices\" WHERE .+`
+	creatSQL := "INSERT INTO \"orders\" .+ VALUES .+"
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(updateProductSQL).
+		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
+		WillReturnRows(sqlmock.NewRows([]string{"price", "currency"}).AddRow(driver.Value("100.00"), driver.Value("USD")))
+	mock.ExpectQuery(selectPriceSQL).WithArgs(testOrder.ProductId, "EUR").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(creatSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId: testOrder.CustomerId,
+		ProductId:  testOrder.ProductId,
+		Currency:   "EUR",
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "EUR", actualResp.Currency)
+	assert.Equal(t, 0.92, actualResp.ExchangeRate)
+	assert.Equal(t, model.NewMoney(92, 0), actualResp.Amount)
+}
+
+func TestCreatOrderPriceListTakesPrecedence(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetRateTable(&currency.RateTable{Base: "USD", Rates: map[string]float64{"USD": 1, "EUR": 0.92}})
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" \= .* .* LIMIT .*`
+	updateProductSQL := "UPDATE \"products\" SET .+"
+	selectPriceSQL := `^SELECT \* FROM \"product_prices\" WHERE .+`
+	creatSQL := "INSERT INTO \"orders\" .+ VALUES .+"
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(updateProductSQL).
+		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
+		WillReturnRows(sqlmock.NewRows([]string{"price", "currency"}).AddRow(driver.Value("100.00"), driver.Value("USD")))
+	mock.ExpectQuery(selectPriceSQL).WithArgs(testOrder.ProductId, "EUR").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "currency", "price"}).AddRow(testOrder.ProductId, "EUR", "89.99"))
+	mock.ExpectQuery(creatSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId: testOrder.CustomerId,
+		ProductId:  testOrder.ProductId,
+		Currency:   "EUR",
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.NewMoney(89, 99), actualResp.Amount)
+	assert.Equal(t, 0.92, actualResp.ExchangeRate)
+}
+
+func TestCreatOrderUnsupportedCurrency(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId: testOrder.CustomerId,
+		ProductId:  testOrder.ProductId,
+		Currency:   "XYZ",
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Regexp(t, constants.UNSUPPORTED_CURRENCY, w.Body.String())
+}
+
+func TestCreatOrderBySku(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" \= .* .* LIMIT .*`
+	updateVariantSQL := `^UPDATE \"variants\" SET \"stock\"=\"variants\"\.\"stock\"-\$1,\"updated_at\"=\$2 WHERE \"variants\"\.\"sku\" = \$3 AND \"variants\"\.\"stock\" >= \$4 RETURNING .+`
+	selectProductSQL := `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" = \$1 AND \"products\"\.\"is_available\" = \$2 .+ LIMIT .*`
+	creatSQL := "INSERT INTO \"orders\" .+ VALUES .+"
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(updateVariantSQL).WithArgs(1, sqlmock.AnyArg(), "TS-M-RED", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "sku", "price"}).AddRow(7, testOrder.ProductId, "TS-M-RED", "12.50"))
+	mock.ExpectQuery(selectProductSQL).WithArgs(testOrder.ProductId, true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "price", "currency", "is_available"}).AddRow(testOrder.ProductId, "10.00", "USD", true))
+	mock.ExpectQuery(creatSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId: testOrder.CustomerId,
+		Sku:        "TS-M-RED",
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testOrder.ProductId, actualResp.ProductId)
+	assert.Equal(t, uint(7), *actualResp.VariantId)
+	assert.Equal(t, "TS-M-RED", *actualResp.Sku)
+	assert.Equal(t, model.NewMoney(12, 50), actualResp.Amount)
+}
+
+func TestCreatOrderSkuOutOfStock(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" \= .* .* LIMIT .*`
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(`^UPDATE \"variants\" SET .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(selectSkuSQL).WithArgs("TS-M-RED").WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 4, "TS-M-RED", 0))
+	mock.ExpectQuery(selectBackorderProductSQL).WithArgs(4, true).WillReturnRows(sqlmock.NewRows(productColumns))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId: testOrder.CustomerId,
+		Sku:        "TS-M-RED",
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, constants.OUT_OF_STOCK+": TS-M-RED", strings.TrimSpace(w.Body.String()))
+}
+
+func TestCreatOrderSkuRequired(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" \= .* .* LIMIT .*`
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId: testOrder.CustomerId,
+		ProductId:  testOrder.ProductId,
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, constants.SKU_REQUIRED, strings.TrimSpace(w.Body.String()))
+}
+
+func TestCreatOrderSnapshotAddresses(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" \= .* .* LIMIT .*`
+	updateProductSQL := "UPDATE \"products\" SET .+"
+	creatSQL := "INSERT INTO \"orders\" .+ VALUES .+"
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns).
+		AddRow(4, testOrder.CustomerId, "1 Main St", "Springfield", "12345", "US", true, true).
+		AddRow(5, testOrder.CustomerId, "2 Side St", "Shelbyville", "54321", "US", false, false))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(updateProductSQL).
+		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(driver.Value("100.00")))
+	mock.ExpectQuery(creatSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	shippingAddressId := uint(5)
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId:        testOrder.CustomerId,
+		ProductId:         testOrder.ProductId,
+		ShippingAddressId: &shippingAddressId,
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(5), actualResp.ShippingAddress.AddressId)
+	assert.Equal(t, "Shelbyville", actualResp.ShippingAddress.City)
+	assert.Equal(t, uint(4), actualResp.BillingAddress.AddressId)
+}
+
+func TestCreatOrderAddressOfOtherCustomer(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" \= .* .* LIMIT .*`
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	billingAddressId := uint(9)
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId:       testOrder.CustomerId,
+		ProductId:        testOrder.ProductId,
+		BillingAddressId: &billingAddressId,
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, constants.ADDRESS_NOT_FOUND, strings.TrimSpace(w.Body.String()))
+}
+
+func TestCreatOrderByNames(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"name\" = \$1 AND \"customers\"\.\"deleted_at\" IS NULL ORDER BY \"customers\"\.\"id\" LIMIT \$2`
+	selectProductSQL := `^SELECT \"products\"\.\"id\" FROM \"products\" WHERE \"products\"\.\"name\" = \$1 .+ LIMIT .*`
+	updateProductSQL := "UPDATE \"products\" SET .+"
+	creatSQL := "INSERT INTO \"orders\" .+ VALUES .+"
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs("Test Customer", 2).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testCustomer.ID).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(selectProductSQL).WithArgs("Demo Product", 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testOrder.ProductId))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(updateProductSQL).
+		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(driver.Value("100.00")))
+	mock.ExpectQuery(creatSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerName: util.GetStringPtr(" Test Customer "),
+		ProductName:  util.GetStringPtr("Demo Product"),
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testCustomer.ID, actualResp.CustomerId)
+	assert.Equal(t, testOrder.ProductId, actualResp.ProductId)
+}
+
+func TestCreatOrderCustomerNameAmbiguous(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"name\" = \$1 .+`).WithArgs("Test Customer", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Test Customer").AddRow(5, "Test Customer"))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerName: util.GetStringPtr("Test Customer"),
+		ProductId:    testOrder.ProductId,
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), constants.CUSTOMER_AMBIGUOUS))
+}
+
+func TestCreatOrderProductNameNotFound(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1 .+`).WithArgs(testCustomer.ID, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testCustomer.ID).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(`^SELECT \"products\"\.\"id\" FROM \"products\" WHERE \"products\"\.\"name\" = \$1 .+`).WithArgs("Missing Product", 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerId:  testCustomer.ID,
+		ProductName: util.GetStringPtr("Missing Product"),
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, constants.PRODUCT_NOT_FOUND+`: no product named "Missing Product"`, strings.TrimSpace(w.Body.String()))
+}
+
+func TestCreatOrderCustomerEmailNotFound(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"email\" = \$1 .+`).WithArgs("nobody@mail.com", 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(CreateOrderRequest{
+		CustomerEmail: "Nobody@Mail.com",
+		Sku:           "TS-M-RED",
+	})
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, constants.CUSTOMER_NOT_FOUND+`: no customer with email "nobody@mail.com"`, strings.TrimSpace(w.Body.String()))
+}
+
+func TestUpdateShipmentDelivered(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	notifier := &recordingNotifier{}
+	handlerCtx.SetNotifier(notifier)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"shipments\" WHERE \"shipments\"\.\"id\" = \$1`).WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(shipmentColumns).AddRow(5, testOrder.ID, "[]", constants.SHIPMENT_STATE_CREATED))
+	mock.ExpectExec(`^UPDATE \"shipments\" SET \"state\"=\$1,\"shipped_at\"=\$2,\"delivered_at\"=\$3,\"tracking_number\"=\$4,\"updated_at\"=\$5 WHERE \"shipments\"\.\"id\" = \$6`).
+		WithArgs(constants.SHIPMENT_STATE_DELIVERED, sqlmock.AnyArg(), sqlmock.AnyArg(), "TRACK1", sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(shipmentColumns).
+			AddRow(4, testOrder.ID, "[]", constants.SHIPMENT_STATE_DELIVERED).
+			AddRow(5, testOrder.ID, "[]", constants.SHIPMENT_STATE_CREATED))
+	mock.ExpectQuery(updateFulfilledOrderSQL+` RETURNING "customer_id","state"`).
+		WithArgs(ORDER_STATE_DELIVERED, sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_FULFILLED, ORDER_STATE_SHIPPED).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "state"}).AddRow(testOrder.CustomerId, ORDER_STATE_DELIVERED))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"shipment_id":5,"state":"DELIVERED","tracking_number":"TRACK1"}`)))
+	handlerCtx.UpdateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []notification.Event{{Type: notification.EVENT_ORDER_DELIVERED, OrderId: testOrder.ID, CustomerId: testOrder.CustomerId, ShipmentId: 5}}, notifier.events)
+}
+
+func TestUpdateShipmentOthersPending(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	// The order is SHIPPED when all of its shipments are
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"shipments\" WHERE \"shipments\"\.\"id\" = \$1`).WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(shipmentColumns).AddRow(5, testOrder.ID, "[]", constants.SHIPMENT_STATE_CREATED))
+	mock.ExpectExec(`^UPDATE \"shipments\" SET .+`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(selectShipmentsSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(shipmentColumns).
+			AddRow(4, testOrder.ID, "[]", constants.SHIPMENT_STATE_CREATED).
+			AddRow(5, testOrder.ID, "[]", constants.SHIPMENT_STATE_CREATED))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"shipment_id":5,"state":"SHIPPED"}`)))
+	handlerCtx.UpdateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUpdateShipmentRepeated(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"shipments\" WHERE \"shipments\"\.\"id\" = \$1`).WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(shipmentColumns).AddRow(5, testOrder.ID, "[]", constants.SHIPMENT_STATE_SHIPPED))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"shipment_id":5,"state":"SHIPPED"}`)))
+	handlerCtx.UpdateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUpdateShipmentBackwards(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"shipments\" WHERE \"shipments\"\.\"id\" = \$1`).WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(shipmentColumns).AddRow(5, testOrder.ID, "[]", constants.SHIPMENT_STATE_DELIVERED))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"shipment_id":5,"state":"SHIPPED"}`)))
+	handlerCtx.UpdateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateShipmentNotFound(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"shipments\" WHERE \"shipments\"\.\"id\" = \$1`).WithArgs(5, 1).WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"shipment_id":5,"state":"SHIPPED"}`)))
+	handlerCtx.UpdateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateShipmentInvalidState(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"shipment_id":5,"state":"LOST"}`)))
+	handlerCtx.UpdateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreatOrderItems(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	selectCustomerSQL := `^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" \= .* .* LIMIT .*`
+	updateVariantSQL := `^UPDATE \"variants\" SET \"stock\"=\"variants\"\.\"stock\"-\$1,\"updated_at\"=\$2 WHERE \"variants\"\.\"sku\" = \$3 AND \"variants\"\.\"stock\" >= \$4 RETURNING .+`
+	selectProductSQL := `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" = \$1 AND \"products\"\.\"is_available\" = \$2 .+ LIMIT .*`
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectCustomerSQL).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("UPDATE \"products\" SET .+").
+		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
+		WillReturnRows(sqlmock.NewRows([]string{"price", "currency"}).AddRow("100.00", "USD"))
+	mock.ExpectQuery(updateVariantSQL).WithArgs(2, sqlmock.AnyArg(), "TS-M-RED", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "sku", "price"}).AddRow(7, 4, "TS-M-RED", "12.50"))
+	mock.ExpectQuery(selectProductSQL).WithArgs(4, true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "price", "currency", "is_available"}).AddRow(4, "10.00", "USD", true))
+	mock.ExpectQuery("INSERT INTO \"orders\" .+ VALUES .+").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).
+		WithArgs(1, testOrder.ProductId, nil, nil, 1, "100.00", "100.00", "0", "0", 0, 0, "0", false, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			1, 4, 7, "TS-M-RED", 2, "12.50", "25.00", "0", "0", 0, 0, "0", false, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11).AddRow(12))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(
+		`{"customer_id":2,"items":[{"product_id":3},{"sku":"TS-M-RED","quantity":2}]}`)))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.NewMoney(125, 0), actualResp.Amount)
+	assert.Equal(t, testOrder.ProductId, actualResp.ProductId)
+	assert.Len(t, actualResp.Lines, 2)
+	assert.Equal(t, uint(12), actualResp.Lines[1].ID)
+}
+
+func TestCreatOrderCoupon(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1`).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("UPDATE \"products\" SET .+").
+		WithArgs(false, sqlmock.AnyArg(), testOrder.ProductId, true).
+		WillReturnRows(sqlmock.NewRows([]string{"price", "currency"}).AddRow("100.00", "USD"))
+	mock.ExpectQuery(`^SELECT \* FROM \"coupons\" WHERE \"coupons\"\.\"code\" = \$1`).WithArgs("SAVE10", 1).
+		WillReturnRows(sqlmock.NewRows(couponColumns).AddRow(9, "SAVE10", "PERCENT", 10, "USD", 5, 1, 2, true, "[]", "[]"))
+	mock.ExpectExec(`^UPDATE \"coupons\" SET \"used_count\"=\"coupons\"\.\"used_count\"\+\$1,\"updated_at\"=\$2 WHERE \"coupons\"\.\"id\" = \$3 AND \"coupons\"\.\"used_count\" < \$4`).
+		WithArgs(1, sqlmock.AnyArg(), 9, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`^SELECT count\(\*\) FROM \"order_discounts\" WHERE \"order_discounts\"\.\"coupon_id\" = \$1 AND \"order_discounts\"\.\"customer_id\" = \$2 AND \"order_discounts\"\.\"voided_at\" IS NULL`).
+		WithArgs(9, testCustomer.ID).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("INSERT INTO \"orders\" .+ VALUES .+").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).
+		WithArgs(1, testOrder.ProductId, nil, nil, 1, "100.00", "100.00", "10.00", "0", 0, 0, "0", false, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`^INSERT INTO \"order_discounts\" .+ VALUES .+`).
+		WithArgs(1, 9, testCustomer.ID, "SAVE10", "10.00", sqlmock.AnyArg(), nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"product_id":3,"coupon_code":"save10"}`)))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.NewMoney(100, 0), actualResp.Subtotal)
+	assert.Equal(t, model.NewMoney(10, 0), actualResp.DiscountAmount)
+	assert.Equal(t, model.NewMoney(90, 0), actualResp.Amount)
+	assert.Len(t, actualResp.Discounts, 1)
+}
+
+func TestCreatOrderTax(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetTaxRules(&tax.RuleTable{Jurisdictions: []tax.Jurisdiction{{Country: "US", Region: "CA", Name: "California sales tax", Rate: 0.0725}}})
+
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1`).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).
+		WillReturnRows(sqlmock.NewRows(append(addressColumns, "region")).AddRow(5, testCustomer.ID, "1 Main St", "Los Angeles", "90001", "US", true, true, "CA"))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("UPDATE \"products\" SET .+").
+		WillReturnRows(sqlmock.NewRows([]string{"price", "currency"}).AddRow("100.00", "USD"))
+	mock.ExpectQuery("INSERT INTO \"orders\" .+ VALUES .+").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).
+		WithArgs(1, testOrder.ProductId, nil, nil, 1, "100.00", "100.00", "0", "7.25", 0, 0, "0", false, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`^INSERT INTO \"order_taxes\" .+ VALUES .+`).
+		WithArgs(1, "California sales tax", 0.0725, "100.00", "7.25", false, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"product_id":3}`)))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.NewMoney(7, 25), actualResp.TaxAmount)
+	assert.Equal(t, model.NewMoney(107, 25), actualResp.Amount)
+	assert.Len(t, actualResp.Taxes, 1)
+}
+
+func TestCreatOrderCouponLimitReached(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1`).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(countVariantsSQL).WithArgs(testOrder.ProductId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("UPDATE \"products\" SET .+").
+		WillReturnRows(sqlmock.NewRows([]string{"price", "currency"}).AddRow("100.00", "USD"))
+	mock.ExpectQuery(`^SELECT \* FROM \"coupons\" WHERE \"coupons\"\.\"code\" = \$1`).WithArgs("SAVE10", 1).
+		WillReturnRows(sqlmock.NewRows(couponColumns).AddRow(9, "SAVE10", "PERCENT", 10, "USD", 5, 1, 5, true, "[]", "[]"))
+	// Another order took the last use
+	mock.ExpectExec(`^UPDATE \"coupons\" SET .+`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"product_id":3,"coupon_code":"SAVE10"}`)))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), constants.COUPON_LIMIT_REACHED)
+}
+
+func TestCreatOrderQuantityWithoutSku(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"product_id":3,"quantity":2}`)))
+	handlerCtx.CreateOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), constants.INVALID_QUANTITY)
+}
+
+func TestCreateShipmentPartially(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	paidOrder := testOrder
+	paidOrder.State = ORDER_STATE_PAID
+	orderRows, _ := util.ObjectToRows(paidOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).
+			AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0).
+			AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 0, 0))
+	mock.ExpectQuery(`^INSERT INTO \"shipments\" .+ VALUES .+`).
+		WithArgs(testOrder.ID, "UPS", "1Z1", `[{"line_id":12,"product_id":4,"quantity":1}]`, constants.SHIPMENT_STATE_CREATED, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec(updateLineSQL).WithArgs(1, sqlmock.AnyArg(), 12, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(updateFulfilledOrderSQL).
+		WithArgs(ORDER_STATE_PARTIALLY_FULFILLED, sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_PAID, ORDER_STATE_PARTIALLY_FULFILLED).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(
+		`{"order_id":1,"items":[{"line_id":12,"quantity":1}],"carrier":"UPS","tracking_number":"1Z1"}`)))
+	handlerCtx.CreateShipment(w, r)
+
+	actualResp := model.Shipment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(5), actualResp.ID)
+}
+
+func TestCreateShipmentLocksLines(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	// The order and its lines are locked, and a line never goes beyond its quantity
+	paidOrder := testOrder
+	paidOrder.State = ORDER_STATE_PAID
+	orderRows, _ := util.ObjectToRows(paidOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1 ORDER BY \"orders\"\.\"id\" LIMIT \$2 FOR UPDATE`).
+		WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectQuery(selectLinesSQL + ` ORDER BY \"order_lines\"\.\"id\" FOR UPDATE`).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 0, 0))
+	mock.ExpectQuery(createShipmentSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec(updateLineSQL+` AND \"order_lines\"\.\"quantity\"-\$4 >= \"order_lines\"\.\"fulfilled_quantity\" \+ \"order_lines\"\.\"canceled_quantity\"`).
+		WithArgs(2, sqlmock.AnyArg(), 12, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"items":[{"line_id":12,"quantity":2}]}`)))
+	handlerCtx.CreateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateShipmentExceedsLine(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	paidOrder := testOrder
+	paidOrder.State = ORDER_STATE_PARTIALLY_FULFILLED
+	orderRows, _ := util.ObjectToRows(paidOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 1, 0))
+	mock.ExpectQuery(`^INSERT INTO \"shipments\" .+ VALUES .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"items":[{"line_id":12,"quantity":2}]}`)))
+	handlerCtx.CreateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), constants.INVALID_QUANTITY)
+}
+
+func TestCreateShipmentOrderNotPaid(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	orderRows, _ := util.ObjectToRows(testOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"items":[{"line_id":11,"quantity":1}]}`)))
+	handlerCtx.CreateShipment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), constants.ORDER_NOT_FULFILLABLE)
+}
+
+func TestCancelLinesRefund(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	var refund RefundRequest
+	handlerCtx.SetRefundMethod(func(c context.Context, req RefundRequest) error {
+		refund = req
+		return nil
+	})
+
+	partialOrder := testOrder
+	partialOrder.State = ORDER_STATE_PARTIALLY_FULFILLED
+	partialOrder.Currency = "EUR"
+	orderRows, _ := util.ObjectToRows(partialOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).
+			AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 1, 0).
+			AddRow(12, testOrder.ID, 4, 2, "5.00", "10.00", 0, 0))
+	mock.ExpectExec(`^UPDATE \"order_lines\" SET \"canceled_quantity\"=\"order_lines\"\.\"canceled_quantity\"\+\$1,\"refunded_amount\"=\$2,\"updated_at\"=\$3 WHERE \"order_lines\"\.\"id\" = \$4`).
+		WithArgs(2, "10.00", sqlmock.AnyArg(), 12, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"refunded_amount\"=\$2,\"updated_at\"=\$3 WHERE \"orders\"\.\"id\" = \$4`).
+		WithArgs(ORDER_STATE_FULFILLED, "10.00", sqlmock.AnyArg(), testOrder.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createRefundSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(completeRefundSQL).WithArgs(constants.REFUND_STATE_COMPLETED, sqlmock.AnyArg(), 7, constants.REFUND_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"items":[{"line_id":12,"quantity":2}]}`)))
+	handlerCtx.CancelLines(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ORDER_STATE_FULFILLED, actualResp.State)
+	assert.Equal(t, model.NewMoney(10, 0), actualResp.RefundedAmount)
+	assert.Equal(t, RefundRequest{RefundId: 7, OrderId: 1, Amount: model.NewMoney(10, 0), Currency: "EUR", Reason: "Order lines can't be fulfilled"}, refund)
+}
+
+func TestCancelLinesLastUnit(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	var refund RefundRequest
+	handlerCtx.SetRefundMethod(func(c context.Context, req RefundRequest) error {
+		refund = req
+		return nil
+	})
+
+	// 6.67 of the line was refunded for two units, the last unit refunds the rest of its 10.00
+	paidOrder := testOrder
+	paidOrder.State = ORDER_STATE_PAID
+	paidOrder.RefundedAmount = model.NewMoney(6, 67)
+	orderRows, _ := util.ObjectToRows(paidOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(append(lineColumns, "refunded_amount")).AddRow(12, testOrder.ID, 4, 3, "3.33", "10.00", 0, 2, "6.67"))
+	mock.ExpectExec(`^UPDATE \"order_lines\" SET \"canceled_quantity\"=.+`).WithArgs(1, "10.00", sqlmock.AnyArg(), 12, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"refunded_amount\"=\$2,\"fail_reason\"=\$3,.+`).
+		WithArgs(ORDER_STATE_FULFILLMENT_FAILED, "10.00", "Lost in warehouse", sqlmock.AnyArg(), testOrder.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createRefundSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(completeRefundSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"items":[{"line_id":12,"quantity":1}],"reason":"Lost in warehouse"}`)))
+	handlerCtx.CancelLines(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.NewMoney(3, 33), refund.Amount)
+}
+
+func TestCancelLinesRefundFail(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	calls := 0
+	handlerCtx.SetRefundMethod(func(c context.Context, req RefundRequest) error {
+		calls++
+		return errors.New("payment api unavailable")
+	})
+
+	// The lines are canceled with a pending refund, the payment API is called after commit
+	paidOrder := testOrder
+	paidOrder.State = ORDER_STATE_PAID
+	orderRows, _ := util.ObjectToRows(paidOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectQuery(selectLinesSQL).WithArgs(testOrder.ID).
+		WillReturnRows(sqlmock.NewRows(lineColumns).AddRow(11, testOrder.ID, testOrder.ProductId, 1, "100.00", "100.00", 0, 0))
+	mock.ExpectExec(`^UPDATE \"order_lines\" SET .+`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"refunded_amount\"=\$2,\"fail_reason\"=\$3,.+`).
+		WithArgs(ORDER_STATE_FULFILLMENT_FAILED, "100.00", "Lost in warehouse", sqlmock.AnyArg(), testOrder.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createRefundSQL).
+		WithArgs(testOrder.ID, testOrder.CustomerId, "100.00", "", "Lost in warehouse", constants.REFUND_STATE_PENDING, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"items":[{"line_id":11,"quantity":1}],"reason":"Lost in warehouse"}`)))
+	handlerCtx.CancelLines(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, ORDER_STATE_FULFILLMENT_FAILED, actualResp.State)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRetryRefunds(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	var refunds []RefundRequest
+	handlerCtx.SetRefundMethod(func(c context.Context, req RefundRequest) error {
+		refunds = append(refunds, req)
+		return nil
+	})
+
+	// Pending refunds are requested again with the same refund id
+	mock.ExpectQuery(`^SELECT \* FROM \"refunds\" WHERE \"refunds\"\.\"state\" = \$1 ORDER BY \"refunds\"\.\"id\"`).
+		WithArgs(constants.REFUND_STATE_PENDING).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "customer_id", "amount", "currency", "reason", "state"}).
+			AddRow(7, testOrder.ID, testOrder.CustomerId, "100.00", "USD", "Lost in warehouse", constants.REFUND_STATE_PENDING))
+	mock.ExpectBegin()
+	mock.ExpectExec(completeRefundSQL).WithArgs(constants.REFUND_STATE_COMPLETED, sqlmock.AnyArg(), 7, constants.REFUND_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	handlerCtx.retryRefunds(context.Background())
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []RefundRequest{{RefundId: 7, OrderId: 1, Amount: model.NewMoney(100, 0), Currency: "USD", Reason: "Lost in warehouse"}}, refunds)
+}
+
+var (
+	selectOpenCartSQL         = `^SELECT \* FROM \"carts\" WHERE \"carts\"\.\"customer_id\" = \$1 AND \"carts\"\.\"state\" = \$2`
+	selectCartItemsSQL        = `^SELECT \* FROM \"cart_items\" WHERE \"cart_items\"\.\"cart_id\" = \$1 ORDER BY \"cart_items\"\.\"id\"`
+	selectSkuSQL              = `^SELECT \* FROM \"variants\" WHERE \"variants\"\.\"sku\" = \$1`
+	selectProductOfItemSQL    = `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" = \$1 AND \"products\"\.\"deleted_at\" IS NULL$`
+	selectAvailableSQL        = `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" = \$1 AND \"products\"\.\"is_available\" = \$2`
+	selectBackorderProductSQL = `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" = \$1 AND \"products\"\.\"allow_backorder\" = \$2`
+	cartColumns               = []string{"id", "customer_id", "currency", "state", "expires_at"}
+	cartItemColumns           = []string{"id", "cart_id", "product_id", "variant_id", "sku", "quantity", "unit_price"}
+	variantColumns            = []string{"id", "product_id", "sku", "stock"}
+	productColumns            = []string{"id", "price", "currency", "is_available"}
+)
+
+func expectOpenCart(mock sqlmock.Sqlmock, expiresAt time.Time) {
+	mock.ExpectQuery(selectOpenCartSQL).WithArgs(testOrder.CustomerId, constants.CART_STATE_OPEN).
+		WillReturnRows(sqlmock.NewRows(cartColumns).AddRow(5, testOrder.CustomerId, "USD", constants.CART_STATE_OPEN, expiresAt))
+	mock.ExpectQuery(selectCartItemsSQL).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(cartItemColumns).AddRow(9, 5, 4, 7, "TS-M-RED", 2, "10.00"))
+}
+
+func TestAddCartItemNewCart(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectOpenCartSQL).WithArgs(testOrder.CustomerId, constants.CART_STATE_OPEN).WillReturnRows(sqlmock.NewRows(cartColumns))
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1`).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectSkuSQL).WithArgs("TS-M-RED").WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 4, "TS-M-RED", 5))
+	mock.ExpectQuery(selectProductOfItemSQL).WithArgs(4).WillReturnRows(sqlmock.NewRows(productColumns).AddRow(4, "10.00", "USD", true))
+	mock.ExpectQuery(`^INSERT INTO \"carts\" .+ VALUES .+`).
+		WithArgs(testOrder.CustomerId, "USD", constants.CART_STATE_OPEN, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`^INSERT INTO \"cart_items\" .+ VALUES .+`).
+		WithArgs(5, 4, 7, "TS-M-RED", 2, "10.00", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"sku":"TS-M-RED","quantity":2}`)))
+	handlerCtx.AddCartItem(w, r)
+
+	actualResp := model.Cart{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(5), actualResp.ID)
+	assert.Equal(t, "USD", actualResp.Currency)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), actualResp.ExpiresAt, time.Minute)
+	assert.Len(t, actualResp.Items, 1)
+	assert.Equal(t, model.NewMoney(10, 0), actualResp.Items[0].UnitPrice)
+}
+
+func TestAddCartItemAddsUpQuantity(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	expectOpenCart(mock, time.Now().Add(time.Hour))
+	mock.ExpectQuery(selectSkuSQL).WithArgs("TS-M-RED").WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 4, "TS-M-RED", 2))
+	mock.ExpectQuery(selectProductOfItemSQL).WithArgs(4).WillReturnRows(sqlmock.NewRows(productColumns).AddRow(4, "10.00", "USD", true))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"sku":"TS-M-RED"}`)))
+	handlerCtx.AddCartItem(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), constants.OUT_OF_STOCK)
+}
+
+func TestUpdateCartItemRemove(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	expectOpenCart(mock, time.Now().Add(time.Hour))
+	mock.ExpectExec(`^DELETE FROM \"cart_items\" WHERE \"cart_items\"\.\"id\" = \$1`).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"carts\" SET \"expires_at\"=\$1,\"updated_at\"=\$2 WHERE \"carts\"\.\"id\" = \$3`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"item_id":9,"quantity":0}`)))
+	handlerCtx.UpdateCartItem(w, r)
+
+	actualResp := model.Cart{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, actualResp.Items)
+}
+
+func TestUpdateCartItemNotFound(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	expectOpenCart(mock, time.Now().Add(time.Hour))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"item_id":8,"quantity":1}`)))
+	handlerCtx.UpdateCartItem(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestQueryCartPriceChanged(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	expectOpenCart(mock, time.Now().Add(time.Hour))
+	mock.ExpectQuery(selectSkuSQL).WithArgs("TS-M-RED").WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 4, "TS-M-RED", 5))
+	mock.ExpectQuery(selectProductOfItemSQL).WithArgs(4).WillReturnRows(sqlmock.NewRows(productColumns).AddRow(4, "12.00", "USD", true))
+	mock.ExpectExec(`^UPDATE \"cart_items\" SET \"unit_price\"=\$1,\"updated_at\"=\$2 WHERE \"cart_items\"\.\"id\" = \$3`).
+		WithArgs("12.00", sqlmock.AnyArg(), 9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2}`)))
+	handlerCtx.QueryCart(w, r)
+
+	actualResp := model.Cart{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, actualResp.Items[0].PriceChanged)
+	assert.Equal(t, model.NewMoney(12, 0), actualResp.Items[0].UnitPrice)
+}
+
+func TestQueryCartExpired(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectOpenCartSQL).WithArgs(testOrder.CustomerId, constants.CART_STATE_OPEN).
+		WillReturnRows(sqlmock.NewRows(cartColumns).AddRow(5, testOrder.CustomerId, "USD", constants.CART_STATE_OPEN, time.Now().Add(-time.Minute)))
+	mock.ExpectExec(`^UPDATE \"carts\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"carts\"\.\"id\" = \$3 AND \"carts\"\.\"state\" = \$4`).
+		WithArgs(constants.CART_STATE_EXPIRED, sqlmock.AnyArg(), 5, constants.CART_STATE_OPEN).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2}`)))
+	handlerCtx.QueryCart(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCheckoutCart(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	// Validate the cart
+	mock.ExpectBegin()
+	expectOpenCart(mock, time.Now().Add(time.Hour))
+	mock.ExpectQuery(selectSkuSQL).WithArgs("TS-M-RED").WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 4, "TS-M-RED", 5))
+	mock.ExpectQuery(selectProductOfItemSQL).WithArgs(4).WillReturnRows(sqlmock.NewRows(productColumns).AddRow(4, "10.00", "USD", true))
+	mock.ExpectCommit()
+
+	// Create the order and check out the cart
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1`).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(`^UPDATE \"variants\" SET .+ RETURNING .+`).WithArgs(2, sqlmock.AnyArg(), "TS-M-RED", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "sku", "price"}).AddRow(7, 4, "TS-M-RED", nil))
+	mock.ExpectQuery(selectAvailableSQL).WithArgs(4, true, 1).
+		WillReturnRows(sqlmock.NewRows(productColumns).AddRow(4, "10.00", "USD", true))
+	mock.ExpectQuery("INSERT INTO \"orders\" .+ VALUES .+").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).
+		WithArgs(1, 4, 7, "TS-M-RED", 2, "10.00", "20.00", "0", "0", 0, 0, "0", false, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`^UPDATE \"carts\" SET \"state\"=\$1,\"order_id\"=\$2,\"updated_at\"=\$3 WHERE \"carts\"\.\"id\" = \$4 AND \"carts\"\.\"state\" = \$5`).
+		WithArgs(constants.CART_STATE_CHECKED_OUT, 1, sqlmock.AnyArg(), 5, constants.CART_STATE_OPEN).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2}`)))
+	handlerCtx.CheckoutCart(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.NewMoney(20, 0), actualResp.Amount)
+	assert.Equal(t, "USD", actualResp.Currency)
+	assert.Equal(t, 1, handlerCtx.GetPendingOrderCount())
+}
+
+func TestCheckoutCartChanged(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	expectOpenCart(mock, time.Now().Add(time.Hour))
+	mock.ExpectQuery(selectSkuSQL).WithArgs("TS-M-RED").WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 4, "TS-M-RED", 1))
+	mock.ExpectQuery(selectProductOfItemSQL).WithArgs(4).WillReturnRows(sqlmock.NewRows(productColumns).AddRow(4, "10.00", "USD", true))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2}`)))
+	handlerCtx.CheckoutCart(w, r)
+
+	actualResp := model.Cart{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, constants.OUT_OF_STOCK+": TS-M-RED", actualResp.Items[0].Unavailable)
+	assert.Equal(t, 0, handlerCtx.GetPendingOrderCount())
+}
+
+func TestExpireCarts(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"carts\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"carts\"\.\"state\" = \$3 AND \"carts\"\.\"expires_at\" < \$4`).
+		WithArgs(constants.CART_STATE_EXPIRED, sqlmock.AnyArg(), constants.CART_STATE_OPEN, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	count, err := handlerCtx.ExpireCarts(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+var releaseDiscountsSQL = `^UPDATE \"order_discounts\" SET \"voided_at\"=\$1 WHERE \"order_discounts\"\.\"order_id\" = \$2 AND \"order_discounts\"\.\"voided_at\" IS NULL RETURNING \"coupon_id\"`
+var releaseReservationsSQL = `^UPDATE \"stock_reservations\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"stock_reservations\"\.\"order_id\" = \$3 AND \"stock_reservations\"\.\"state\" = \$4 RETURNING .+`
+
+func TestCancelOrder(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	notifier := &recordingNotifier{}
+	handlerCtx.SetNotifier(notifier)
+
+	awaitingOrder := testOrder
+	awaitingOrder.State = ORDER_STATE_AWAITPAYMENT
+	orderRows, _ := util.ObjectToRows(awaitingOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"fail_reason\"=\$2,\"updated_at\"=\$3 WHERE \"orders\"\.\"id\" = \$4 AND \"orders\"\.\"state\" IN \(\$5,\$6\)`).
+		WithArgs(ORDER_STATE_CANCELED, "Changed my mind", sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_CREATED, ORDER_STATE_AWAITPAYMENT).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(releaseDiscountsSQL).WithArgs(sqlmock.AnyArg(), testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"coupon_id"}))
+	mock.ExpectQuery(releaseReservationsSQL).WithArgs(constants.RESERVATION_STATE_RELEASED, sqlmock.AnyArg(), testOrder.ID, constants.RESERVATION_STATE_RESERVED).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"}).AddRow(4, 7, 2))
+	mock.ExpectExec(`^UPDATE \"variants\" SET \"stock\"=\"variants\"\.\"stock\"\+\$1,\"updated_at\"=\$2 WHERE \"variants\"\.\"id\" = \$3`).
+		WithArgs(2, sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"reason":"Changed my mind"}`)))
+	handlerCtx.CancelOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ORDER_STATE_CANCELED, actualResp.State)
+	assert.Equal(t, []notification.Event{{Type: notification.EVENT_ORDER_CANCELED, OrderId: 1, CustomerId: 2, Reason: "Changed my mind"}}, notifier.events)
+}
+
+// The stock sold to a canceled backorder is returned and its payment authorization voided
+func TestCancelOrderBackordered(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	voided := make(chan uint, 1)
+	handlerCtx.SetVoidMethod(func(c context.Context, orderId uint) error {
+		voided <- orderId
+		return nil
+	})
+
+	backorder := testOrder
+	backorder.State = ORDER_STATE_BACKORDERED
+	orderRows, _ := util.ObjectToRows(backorder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"fail_reason\"=\$2,\"updated_at\"=\$3 WHERE \"orders\"\.\"id\" = \$4 AND \"orders\"\.\"state\" = \$5`).
+		WithArgs(ORDER_STATE_CANCELED, "Changed my mind", sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_BACKORDERED).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(releaseDiscountsSQL).WithArgs(sqlmock.AnyArg(), testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"coupon_id"}))
+	mock.ExpectQuery(releaseReservationsSQL).WithArgs(constants.RESERVATION_STATE_RELEASED, sqlmock.AnyArg(), testOrder.ID, constants.RESERVATION_STATE_SOLD).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"}).AddRow(4, 7, 2))
+	mock.ExpectExec(`^UPDATE \"variants\" SET \"stock\"=\"variants\"\.\"stock\"\+\$1,\"updated_at\"=\$2 WHERE \"variants\"\.\"id\" = \$3`).
+		WithArgs(2, sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createPaymentActionSQL).WithArgs(testOrder.ID, constants.PAYMENT_ACTION_VOID, constants.PAYMENT_ACTION_STATE_PENDING, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectCommit()
+	// The void is made in the background
+	mock.ExpectBegin()
+	mock.ExpectExec(updatePaymentActionSQL).WithArgs(constants.PAYMENT_ACTION_STATE_COMPLETED, sqlmock.AnyArg(), 8, constants.PAYMENT_ACTION_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"reason":"Changed my mind"}`)))
+	handlerCtx.CancelOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ORDER_STATE_CANCELED, actualResp.State)
+	assert.Equal(t, uint(1), <-voided)
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+}
+
+func TestCancelOrderPaid(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	paidOrder := testOrder
+	paidOrder.State = ORDER_STATE_PAID
+	orderRows, _ := util.ObjectToRows(paidOrder)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1}`)))
+	handlerCtx.CancelOrder(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), constants.ORDER_NOT_CANCELABLE)
+}
+
+func TestExpireReservations(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	createdOrder := testOrder
+	createdRows, _ := util.ObjectToRows(createdOrder)
+	paidOrder := testOrder
+	paidOrder.ID = 5
+	paidOrder.State = ORDER_STATE_PAID
+	paidRows, _ := util.ObjectToRows(paidOrder)
+	mock.ExpectQuery(`^SELECT DISTINCT \"stock_reservations\"\.\"order_id\" FROM \"stock_reservations\" .+`).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id"}).AddRow(1).AddRow(5))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(1, 1).WillReturnRows(createdRows)
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"fail_reason\"=\$2,.+`).
+		WithArgs(ORDER_STATE_CANCELED, "Stock reservation expired", sqlmock.AnyArg(), 1, ORDER_STATE_CREATED, ORDER_STATE_AWAITPAYMENT).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(releaseDiscountsSQL).WithArgs(sqlmock.AnyArg(), 1).WillReturnRows(sqlmock.NewRows([]string{"coupon_id"}))
+	mock.ExpectQuery(releaseReservationsSQL).WithArgs(constants.RESERVATION_STATE_EXPIRED, sqlmock.AnyArg(), 1, constants.RESERVATION_STATE_RESERVED).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"}).AddRow(3, nil, 1))
+	mock.ExpectExec(`^UPDATE \"products\" SET \"is_available\"=\$1,\"updated_at\"=\$2 WHERE \"products\"\.\"id\" = \$3`).
+		WithArgs(true, sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// Paid meanwhile
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(5, 1).WillReturnRows(paidRows)
+	mock.ExpectRollback()
+
+	count, err := handlerCtx.ExpireReservations(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPaymentCallBackFailedReleasesStock(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	awaitingOrder := testOrder
+	awaitingOrder.State = ORDER_STATE_AWAITPAYMENT
+	orderRows, _ := util.ObjectToRows(awaitingOrder)
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"fail_reason\"=\$2,\"updated_at\"=\$3 WHERE \"orders\"\.\"id\" = \$4 AND \"orders\"\.\"state\" = \$5`).
+		WithArgs(ORDER_STATE_FAILED, "Card declined", sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_AWAITPAYMENT).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The coupon use is given back
+	mock.ExpectQuery(releaseDiscountsSQL).WithArgs(sqlmock.AnyArg(), testOrder.ID).WillReturnRows(sqlmock.NewRows([]string{"coupon_id"}).AddRow(9))
+	mock.ExpectExec(`^UPDATE \"coupons\" SET \"used_count\"=\"coupons\"\.\"used_count\"-\$1,\"updated_at\"=\$2 WHERE \"coupons\"\.\"id\" = \$3 AND \"coupons\"\.\"used_count\" > \$4`).
+		WithArgs(1, sqlmock.AnyArg(), 9, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(releaseReservationsSQL).WithArgs(constants.RESERVATION_STATE_RELEASED, sqlmock.AnyArg(), testOrder.ID, constants.RESERVATION_STATE_RESERVED).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"}).AddRow(4, 7, 2))
+	mock.ExpectExec(`^UPDATE \"variants\" SET \"stock\"=\"variants\"\.\"stock\"\+\$1,.+`).
+		WithArgs(2, sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(
+		`{"order_id":1,"payment_detail":{"id":3,"state":2,"payment_result":"Card declined"}}`)))
+	handlerCtx.PaymentCallBack(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, handlerCtx.GetPendingOrderCount())
+}
+
+func TestPaymentCallBackPaidSellsStock(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	awaitingOrder := testOrder
+	awaitingOrder.State = ORDER_STATE_AWAITPAYMENT
+	orderRows, _ := util.ObjectToRows(awaitingOrder)
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"orders\"\.\"id\" = \$3 AND \"orders\"\.\"state\" = \$4`).
+		WithArgs(ORDER_STATE_PAID, sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_AWAITPAYMENT).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"stock_reservations\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"stock_reservations\"\.\"order_id\" = \$3 AND \"stock_reservations\"\.\"state\" = \$4`).
+		WithArgs(constants.RESERVATION_STATE_SOLD, sqlmock.AnyArg(), testOrder.ID, constants.RESERVATION_STATE_RESERVED).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"payment_detail":{"id":3,"state":1}}`)))
+	handlerCtx.PaymentCallBack(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// A backorder payment authorized after the order was canceled is voided
+// A backorder payment authorized after the order was canceled is voided in the background, a failed void is retried
+func TestPaymentCallBackCanceledVoid(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	voided := make(chan uint, 2)
+	handlerCtx.SetVoidMethod(func(c context.Context, orderId uint) error {
+		voided <- orderId
+		if len(voided) == 1 {
+			return errors.New("payment API unavailable")
+		}
+		return nil
+	})
+
+	canceledOrder := testOrder
+	canceledOrder.State = ORDER_STATE_CANCELED
+	orderRows, _ := util.ObjectToRows(canceledOrder)
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectBegin()
+	mock.ExpectQuery(createPaymentActionSQL).WithArgs(testOrder.ID, constants.PAYMENT_ACTION_VOID, constants.PAYMENT_ACTION_STATE_PENDING, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(updatePaymentActionSQL).WithArgs(constants.PAYMENT_ACTION_STATE_COMPLETED, sqlmock.AnyArg(), 8, constants.PAYMENT_ACTION_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"payment_detail":{"id":3,"state":4}}`)))
+	handlerCtx.PaymentCallBack(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, len(voided))
+	assert.Equal(t, 0, handlerCtx.GetPendingOrderCount())
+}
+
+func TestPaymentCallBackCanceledRefund(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	var refund RefundRequest
+	handlerCtx.SetRefundMethod(func(c context.Context, req RefundRequest) error {
+		refund = req
+		return nil
+	})
+
+	canceledOrder := testOrder
+	canceledOrder.State = ORDER_STATE_CANCELED
+	canceledOrder.Currency = "USD"
+	orderRows, _ := util.ObjectToRows(canceledOrder)
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"refunded_amount\"=\$1,\"updated_at\"=\$2 WHERE \"orders\"\.\"id\" = \$3 AND \"orders\"\.\"refunded_amount\" = \$4`).
+		WithArgs("100.00", sqlmock.AnyArg(), testOrder.ID, "0.00").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createRefundSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(completeRefundSQL).WithArgs(constants.REFUND_STATE_COMPLETED, sqlmock.AnyArg(), 7, constants.REFUND_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"payment_detail":{"id":3,"state":1}}`)))
+	handlerCtx.PaymentCallBack(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, RefundRequest{RefundId: 7, OrderId: 1, Amount: model.NewMoney(100, 0), Currency: "USD", Reason: "Order was canceled before the payment completed"}, refund)
+	assert.Equal(t, 0, handlerCtx.GetPendingOrderCount())
+}
+
+func TestCreatOrderBackorder(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+
+	customerRows, _ := util.ObjectToRows(testCustomer)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"customers\" WHERE \"customers\"\.\"id\" = \$1`).WithArgs(testOrder.CustomerId, 1).WillReturnRows(customerRows)
+	mock.ExpectQuery(selectAddressesSQL).WithArgs(testOrder.CustomerId).WillReturnRows(sqlmock.NewRows(addressColumns))
+	mock.ExpectQuery(`^UPDATE \"variants\" SET .+ RETURNING .+`).WithArgs(2, sqlmock.AnyArg(), "TS-M-RED", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "sku", "price"}))
+	mock.ExpectQuery(selectSkuSQL).WithArgs("TS-M-RED").WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 4, "TS-M-RED", 1))
+	mock.ExpectQuery(selectBackorderProductSQL).WithArgs(4, true).
+		WillReturnRows(sqlmock.NewRows(productColumns).AddRow(4, "10.00", "USD", true))
+	mock.ExpectQuery("INSERT INTO \"orders\" .+ VALUES .+").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(createLinesSQL).
+		WithArgs(1, 4, 7, "TS-M-RED", 2, "10.00", "20.00", "0", "0", 0, 0, "0", true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"customer_id":2,"sku":"TS-M-RED","quantity":2}`)))
+	handlerCtx.CreateOrder(w, r)
+
+	actualResp := model.Order{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, actualResp.Backordered)
+	assert.True(t, actualResp.Lines[0].Backordered)
+	assert.Equal(t, model.NewMoney(20, 0), actualResp.Amount)
+}
+
+func TestPaymentCallBackAuthorizedBackorders(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	notifier := &recordingNotifier{}
+	handlerCtx.SetNotifier(notifier)
+
+	awaitingOrder := testOrder
+	awaitingOrder.State = ORDER_STATE_AWAITPAYMENT
+	orderRows, _ := util.ObjectToRows(awaitingOrder)
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(testOrder.ID, 1).WillReturnRows(orderRows)
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"orders\"\.\"id\" = \$3 AND \"orders\"\.\"state\" = \$4`).
+		WithArgs(ORDER_STATE_BACKORDERED, sqlmock.AnyArg(), testOrder.ID, ORDER_STATE_AWAITPAYMENT).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"stock_reservations\" SET .+`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"payment_detail":{"id":3,"state":4}}`)))
+	handlerCtx.PaymentCallBack(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []notification.Event{{Type: notification.EVENT_ORDER_BACKORDERED, OrderId: 1, CustomerId: 2}}, notifier.events)
+	assert.Equal(t, 1, handlerCtx.GetPendingOrderCount())
+}
+
+func TestFillBackorders(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	captured := make([]uint, 0)
+	handlerCtx.SetCaptureMethod(func(c context.Context, orderId uint) error {
+		captured = append(captured, orderId)
+		return nil
+	})
+
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"state\" = \$1 ORDER BY \"orders\"\.\"id\"`).WithArgs(ORDER_STATE_BACKORDERED).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "state"}).
+			AddRow(1, 2, ORDER_STATE_BACKORDERED).AddRow(2, 2, ORDER_STATE_BACKORDERED).AddRow(3, 2, ORDER_STATE_BACKORDERED))
+	mock.ExpectQuery(`^SELECT \* FROM \"order_lines\" WHERE \"order_lines\"\.\"order_id\" IN \(\$1,\$2,\$3\) AND \"order_lines\"\.\"backordered\" = \$4 ORDER BY \"order_lines\"\.\"id\"`).
+		WithArgs(1, 2, 3, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "variant_id", "sku", "quantity", "backordered"}).
+			AddRow(11, 1, 4, 7, "TS-M-RED", 2, true).
+			AddRow(12, 2, 4, 7, "TS-M-RED", 1, true).
+			AddRow(13, 3, 5, nil, nil, 1, true))
+	// The first order is still out of stock and holds back the second order of the same SKU
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^UPDATE \"variants\" SET .+ RETURNING .+`).WithArgs(2, sqlmock.AnyArg(), "TS-M-RED", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "sku", "price"}))
+	mock.ExpectRollback()
+	// The order of another product is filled
+	mock.ExpectBegin()
+	mock.ExpectQuery(countVariantsSQL).WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`^UPDATE \"products\" SET \"is_available\"=\$1,.+ RETURNING .+`).WithArgs(false, sqlmock.AnyArg(), 5, true).
+		WillReturnRows(sqlmock.NewRows([]string{"price", "currency"}).AddRow("10.00", "USD"))
+	mock.ExpectExec(`^UPDATE \"order_lines\" SET \"backordered\"=\$1,\"updated_at\"=\$2 WHERE \"order_lines\"\.\"order_id\" = \$3 AND \"order_lines\"\.\"backordered\" = \$4`).
+		WithArgs(false, sqlmock.AnyArg(), 3, true).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"orders\"\.\"id\" = \$3 AND \"orders\"\.\"state\" = \$4`).
+		WithArgs(ORDER_STATE_PAID, sqlmock.AnyArg(), 3, ORDER_STATE_BACKORDERED).WillReturnResult(sqlmock.NewResult(0, 1))
+	// The taken stock is sold to the order, its payment is captured after commit
+	expectSellBackorder(mock, 3)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(updatePaymentActionSQL).WithArgs(constants.PAYMENT_ACTION_STATE_COMPLETED, sqlmock.AnyArg(), 8, constants.PAYMENT_ACTION_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	filled := handlerCtx.FillBackorders(context.Background())
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, filled)
+	assert.Equal(t, []uint{3}, captured)
+	assert.Equal(t, 1, handlerCtx.GetPendingOrderCount())
+}
+
+// Expect the stock taken by a filled backorder to be sold to it and its capture to be saved
+func expectSellBackorder(mock sqlmock.Sqlmock, orderId uint) {
+	mock.ExpectQuery(createReservationsSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	mock.ExpectExec(`^UPDATE \"stock_reservations\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"stock_reservations\"\.\"order_id\" = \$3 AND \"stock_reservations\"\.\"state\" = \$4`).
+		WithArgs(constants.RESERVATION_STATE_SOLD, sqlmock.AnyArg(), orderId, constants.RESERVATION_STATE_RESERVED).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createPaymentActionSQL).WithArgs(orderId, constants.PAYMENT_ACTION_CAPTURE, constants.PAYMENT_ACTION_STATE_PENDING, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+}
+
+// Expect the backorder of product 5 to be filled
+func expectFillBackorder(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"state\" = \$1`).WithArgs(ORDER_STATE_BACKORDERED).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "state"}).AddRow(3, 2, ORDER_STATE_BACKORDERED))
+	mock.ExpectQuery(`^SELECT \* FROM \"order_lines\" WHERE .+`).WithArgs(3, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "variant_id", "sku", "quantity", "backordered"}).AddRow(13, 3, 5, nil, nil, 1, true))
+	mock.ExpectBegin()
+	mock.ExpectQuery(countVariantsSQL).WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`^UPDATE \"products\" SET .+ RETURNING .+`).WillReturnRows(sqlmock.NewRows([]string{"price", "currency"}).AddRow("10.00", "USD"))
+	mock.ExpectExec(`^UPDATE \"order_lines\" SET .+`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"orders\" SET .+`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSellBackorder(mock, 3)
+	mock.ExpectCommit()
+}
+
+// The backorder is filled but the payment API is unavailable, the capture stays pending and the order isn't fulfilled yet
+func TestFillBackordersCapturePending(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetCaptureMethod(func(c context.Context, orderId uint) error {
+		return errors.New("payment API unavailable")
+	})
+
+	expectFillBackorder(mock)
+
+	filled := handlerCtx.FillBackorders(context.Background())
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, filled)
+	assert.Equal(t, 0, handlerCtx.GetPendingOrderCount())
+}
+
+// The payment API has no authorized payment to capture, the order fails and gives the stock sold to it back
+func TestFillBackordersCaptureRejected(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	captures := 0
+	handlerCtx.SetCaptureMethod(func(c context.Context, orderId uint) error {
+		captures++
+		return errPaymentNotAuthorized
+	})
+
+	expectFillBackorder(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(failPaymentActionSQL).
+		WithArgs(constants.PAYMENT_ACTION_STATE_FAILED, constants.PAYMENT_NOT_AUTHORIZED, sqlmock.AnyArg(), 8, constants.PAYMENT_ACTION_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"state\"=\$1,\"fail_reason\"=\$2,\"updated_at\"=\$3 WHERE \"orders\"\.\"id\" = \$4 AND \"orders\"\.\"state\" = \$5`).
+		WithArgs(ORDER_STATE_FAILED, constants.CAPTURE_FAILED+": "+constants.PAYMENT_NOT_AUTHORIZED, sqlmock.AnyArg(), 3, ORDER_STATE_PAID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(releaseDiscountsSQL).WithArgs(sqlmock.AnyArg(), 3).WillReturnRows(sqlmock.NewRows([]string{"coupon_id"}))
+	mock.ExpectQuery(releaseReservationsSQL).WithArgs(constants.RESERVATION_STATE_RELEASED, sqlmock.AnyArg(), 3, constants.RESERVATION_STATE_SOLD).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"}).AddRow(5, nil, 1))
+	mock.ExpectExec(`^UPDATE \"products\" SET \"is_available\"=\$1,\"updated_at\"=\$2 WHERE \"products\"\.\"id\" = \$3`).
+		WithArgs(true, sqlmock.AnyArg(), 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	filled := handlerCtx.FillBackorders(context.Background())
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, filled)
+	assert.Equal(t, 1, captures)
+	assert.Equal(t, 0, handlerCtx.GetPendingOrderCount())
+}
+
+// A pending capture is made and its order fulfilled, a pending void of a captured payment is refunded instead
+func TestRetryPaymentActions(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, mockPayment, "")
+	handlerCtx.SetCaptureMethod(func(c context.Context, orderId uint) error {
+		return nil
+	})
+	handlerCtx.SetVoidMethod(func(c context.Context, orderId uint) error {
+		return errPaymentCaptured
+	})
+	refunds := make([]RefundRequest, 0)
+	handlerCtx.SetRefundMethod(func(c context.Context, req RefundRequest) error {
+		refunds = append(refunds, req)
+		return nil
+	})
+
+	mock.ExpectQuery(`^SELECT \* FROM \"payment_actions\" WHERE \"payment_actions\"\.\"state\" = \$1 ORDER BY \"payment_actions\"\.\"id\"`).
+		WithArgs(constants.PAYMENT_ACTION_STATE_PENDING).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "action", "state"}).
+			AddRow(8, 3, constants.PAYMENT_ACTION_CAPTURE, constants.PAYMENT_ACTION_STATE_PENDING).
+			AddRow(9, 4, constants.PAYMENT_ACTION_VOID, constants.PAYMENT_ACTION_STATE_PENDING))
+	mock.ExpectBegin()
+	mock.ExpectExec(updatePaymentActionSQL).WithArgs(constants.PAYMENT_ACTION_STATE_COMPLETED, sqlmock.AnyArg(), 8, constants.PAYMENT_ACTION_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "state"}).AddRow(3, 2, ORDER_STATE_PAID))
+	mock.ExpectBegin()
+	mock.ExpectExec(failPaymentActionSQL).
+		WithArgs(constants.PAYMENT_ACTION_STATE_FAILED, constants.PAYMENT_CAPTURED, sqlmock.AnyArg(), 9, constants.PAYMENT_ACTION_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`^SELECT \* FROM \"orders\" WHERE \"orders\"\.\"id\" = \$1`).WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "state", "amount", "currency", "refunded_amount"}).
+			AddRow(4, 2, ORDER_STATE_CANCELED, "100.00", "USD", "0.00"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"orders\" SET \"refunded_amount\"=\$1,.+`).WithArgs("100.00", sqlmock.AnyArg(), 4, "0.00").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(createRefundSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(completeRefundSQL).WithArgs(constants.REFUND_STATE_COMPLETED, sqlmock.AnyArg(), 7, constants.REFUND_STATE_PENDING).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	handlerCtx.retryPaymentActions(context.Background())
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, handlerCtx.GetPendingOrderCount())
+	assert.Len(t, refunds, 1)
+	assert.Equal(t, uint(4), refunds[0].OrderId)
+	assert.Equal(t, model.NewMoney(100, 0), refunds[0].Amount)
+}
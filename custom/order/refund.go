@@ -0,0 +1,259 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/notification"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+)
+
+// The cancellation was saved but the payment API failed to refund it
+var errRefundPending = errors.New(constants.REFUND_PENDING)
+
+// CancelLinesRequest Quantity of order lines which can't be fulfilled, it is refunded to the customer
+type CancelLinesRequest struct {
+	OrderId uint           `json:"order_id"`
+	Items   []LineQuantity `json:"items"`
+	Reason  string         `json:"reason,omitempty"`
+}
+
+// SetRefundMethod Set the method refunding payments, defaults to calling the payment API
+func (ctx *HandlerContext) SetRefundMethod(refundMethod RefundMethod) {
+	ctx.refundMethod = refundMethod
+}
+
+// CancelLines Cancel and refund order lines which can't be fulfilled.
+// The order is FULFILLED when the rest was shipped, and FULFILLMENT FAILED when nothing was shipped.
+// Accepted is returned when the lines were canceled but the payment API failed, the refund is requested again on restart.
+func (ctx *HandlerContext) CancelLines(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := CancelLinesRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.OrderId == 0 {
+		http.Error(w, "Order id is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		http.Error(w, "Items are required", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "Order lines can't be fulfilled"
+	}
+
+	orderInfo, err := ctx.cancelLines(r.Context(), req.OrderId, req.Items, req.Reason)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errOrderNotFulfillable) || errors.Is(err, errLineNotFound) || errors.Is(err, errInvalidLineQuantity) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := http.StatusOK
+	if errors.Is(err, errRefundPending) {
+		// The lines were canceled, the refund is made later
+		util.GetLogger(r.Context()).Error(err.Error(), "order_id", req.OrderId)
+		status = http.StatusAccepted
+	} else if err != nil {
+		util.GetLogger(r.Context()).Error(err.Error(), "order_id", req.OrderId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(orderInfo)
+	w.Write(respBody)
+}
+
+// Cancel a quantity of order lines, all remaining quantity when items is nil, and refund it through the payment API.
+// The refund is saved with the cancellation and made after commit, the order is returned with errRefundPending when the payment API fails.
+// The customer is notified of the refund, and of the failed fulfillment when nothing of the order was shipped.
+func (ctx *HandlerContext) cancelLines(c context.Context, orderId uint, items []LineQuantity, reason string) (*model.Order, error) {
+	var orderInfo *model.Order
+	var refundAmount model.Money
+	var refund *model.Refund
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		var errTx error
+		orderInfo, errTx = lockOrder(c, tx, orderId)
+		if errTx != nil {
+			return gorm.ErrRecordNotFound
+		}
+		if orderInfo.State != ORDER_STATE_PAID && orderInfo.State != ORDER_STATE_PARTIALLY_FULFILLED {
+			return fmt.Errorf("%w: order is %s", errOrderNotFulfillable, stateCodeToString(orderInfo.State))
+		}
+		lines, errTx := lockLines(c, tx, orderId)
+		if errTx != nil {
+			return errTx
+		}
+		if items == nil {
+			for _, line := range lines {
+				if line.Remaining() > 0 {
+					items = append(items, LineQuantity{LineId: line.ID, Quantity: line.Remaining()})
+				}
+			}
+		}
+		if refundAmount, errTx = addLineQuantities(c, tx, lines, items, true); errTx != nil {
+			return errTx
+		}
+		orderInfo.State = fulfillmentState(lines)
+		orderInfo.RefundedAmount = orderInfo.RefundedAmount.Add(refundAmount)
+		updates := []field.AssignExpr{tx.Order.State.Value(orderInfo.State), tx.Order.RefundedAmount.Value(orderInfo.RefundedAmount)}
+		if orderInfo.State == ORDER_STATE_FULFILLMENT_FAILED {
+			orderInfo.FailReason = &reason
+			updates = append(updates, tx.Order.FailReason.Value(reason))
+		}
+		if _, errTx = tx.Order.WithContext(c).Where(tx.Order.ID.Eq(orderId)).UpdateSimple(updates...); errTx != nil {
+			return errTx
+		}
+		for _, line := range lines {
+			orderInfo.Lines = append(orderInfo.Lines, *line)
+		}
+		if refundAmount == 0 {
+			return nil
+		}
+		refund = newRefund(orderInfo, refundAmount, reason)
+		return tx.Refund.WithContext(c).Create(refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+	util.GetLogger(c).Info("Order lines were canceled",
+		"order_id", orderId,
+		"refund", refundAmount,
+		"currency", orderInfo.Currency,
+		"state", stateCodeToString(orderInfo.State))
+
+	if refund != nil {
+		err = ctx.makeRefund(c, refund)
+	}
+	if orderInfo.State == ORDER_STATE_FULFILLMENT_FAILED {
+		ctx.notify(c, notification.Event{
+			Type:       notification.EVENT_ORDER_FULFILLMENT_FAILED,
+			OrderId:    orderId,
+			CustomerId: orderInfo.CustomerId,
+			Reason:     reason,
+		})
+	}
+	return orderInfo, err
+}
+
+// Refund the payment of an order which was canceled before its payment completed. Repeated callbacks refund once.
+func (ctx *HandlerContext) refundCanceledOrder(c context.Context, orderInfo *model.Order) error {
+	refundAmount := orderInfo.Amount - orderInfo.RefundedAmount
+	if refundAmount <= 0 {
+		return nil
+	}
+	var refund *model.Refund
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		result, errTx := tx.Order.WithContext(c).Where(tx.Order.ID.Eq(orderInfo.ID), tx.Order.RefundedAmount.Eq(orderInfo.RefundedAmount)).
+			UpdateSimple(tx.Order.RefundedAmount.Value(orderInfo.Amount))
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		refund = newRefund(orderInfo, refundAmount, "Order was canceled before the payment completed")
+		return tx.Refund.WithContext(c).Create(refund)
+	})
+	if err != nil || refund == nil {
+		return err
+	}
+	return ctx.makeRefund(c, refund)
+}
+
+// Pending refund of an order, saved in the transaction which changed the refunded amount of the order
+func newRefund(orderInfo *model.Order, amount model.Money, reason string) *model.Refund {
+	return &model.Refund{
+		OrderId:    orderInfo.ID,
+		CustomerId: orderInfo.CustomerId,
+		Amount:     amount,
+		Currency:   orderInfo.Currency,
+		Reason:     reason,
+		State:      constants.REFUND_STATE_PENDING,
+	}
+}
+
+// Make a saved refund through the payment API, failed calls are retried after the backoff.
+// The refund is COMPLETED and the customer notified then, it stays PENDING when the payment API keeps failing.
+func (ctx *HandlerContext) makeRefund(c context.Context, refund *model.Refund) error {
+	logger := util.GetLogger(c).With("order_id", refund.OrderId, "refund_id", refund.ID)
+	settings := ctx.getSettings()
+	req := RefundRequest{
+		RefundId: refund.ID,
+		OrderId:  refund.OrderId,
+		Amount:   refund.Amount,
+		Currency: refund.Currency,
+		Reason:   refund.Reason,
+	}
+	err := ctx.refundMethod(c, req)
+	for retry := 1; err != nil && retry <= settings.PaymentApiRetryCount; retry++ {
+		delay := util.Backoff(retry, settings.RetryBackoff, settings.RetryMaxBackoff)
+		logger.Error("Call refund failed: "+err.Error(), "retry", retry, "backoff", delay)
+		if errSleep := util.SleepContext(c, delay); errSleep != nil {
+			break
+		}
+		err = ctx.refundMethod(c, req)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", errRefundPending, err.Error())
+	}
+
+	refundTable := ctx.db.Refund
+	_, err = refundTable.WithContext(c).Where(refundTable.ID.Eq(refund.ID), refundTable.State.Eq(constants.REFUND_STATE_PENDING)).
+		UpdateSimple(refundTable.State.Value(constants.REFUND_STATE_COMPLETED))
+	if err != nil {
+		// Requested again on restart, the payment API refunds once
+		logger.Error("Update refund failed: " + err.Error())
+	}
+	refund.State = constants.REFUND_STATE_COMPLETED
+	logger.Info("Payment was refunded", "refund", refund.Amount, "currency", refund.Currency)
+	ctx.notify(c, notification.Event{
+		Type:       notification.EVENT_ORDER_REFUNDED,
+		OrderId:    refund.OrderId,
+		CustomerId: refund.CustomerId,
+		Amount:     &refund.Amount,
+		Currency:   refund.Currency,
+		Reason:     refund.Reason,
+	})
+	return nil
+}
+
+// Request the pending refunds again, e.g. when the payment API was unavailable before a restart
+func (ctx *HandlerContext) retryRefunds(c context.Context) {
+	logger := util.GetLogger(c)
+	refundTable := ctx.db.Refund
+	refunds, err := refundTable.WithContext(c).Where(refundTable.State.Eq(constants.REFUND_STATE_PENDING)).Order(refundTable.ID).Find()
+	if err != nil {
+		logger.Error("Find pending refunds failed: " + err.Error())
+		return
+	}
+	if len(refunds) > 0 {
+		logger.Info("Found pending refunds", "count", len(refunds))
+	}
+	for _, refund := range refunds {
+		if err = ctx.makeRefund(c, refund); err != nil {
+			logger.Error(err.Error(), "order_id", refund.OrderId, "refund_id", refund.ID)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log/slog"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/notification"
+	"order_system/custom/promotion"
+	"order_system/custom/reservation"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"time"
+)
+
+var errOrderNotCancelable = errors.New(constants.ORDER_NOT_CANCELABLE)
+
+// CancelOrderRequest Cancel an unpaid order
+type CancelOrderRequest struct {
+	OrderId uint   `json:"order_id"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// CancelOrder Cancel an order which isn't paid yet, its stock reservations are released.
+// The payment authorization of a backorder is voided, a payment completing after the cancellation is refunded.
+func (ctx *HandlerContext) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := CancelOrderRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.OrderId == 0 {
+		http.Error(w, "Order id is required", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "Order was canceled"
+	}
+
+	orderInfo, err := ctx.cancelUnpaidOrder(r.Context(), req.OrderId, req.Reason, constants.RESERVATION_STATE_RELEASED)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errOrderNotCancelable) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		util.GetLogger(r.Context()).Error(err.Error(), "order_id", req.OrderId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(orderInfo)
+	w.Write(respBody)
+}
+
+// ExpireReservations Cancel unpaid orders holding stock reservations past their expiry and release the stock.
+// Returns the count of canceled orders, orders which were paid or failed meanwhile are skipped.
+func (ctx *HandlerContext) ExpireReservations(c context.Context) (int, error) {
+	orderIds, err := reservation.ExpiredOrders(c, ctx.db, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	canceled := 0
+	for _, orderId := range orderIds {
+		_, err = ctx.cancelUnpaidOrder(c, orderId, "Stock reservation expired", constants.RESERVATION_STATE_EXPIRED)
+		if errors.Is(err, errOrderNotCancelable) {
+			continue
+		}
+		if err != nil {
+			return canceled, err
+		}
+		canceled++
+	}
+	return canceled, nil
+}
+
+// SweepReservations Expire stock reservations of unpaid orders in the interval
+func (ctx *HandlerContext) SweepReservations(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		count, err := ctx.ExpireReservations(util.ContextWithRequestId(context.Background(), util.NewRequestId()))
+		if err != nil {
+			slog.Error("Expire stock reservations failed", "error", err.Error())
+		}
+		if count > 0 {
+			slog.Info("Unpaid orders with expired stock reservations were canceled", "count", count)
+		}
+	}
+}
+
+// Cancel a CREATED, AWAIT PAYMENT or BACKORDERED order and release its stock reservations with the state, RELEASED or EXPIRED.
+// The coupon uses of the order are given back, the stock sold to a backorder is returned and its payment authorization voided in the background.
+func (ctx *HandlerContext) cancelUnpaidOrder(c context.Context, orderId uint, reason string, releaseState int8) (*model.Order, error) {
+	var orderInfo *model.Order
+	released := 0
+	var void *model.PaymentAction
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		var errTx error
+		orderInfo, errTx = tx.Order.WithContext(c).Where(tx.Order.ID.Eq(orderId)).First()
+		if errTx != nil {
+			return gorm.ErrRecordNotFound
+		}
+		if orderInfo.State != ORDER_STATE_CREATED && orderInfo.State != ORDER_STATE_AWAITPAYMENT && orderInfo.State != ORDER_STATE_BACKORDERED {
+			return fmt.Errorf("%w: order is %s", errOrderNotCancelable, stateCodeToString(orderInfo.State))
+		}
+		// The order may be paid or the backorder filled concurrently, it is only canceled while unpaid or backordered
+		backordered := orderInfo.State == ORDER_STATE_BACKORDERED
+		cancelable := tx.Order.State.In(ORDER_STATE_CREATED, ORDER_STATE_AWAITPAYMENT)
+		if backordered {
+			cancelable = tx.Order.State.Eq(ORDER_STATE_BACKORDERED)
+		}
+		result, errTx := tx.Order.WithContext(c).Where(tx.Order.ID.Eq(orderId), cancelable).
+			UpdateSimple(tx.Order.State.Value(ORDER_STATE_CANCELED), tx.Order.FailReason.Value(reason))
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return errOrderNotCancelable
+		}
+		orderInfo.State = ORDER_STATE_CANCELED
+		orderInfo.FailReason = &reason
+		if _, errTx = promotion.Release(c, tx, orderId, time.Now()); errTx != nil {
+			return errTx
+		}
+		if backordered {
+			if released, errTx = reservation.ReleaseSold(c, tx, orderId, releaseState); errTx != nil {
+				return errTx
+			}
+			void = newPaymentAction(orderId, constants.PAYMENT_ACTION_VOID)
+			return tx.PaymentAction.WithContext(c).Create(void)
+		}
+		released, errTx = reservation.Release(c, tx, orderId, releaseState)
+		return errTx
+	})
+	if err != nil {
+		return nil, err
+	}
+	if void != nil {
+		ctx.voidInBackground(c, void)
+	}
+	util.GetLogger(c).Info("Order state was updated",
+		"order_id", orderId,
+		"state", stateCodeToString(ORDER_STATE_CANCELED),
+		"reason", reason,
+		"released_reservations", released)
+	if released > 0 {
+		ctx.refillBackorders(c)
+	}
+	ctx.notify(c, notification.Event{
+		Type:       notification.EVENT_ORDER_CANCELED,
+		OrderId:    orderId,
+		CustomerId: orderInfo.CustomerId,
+		Reason:     reason,
+	})
+	return orderInfo, nil
+}
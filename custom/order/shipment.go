@@ -0,0 +1,449 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/fulfillment"
+	"order_system/custom/notification"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"time"
+)
+
+var (
+	errInvalidShipmentState = errors.New(constants.INVALID_SHIPMENT_STATE)
+	errOrderNotFulfillable  = errors.New(constants.ORDER_NOT_FULFILLABLE)
+	errLineNotFound         = errors.New(constants.ORDER_LINE_NOT_FOUND)
+	errInvalidLineQuantity  = errors.New(constants.INVALID_QUANTITY)
+	// The provider shipped nothing, the shipment is rolled back
+	errNothingShipped = errors.New("nothing was shipped")
+	// The provider shipped the shipment but the shipped items couldn't be saved
+	errShipmentNotRecorded = errors.New("shipped items were not recorded")
+)
+
+// CreateShipmentRequest Shipment posted by a warehouse, with the shipped quantity of order lines
+type CreateShipmentRequest struct {
+	OrderId        uint           `json:"order_id"`
+	Items          []LineQuantity `json:"items"`
+	Carrier        *string        `json:"carrier,omitempty"`
+	TrackingNumber *string        `json:"tracking_number,omitempty"`
+}
+
+// LineQuantity Quantity of an order line
+type LineQuantity struct {
+	LineId   uint `json:"line_id"`
+	Quantity int  `json:"quantity"`
+}
+
+// SetFulfillmentProvider Set the provider shipping paid orders, defaults to the manual provider
+func (ctx *HandlerContext) SetFulfillmentProvider(provider fulfillment.Provider) {
+	ctx.fulfillment = provider
+}
+
+// Hand the remaining quantity of an order over to the fulfillment provider, which may ship a part of it.
+// The shipment is saved before it is handed over and the provider is called outside of a transaction,
+// the shipped items are recorded in a second transaction. The shipment is deleted when the provider fails or ships nothing.
+// Items of shipments which were handed over but not recorded, e.g. before a crash, are not handed over again.
+// The shipment is nil when nothing was shipped, otherwise the new order state is returned with it.
+func (ctx *HandlerContext) shipRemaining(c context.Context, order *model.Order) (*model.Shipment, int8, error) {
+	var shipment *model.Shipment
+	orderState := order.State
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		lines, errTx := lockLines(c, tx, order.ID)
+		if errTx != nil {
+			return errTx
+		}
+		items := model.ShipmentItemsOf(lines)
+		if len(items) == 0 {
+			return errNothingShipped
+		}
+		shipments, errTx := tx.Shipment.WithContext(c).Where(tx.Shipment.OrderId.Eq(order.ID)).Find()
+		if errTx != nil {
+			return errTx
+		}
+		if inFlight := inFlightItems(lines, shipments); len(inFlight) > 0 {
+			util.GetLogger(c).Warn("Items of unrecorded shipments are not handed over again", "order_id", order.ID, "items", len(inFlight))
+			items = items.Without(inFlight)
+		}
+		if len(items) == 0 {
+			return errNothingShipped
+		}
+		shipment = &model.Shipment{
+			OrderId: order.ID,
+			Items:   items,
+			State:   constants.SHIPMENT_STATE_CREATED,
+		}
+		return tx.Shipment.WithContext(c).Create(shipment)
+	})
+	if errors.Is(err, errNothingShipped) {
+		return nil, orderState, nil
+	}
+	if err != nil {
+		return nil, orderState, err
+	}
+
+	if err = ctx.fulfillment.CreateShipment(c, order, shipment); err != nil || len(shipment.Items) == 0 {
+		ctx.deleteShipment(c, shipment.ID)
+		return nil, orderState, err
+	}
+
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		lines, errTx := lockLines(c, tx, order.ID)
+		if errTx != nil {
+			return errTx
+		}
+		_, errTx = tx.Shipment.WithContext(c).Where(tx.Shipment.ID.Eq(shipment.ID)).
+			Updates(model.Shipment{Items: shipment.Items, Carrier: shipment.Carrier, TrackingNumber: shipment.TrackingNumber})
+		if errTx != nil {
+			return errTx
+		}
+		orderState, errTx = recordShipped(c, tx, order.ID, lines, shipment.Items)
+		return errTx
+	})
+	if err != nil {
+		// The provider has the shipment, handing it over again would ship it twice
+		return nil, order.State, fmt.Errorf("%w: shipment %d: %w", errShipmentNotRecorded, shipment.ID, err)
+	}
+	return shipment, orderState, nil
+}
+
+// Items of the shipments of an order which were handed over to the provider but whose shipped items weren't recorded.
+// Every recorded shipment adds its items to the fulfilled quantity of the lines, the rest of the quantity in shipments is in flight.
+func inFlightItems(lines []*model.OrderLine, shipments []*model.Shipment) model.ShipmentItems {
+	shipped := make(map[uint]int, len(lines))
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			shipped[item.LineId] += item.Quantity
+		}
+	}
+	items := make(model.ShipmentItems, 0)
+	for _, line := range lines {
+		if quantity := shipped[line.ID] - line.FulfilledQuantity; quantity > 0 {
+			items = append(items, model.ShipmentItem{LineId: line.ID, ProductId: line.ProductId, VariantId: line.VariantId, Sku: line.Sku, Quantity: quantity})
+		}
+	}
+	return items
+}
+
+// Delete a saved shipment which the provider didn't ship, also when the order is no longer processed. Errors are logged only.
+func (ctx *HandlerContext) deleteShipment(c context.Context, shipmentId uint) {
+	shipmentTable := ctx.db.Shipment
+	if _, err := shipmentTable.WithContext(context.WithoutCancel(c)).Where(shipmentTable.ID.Eq(shipmentId)).Delete(); err != nil {
+		util.GetLogger(c).Error("Delete shipment failed: "+err.Error(), "shipment_id", shipmentId)
+	}
+}
+
+// Add shipped items to the fulfilled quantity of the order lines, then move the order to FULFILLED or PARTIALLY FULFILLED
+func recordShipped(c context.Context, tx *dal.Query, orderId uint, lines []*model.OrderLine, items model.ShipmentItems) (int8, error) {
+	quantities := make([]LineQuantity, 0, len(items))
+	for _, item := range items {
+		quantities = append(quantities, LineQuantity{LineId: item.LineId, Quantity: item.Quantity})
+	}
+	if _, err := addLineQuantities(c, tx, lines, quantities, false); err != nil {
+		return 0, err
+	}
+	orderState := fulfillmentState(lines)
+	_, err := tx.Order.WithContext(c).Where(tx.Order.ID.Eq(orderId), tx.Order.State.In(ORDER_STATE_PAID, ORDER_STATE_PARTIALLY_FULFILLED)).
+		UpdateSimple(tx.Order.State.Value(orderState))
+	return orderState, err
+}
+
+// Lock the order until the transaction ends, shipments and cancellations of the order wait for each other
+func lockOrder(c context.Context, tx *dal.Query, orderId uint) (*model.Order, error) {
+	return tx.Order.WithContext(c).Clauses(clause.Locking{Strength: "UPDATE"}).Where(tx.Order.ID.Eq(orderId)).First()
+}
+
+// Lock the lines of an order until the transaction ends, their quantities are checked and added to afterwards
+func lockLines(c context.Context, tx *dal.Query, orderId uint) ([]*model.OrderLine, error) {
+	return tx.OrderLine.WithContext(c).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(tx.OrderLine.OrderId.Eq(orderId)).Order(tx.OrderLine.ID).Find()
+}
+
+// Add quantities to the fulfilled or canceled quantity of order lines, a line never goes beyond its quantity.
+// The lines are locked by the caller, the update is bound by the quantity of the line as well. Returns the refund of the canceled quantities.
+func addLineQuantities(c context.Context, tx *dal.Query, lines []*model.OrderLine, quantities []LineQuantity, canceled bool) (model.Money, error) {
+	var refund model.Money
+	linesById := make(map[uint]*model.OrderLine, len(lines))
+	for _, line := range lines {
+		linesById[line.ID] = line
+	}
+	for _, quantity := range quantities {
+		line, ok := linesById[quantity.LineId]
+		if !ok {
+			return 0, fmt.Errorf("%w: %d", errLineNotFound, quantity.LineId)
+		}
+		if quantity.Quantity <= 0 || quantity.Quantity > line.Remaining() {
+			return 0, fmt.Errorf("%w: %d of line %d, %d remaining", errInvalidLineQuantity, quantity.Quantity, line.ID, line.Remaining())
+		}
+		updates := []field.AssignExpr{tx.OrderLine.FulfilledQuantity.Add(quantity.Quantity)}
+		if canceled {
+			refund = refund.Add(line.Cancel(quantity.Quantity))
+			updates = []field.AssignExpr{tx.OrderLine.CanceledQuantity.Add(quantity.Quantity), tx.OrderLine.RefundedAmount.Value(line.RefundedAmount)}
+		} else {
+			line.FulfilledQuantity += quantity.Quantity
+		}
+		result, err := tx.OrderLine.WithContext(c).
+			Where(tx.OrderLine.ID.Eq(line.ID),
+				tx.OrderLine.Quantity.Sub(quantity.Quantity).GteCol(tx.OrderLine.FulfilledQuantity.AddCol(tx.OrderLine.CanceledQuantity))).
+			UpdateSimple(updates...)
+		if err != nil {
+			return 0, err
+		}
+		if result.RowsAffected == 0 {
+			return 0, fmt.Errorf("%w: %d of line %d, the line was changed concurrently", errInvalidLineQuantity, quantity.Quantity, line.ID)
+		}
+	}
+	return refund, nil
+}
+
+// Order state by the fulfilled and canceled quantity of its lines
+func fulfillmentState(lines []*model.OrderLine) int8 {
+	fulfilled, remaining := 0, 0
+	for _, line := range lines {
+		fulfilled += line.FulfilledQuantity
+		remaining += line.Remaining()
+	}
+	switch {
+	case remaining == 0 && fulfilled == 0:
+		return ORDER_STATE_FULFILLMENT_FAILED
+	case remaining == 0:
+		return ORDER_STATE_FULFILLED
+	case fulfilled > 0:
+		return ORDER_STATE_PARTIALLY_FULFILLED
+	}
+	return ORDER_STATE_PAID
+}
+
+// CreateShipment Shipment of order lines posted by a warehouse, a paid order can be shipped in several shipments
+func (ctx *HandlerContext) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := CreateShipmentRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.OrderId == 0 {
+		http.Error(w, "Order id is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		http.Error(w, "Items are required", http.StatusBadRequest)
+		return
+	}
+
+	var shipment *model.Shipment
+	var orderInfo *model.Order
+	var orderState int8
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		var errTx error
+		orderInfo, errTx = lockOrder(r.Context(), tx, req.OrderId)
+		if errTx != nil {
+			return gorm.ErrRecordNotFound
+		}
+		if orderInfo.State != ORDER_STATE_PAID && orderInfo.State != ORDER_STATE_PARTIALLY_FULFILLED {
+			return fmt.Errorf("%w: order is %s", errOrderNotFulfillable, stateCodeToString(orderInfo.State))
+		}
+		lines, errTx := lockLines(r.Context(), tx, req.OrderId)
+		if errTx != nil {
+			return errTx
+		}
+		items, errTx := shipmentItemsOf(lines, req.Items)
+		if errTx != nil {
+			return errTx
+		}
+		shipment = &model.Shipment{
+			OrderId:        req.OrderId,
+			Carrier:        req.Carrier,
+			TrackingNumber: req.TrackingNumber,
+			Items:          items,
+			State:          constants.SHIPMENT_STATE_CREATED,
+		}
+		if errTx = tx.Shipment.WithContext(r.Context()).Create(shipment); errTx != nil {
+			return errTx
+		}
+		orderState, errTx = recordShipped(r.Context(), tx, req.OrderId, lines, items)
+		return errTx
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errOrderNotFulfillable) || errors.Is(err, errLineNotFound) || errors.Is(err, errInvalidLineQuantity) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		util.GetLogger(r.Context()).Error(err.Error(), "order_id", req.OrderId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Shipment was created", "order_id", req.OrderId, "shipment_id", shipment.ID, "state", stateCodeToString(orderState))
+	ctx.notifyFulfilled(r.Context(), orderInfo, orderState, shipment.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(shipment)
+	w.Write(respBody)
+}
+
+// Shipment items of order line quantities
+func shipmentItemsOf(lines []*model.OrderLine, quantities []LineQuantity) (model.ShipmentItems, error) {
+	items := make(model.ShipmentItems, 0, len(quantities))
+	for _, quantity := range quantities {
+		var line *model.OrderLine
+		for _, l := range lines {
+			if l.ID == quantity.LineId {
+				line = l
+			}
+		}
+		if line == nil {
+			return nil, fmt.Errorf("%w: %d", errLineNotFound, quantity.LineId)
+		}
+		items = append(items, model.ShipmentItem{
+			LineId:    line.ID,
+			ProductId: line.ProductId,
+			VariantId: line.VariantId,
+			Sku:       line.Sku,
+			Quantity:  quantity.Quantity,
+		})
+	}
+	return items, nil
+}
+
+// ApplyShipmentUpdate Move a shipment forward to SHIPPED or DELIVERED, repeated updates are ignored.
+// A fulfilled order follows its shipments, it is SHIPPED or DELIVERED when all of them are.
+func (ctx *HandlerContext) ApplyShipmentUpdate(c context.Context, update fulfillment.ShipmentUpdate) error {
+	state, err := fulfillment.ParseUpdateState(update.State)
+	if err != nil {
+		return err
+	}
+
+	var orderId uint
+	updatedOrders := make([]model.Order, 0)
+	orderState := ORDER_STATE_SHIPPED
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		shipment, errTx := tx.Shipment.WithContext(c).Where(tx.Shipment.ID.Eq(update.ShipmentId)).First()
+		if errTx != nil {
+			return gorm.ErrRecordNotFound
+		}
+		orderId = shipment.OrderId
+		if state < shipment.State {
+			return errInvalidShipmentState
+		}
+
+		now := time.Now()
+		updates := make([]field.AssignExpr, 0)
+		if state != shipment.State {
+			updates = append(updates, tx.Shipment.State.Value(state))
+			if shipment.ShippedAt == nil {
+				updates = append(updates, tx.Shipment.ShippedAt.Value(now))
+			}
+			if state == constants.SHIPMENT_STATE_DELIVERED {
+				updates = append(updates, tx.Shipment.DeliveredAt.Value(now))
+			}
+		}
+		if update.Carrier != nil {
+			updates = append(updates, tx.Shipment.Carrier.Value(*update.Carrier))
+		}
+		if update.TrackingNumber != nil {
+			updates = append(updates, tx.Shipment.TrackingNumber.Value(*update.TrackingNumber))
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		if _, errTx = tx.Shipment.WithContext(c).Where(tx.Shipment.ID.Eq(shipment.ID)).UpdateSimple(updates...); errTx != nil {
+			return errTx
+		}
+
+		shipments, errTx := tx.Shipment.WithContext(c).Where(tx.Shipment.OrderId.Eq(shipment.OrderId)).Find()
+		if errTx != nil {
+			return errTx
+		}
+		leastState := state
+		for _, s := range shipments {
+			if s.ID != shipment.ID && s.State < leastState {
+				leastState = s.State
+			}
+		}
+		switch leastState {
+		case constants.SHIPMENT_STATE_CREATED:
+			return nil
+		case constants.SHIPMENT_STATE_DELIVERED:
+			orderState = ORDER_STATE_DELIVERED
+		}
+		_, errTx = tx.Order.WithContext(c).Returning(&updatedOrders, "customer_id", "state").
+			Where(tx.Order.ID.Eq(shipment.OrderId), tx.Order.State.In(ORDER_STATE_FULFILLED, ORDER_STATE_SHIPPED)).
+			UpdateSimple(tx.Order.State.Value(orderState))
+		return errTx
+	})
+	if err != nil {
+		return err
+	}
+	util.GetLogger(c).Info("Shipment was updated", "order_id", orderId, "shipment_id", update.ShipmentId, "state", update.State)
+	if len(updatedOrders) > 0 {
+		eventType := notification.EVENT_ORDER_SHIPPED
+		if orderState == ORDER_STATE_DELIVERED {
+			eventType = notification.EVENT_ORDER_DELIVERED
+		}
+		ctx.notify(c, notification.Event{Type: eventType, OrderId: orderId, CustomerId: updatedOrders[0].CustomerId, ShipmentId: update.ShipmentId})
+	}
+	return nil
+}
+
+// UpdateShipment Shipment updates posted by warehouses, state is SHIPPED or DELIVERED
+func (ctx *HandlerContext) UpdateShipment(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := fulfillment.ShipmentUpdate{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Validate payload
+	if req.ShipmentId == 0 {
+		http.Error(w, "Shipment id is required", http.StatusBadRequest)
+		return
+	}
+	if _, err = fulfillment.ParseUpdateState(req.State); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = ctx.ApplyShipmentUpdate(r.Context(), req)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, constants.SHIPMENT_NOT_FOUND, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errInvalidShipmentState) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		util.GetLogger(r.Context()).Error(err.Error(), "shipment_id", req.ShipmentId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("Update shipment success."))
+}
@@ -2,15 +2,24 @@ package order
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/romana/rlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
 	"net/http"
+	"order_system/custom/fulfillment"
+	"order_system/custom/notification"
 	"order_system/custom/util"
 	"order_system/model"
 )
 
+var tracer = otel.Tracer("order_system/custom/order")
+
 // Order States
 const ORDER_STATE_CREATED = int8(0)
 const ORDER_STATE_AWAITPAYMENT = int8(1)
@@ -18,6 +27,26 @@ const ORDER_STATE_PAID = int8(2)
 const ORDER_STATE_FULFILLED = int8(3)
 const ORDER_STATE_FAILED = int8(4)
 const ORDER_STATE_CANCELED = int8(5)
+const ORDER_STATE_SHIPPED = int8(6)
+const ORDER_STATE_DELIVERED = int8(7)
+const ORDER_STATE_PARTIALLY_FULFILLED = int8(8)
+const ORDER_STATE_FULFILLMENT_FAILED = int8(9)
+const ORDER_STATE_BACKORDERED = int8(10)
+
+// fulfillmentResult Outcome of handing an order over to the fulfillment provider
+type fulfillmentResult struct {
+	// Nil when nothing was shipped
+	shipment *model.Shipment
+	state    int8
+	attempts int
+	err      error
+}
+
+// orderEvent Order pushed to executor, along with the context of the request which triggered it
+type orderEvent struct {
+	ctx   context.Context
+	order *model.Order
+}
 
 func stateCodeToString(state int8) string {
 	switch state {
@@ -33,6 +62,16 @@ func stateCodeToString(state int8) string {
 		return "FAILED"
 	case ORDER_STATE_CANCELED:
 		return "CANCELED"
+	case ORDER_STATE_SHIPPED:
+		return "SHIPPED"
+	case ORDER_STATE_DELIVERED:
+		return "DELIVERED"
+	case ORDER_STATE_PARTIALLY_FULFILLED:
+		return "PARTIALLY FULFILLED"
+	case ORDER_STATE_FULFILLMENT_FAILED:
+		return "FULFILLMENT FAILED"
+	case ORDER_STATE_BACKORDERED:
+		return "BACKORDERED"
 	}
 	return "UNKNOWN"
 }
@@ -40,96 +79,225 @@ func stateCodeToString(state int8) string {
 // ScanPendingOrders Will be used to fetch pending orders and trigger them agan when starting
 func (ctx *HandlerContext) ScanPendingOrders() {
 	orderTable := ctx.db.Order
-	pendingOrders, err := orderTable.Where(orderTable.State.In(ORDER_STATE_CREATED, ORDER_STATE_PAID)).Find()
+	pendingOrders, err := orderTable.Where(orderTable.State.In(ORDER_STATE_CREATED, ORDER_STATE_PAID, ORDER_STATE_PARTIALLY_FULFILLED)).Find()
 	if err != nil {
-		rlog.Error(err)
+		slog.Error(err.Error())
 		return
 	}
 	if len(pendingOrders) > 0 {
-		rlog.Infof("Found %d pending orders.", len(pendingOrders))
+		slog.Info("Found pending orders", "count", len(pendingOrders))
+	}
+	// Orders whose payment isn't captured yet are fulfilled once the capture is made
+	pendingCaptures, err := ctx.pendingCaptures(context.Background())
+	if err != nil {
+		slog.Error(err.Error())
+		return
 	}
 	for _, order := range pendingOrders {
-		ctx.orderChan <- order
+		if pendingCaptures[order.ID] {
+			continue
+		}
+		ctx.orderChan <- &orderEvent{
+			ctx:   util.ContextWithRequestId(context.Background(), util.NewRequestId()),
+			order: order,
+		}
+	}
+	ctx.FillBackorders(util.ContextWithRequestId(context.Background(), util.NewRequestId()))
+	ctx.retryRefunds(util.ContextWithRequestId(context.Background(), util.NewRequestId()))
+	ctx.retryPaymentActions(util.ContextWithRequestId(context.Background(), util.NewRequestId()))
+}
+
+// Queue an order for the executors without blocking, the caller may hold an executor slot while the queue is full
+func (ctx *HandlerContext) enqueueOrder(c context.Context, order *model.Order) {
+	event := &orderEvent{ctx: c, order: order}
+	select {
+	case ctx.orderChan <- event:
+	default:
+		go func() { ctx.orderChan <- event }()
 	}
 }
 
 // ExecuteOrders Execute orders in background go routines
 func (ctx *HandlerContext) ExecuteOrders() {
+	ctx.Worker.Start()
+	defer ctx.Worker.Stop()
 	for true {
-		orderDetail := <-ctx.orderChan
-		if orderDetail == nil {
+		event := <-ctx.orderChan
+		ctx.Worker.Beat()
+		if event == nil || event.order == nil {
 			continue
 		}
+		ctx.limiter.Acquire()
 		go func() {
-			switch orderDetail.State {
+			defer ctx.limiter.Release()
+			c, span := tracer.Start(event.ctx, "order_queue process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(attribute.Int64("order.id", int64(event.order.ID)), attribute.String("order.state", stateCodeToString(event.order.State))))
+			defer span.End()
+			switch event.order.State {
 			case ORDER_STATE_CREATED:
-				ctx.makePayment(orderDetail)
-			case ORDER_STATE_PAID:
-				ctx.fulfillOrder(orderDetail)
+				if err := ctx.makePayment(c, event.order); err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				}
+			case ORDER_STATE_PAID, ORDER_STATE_PARTIALLY_FULFILLED:
+				ctx.fulfillOrder(c, event.order)
+			case ORDER_STATE_BACKORDERED:
+				// The stock may have been replenished since the order was placed
+				ctx.FillBackorders(c)
 			}
 		}()
 	}
 }
 
 // Make a payment
-func (ctx *HandlerContext) makePayment(order *model.Order) error {
-	rlog.Info("Calling payment async API....")
-	errCallPayment := ctx.paymentMethod(order)
+func (ctx *HandlerContext) makePayment(c context.Context, order *model.Order) error {
+	logger := util.GetLogger(c).With("order_id", order.ID)
+	logger.Info("Calling payment async API....")
+	settings := ctx.getSettings()
+	errCallPayment := ctx.paymentMethod(c, order)
+	for retry := 1; errCallPayment != nil && retry <= settings.PaymentApiRetryCount; retry++ {
+		delay := util.Backoff(retry, settings.RetryBackoff, settings.RetryMaxBackoff)
+		logger.Error("Call payment fail: "+errCallPayment.Error(), "retry", retry, "backoff", delay)
+		if err := util.SleepContext(c, delay); err != nil {
+			break
+		}
+		errCallPayment = ctx.paymentMethod(c, order)
+	}
 	if errCallPayment != nil {
-		rlog.Error("Call payment fail:", errCallPayment.Error())
-		// Retry
-		errRetry := ctx.paymentMethod(order)
-		if errRetry != nil {
-			rlog.Error("Retry call payment error:", errRetry.Error())
-			_, err := ctx.db.Order.Where(ctx.db.Order.ID.Eq(order.ID)).Updates(model.Order{FailReason: util.GetStringPtr("Failed to call payment api")})
-			if err != nil {
-				rlog.Error("Update order fail:", err.Error())
-			}
-			return errRetry
+		logger.Error("Retry call payment error: " + errCallPayment.Error())
+		_, err := ctx.db.Order.WithContext(c).Where(ctx.db.Order.ID.Eq(order.ID)).Updates(model.Order{FailReason: util.GetStringPtr("Failed to call payment api")})
+		if err != nil {
+			logger.Error("Update order fail: " + err.Error())
 		}
+		return errCallPayment
 	}
-	rlog.Info("Call payment complete")
-	_, err := ctx.db.Order.Where(ctx.db.Order.ID.Eq(order.ID)).Updates(model.Order{State: ORDER_STATE_AWAITPAYMENT})
+	logger.Info("Call payment complete")
+	// The order may have been canceled meanwhile, its payment is refunded on callback then
+	result, err := ctx.db.Order.WithContext(c).Where(ctx.db.Order.ID.Eq(order.ID), ctx.db.Order.State.Eq(ORDER_STATE_CREATED)).Updates(model.Order{State: ORDER_STATE_AWAITPAYMENT})
 	if err != nil {
-		rlog.Error(err)
+		logger.Error(err.Error())
 		return err
 	}
-	rlog.Infof("Order %d state was seted to %d(%s)", order.ID, ORDER_STATE_AWAITPAYMENT, stateCodeToString(ORDER_STATE_AWAITPAYMENT))
+	if result.RowsAffected == 0 {
+		logger.Info("Order was no longer CREATED after calling payment")
+		return nil
+	}
+	logger.Info("Order state was updated", "state", stateCodeToString(ORDER_STATE_AWAITPAYMENT))
 	return nil
 }
 
-// Fulfill the order
-func (ctx *HandlerContext) fulfillOrder(order *model.Order) {
-	// Assume always success
-	rlog.Info("Processing order...")
+// Fulfill the order, the provider ships the remaining quantity of the order lines or a part of it.
+// Failed hand overs are retried, then the remaining quantity is canceled and refunded. The order is FULFILLMENT FAILED when nothing was shipped.
+func (ctx *HandlerContext) fulfillOrder(c context.Context, order *model.Order) fulfillmentResult {
+	logger := util.GetLogger(c).With("order_id", order.ID)
+	logger.Info("Processing order...")
 
-	_, err := ctx.db.Order.Where(ctx.db.Order.ID.Eq(order.ID)).Updates(model.Order{State: ORDER_STATE_FULFILLED})
-	if err != nil {
-		rlog.Error(err)
+	settings := ctx.getSettings()
+	result := fulfillmentResult{state: order.State}
+	for result.attempts = 1; ; result.attempts++ {
+		result.shipment, result.state, result.err = ctx.shipRemaining(c, order)
+		if errors.Is(result.err, errShipmentNotRecorded) {
+			// The shipment was shipped, it is neither handed over again nor canceled
+			logger.Error("Fulfillment failed: " + result.err.Error())
+			return result
+		}
+		if result.err == nil || errors.Is(result.err, fulfillment.ErrOutOfStock) || result.attempts > settings.FulfillmentRetryCount {
+			break
+		}
+		delay := util.Backoff(result.attempts, settings.RetryBackoff, settings.RetryMaxBackoff)
+		logger.Error("Create shipment failed: "+result.err.Error(), "retry", result.attempts, "backoff", delay)
+		if err := util.SleepContext(c, delay); err != nil {
+			// The order is fulfilled again on restart
+			logger.Warn("Fulfillment was interrupted: " + err.Error())
+			return result
+		}
 	}
-	rlog.Infof("Order %d state was seted to %d(%s)", order.ID, ORDER_STATE_FULFILLED, stateCodeToString(ORDER_STATE_FULFILLED))
+	if result.err != nil {
+		logger.Error("Fulfillment failed: "+result.err.Error(), "attempts", result.attempts)
+		orderInfo, err := ctx.cancelLines(c, order.ID, nil, "Fulfillment failed: "+result.err.Error())
+		if err != nil && !errors.Is(err, errRefundPending) {
+			// The order is fulfilled again on restart
+			logger.Error("Cancel order lines failed: " + err.Error())
+			return result
+		}
+		if err != nil {
+			logger.Error(err.Error())
+		}
+		result.state = orderInfo.State
+		logger.Info("Order state was updated", "state", stateCodeToString(result.state))
+		return result
+	}
+	if result.shipment == nil {
+		logger.Info("Nothing was shipped by the fulfillment provider")
+		return result
+	}
+	logger.Info("Shipment was created", "shipment_id", result.shipment.ID)
+	logger.Info("Order state was updated", "state", stateCodeToString(result.state))
+	ctx.notifyFulfilled(c, order, result.state, result.shipment.ID)
+	return result
+}
+
+// Notify the customer of a fulfilled or partially fulfilled order
+func (ctx *HandlerContext) notifyFulfilled(c context.Context, order *model.Order, state int8, shipmentId uint) {
+	eventType := notification.EVENT_ORDER_FULFILLED
+	if state == ORDER_STATE_PARTIALLY_FULFILLED {
+		eventType = notification.EVENT_ORDER_PARTIALLY_FULFILLED
+	}
+	ctx.notify(c, notification.Event{Type: eventType, OrderId: order.ID, CustomerId: order.CustomerId, ShipmentId: shipmentId})
 }
 
 // CallPaymentApi method for Notifying payment API to start a new payment
-func (ctx *HandlerContext) CallPaymentApi(order *model.Order) error {
+func (ctx *HandlerContext) CallPaymentApi(c context.Context, order *model.Order) error {
+	logger := util.GetLogger(c).With("order_id", order.ID)
 	reqBody, err := json.Marshal(*order)
 	if err != nil {
-		rlog.Error(err)
+		logger.Error(err.Error())
 		return err
 	}
-	r, err := http.NewRequest(http.MethodPost, ctx.PaymentMQUrl, bytes.NewBuffer(reqBody))
+	r, err := http.NewRequestWithContext(c, http.MethodPost, ctx.getSettings().PaymentMQUrl, bytes.NewBuffer(reqBody))
 	if err != nil {
-		rlog.Error(err)
+		logger.Error(err.Error())
 		return err
 	}
 	r.Header.Add("Content-Type", "application/json")
-	response, err := http.DefaultClient.Do(r)
+	util.SetRequestIdHeader(c, r)
+	response, err := util.HttpClient.Do(r)
 	if err != nil {
-		rlog.Error(err)
+		logger.Error(err.Error())
 		return err
 	}
+	defer response.Body.Close()
 	if response.StatusCode != http.StatusOK {
 		return errors.New(fmt.Sprintf("Notify order failed with status code %d", response.StatusCode))
 	}
 	return nil
 }
+
+// CallRefundApi method for asking payment API to refund a part of the order payment
+func (ctx *HandlerContext) CallRefundApi(c context.Context, refund RefundRequest) error {
+	logger := util.GetLogger(c).With("order_id", refund.OrderId)
+	reqBody, err := json.Marshal(refund)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	r, err := http.NewRequestWithContext(c, http.MethodPost, ctx.getSettings().PaymentRefundUrl, bytes.NewBuffer(reqBody))
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	r.Header.Add("Content-Type", "application/json")
+	util.SetRequestIdHeader(c, r)
+	response, err := util.HttpClient.Do(r)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	defer response.Body.Close()
+	// Conflict when the refund was made by an earlier request
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusConflict {
+		return errors.New(fmt.Sprintf("Refund failed with status code %d", response.StatusCode))
+	}
+	return nil
+}
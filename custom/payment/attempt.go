@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/model"
+)
+
+// ErrTransient Gateway errors wrapping it, e.g. a timeout or an unavailable gateway, are retried. Other gateway errors are hard declines.
+var ErrTransient = errors.New("transient gateway error")
+
+// IsTransient Whether a payment attempt failed by a transient gateway error, network timeouts are transient as well
+func IsTransient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, ErrTransient) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// Process an approved payment by the gateway. An attempt failed by a transient gateway error is FAILED and retried
+// by a new payment of the order after a backoff, Order system is only notified of the last attempt.
+// Returns the last attempt with its gateway error, hard declines and transient errors after the last retry aren't retried.
+func (ctx *HandlerContext) attemptPayment(c context.Context, order *model.Order, payment *model.Payment) (*model.Payment, error) {
+	settings := ctx.getSettings()
+	paymentTable := ctx.db.Payment
+	for retry := 1; ; retry++ {
+		err := ctx.paymentMethod(c, order)
+		if err == nil || !IsTransient(err) || retry > settings.AttemptRetryCount {
+			return payment, err
+		}
+		logger := util.GetLogger(c).With("order_id", payment.OrderId, "payment_id", payment.ID)
+		delay := util.Backoff(retry, settings.RetryBackoff, settings.RetryMaxBackoff)
+		logger.Warn("Payment attempt failed: "+err.Error(), "retry", retry, "backoff", delay)
+
+		payment.State = constants.PAYMENT_STATE_FAILED
+		payment.PaymentResult = util.GetStringPtr(err.Error())
+		_, errDb := paymentTable.WithContext(c).Where(paymentTable.ID.Eq(payment.ID)).
+			UpdateSimple(paymentTable.State.Value(payment.State), paymentTable.PaymentResult.Value(*payment.PaymentResult))
+		if errDb != nil {
+			logger.Error("Update failed payment attempt failed: " + errDb.Error())
+			return payment, err
+		}
+		if errSleep := util.SleepContext(c, delay); errSleep != nil {
+			return payment, err
+		}
+		nextAttempt := &model.Payment{
+			OrderId:       payment.OrderId,
+			CustomerId:    payment.CustomerId,
+			Amount:        payment.Amount,
+			Currency:      payment.Currency,
+			BaseAmount:    payment.BaseAmount,
+			State:         constants.PAYMENT_STATE_CREATED,
+			AuthorizeOnly: payment.AuthorizeOnly,
+			Attempt:       retry + 1,
+		}
+		if errDb = paymentTable.WithContext(c).Create(nextAttempt); errDb != nil {
+			logger.Error("Create payment attempt failed: " + errDb.Error())
+			return payment, err
+		}
+		payment = nextAttempt
+		util.GetLogger(c).Info("Payment attempt was created", "order_id", payment.OrderId, "payment_id", payment.ID, "attempt", payment.Attempt)
+	}
+}
@@ -0,0 +1,89 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+)
+
+// CaptureRequest Capture the authorized payment of an order
+type CaptureRequest struct {
+	OrderId uint `json:"order_id"`
+}
+
+// CapturePayment Capture the authorized payment of a backorder, the payment is successful then and can be refunded.
+// The capture is idempotent on the order, a captured payment is returned again without capturing it twice.
+func (ctx *HandlerContext) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := CaptureRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	//Validate Payload
+	if req.OrderId <= 0 {
+		http.Error(w, "Order ID is invalid", http.StatusBadRequest)
+		return
+	}
+
+	var payment *model.Payment
+	captured := false
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		payments, errTx := tx.Payment.WithContext(r.Context()).
+			Where(tx.Payment.OrderId.Eq(req.OrderId), tx.Payment.AuthorizeOnly.Is(true),
+				tx.Payment.State.In(constants.PAYMENT_STATE_AUTHORIZED, constants.PAYMENT_STATE_SUCCESS)).
+			Order(tx.Payment.ID).Find()
+		if errTx != nil {
+			return errTx
+		}
+		if len(payments) == 0 {
+			return errPaymentNotFound
+		}
+		payment = payments[len(payments)-1]
+		if payment.State == constants.PAYMENT_STATE_SUCCESS {
+			// Captured by an earlier request, e.g. when Order system failed to save the filled backorder
+			captured = true
+			return nil
+		}
+		// Call bank or 3rd party payment service to capture, assume always success.
+		payment.State = constants.PAYMENT_STATE_SUCCESS
+		payment.PaymentResult = util.GetStringPtr("Captured")
+		result, errTx := tx.Payment.WithContext(r.Context()).Where(tx.Payment.ID.Eq(payment.ID), tx.Payment.State.Eq(constants.PAYMENT_STATE_AUTHORIZED)).
+			UpdateSimple(tx.Payment.State.Value(payment.State), tx.Payment.PaymentResult.Value(*payment.PaymentResult))
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return errPaymentNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, errPaymentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		util.GetLogger(r.Context()).Error("Capture failed: "+err.Error(), "order_id", req.OrderId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if captured {
+		util.GetLogger(r.Context()).Warn("Payment was captured already", "order_id", req.OrderId, "payment_id", payment.ID)
+	} else {
+		util.GetLogger(r.Context()).Info("Payment was captured", "order_id", req.OrderId, "payment_id", payment.ID, "amount", payment.Amount, "currency", payment.Currency)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(payment)
+	w.Write(respBody)
+}
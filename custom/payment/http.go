@@ -2,28 +2,55 @@ package payment
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/romana/rlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"order_system/constants"
+	"order_system/custom/currency"
+	"order_system/custom/health"
 	"order_system/custom/message_queue"
+	"order_system/custom/risk"
 	"order_system/custom/util"
 	"order_system/dal"
 	"order_system/model"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
-type PaymentMethod func(*model.Order) error
-type OrderCallBackMethod func(PaymentCallBackRequest) error
+var tracer = otel.Tracer("order_system/custom/payment")
+
+type PaymentMethod func(context.Context, *model.Order) error
+type OrderCallBackMethod func(context.Context, PaymentCallBackRequest) error
+
+// Settings Runtime tunable values, swapped atomically when config is reloaded
+type Settings struct {
+	OrderCallBackUrl        string
+	PaymentLimit            model.Money
+	OrderCallbackRetryCount int
+	// Payment attempts failed by a transient gateway error are retried after the backoff, it doubles with every retry up to the max backoff
+	AttemptRetryCount int
+	RetryBackoff      time.Duration
+	RetryMaxBackoff   time.Duration
+	WorkerConcurrency int
+}
 
 type HandlerContext struct {
 	db                  *dal.Query
 	mq                  *message_queue.MessageQueue
 	paymentMethod       PaymentMethod
-	OrderCallBackUrl    string
 	OrderCallbackMethod OrderCallBackMethod
+	settings            atomic.Pointer[Settings]
+	riskRules           atomic.Pointer[risk.RuleTable]
+	limiter             *util.Limiter
+	Worker              *health.Worker
 }
 
 type PaymentCallBackRequest struct {
@@ -35,8 +62,47 @@ func (ctx *HandlerContext) InitialHandlerContext(db *dal.Query, mq *message_queu
 	ctx.db = db
 	ctx.mq = mq
 	ctx.paymentMethod = payMethod
-	ctx.OrderCallBackUrl = callBackUrl
 	ctx.OrderCallbackMethod = orderCallbackMethod
+	ctx.limiter = util.NewLimiter(0)
+	ctx.Worker = health.NewWorker("payment_consumer")
+	ctx.riskRules.Store(&risk.RuleTable{})
+	ctx.ApplySettings(Settings{
+		OrderCallBackUrl: callBackUrl,
+		PaymentLimit:     model.NewMoney(1000, 0),
+	})
+}
+
+// ApplySettings Swap runtime settings, takes effect on the next consumed payment
+func (ctx *HandlerContext) ApplySettings(settings Settings) {
+	ctx.settings.Store(&settings)
+	ctx.limiter.SetLimit(settings.WorkerConcurrency)
+}
+
+// ApplyConfig Apply runtime settings from (reloaded) server config
+func (ctx *HandlerContext) ApplyConfig(c *util.ServerConfig) {
+	ctx.ApplySettings(Settings{
+		OrderCallBackUrl:        c.Order_payment_callback_url,
+		PaymentLimit:            model.MoneyFromFloat(c.Runtime.PaymentLimit),
+		OrderCallbackRetryCount: c.Runtime.OrderCallbackRetryCount,
+		AttemptRetryCount:       c.Runtime.PaymentAttemptRetryCount,
+		RetryBackoff:            time.Duration(c.Runtime.RetryBackoffMs) * time.Millisecond,
+		RetryMaxBackoff:         time.Duration(c.Runtime.RetryMaxBackoffMs) * time.Millisecond,
+		WorkerConcurrency:       c.Runtime.PaymentWorkerConcurrency,
+	})
+}
+
+// SetRiskRules Swap risk rules, takes effect on the next consumed payment
+func (ctx *HandlerContext) SetRiskRules(rules *risk.RuleTable) {
+	ctx.riskRules.Store(rules)
+}
+
+func (ctx *HandlerContext) getSettings() *Settings {
+	return ctx.settings.Load()
+}
+
+// GetPaymentMQCount Number of payments waiting in MQ
+func (ctx *HandlerContext) GetPaymentMQCount() int {
+	return ctx.mq.GetMsgCount()
 }
 
 // PublishPaymentMQ receive payment request from Order system and push it to MQ
@@ -63,6 +129,9 @@ func (ctx *HandlerContext) PublishPaymentMQ(w http.ResponseWriter, r *http.Reque
 	if orderInfo.Amount < 0 {
 		validationErrs = append(validationErrs, errors.New("Order Amount is invalid"))
 	}
+	if orderInfo.Currency != "" && !currency.IsValidCode(orderInfo.Currency) {
+		validationErrs = append(validationErrs, errors.New("Order Currency is invalid"))
+	}
 	if len(validationErrs) > 0 {
 		errInfo := ""
 		for i := range validationErrs {
@@ -72,8 +141,15 @@ func (ctx *HandlerContext) PublishPaymentMQ(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	rlog.Infof("Got a new payment, OrderId=%d, Amout=%.2f", orderInfo.ID, orderInfo.Amount)
-	ctx.mq.Enqueue(&orderInfo)
+	util.GetLogger(r.Context()).Info("Got a new payment", "order_id", orderInfo.ID, "amount", orderInfo.Amount, "currency", orderInfo.Currency)
+	c, span := tracer.Start(r.Context(), "payment_mq publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.Int64("order.id", int64(orderInfo.ID))))
+	msg := message_queue.NewMessage(&orderInfo)
+	msg.Headers[util.REQUEST_ID_HEADER] = util.GetRequestId(c)
+	otel.GetTextMapPropagator().Inject(c, propagation.MapCarrier(msg.Headers))
+	ctx.mq.Enqueue(msg)
+	span.End()
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Operation success."))
@@ -82,99 +158,169 @@ func (ctx *HandlerContext) PublishPaymentMQ(w http.ResponseWriter, r *http.Reque
 
 // ConsumePaymentMQ Consume message from MQ and start new payment
 func (ctx *HandlerContext) ConsumePaymentMQ() {
+	ctx.Worker.Start()
+	defer ctx.Worker.Stop()
 	for {
-		newPaymentOrder := ctx.mq.Dequeue()
-		if newPaymentOrder == nil {
+		msg := ctx.mq.Dequeue()
+		ctx.Worker.Beat()
+		if msg == nil || msg.Order == nil {
 			continue
 		}
-		go ctx.startNewPayment(newPaymentOrder)
+		requestId := msg.Headers[util.REQUEST_ID_HEADER]
+		if requestId == "" {
+			requestId = util.NewRequestId()
+		}
+		c := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(msg.Headers))
+		c = util.ContextWithRequestId(c, requestId)
+		ctx.limiter.Acquire()
+		go func(c context.Context, order *model.Order) {
+			defer ctx.limiter.Release()
+			c, span := tracer.Start(c, "payment_mq process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(attribute.Int64("order.id", int64(order.ID))))
+			defer span.End()
+			if err := ctx.startNewPayment(c, order); err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}(c, msg.Order)
 	}
 }
 
 // Starting a new payment
-func (ctx *HandlerContext) startNewPayment(newOrder *model.Order) error {
+func (ctx *HandlerContext) startNewPayment(c context.Context, newOrder *model.Order) error {
 	// Validate Order
 	if newOrder.ID <= 0 {
 		return errors.New(fmt.Sprintf("Order ID [%d] is invalid", newOrder.ID))
 	}
 	if newOrder.Amount < 0 {
-		return errors.New(fmt.Sprintf("Order Amount [%.2f] is invalid", newOrder.Amount))
+		return errors.New(fmt.Sprintf("Order Amount [%s] is invalid", newOrder.Amount))
+	}
+	// Create new payment, charged in the order currency
+	paymentCurrency := newOrder.Currency
+	if paymentCurrency == "" {
+		paymentCurrency = currency.DEFAULT_CURRENCY
 	}
-	// Create new payment
 	newPayment := model.Payment{
 		OrderId:         newOrder.ID,
+		CustomerId:      newOrder.CustomerId,
 		Amount:          newOrder.Amount,
+		Currency:        paymentCurrency,
+		BaseAmount:      currency.ToBase(newOrder.Amount, newOrder.ExchangeRate),
 		State:           constants.PAYMENT_STATE_CREATED,
+		AuthorizeOnly:   newOrder.Backordered,
+		Attempt:         1,
 		IsNotifiedOrder: false,
 	}
 	paymentTable := ctx.db.Payment
-	errDb := paymentTable.Create(&newPayment)
+	errDb := paymentTable.WithContext(c).Create(&newPayment)
 	if errDb != nil {
 		return errors.New("Failed to create payment in DB with Error: " + errDb.Error())
 	}
-	rlog.Infof("Payment was created, ID=%d,OrderId=%d,Amount=%.2f", newPayment.ID, newPayment.OrderId, newPayment.Amount)
+	logger := util.GetLogger(c).With("order_id", newPayment.OrderId, "payment_id", newPayment.ID)
+	logger.Info("Payment was created", "amount", newPayment.Amount, "currency", newPayment.Currency)
+
+	// Check risk rules, a payment which can't be checked is declined
+	decision, err := risk.Evaluate(c, ctx.db, ctx.riskRules.Load(), &newPayment, newOrder.BillingAddress, ctx.getSettings().PaymentLimit, time.Now())
+	if err != nil {
+		decision = &risk.Decision{Action: risk.DECISION_DECLINE, Reasons: []string{"risk check failed: " + err.Error()}}
+	}
+	logger.Info("Risk rules were checked", "decision", decision.Action, "reasons", decision.Reasons)
+
+	// Hold the payment for review, it is resumed by a reviewer decision
+	if decision.Action == risk.DECISION_REVIEW {
+		newPayment.State = constants.PAYMENT_STATE_REVIEW
+		newPayment.PaymentResult = util.GetStringPtr(decision.Result())
+		_, err = paymentTable.WithContext(c).Where(paymentTable.ID.Eq(newPayment.ID)).
+			UpdateSimple(paymentTable.State.Value(newPayment.State), paymentTable.PaymentResult.Value(*newPayment.PaymentResult))
+		if err != nil {
+			logger.Error("Hold payment for review failed: " + err.Error())
+			return err
+		}
+		logger.Warn("Payment was held for review", "reasons", decision.Reasons)
+		return nil
+	}
+	_, err = ctx.completePayment(c, newOrder, &newPayment, decision)
+	return err
+}
 
+// Process an approved payment or fail a declined one, then notify Order system and save the result.
+// Returns the last attempt of the payment, see attemptPayment.
+func (ctx *HandlerContext) completePayment(c context.Context, order *model.Order, payment *model.Payment, decision *risk.Decision) (*model.Payment, error) {
 	errArray := make([]string, 0)
 	// Process Payment
-	rlog.Info("Starting process payment.")
-	err := ctx.paymentMethod(newOrder)
-	if err != nil {
-		errInfo := err.Error()
+	var errPayment error
+	if decision.Action != risk.DECISION_DECLINE {
+		payment, errPayment = ctx.attemptPayment(c, order, payment)
+	}
+	logger := util.GetLogger(c).With("order_id", payment.OrderId, "payment_id", payment.ID)
+	if decision.Action == risk.DECISION_DECLINE {
+		errInfo := decision.Result()
+		errArray = append(errArray, errInfo)
+		payment.State = constants.PAYMENT_STATE_FAILED
+		payment.PaymentResult = &errInfo
+		logger.Error("Payment was declined: " + errInfo)
+	} else if errPayment != nil {
+		errInfo := errPayment.Error()
 		errArray = append(errArray, errInfo)
-		newPayment.State = constants.PAYMENT_STATE_FAILED
-		newPayment.PaymentResult = &errInfo
-		rlog.Error("Process payment failed: " + errInfo)
+		payment.State = constants.PAYMENT_STATE_FAILED
+		payment.PaymentResult = &errInfo
+		logger.Error("Process payment failed: "+errInfo, "attempt", payment.Attempt)
+	} else if payment.AuthorizeOnly {
+		// Backorders are only authorized, the payment is captured when the order is in stock
+		payment.State = constants.PAYMENT_STATE_AUTHORIZED
+		payment.PaymentResult = util.GetStringPtr("Authorized")
 	} else {
-		newPayment.State = constants.PAYMENT_STATE_SUCCESS
-		newPayment.PaymentResult = util.GetStringPtr("Succeed")
+		payment.State = constants.PAYMENT_STATE_SUCCESS
+		payment.PaymentResult = util.GetStringPtr("Succeed")
 	}
 
 	// Notify Order system
-	err = ctx.notifyOrderSystem(&model.Payment{
-		OrderId: newOrder.ID,
-		State:   newPayment.State,
+	err := ctx.notifyOrderSystem(c, &model.Payment{
+		ID:            payment.ID,
+		OrderId:       payment.OrderId,
+		State:         payment.State,
+		PaymentResult: payment.PaymentResult,
 	})
-	newPayment.IsNotifiedOrder = true
+	payment.IsNotifiedOrder = true
 	if err != nil {
-		newPayment.IsNotifiedOrder = false
-		rlog.Errorf("Notify Payment(PaymentId=%d,OrderId=%d) result to Order System failed due to: %s", newPayment.ID, newPayment.OrderId, err.Error())
+		payment.IsNotifiedOrder = false
+		logger.Error("Notify Payment result to Order System failed due to: " + err.Error())
 		errArray = append(errArray, err.Error())
 	}
 
 	// Update payment result to DB
-	updateResult, err := paymentTable.Where(paymentTable.ID.Eq(newPayment.ID)).Updates(newPayment)
+	paymentTable := ctx.db.Payment
+	updateResult, err := paymentTable.WithContext(c).Where(paymentTable.ID.Eq(payment.ID)).Updates(payment)
 	if err != nil || updateResult.RowsAffected == 0 {
 		errInfo := "Update payment state failed"
 		if err != nil {
 			errInfo += " with error: " + err.Error()
 		}
 		errArray = append(errArray, errInfo)
-		rlog.Error(errInfo)
+		logger.Error(errInfo)
 	} else {
-		rlog.Infof("Payment(ID=%d) state was update to %d", newPayment.ID, newPayment.State)
+		logger.Info("Payment state was updated", "state", payment.State)
 	}
 
 	if len(errArray) > 0 {
 		errInfo := strings.Join(errArray, "\n")
-		return errors.New(errInfo)
+		return payment, errors.New(errInfo)
 	}
-	return nil
+	return payment, nil
 
 }
 
 // ProcessPaymentMethod Process payment, will be mocked in unit test cases
-func (ctx *HandlerContext) ProcessPaymentMethod(newOrder *model.Order) error {
+func (ctx *HandlerContext) ProcessPaymentMethod(c context.Context, newOrder *model.Order) error {
 	// Call bank or 3rd party payment service to process payment.
-	// Assume always success, only failure when exceed payment limit
-	if newOrder.Amount > 1000 {
-		return errors.New(constants.EXCEED_PAYMENT_LIMIT)
-	}
-
+	// Assume always success, the payment limit and other risk rules are checked before.
+	// Transient gateway errors should wrap ErrTransient to be retried.
 	return nil
 }
 
 // Notify payment result to Order system
-func (ctx *HandlerContext) notifyOrderSystem(payment *model.Payment) error {
+func (ctx *HandlerContext) notifyOrderSystem(c context.Context, payment *model.Payment) error {
 	if payment == nil {
 		return errors.New("Payment cannot be nil.")
 	}
@@ -184,33 +330,46 @@ func (ctx *HandlerContext) notifyOrderSystem(payment *model.Payment) error {
 		PaymentDetail: *payment,
 	}
 
-	err := ctx.OrderCallbackMethod(reqObj)
+	logger := util.GetLogger(c).With("order_id", payment.OrderId, "payment_id", payment.ID)
+	retryCount := ctx.getSettings().OrderCallbackRetryCount
+	err := ctx.OrderCallbackMethod(c, reqObj)
+	for retry := 1; err != nil && retry <= retryCount; retry++ {
+		logger.Error("Call Order payment callback API failed with err: "+err.Error(), "retry", retry)
+		err = ctx.OrderCallbackMethod(c, reqObj)
+	}
 	if err != nil {
-		rlog.Error("Call Order payment callback API failed with err: ", err.Error())
+		logger.Error("Call Order payment callback API failed with err: " + err.Error())
 	} else {
-		rlog.Infof("Call Order Payment callback API succeed.")
+		logger.Info("Call Order Payment callback API succeed.")
 	}
 
 	return err
 }
 
 // CallPaymentCallbackAPI call order system's paymentCallback api, will be mocked in unit test cases
-func (ctx *HandlerContext) CallPaymentCallbackAPI(reqObj PaymentCallBackRequest) error {
+func (ctx *HandlerContext) CallPaymentCallbackAPI(c context.Context, reqObj PaymentCallBackRequest) error {
+	logger := util.GetLogger(c).With("order_id", reqObj.OrderId, "payment_id", reqObj.PaymentDetail.ID)
 	reqBody, err := json.Marshal(reqObj)
-	r, err := http.NewRequest(http.MethodPost, ctx.OrderCallBackUrl, bytes.NewBuffer(reqBody))
 	if err != nil {
-		rlog.Error(err)
+		logger.Error(err.Error())
+		return err
+	}
+	r, err := http.NewRequestWithContext(c, http.MethodPost, ctx.getSettings().OrderCallBackUrl, bytes.NewBuffer(reqBody))
+	if err != nil {
+		logger.Error(err.Error())
 		return err
 	}
 	r.Header.Add("Content-Type", "application/json")
-	reponse, err := http.DefaultClient.Do(r)
+	util.SetRequestIdHeader(c, r)
+	reponse, err := util.HttpClient.Do(r)
 	if err != nil {
-		rlog.Error(err)
+		logger.Error(err.Error())
 		return err
 	}
+	defer reponse.Body.Close()
 	if reponse.StatusCode != http.StatusOK {
 		errInfo := fmt.Sprintf("Notify Order system failed with Status code %d", reponse.StatusCode)
-		rlog.Errorf(errInfo)
+		logger.Error(errInfo)
 		return errors.New(errInfo)
 	}
 	return nil
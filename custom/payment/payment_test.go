@@ -2,18 +2,25 @@ package payment
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 	"net/http"
 	"net/http/httptest"
 	"order_system/constants"
 	"order_system/custom/message_queue"
+	"order_system/custom/risk"
 	"order_system/custom/util"
 	"order_system/dal"
 	"order_system/model"
 	"testing"
+	"time"
 )
 
 var (
@@ -21,27 +28,27 @@ var (
 		ID:         1,
 		CustomerId: 2,
 		ProductId:  3,
-		Amount:     100.00,
+		Amount:     model.NewMoney(100, 0),
 		State:      1,
 		FailReason: nil,
 	}
 	testPayment = model.Payment{
 		ID:              1,
 		OrderId:         1,
-		Amount:          100.00,
+		Amount:          model.NewMoney(100, 0),
 		State:           constants.PAYMENT_STATE_CREATED,
 		IsNotifiedOrder: false,
 	}
 )
 
-func mockProcessPayment(order *model.Order) error {
-	if order.Amount > 1000 {
+func mockProcessPayment(c context.Context, order *model.Order) error {
+	if order.Amount > model.NewMoney(1000, 0) {
 		return errors.New("exceed payment limit")
 	}
 	return nil
 }
 
-func mockPaymentCallBackAPI(request PaymentCallBackRequest) error {
+func mockPaymentCallBackAPI(c context.Context, request PaymentCallBackRequest) error {
 	return nil
 }
 
@@ -103,7 +110,7 @@ func TestPublishPaymentMQInvalidPayload(t *testing.T) {
 
 	// Invalid Order Amount
 	newOrder = testOrder
-	newOrder.Amount = -10.00
+	newOrder.Amount = model.NewMoney(-10, 0)
 	reqBody, _ = json.Marshal(newOrder)
 	r = httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
 	handlerCtx.PublishPaymentMQ(w, r)
@@ -127,7 +134,7 @@ func TestStartNewPaymentSuccess(t *testing.T) {
 	mock.ExpectExec(expectSql).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	err := handlerCtx.startNewPayment(&testOrder)
+	err := handlerCtx.startNewPayment(context.Background(), &testOrder)
 	assert.Nil(t, err)
 }
 
@@ -140,12 +147,12 @@ func TestStartNewPaymentInvalidOrder(t *testing.T) {
 
 	newOrder := testOrder
 	newOrder.ID = 0
-	err := handlerCtx.startNewPayment(&newOrder)
+	err := handlerCtx.startNewPayment(context.Background(), &newOrder)
 	assert.Error(t, err)
 
 	newOrder = testOrder
-	newOrder.Amount = -100.00
-	err = handlerCtx.startNewPayment(&newOrder)
+	newOrder.Amount = model.NewMoney(-100, 0)
+	err = handlerCtx.startNewPayment(context.Background(), &newOrder)
 	assert.Error(t, err)
 }
 
@@ -156,31 +163,14 @@ func TestStartNewPaymentExceedLimit(t *testing.T) {
 	mq := message_queue.NewMessageQueue()
 	handlerCtx.InitialHandlerContext(dal.Q, mq, mockProcessPayment, "", mockPaymentCallBackAPI)
 
-	expectSql := ".+"
-	rows, _ := util.ObjectToRows(testPayment)
-	mock.ExpectBegin()
-	mock.ExpectQuery(expectSql).WillReturnRows(rows)
-	mock.ExpectCommit()
-
-	mock.ExpectBegin()
-	mock.ExpectExec(expectSql).WithArgs(
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		constants.PAYMENT_STATE_FAILED,
-		sqlmock.AnyArg(),
-		true,
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectCommit()
+	expectNewPayment(mock, constants.PAYMENT_STATE_FAILED, "Declined: "+constants.EXCEED_PAYMENT_LIMIT)
 
 	newOrder := testOrder
-	newOrder.Amount = 2000.00
-	err := handlerCtx.startNewPayment(&newOrder)
+	newOrder.Amount = model.NewMoney(2000, 0)
+	err := handlerCtx.startNewPayment(context.Background(), &newOrder)
 	assert.Error(t, err)
-	assert.Equal(t, constants.EXCEED_PAYMENT_LIMIT, err.Error())
+	assert.Equal(t, "Declined: "+constants.EXCEED_PAYMENT_LIMIT, err.Error())
+	assert.Nil(t, mock.ExpectationsWereMet())
 }
 
 func TestNotifyOrderSystemSuccess(t *testing.T) {
@@ -192,7 +182,7 @@ func TestNotifyOrderSystemSuccess(t *testing.T) {
 
 	newPayment := testPayment
 	newPayment.State = constants.PAYMENT_STATE_SUCCESS
-	err := handlerCtx.notifyOrderSystem(&newPayment)
+	err := handlerCtx.notifyOrderSystem(context.Background(), &newPayment)
 	assert.Nil(t, err)
 }
 
@@ -203,6 +193,756 @@ func TestNotifyOrderSystemFail(t *testing.T) {
 	mq := message_queue.NewMessageQueue()
 	handlerCtx.InitialHandlerContext(dal.Q, mq, mockProcessPayment, "", mockPaymentCallBackAPI)
 
-	err := handlerCtx.notifyOrderSystem(nil)
+	err := handlerCtx.notifyOrderSystem(context.Background(), nil)
 	assert.Error(t, err)
 }
+
+func TestPublishPaymentMQPropagateRequestId(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	mq := message_queue.NewMessageQueue()
+	handlerCtx.InitialHandlerContext(dal.Q, mq, mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(testOrder)
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	r.Header.Set(util.REQUEST_ID_HEADER, "test-request-id")
+	util.RequestIdMiddleware(http.HandlerFunc(handlerCtx.PublishPaymentMQ)).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "test-request-id", w.Header().Get(util.REQUEST_ID_HEADER))
+	assert.Equal(t, "test-request-id", mq.Dequeue().Headers[util.REQUEST_ID_HEADER])
+}
+
+func TestCallPaymentCallbackAPIPropagateRequestId(t *testing.T) {
+	requestId := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = r.Header.Get(util.REQUEST_ID_HEADER)
+	}))
+	defer server.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, server.URL, mockPaymentCallBackAPI)
+
+	c := util.ContextWithRequestId(context.Background(), "test-request-id")
+	err := handlerCtx.CallPaymentCallbackAPI(c, PaymentCallBackRequest{OrderId: 1, PaymentDetail: testPayment})
+	assert.Nil(t, err)
+	assert.Equal(t, "test-request-id", requestId)
+}
+
+func TestPublishPaymentMQPropagateTraceContext(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	tracerProvider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	_, err := util.InitTracer("payment_api", util.TracingConfig{})
+	assert.Nil(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	handlerCtx := HandlerContext{}
+	mq := message_queue.NewMessageQueue()
+	handlerCtx.InitialHandlerContext(dal.Q, mq, mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(testOrder)
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	util.TracingMiddleware("payment_api", http.HandlerFunc(handlerCtx.PublishPaymentMQ)).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, mq.Dequeue().Headers["traceparent"])
+	assert.Len(t, recorder.Ended(), 2)
+}
+
+// Expect a new payment of testOrder, updated with the state and result once its first attempt was processed
+func expectNewPayment(mock sqlmock.Sqlmock, state int8, result string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^INSERT INTO \"payments\" .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"payments\" SET .+`).
+		WithArgs(testOrder.ID, testOrder.CustomerId, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), state, result, 1, true, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestStartNewPaymentLimitFromSettings(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	newOrder := testOrder
+	newOrder.Amount = model.NewMoney(150, 0)
+	expectNewPayment(mock, constants.PAYMENT_STATE_FAILED, "Declined: "+constants.EXCEED_PAYMENT_LIMIT)
+	handlerCtx.ApplySettings(Settings{PaymentLimit: model.NewMoney(100, 0)})
+	assert.Error(t, handlerCtx.startNewPayment(context.Background(), &newOrder))
+
+	expectNewPayment(mock, constants.PAYMENT_STATE_SUCCESS, "Succeed")
+	handlerCtx.ApplySettings(Settings{PaymentLimit: model.NewMoney(200, 0)})
+	assert.Nil(t, handlerCtx.startNewPayment(context.Background(), &newOrder))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestStartNewPaymentLimitInBaseCurrency(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), handlerCtx.ProcessPaymentMethod, "", mockPaymentCallBackAPI)
+
+	// 30000 TWD at 32 TWD per USD is 937.50 USD, within the 1000 limit
+	newOrder := testOrder
+	newOrder.Amount = model.NewMoney(30000, 0)
+	newOrder.Currency = "TWD"
+	newOrder.ExchangeRate = 32
+	expectNewPayment(mock, constants.PAYMENT_STATE_SUCCESS, "Succeed")
+	assert.Nil(t, handlerCtx.startNewPayment(context.Background(), &newOrder))
+
+	newOrder.Amount = model.NewMoney(33000, 0)
+	expectNewPayment(mock, constants.PAYMENT_STATE_FAILED, "Declined: "+constants.EXCEED_PAYMENT_LIMIT)
+	assert.Error(t, handlerCtx.startNewPayment(context.Background(), &newOrder))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestStartNewPaymentReview(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	notified := false
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", func(c context.Context, request PaymentCallBackRequest) error {
+		notified = true
+		return nil
+	})
+	handlerCtx.SetRiskRules(&risk.RuleTable{ReviewAmount: 50})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^INSERT INTO \"payments\" .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"payments\" SET \"state\"=\$1,\"payment_result\"=\$2,\"updated_at\"=\$3 WHERE \"payments\"\.\"id\" = \$4`).
+		WithArgs(constants.PAYMENT_STATE_REVIEW, "Review: amount 100.00 is above the review threshold 50.00", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.Nil(t, handlerCtx.startNewPayment(context.Background(), &testOrder))
+	assert.Nil(t, mock.ExpectationsWereMet())
+	// The payment is held, Order system is notified once it was reviewed
+	assert.False(t, notified)
+}
+
+func TestStartNewPaymentBlockedCustomer(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	processed := false
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), func(c context.Context, order *model.Order) error {
+		processed = true
+		return nil
+	}, "", mockPaymentCallBackAPI)
+	handlerCtx.SetRiskRules(&risk.RuleTable{Blocklist: risk.Blocklist{CustomerIds: []uint{testOrder.CustomerId}}})
+
+	expectNewPayment(mock, constants.PAYMENT_STATE_FAILED, "Declined: customer 2 is blocked")
+	assert.Error(t, handlerCtx.startNewPayment(context.Background(), &testOrder))
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.False(t, processed)
+}
+
+var (
+	selectOrderPaymentsSQL = `^SELECT \* FROM \"payments\" WHERE \"payments\"\.\"order_id\" = \$1 AND \"payments\"\.\"state\" IN \(\$2,\$3\)`
+	paymentColumns         = []string{"id", "order_id", "amount", "currency", "state"}
+	selectRefundIdSQL      = `^SELECT \* FROM \"payments\" WHERE \"payments\"\.\"refund_id\" = \$1`
+)
+
+func TestRefundPaymentSuccess(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectOrderPaymentsSQL).WithArgs(1, constants.PAYMENT_STATE_SUCCESS, constants.PAYMENT_STATE_REFUND).
+		WillReturnRows(sqlmock.NewRows(append(paymentColumns, "customer_id", "base_amount")).
+			AddRow(1, 1, "100.00", "EUR", constants.PAYMENT_STATE_SUCCESS, 2, "110.00").
+			AddRow(2, 1, "40.00", "EUR", constants.PAYMENT_STATE_REFUND, 2, "44.00"))
+	mock.ExpectQuery(`^INSERT INTO \"payments\" .+`).
+		WithArgs(1, 2, "60.00", "EUR", "66.00", constants.PAYMENT_STATE_REFUND, "Out of stock", false, 1, nil, true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"amount":60,"currency":"EUR","reason":"Out of stock"}`)))
+	handlerCtx.RefundPayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(3), actualResp.ID)
+	assert.Equal(t, constants.PAYMENT_STATE_REFUND, actualResp.State)
+}
+
+func TestRefundPaymentRefundId(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectRefundIdSQL).WithArgs(7).WillReturnRows(sqlmock.NewRows(paymentColumns))
+	mock.ExpectQuery(selectOrderPaymentsSQL).WithArgs(1, constants.PAYMENT_STATE_SUCCESS, constants.PAYMENT_STATE_REFUND).
+		WillReturnRows(sqlmock.NewRows(paymentColumns).AddRow(1, 1, "100.00", "USD", constants.PAYMENT_STATE_SUCCESS))
+	mock.ExpectQuery(`^INSERT INTO \"payments\" .+`).
+		WithArgs(1, 0, "60.00", "USD", "0", constants.PAYMENT_STATE_REFUND, "Out of stock", false, 1, 7, true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"refund_id":7,"order_id":1,"amount":60,"reason":"Out of stock"}`)))
+	handlerCtx.RefundPayment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRefundPaymentDuplicate(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	// The refund id was refunded already, nothing is refunded again
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectRefundIdSQL).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(append(paymentColumns, "refund_id")).AddRow(3, 1, "60.00", "USD", constants.PAYMENT_STATE_REFUND, 7))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"refund_id":7,"order_id":1,"amount":60,"reason":"Out of stock"}`)))
+	handlerCtx.RefundPayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, uint(3), actualResp.ID)
+}
+
+func TestRefundPaymentExceedsPayment(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectOrderPaymentsSQL).WithArgs(1, constants.PAYMENT_STATE_SUCCESS, constants.PAYMENT_STATE_REFUND).
+		WillReturnRows(sqlmock.NewRows(paymentColumns).
+			AddRow(1, 1, "100.00", "USD", constants.PAYMENT_STATE_SUCCESS).
+			AddRow(2, 1, "40.00", "USD", constants.PAYMENT_STATE_REFUND))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"amount":60.01}`)))
+	handlerCtx.RefundPayment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), constants.REFUND_EXCEEDS_PAYMENT)
+}
+
+func TestRefundPaymentNotPaid(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectOrderPaymentsSQL).WillReturnRows(sqlmock.NewRows(paymentColumns))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1,"amount":10}`)))
+	handlerCtx.RefundPayment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStartNewPaymentBackorderAuthorized(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	mq := message_queue.NewMessageQueue()
+	handlerCtx.InitialHandlerContext(dal.Q, mq, mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^INSERT INTO \"payments\" .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	// The payment is saved as authorize only, a reviewed backorder payment is authorized as well
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"payments\" SET .+`).
+		WithArgs(testOrder.ID, testOrder.CustomerId, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), constants.PAYMENT_STATE_AUTHORIZED, "Authorized", true, 1, true, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	newOrder := testOrder
+	newOrder.Backordered = true
+	err := handlerCtx.startNewPayment(context.Background(), &newOrder)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCapturePaymentSuccess(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectAuthorizedPaymentSQL).
+		WithArgs(1, true, constants.PAYMENT_STATE_AUTHORIZED, constants.PAYMENT_STATE_SUCCESS).
+		WillReturnRows(sqlmock.NewRows(paymentColumns).AddRow(4, 1, "100.00", "USD", constants.PAYMENT_STATE_AUTHORIZED))
+	mock.ExpectExec(`^UPDATE \"payments\" SET \"state\"=\$1,\"payment_result\"=\$2,\"updated_at\"=\$3 WHERE \"payments\"\.\"id\" = \$4 AND \"payments\"\.\"state\" = \$5`).
+		WithArgs(constants.PAYMENT_STATE_SUCCESS, "Captured", sqlmock.AnyArg(), 4, constants.PAYMENT_STATE_AUTHORIZED).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1}`)))
+	handlerCtx.CapturePayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, constants.PAYMENT_STATE_SUCCESS, actualResp.State)
+}
+
+// The capture is retried when Order system fails to save the filled backorder, the payment is captured once
+func TestCapturePaymentCaptured(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectAuthorizedPaymentSQL).
+		WithArgs(1, true, constants.PAYMENT_STATE_AUTHORIZED, constants.PAYMENT_STATE_SUCCESS).
+		WillReturnRows(sqlmock.NewRows(paymentColumns).AddRow(4, 1, "100.00", "USD", constants.PAYMENT_STATE_SUCCESS))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1}`)))
+	handlerCtx.CapturePayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(4), actualResp.ID)
+	assert.Equal(t, constants.PAYMENT_STATE_SUCCESS, actualResp.State)
+}
+
+func TestCapturePaymentNotAuthorized(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"payments\" WHERE .+`).WillReturnRows(sqlmock.NewRows(paymentColumns))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1}`)))
+	handlerCtx.CapturePayment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestVoidPaymentSuccess(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectVoidPaymentSQL).
+		WithArgs(1, true, constants.PAYMENT_STATE_AUTHORIZED, constants.PAYMENT_STATE_VOIDED, constants.PAYMENT_STATE_SUCCESS).
+		WillReturnRows(sqlmock.NewRows(paymentColumns).AddRow(4, 1, "100.00", "USD", constants.PAYMENT_STATE_AUTHORIZED))
+	mock.ExpectExec(`^UPDATE \"payments\" SET \"state\"=\$1,\"payment_result\"=\$2,\"updated_at\"=\$3 WHERE \"payments\"\.\"id\" = \$4 AND \"payments\"\.\"state\" = \$5`).
+		WithArgs(constants.PAYMENT_STATE_VOIDED, "Voided", sqlmock.AnyArg(), 4, constants.PAYMENT_STATE_AUTHORIZED).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1}`)))
+	handlerCtx.VoidPayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, constants.PAYMENT_STATE_VOIDED, actualResp.State)
+}
+
+// A retried void returns the voided payment
+func TestVoidPaymentVoided(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectVoidPaymentSQL).
+		WithArgs(1, true, constants.PAYMENT_STATE_AUTHORIZED, constants.PAYMENT_STATE_VOIDED, constants.PAYMENT_STATE_SUCCESS).
+		WillReturnRows(sqlmock.NewRows(paymentColumns).AddRow(4, 1, "100.00", "USD", constants.PAYMENT_STATE_VOIDED))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1}`)))
+	handlerCtx.VoidPayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(4), actualResp.ID)
+	assert.Equal(t, constants.PAYMENT_STATE_VOIDED, actualResp.State)
+}
+
+// The authorized payment was captured meanwhile, it is refunded by Order system instead
+func TestVoidPaymentCaptured(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectVoidPaymentSQL).
+		WithArgs(1, true, constants.PAYMENT_STATE_AUTHORIZED, constants.PAYMENT_STATE_VOIDED, constants.PAYMENT_STATE_SUCCESS).
+		WillReturnRows(sqlmock.NewRows(paymentColumns).AddRow(4, 1, "100.00", "USD", constants.PAYMENT_STATE_SUCCESS))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1}`)))
+	handlerCtx.VoidPayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, constants.PAYMENT_STATE_SUCCESS, actualResp.State)
+}
+
+func TestVoidPaymentNotAuthorized(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectVoidPaymentSQL).WillReturnRows(sqlmock.NewRows(paymentColumns))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"order_id":1}`)))
+	handlerCtx.VoidPayment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+var (
+	selectVoidPaymentSQL       = `^SELECT \* FROM \"payments\" WHERE \"payments\"\.\"order_id\" = \$1 AND \"payments\"\.\"authorize_only\" = \$2 AND \"payments\"\.\"state\" IN \(\$3,\$4,\$5\) ORDER BY \"payments\"\.\"id\"`
+	selectAuthorizedPaymentSQL = `^SELECT \* FROM \"payments\" WHERE \"payments\"\.\"order_id\" = \$1 AND \"payments\"\.\"authorize_only\" = \$2 AND \"payments\"\.\"state\" IN \(\$3,\$4\) ORDER BY \"payments\"\.\"id\"`
+	selectHeldPaymentSQL       = `^SELECT \* FROM \"payments\" WHERE \"payments\"\.\"id\" = \$1 AND \"payments\"\.\"state\" = \$2`
+	heldPaymentColumns         = []string{"id", "order_id", "customer_id", "amount", "currency", "state", "payment_result"}
+	heldPaymentResult          = "Review: amount 600.00 is above the review threshold 500.00; 6 payments within 10 minutes"
+)
+
+// Expect a held payment to be taken out of review and the reviewer decision to be recorded
+func expectReview(mock sqlmock.Sqlmock, decision string, reviewer string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectHeldPaymentSQL).WithArgs(4, constants.PAYMENT_STATE_REVIEW, 1).
+		WillReturnRows(sqlmock.NewRows(heldPaymentColumns).AddRow(4, 1, 2, "600.00", "USD", constants.PAYMENT_STATE_REVIEW, heldPaymentResult))
+	mock.ExpectExec(`^UPDATE \"payments\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"payments\"\.\"id\" = \$3 AND \"payments\"\.\"state\" = \$4`).
+		WithArgs(constants.PAYMENT_STATE_CREATED, sqlmock.AnyArg(), 4, constants.PAYMENT_STATE_REVIEW).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`^INSERT INTO \"payment_reviews\" .+`).
+		WithArgs(4, 1, decision, reviewer, sqlmock.AnyArg(), heldPaymentResult, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+func TestReviewQueue(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectQuery(`^SELECT \* FROM \"payments\" WHERE \"payments\"\.\"state\" = \$1 ORDER BY \"payments\"\.\"id\"`).
+		WithArgs(constants.PAYMENT_STATE_REVIEW).
+		WillReturnRows(sqlmock.NewRows(heldPaymentColumns).AddRow(4, 1, 2, "600.00", "USD", constants.PAYMENT_STATE_REVIEW, heldPaymentResult))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", nil)
+	handlerCtx.ReviewQueue(w, r)
+
+	actualResp := make([]HeldPayment, 0)
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, actualResp, 1)
+	assert.Equal(t, uint(4), actualResp[0].ID)
+	assert.Equal(t, []string{"amount 600.00 is above the review threshold 500.00", "6 payments within 10 minutes"}, actualResp[0].Reasons)
+}
+
+func TestReviewPaymentApprove(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	var notifiedState int8 = -1
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", func(c context.Context, request PaymentCallBackRequest) error {
+		notifiedState = request.PaymentDetail.State
+		return nil
+	})
+
+	expectReview(mock, constants.REVIEW_DECISION_APPROVE, "alice")
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"payments\" SET .+`).
+		WithArgs(1, 2, sqlmock.AnyArg(), "USD", constants.PAYMENT_STATE_SUCCESS, "Succeed", true, sqlmock.AnyArg(), 4, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"payment_id":4,"decision":"approve","reviewer":"alice"}`)))
+	handlerCtx.ReviewPayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, constants.PAYMENT_STATE_SUCCESS, actualResp.State)
+	assert.True(t, actualResp.IsNotifiedOrder)
+	assert.Equal(t, constants.PAYMENT_STATE_SUCCESS, notifiedState)
+}
+
+func TestReviewPaymentReject(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	processed := false
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), func(c context.Context, order *model.Order) error {
+		processed = true
+		return nil
+	}, "", mockPaymentCallBackAPI)
+
+	expectReview(mock, constants.REVIEW_DECISION_REJECT, "bob")
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"payments\" SET .+`).
+		WithArgs(1, 2, sqlmock.AnyArg(), "USD", constants.PAYMENT_STATE_FAILED, "Declined: rejected by reviewer bob: stolen card", true, sqlmock.AnyArg(), 4, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"payment_id":4,"decision":"REJECT","reviewer":"bob","note":"stolen card"}`)))
+	handlerCtx.ReviewPayment(w, r)
+
+	actualResp := model.Payment{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, constants.PAYMENT_STATE_FAILED, actualResp.State)
+	assert.False(t, processed)
+}
+
+func TestReviewPaymentNotInReview(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectHeldPaymentSQL).WillReturnRows(sqlmock.NewRows(heldPaymentColumns))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"payment_id":4,"decision":"APPROVE","reviewer":"alice"}`)))
+	handlerCtx.ReviewPayment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReviewPaymentDbError(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectHeldPaymentSQL).WillReturnError(errors.New("connection refused"))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"payment_id":4,"decision":"APPROVE","reviewer":"alice"}`)))
+	handlerCtx.ReviewPayment(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestReviewPaymentInvalidPayload(t *testing.T) {
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	for _, payload := range []string{
+		`{"decision":"APPROVE","reviewer":"alice"}`,
+		`{"payment_id":4,"decision":"ESCALATE","reviewer":"alice"}`,
+		`{"payment_id":4,"decision":"APPROVE","reviewer":" "}`,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(payload)))
+		handlerCtx.ReviewPayment(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+	}
+}
+
+func TestListReviews(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), mockProcessPayment, "", mockPaymentCallBackAPI)
+
+	mock.ExpectQuery(`^SELECT \* FROM \"payment_reviews\" WHERE \"payment_reviews\"\.\"reviewer\" = \$1 ORDER BY \"payment_reviews\"\.\"id\" DESC`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payment_id", "order_id", "decision", "reviewer", "reasons"}).
+			AddRow(2, 4, 1, constants.REVIEW_DECISION_APPROVE, "alice", heldPaymentResult))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"reviewer":"alice"}`)))
+	handlerCtx.ListReviews(w, r)
+
+	actualResp := make([]model.PaymentReview, 0)
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, actualResp, 1)
+	assert.Equal(t, constants.REVIEW_DECISION_APPROVE, actualResp[0].Decision)
+}
+
+// Expect a payment attempt failed by a transient gateway error and the next attempt created
+func expectRetriedAttempt(mock sqlmock.Sqlmock, paymentId uint, result string, nextAttempt int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"payments\" SET \"state\"=\$1,\"payment_result\"=\$2,\"updated_at\"=\$3 WHERE \"payments\"\.\"id\" = \$4`).
+		WithArgs(constants.PAYMENT_STATE_FAILED, result, sqlmock.AnyArg(), paymentId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^INSERT INTO \"payments\" .+`).
+		WithArgs(testOrder.ID, testOrder.CustomerId, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), constants.PAYMENT_STATE_CREATED, nil, false, nextAttempt, nil, false, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(paymentId + 1))
+	mock.ExpectCommit()
+}
+
+func TestStartNewPaymentRetryTransient(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	attempts := 0
+	callbacks := make([]PaymentCallBackRequest, 0)
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), func(c context.Context, order *model.Order) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("%w: gateway timeout", ErrTransient)
+		}
+		return nil
+	}, "", func(c context.Context, request PaymentCallBackRequest) error {
+		callbacks = append(callbacks, request)
+		return nil
+	})
+	handlerCtx.ApplySettings(Settings{PaymentLimit: model.NewMoney(1000, 0), AttemptRetryCount: 3, RetryBackoff: time.Millisecond})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^INSERT INTO \"payments\" .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expectRetriedAttempt(mock, 1, "transient gateway error: gateway timeout", 2)
+	expectRetriedAttempt(mock, 2, "transient gateway error: gateway timeout", 3)
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"payments\" SET .+`).
+		WithArgs(testOrder.ID, testOrder.CustomerId, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), constants.PAYMENT_STATE_SUCCESS, "Succeed", 3, true, sqlmock.AnyArg(), sqlmock.AnyArg(), 3, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, handlerCtx.startNewPayment(context.Background(), &testOrder))
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 3, attempts)
+	// Order system is only notified of the last attempt
+	assert.Len(t, callbacks, 1)
+	assert.Equal(t, uint(3), callbacks[0].PaymentDetail.ID)
+	assert.Equal(t, constants.PAYMENT_STATE_SUCCESS, callbacks[0].PaymentDetail.State)
+}
+
+func TestStartNewPaymentRetryExhausted(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	attempts := 0
+	callbacks := make([]PaymentCallBackRequest, 0)
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), func(c context.Context, order *model.Order) error {
+		attempts++
+		return fmt.Errorf("%w: gateway unavailable", ErrTransient)
+	}, "", func(c context.Context, request PaymentCallBackRequest) error {
+		callbacks = append(callbacks, request)
+		return nil
+	})
+	handlerCtx.ApplySettings(Settings{PaymentLimit: model.NewMoney(1000, 0), AttemptRetryCount: 1, RetryBackoff: time.Millisecond})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^INSERT INTO \"payments\" .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expectRetriedAttempt(mock, 1, "transient gateway error: gateway unavailable", 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"payments\" SET .+`).
+		WithArgs(testOrder.ID, testOrder.CustomerId, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), constants.PAYMENT_STATE_FAILED, "transient gateway error: gateway unavailable", 2, true, sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Error(t, handlerCtx.startNewPayment(context.Background(), &testOrder))
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 2, attempts)
+	assert.Len(t, callbacks, 1)
+	assert.Equal(t, constants.PAYMENT_STATE_FAILED, callbacks[0].PaymentDetail.State)
+}
+
+func TestStartNewPaymentHardDeclineNotRetried(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	attempts := 0
+	handlerCtx.InitialHandlerContext(dal.Q, message_queue.NewMessageQueue(), func(c context.Context, order *model.Order) error {
+		attempts++
+		return errors.New("insufficient funds")
+	}, "", mockPaymentCallBackAPI)
+	handlerCtx.ApplySettings(Settings{PaymentLimit: model.NewMoney(1000, 0), AttemptRetryCount: 3, RetryBackoff: time.Millisecond})
+
+	expectNewPayment(mock, constants.PAYMENT_STATE_FAILED, "insufficient funds")
+	assert.Error(t, handlerCtx.startNewPayment(context.Background(), &testOrder))
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, attempts)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(fmt.Errorf("%w: gateway timeout", ErrTransient)))
+	assert.True(t, IsTransient(fmt.Errorf("call gateway failed: %w", timeoutError{})))
+	assert.False(t, IsTransient(errors.New("insufficient funds")))
+	assert.False(t, IsTransient(nil))
+}
@@ -0,0 +1,133 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+)
+
+var (
+	errPaymentNotFound      = errors.New(constants.PAYMENT_NOT_FOUND)
+	errRefundExceedsPayment = errors.New(constants.REFUND_EXCEEDS_PAYMENT)
+	errRefundAlreadyMade    = errors.New(constants.REFUND_ALREADY_MADE)
+)
+
+// RefundRequest Refund a part of the payment of an order, in the order currency.
+// The refund id of Order system is the idempotency key, a refund is made once however often it is requested.
+type RefundRequest struct {
+	RefundId uint        `json:"refund_id"`
+	OrderId  uint        `json:"order_id"`
+	Amount   model.Money `json:"amount"`
+	Currency string      `json:"currency"`
+	Reason   string      `json:"reason"`
+}
+
+// RefundPayment Refund a part of the successful payment of an order, the refunds of an order never exceed its payment.
+// A refund id which was refunded already is rejected with Conflict and the refund made for it.
+func (ctx *HandlerContext) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := RefundRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	//Validate Payload
+	if req.OrderId <= 0 {
+		http.Error(w, "Order ID is invalid", http.StatusBadRequest)
+		return
+	}
+	if req.Amount <= 0 {
+		http.Error(w, "Refund Amount is invalid", http.StatusBadRequest)
+		return
+	}
+
+	refund := model.Payment{
+		OrderId:         req.OrderId,
+		Amount:          req.Amount,
+		Currency:        req.Currency,
+		State:           constants.PAYMENT_STATE_REFUND,
+		PaymentResult:   util.GetStringPtr(req.Reason),
+		IsNotifiedOrder: true,
+	}
+	if req.RefundId > 0 {
+		refund.RefundId = &req.RefundId
+	}
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		if req.RefundId > 0 {
+			// The unique refund id rejects a concurrent request which isn't found here
+			made, errTx := tx.Payment.WithContext(r.Context()).Where(tx.Payment.RefundId.Eq(req.RefundId)).Find()
+			if errTx != nil {
+				return errTx
+			}
+			if len(made) > 0 {
+				refund = *made[0]
+				return errRefundAlreadyMade
+			}
+		}
+		payments, errTx := tx.Payment.WithContext(r.Context()).
+			Where(tx.Payment.OrderId.Eq(req.OrderId), tx.Payment.State.In(constants.PAYMENT_STATE_SUCCESS, constants.PAYMENT_STATE_REFUND)).Find()
+		if errTx != nil {
+			return errTx
+		}
+		var paid, paidBase, refunded model.Money
+		for _, payment := range payments {
+			if payment.State == constants.PAYMENT_STATE_SUCCESS {
+				paid = paid.Add(payment.Amount)
+				paidBase = paidBase.Add(payment.BaseAmount)
+				refund.Currency = payment.Currency
+				refund.CustomerId = payment.CustomerId
+			} else {
+				refunded = refunded.Add(payment.Amount)
+			}
+		}
+		if paid == 0 {
+			return errPaymentNotFound
+		}
+		if req.Currency != "" && req.Currency != refund.Currency {
+			return fmt.Errorf("%w: the order was paid in %s", errRefundExceedsPayment, refund.Currency)
+		}
+		if refunded.Add(req.Amount) > paid {
+			return fmt.Errorf("%w: %s of %s was refunded already", errRefundExceedsPayment, refunded, paid)
+		}
+		refund.BaseAmount = paidBase.MulRatio(req.Amount.MinorUnits(), paid.MinorUnits())
+		// Call bank or 3rd party payment service to refund, assume always success.
+		return tx.Payment.WithContext(r.Context()).Create(&refund)
+	})
+	if errors.Is(err, errRefundAlreadyMade) {
+		util.GetLogger(r.Context()).Warn("Refund was requested again", "order_id", req.OrderId, "refund_id", req.RefundId, "payment_id", refund.ID)
+		w.WriteHeader(http.StatusConflict)
+		w.Header().Set("Content-Type", "application/json")
+		respBody, _ := json.Marshal(refund)
+		w.Write(respBody)
+		return
+	}
+	if errors.Is(err, errPaymentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errRefundExceedsPayment) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		util.GetLogger(r.Context()).Error("Refund failed: "+err.Error(), "order_id", req.OrderId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Payment was refunded", "order_id", req.OrderId, "payment_id", refund.ID, "amount", refund.Amount, "currency", refund.Currency)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(refund)
+	w.Write(respBody)
+}
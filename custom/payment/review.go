@@ -0,0 +1,206 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/risk"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"strings"
+)
+
+var errPaymentNotInReview = errors.New(constants.PAYMENT_NOT_IN_REVIEW)
+
+// HeldPayment Payment held for review with the reasons of the risk rules
+type HeldPayment struct {
+	*model.Payment
+	Reasons []string `json:"reasons"`
+}
+
+// ReviewRequest Approve or reject a payment held for review
+type ReviewRequest struct {
+	PaymentId uint    `json:"payment_id"`
+	Decision  string  `json:"decision"`
+	Reviewer  string  `json:"reviewer"`
+	Note      *string `json:"note,omitempty"`
+}
+
+// ListReviewsRequest Filter reviewer decisions, every filter is optional
+type ListReviewsRequest struct {
+	PaymentId uint   `json:"payment_id"`
+	Reviewer  string `json:"reviewer"`
+}
+
+// ReviewQueue List payments held for review, oldest first
+func (ctx *HandlerContext) ReviewQueue(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	paymentTable := ctx.db.Payment
+	payments, errDb := paymentTable.WithContext(r.Context()).Where(paymentTable.State.Eq(constants.PAYMENT_STATE_REVIEW)).Order(paymentTable.ID).Find()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+	heldPayments := make([]HeldPayment, 0, len(payments))
+	for _, payment := range payments {
+		heldPayment := HeldPayment{Payment: payment}
+		if payment.PaymentResult != nil {
+			heldPayment.Reasons = risk.ReasonsOf(*payment.PaymentResult)
+		}
+		heldPayments = append(heldPayments, heldPayment)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(heldPayments)
+	w.Write(respBody)
+}
+
+// ReviewPayment Approve or reject a payment held for review, then resume it and notify Order system.
+// An approved payment is processed as if the risk rules approved it, a rejected one fails.
+func (ctx *HandlerContext) ReviewPayment(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := ReviewRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	//Validate Payload
+	if req.PaymentId <= 0 {
+		http.Error(w, "Payment ID is invalid", http.StatusBadRequest)
+		return
+	}
+	req.Decision = strings.ToUpper(req.Decision)
+	if req.Decision != constants.REVIEW_DECISION_APPROVE && req.Decision != constants.REVIEW_DECISION_REJECT {
+		http.Error(w, fmt.Sprintf("Decision must be %s or %s", constants.REVIEW_DECISION_APPROVE, constants.REVIEW_DECISION_REJECT), http.StatusBadRequest)
+		return
+	}
+	req.Reviewer = strings.TrimSpace(req.Reviewer)
+	if req.Reviewer == "" {
+		http.Error(w, "Reviewer is required", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := ctx.reviewPayment(r.Context(), &req)
+	if errors.Is(err, errPaymentNotInReview) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		util.GetLogger(r.Context()).Error("Review payment failed: "+err.Error(), "payment_id", req.PaymentId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(payment)
+	w.Write(respBody)
+}
+
+// ListReviews List reviewer decisions, newest first
+func (ctx *HandlerContext) ListReviews(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	req := ListReviewsRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reviewTable := ctx.db.PaymentReview
+	query := reviewTable.WithContext(r.Context())
+	if req.PaymentId > 0 {
+		query = query.Where(reviewTable.PaymentId.Eq(req.PaymentId))
+	}
+	if req.Reviewer != "" {
+		query = query.Where(reviewTable.Reviewer.Eq(req.Reviewer))
+	}
+	reviews, errDb := query.Order(reviewTable.ID.Desc()).Find()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(reviews)
+	w.Write(respBody)
+}
+
+// Take the payment out of review and record the reviewer decision in one transaction, then complete the payment.
+// Errors of the completion are logged only, the saved payment shows its state and whether Order system was notified.
+func (ctx *HandlerContext) reviewPayment(c context.Context, req *ReviewRequest) (*model.Payment, error) {
+	var payment *model.Payment
+	err := ctx.db.Transaction(func(tx *dal.Query) error {
+		var errTx error
+		payment, errTx = tx.Payment.WithContext(c).
+			Where(tx.Payment.ID.Eq(req.PaymentId), tx.Payment.State.Eq(constants.PAYMENT_STATE_REVIEW)).First()
+		if errors.Is(errTx, gorm.ErrRecordNotFound) {
+			return errPaymentNotInReview
+		}
+		if errTx != nil {
+			return errTx
+		}
+		// The conditional update lets only one reviewer decide on the payment
+		result, errTx := tx.Payment.WithContext(c).Where(tx.Payment.ID.Eq(payment.ID), tx.Payment.State.Eq(constants.PAYMENT_STATE_REVIEW)).
+			UpdateSimple(tx.Payment.State.Value(constants.PAYMENT_STATE_CREATED))
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return errPaymentNotInReview
+		}
+		return tx.PaymentReview.WithContext(c).Create(&model.PaymentReview{
+			PaymentId: payment.ID,
+			OrderId:   payment.OrderId,
+			Decision:  req.Decision,
+			Reviewer:  req.Reviewer,
+			Note:      req.Note,
+			Reasons:   payment.PaymentResult,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger := util.GetLogger(c).With("order_id", payment.OrderId, "payment_id", payment.ID)
+	logger.Info("Payment was reviewed", "decision", req.Decision, "reviewer", req.Reviewer)
+
+	decision := &risk.Decision{Action: risk.DECISION_APPROVE}
+	if req.Decision == constants.REVIEW_DECISION_REJECT {
+		reason := "rejected by reviewer " + req.Reviewer
+		if req.Note != nil && *req.Note != "" {
+			reason += ": " + *req.Note
+		}
+		decision = &risk.Decision{Action: risk.DECISION_DECLINE, Reasons: []string{reason}}
+	}
+	order := &model.Order{
+		ID:          payment.OrderId,
+		CustomerId:  payment.CustomerId,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Backordered: payment.AuthorizeOnly,
+	}
+	if payment, err = ctx.completePayment(c, order, payment, decision); err != nil {
+		logger.Warn("Reviewed payment was completed with errors: " + err.Error())
+	}
+	return payment, nil
+}
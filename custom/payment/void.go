@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+)
+
+// The authorized payment was captured meanwhile, it can only be refunded
+var errPaymentCaptured = errors.New(constants.PAYMENT_CAPTURED)
+
+// VoidRequest Void the authorized payment of an order
+type VoidRequest struct {
+	OrderId uint `json:"order_id"`
+}
+
+// VoidPayment Void the authorized payment of a canceled backorder, the authorized amount is released and can't be captured.
+// The void is idempotent on the order, a voided payment is returned again without voiding it twice. A captured payment is a conflict, it is refunded instead.
+func (ctx *HandlerContext) VoidPayment(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := VoidRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	//Validate Payload
+	if req.OrderId <= 0 {
+		http.Error(w, "Order ID is invalid", http.StatusBadRequest)
+		return
+	}
+
+	var payment *model.Payment
+	voided := false
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		payments, errTx := tx.Payment.WithContext(r.Context()).
+			Where(tx.Payment.OrderId.Eq(req.OrderId), tx.Payment.AuthorizeOnly.Is(true),
+				tx.Payment.State.In(constants.PAYMENT_STATE_AUTHORIZED, constants.PAYMENT_STATE_VOIDED, constants.PAYMENT_STATE_SUCCESS)).
+			Order(tx.Payment.ID).Find()
+		if errTx != nil {
+			return errTx
+		}
+		if len(payments) == 0 {
+			return errPaymentNotFound
+		}
+		payment = payments[len(payments)-1]
+		if payment.State == constants.PAYMENT_STATE_SUCCESS {
+			return errPaymentCaptured
+		}
+		if payment.State == constants.PAYMENT_STATE_VOIDED {
+			// Voided by an earlier request, e.g. when Order system retried the void
+			voided = true
+			return nil
+		}
+		// Call bank or 3rd party payment service to release the authorization, assume always success.
+		payment.State = constants.PAYMENT_STATE_VOIDED
+		payment.PaymentResult = util.GetStringPtr("Voided")
+		result, errTx := tx.Payment.WithContext(r.Context()).Where(tx.Payment.ID.Eq(payment.ID), tx.Payment.State.Eq(constants.PAYMENT_STATE_AUTHORIZED)).
+			UpdateSimple(tx.Payment.State.Value(payment.State), tx.Payment.PaymentResult.Value(*payment.PaymentResult))
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return errPaymentNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, errPaymentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errPaymentCaptured) {
+		util.GetLogger(r.Context()).Warn("Void of a captured payment was requested", "order_id", req.OrderId, "payment_id", payment.ID)
+		w.WriteHeader(http.StatusConflict)
+		w.Header().Set("Content-Type", "application/json")
+		respBody, _ := json.Marshal(payment)
+		w.Write(respBody)
+		return
+	}
+	if err != nil {
+		util.GetLogger(r.Context()).Error("Void failed: "+err.Error(), "order_id", req.OrderId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if voided {
+		util.GetLogger(r.Context()).Warn("Payment was voided already", "order_id", req.OrderId, "payment_id", payment.ID)
+	} else {
+		util.GetLogger(r.Context()).Info("Payment was voided", "order_id", req.OrderId, "payment_id", payment.ID, "amount", payment.Amount, "currency", payment.Currency)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(payment)
+	w.Write(respBody)
+}
@@ -1,27 +1,87 @@
 package product
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
+	"order_system/custom/category"
+	"order_system/custom/currency"
 	"order_system/custom/util"
 	"order_system/dal"
 	"order_system/model"
+	"strings"
 )
 
+// RestockHook Called when the stock of a product may have been replenished, e.g. to fill backorders
+type RestockHook func(c context.Context, productId uint)
+
 type HandlerContext struct {
-	db *dal.Query
+	db          *dal.Query
+	restockHook RestockHook
 }
 
 type CreateProductsRequest struct {
 	Products *[]model.Product `json:"products"`
 }
 
+// UpdateProductRequest Only the given fields are updated
+type UpdateProductRequest struct {
+	ID             uint         `json:"id"`
+	Name           *string      `json:"name,omitempty"`
+	Description    *string      `json:"description,omitempty"`
+	Price          *model.Money `json:"price,omitempty"`
+	Currency       *string      `json:"currency,omitempty"`
+	IsAvailable    *bool        `json:"is_available,omitempty"`
+	AllowBackorder *bool        `json:"allow_backorder,omitempty"`
+	CategoryId     *uint        `json:"category_id,omitempty"`
+}
+
+// ListProductsRequest Every filter is optional, query is a full-text search on name and description
+type ListProductsRequest struct {
+	Query       string       `json:"query,omitempty"`
+	MinPrice    *model.Money `json:"min_price,omitempty"`
+	MaxPrice    *model.Money `json:"max_price,omitempty"`
+	Currency    string       `json:"currency,omitempty"`
+	IsAvailable *bool        `json:"is_available,omitempty"`
+	// Products of the category and all its sub categories
+	CategoryId *uint  `json:"category_id,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+}
+
+type ListProductsResponse struct {
+	Products []*model.Product `json:"products"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+}
+
+type SetProductPricesRequest struct {
+	ProductId uint                 `json:"product_id"`
+	Prices    []model.ProductPrice `json:"prices"`
+}
+
 func (ctx *HandlerContext) InitialHandlerContext(db *dal.Query) {
 	ctx.db = db
 }
 
+// SetRestockHook Set the hook called when a product is available again or the stock of a SKU is set
+func (ctx *HandlerContext) SetRestockHook(hook RestockHook) {
+	ctx.restockHook = hook
+}
+
+func (ctx *HandlerContext) restocked(c context.Context, productId uint) {
+	if ctx.restockHook != nil {
+		ctx.restockHook(c, productId)
+	}
+}
+
 // CreateProducts Create new Products
 func (ctx *HandlerContext) CreateProducts(w http.ResponseWriter, r *http.Request) {
 	// Validate http method
@@ -39,20 +99,37 @@ func (ctx *HandlerContext) CreateProducts(w http.ResponseWriter, r *http.Request
 	// Validate Payload
 	validationErr := ""
 	for i := range *req.Products {
-		if (*req.Products)[i].Name == "" {
+		product := &(*req.Products)[i]
+		if product.Name == "" {
 			validationErr += fmt.Sprintf("The %d product name is required.", i+1)
 		}
+		if product.Currency == "" {
+			product.Currency = currency.DEFAULT_CURRENCY
+		}
+		if !currency.IsValidCode(product.Currency) {
+			validationErr += fmt.Sprintf("The %d product currency is invalid.", i+1)
+		}
+		validationErr += validatePrices(product.Prices, fmt.Sprintf("The %d product", i+1))
 	}
 	if validationErr != "" {
 		http.Error(w, validationErr, http.StatusBadRequest)
+		return
 	}
 
 	createdProducts := make([]model.Product, 0)
 	err = ctx.db.Transaction(func(tx *dal.Query) error {
 		for _, product := range *req.Products {
-			if errCreate := tx.Product.Create(&product); errCreate != nil {
+			if errCreate := tx.Product.WithContext(r.Context()).Create(&product); errCreate != nil {
 				return errors.New(product.Name + ": " + errCreate.Error())
 			}
+			if len(product.Prices) > 0 {
+				for i := range product.Prices {
+					product.Prices[i].ProductId = product.ID
+				}
+				if errCreate := tx.ProductPrice.WithContext(r.Context()).Create(sliceOfPtr(product.Prices)...); errCreate != nil {
+					return errors.New(product.Name + ": " + errCreate.Error())
+				}
+			}
 			createdProducts = append(createdProducts, product)
 		}
 		return nil
@@ -87,13 +164,327 @@ func (ctx *HandlerContext) QueryProduct(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	productInfo, errDb := ctx.db.Product.Where(ctx.db.Product.ID.Eq(req.ID)).First()
+	productInfo, errDb := ctx.db.Product.WithContext(r.Context()).Where(ctx.db.Product.ID.Eq(req.ID)).First()
 	if errDb != nil {
 		http.Error(w, errDb.Error(), http.StatusNotFound)
 		return
 	}
+	prices, errDb := ctx.db.ProductPrice.WithContext(r.Context()).Where(ctx.db.ProductPrice.ProductId.Eq(req.ID)).Order(ctx.db.ProductPrice.Currency).Find()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, price := range prices {
+		productInfo.Prices = append(productInfo.Prices, *price)
+	}
+	if productInfo.CategoryId != nil {
+		productInfo.Breadcrumbs, errDb = category.Breadcrumbs(r.Context(), ctx.db, *productInfo.CategoryId)
+		if errDb != nil {
+			http.Error(w, errDb.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	productInfo.Tags, errDb = category.ProductTags(r.Context(), ctx.db, productInfo.ID)
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+	variants, errDb := ctx.db.Variant.WithContext(r.Context()).Where(ctx.db.Variant.ProductId.Eq(req.ID)).Order(ctx.db.Variant.ID).Find()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, variant := range variants {
+		productInfo.Variants = append(productInfo.Variants, *variant)
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("content-type", "application/json")
 	respBody, _ := json.Marshal(*productInfo)
 	w.Write(respBody)
 }
+
+// SetProductPrices Create or replace prices of a product in other currencies
+func (ctx *HandlerContext) SetProductPrices(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := SetProductPricesRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ProductId == 0 {
+		http.Error(w, "Product ID is required", http.StatusBadRequest)
+		return
+	}
+	if validationErr := validatePrices(req.Prices, "The product"); validationErr != "" {
+		http.Error(w, validationErr, http.StatusBadRequest)
+		return
+	}
+	if len(req.Prices) == 0 {
+		http.Error(w, "Prices are required", http.StatusBadRequest)
+		return
+	}
+
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		if _, errTx := tx.Product.WithContext(r.Context()).Where(tx.Product.ID.Eq(req.ProductId)).First(); errTx != nil {
+			return errors.New("Product not found: " + errTx.Error())
+		}
+		for i := range req.Prices {
+			req.Prices[i].ProductId = req.ProductId
+		}
+		return tx.ProductPrice.WithContext(r.Context()).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "product_id"}, {Name: "currency"}},
+			DoUpdates: clause.AssignmentColumns([]string{"price", "updated_at"}),
+		}).Create(sliceOfPtr(req.Prices)...)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(req.Prices)
+	w.Write(respBody)
+}
+
+// Validation errors of a price list, empty when it is valid
+func validatePrices(prices []model.ProductPrice, subject string) string {
+	validationErr := ""
+	currencies := make(map[string]bool)
+	for i := range prices {
+		if !currency.IsValidCode(prices[i].Currency) {
+			validationErr += fmt.Sprintf("%s price %d currency is invalid.", subject, i+1)
+		} else if currencies[prices[i].Currency] {
+			validationErr += fmt.Sprintf("%s price %d currency is duplicated.", subject, i+1)
+		}
+		if prices[i].Price < 0 {
+			validationErr += fmt.Sprintf("%s price %d is invalid.", subject, i+1)
+		}
+		currencies[prices[i].Currency] = true
+	}
+	return validationErr
+}
+
+func sliceOfPtr(prices []model.ProductPrice) []*model.ProductPrice {
+	ptrs := make([]*model.ProductPrice, 0, len(prices))
+	for i := range prices {
+		ptrs = append(ptrs, &prices[i])
+	}
+	return ptrs
+}
+
+// UpdateProduct Update fields of a product
+func (ctx *HandlerContext) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := UpdateProductRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "Product ID is required", http.StatusBadRequest)
+		return
+	}
+	productTable := ctx.db.Product
+	updates := make([]field.AssignExpr, 0)
+	if req.Name != nil {
+		if *req.Name == "" {
+			http.Error(w, "Product name must not be empty", http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, productTable.Name.Value(*req.Name))
+	}
+	if req.Description != nil {
+		updates = append(updates, productTable.Description.Value(*req.Description))
+	}
+	if req.Price != nil {
+		if *req.Price < 0 {
+			http.Error(w, "Product price is invalid", http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, productTable.Price.Value(*req.Price))
+	}
+	if req.Currency != nil {
+		if !currency.IsValidCode(*req.Currency) {
+			http.Error(w, "Product currency is invalid", http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, productTable.Currency.Value(*req.Currency))
+	}
+	if req.IsAvailable != nil {
+		updates = append(updates, productTable.IsAvailable.Value(*req.IsAvailable))
+	}
+	if req.AllowBackorder != nil {
+		updates = append(updates, productTable.AllowBackorder.Value(*req.AllowBackorder))
+	}
+	if req.CategoryId != nil {
+		updates = append(updates, productTable.CategoryId.Value(*req.CategoryId))
+	}
+	if len(updates) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var productInfo *model.Product
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		result, errTx := tx.Product.WithContext(r.Context()).Where(tx.Product.ID.Eq(req.ID)).UpdateSimple(updates...)
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		productInfo, errTx = tx.Product.WithContext(r.Context()).Where(tx.Product.ID.Eq(req.ID)).First()
+		return errTx
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Product was updated", "product_id", req.ID)
+	if req.IsAvailable != nil && *req.IsAvailable {
+		ctx.restocked(r.Context(), req.ID)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(*productInfo)
+	w.Write(respBody)
+}
+
+// DeleteProduct Soft delete a product, it is no longer listed nor orderable but existing orders keep referring to it
+func (ctx *HandlerContext) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost, http.MethodDelete}, w, r) {
+		return
+	}
+
+	req := model.Product{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "Product ID is required", http.StatusBadRequest)
+		return
+	}
+
+	result, errDb := ctx.db.Product.WithContext(r.Context()).Where(ctx.db.Product.ID.Eq(req.ID)).Delete()
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Product was deleted", "product_id", req.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Delete product success."))
+}
+
+// ListProducts List products page by page, filtered by full-text search, price range, currency and availability
+func (ctx *HandlerContext) ListProducts(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	req := ListProductsRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if err = util.NormalizePage(&req.Page, &req.PageSize); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MinPrice != nil && req.MaxPrice != nil && *req.MinPrice > *req.MaxPrice {
+		http.Error(w, "Min price must not exceed max price", http.StatusBadRequest)
+		return
+	}
+	if req.Currency != "" && !currency.IsValidCode(req.Currency) {
+		http.Error(w, "Currency is invalid", http.StatusBadRequest)
+		return
+	}
+
+	productTable := ctx.db.Product
+	query := productTable.WithContext(r.Context())
+	if req.Query != "" {
+		// Most relevant first. search_vector is a generated column (see migration 0003) which gen can't express, so use gorm directly
+		query.ReplaceDB(query.UnderlyingDB().
+			Where("search_vector @@ websearch_to_tsquery('simple', ?)", req.Query).
+			Clauses(clause.OrderBy{Expression: clause.Expr{SQL: `ts_rank(search_vector, websearch_to_tsquery('simple', ?)) DESC, "products"."id"`, Vars: []interface{}{req.Query}}}))
+	} else {
+		query = query.Order(productTable.ID)
+	}
+	if req.MinPrice != nil {
+		query = query.Where(productTable.Price.Gte(*req.MinPrice))
+	}
+	if req.MaxPrice != nil {
+		query = query.Where(productTable.Price.Lte(*req.MaxPrice))
+	}
+	if req.Currency != "" {
+		query = query.Where(productTable.Currency.Eq(req.Currency))
+	}
+	if req.IsAvailable != nil {
+		query = query.Where(productTable.IsAvailable.Is(*req.IsAvailable))
+	}
+	if req.CategoryId != nil {
+		categoryInfo, errDb := ctx.db.Category.WithContext(r.Context()).Where(ctx.db.Category.ID.Eq(*req.CategoryId)).First()
+		if errDb != nil {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		subtree := ctx.db.Category.WithContext(r.Context()).Select(ctx.db.Category.ID).Where(ctx.db.Category.Path.Like(categoryInfo.Path + "%"))
+		query = query.Where(productTable.Columns(productTable.CategoryId).In(subtree))
+	}
+	if req.Tag != "" {
+		tagged := ctx.db.ProductTag.WithContext(r.Context()).
+			Select(ctx.db.ProductTag.ProductId).
+			Join(ctx.db.Tag, ctx.db.Tag.ID.EqCol(ctx.db.ProductTag.TagId)).
+			Where(ctx.db.Tag.Name.Eq(strings.ToLower(strings.TrimSpace(req.Tag))))
+		query = query.Where(productTable.Columns(productTable.ID).In(tagged))
+	}
+
+	products, total, errDb := query.FindByPage((req.Page-1)*req.PageSize, req.PageSize)
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(ListProductsResponse{
+		Products: products,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+	w.Write(respBody)
+}
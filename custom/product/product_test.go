@@ -2,14 +2,17 @@ package product
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 	"gorm.io/gorm"
 	"net/http"
 	"net/http/httptest"
 	"order_system/custom/util"
 	"order_system/dal"
 	"order_system/model"
+	"strings"
 	"testing"
 )
 
@@ -18,9 +21,19 @@ var (
 		ID:          1,
 		Name:        "test product",
 		Description: util.GetStringPtr("this is a test product"),
-		Price:       100.00,
+		Price:       model.NewMoney(100, 0),
+		Currency:    "USD",
 		IsAvailable: true,
 	}
+	selectTagsSQL     = `^SELECT \"tags\"\.\"name\" FROM \"tags\" INNER JOIN \"product_tags\" ON \"product_tags\"\.\"tag_id\" = \"tags\"\.\"id\" WHERE \"product_tags\"\.\"product_id\" = \$1 ORDER BY \"tags\"\.\"name\"`
+	selectVariantsSQL = `^SELECT \* FROM \"variants\" WHERE \"variants\"\.\"product_id\" = \$1 ORDER BY \"variants\"\.\"id\"`
+	variantColumns    = []string{"id", "product_id", "sku", "options", "price", "stock"}
+	testProductPrice  = model.ProductPrice{
+		ID:        1,
+		ProductId: 1,
+		Currency:  "EUR",
+		Price:     model.NewMoney(95, 0),
+	}
 )
 
 func TestQueryProductSuccess(t *testing.T) {
@@ -30,8 +43,14 @@ func TestQueryProductSuccess(t *testing.T) {
 	handlerCtx.InitialHandlerContext(dal.Q)
 
 	returnData, _ := util.ObjectToRows(testProduct)
+	priceRows, _ := util.ObjectToRows(testProductPrice)
 	expectedSQL := `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" \= .* .* LIMIT .*`
+	selectPricesSQL := `^SELECT \* FROM \"product_prices\" WHERE \"product_prices\"\.\"product_id\" \= .*`
 	mock.ExpectQuery(expectedSQL).WithArgs(testProduct.ID, 1).WillReturnRows(returnData)
+	mock.ExpectQuery(selectPricesSQL).WithArgs(testProduct.ID).WillReturnRows(priceRows)
+	mock.ExpectQuery(selectTagsSQL).WithArgs(testProduct.ID).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("sale"))
+	mock.ExpectQuery(selectVariantsSQL).WithArgs(testProduct.ID).
+		WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(1, 1, "TS-M-RED", []byte(`{"size":"M","color":"red"}`), "12.00", 5))
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1}`)))
@@ -40,9 +59,14 @@ func TestQueryProductSuccess(t *testing.T) {
 	acutalResp := model.Product{}
 	json.Unmarshal(w.Body.Bytes(), &acutalResp)
 
+	expectedProduct := testProduct
+	expectedProduct.Prices = []model.ProductPrice{testProductPrice}
+	expectedProduct.Tags = []string{"sale"}
+	variantPrice := model.NewMoney(12, 0)
+	expectedProduct.Variants = []model.Variant{{ID: 1, ProductId: 1, Sku: "TS-M-RED", Options: model.Options{"size": "M", "color": "red"}, Price: &variantPrice, Stock: 5}}
 	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.EqualValues(t, testProduct, acutalResp, "Unexpected result")
+	assert.EqualValues(t, expectedProduct, acutalResp, "Unexpected result")
 }
 
 func TestQueryCustomerBadHttpMethod(t *testing.T) {
@@ -173,3 +197,340 @@ func TestCreatCustomerNameExisting(t *testing.T) {
 	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestCreatProductInvalidCurrency(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	invalidProduct := testProduct
+	invalidProduct.Currency = "usd"
+	reqBody, _ := json.Marshal(CreateProductsRequest{Products: &[]model.Product{invalidProduct}})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.CreateProducts(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSetProductPricesSuccess(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	productRows, _ := util.ObjectToRows(testProduct)
+	selectProductSQL := `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" \= .* .* LIMIT .*`
+	upsertPriceSQL := `INSERT INTO \"product_prices\" .+ ON CONFLICT \("product_id","currency"\) DO UPDATE SET .+`
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectProductSQL).WithArgs(testProduct.ID, 1).WillReturnRows(productRows)
+	mock.ExpectQuery(upsertPriceSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	reqBody, _ := json.Marshal(SetProductPricesRequest{
+		ProductId: testProduct.ID,
+		Prices:    []model.ProductPrice{{Currency: "EUR", Price: model.NewMoney(95, 0)}},
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.SetProductPrices(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSetProductPricesDuplicatedCurrency(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	reqBody, _ := json.Marshal(SetProductPricesRequest{
+		ProductId: testProduct.ID,
+		Prices: []model.ProductPrice{
+			{Currency: "EUR", Price: model.NewMoney(95, 0)},
+			{Currency: "EUR", Price: model.NewMoney(90, 0)},
+		},
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer(reqBody))
+	handlerCtx.SetProductPrices(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateProductSuccess(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	updatedProduct := testProduct
+	updatedProduct.Price = model.NewMoney(120, 50)
+	updatedProduct.IsAvailable = false
+	productRows, _ := util.ObjectToRows(updatedProduct)
+	updateSQL := `^UPDATE \"products\" SET \"price\"=\$1,\"is_available\"=\$2,\"updated_at\"=\$3 WHERE \"products\"\.\"id\" = \$4 AND \"products\"\.\"deleted_at\" IS NULL`
+	selectSQL := `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" \= .* .* LIMIT .*`
+	mock.ExpectBegin()
+	mock.ExpectExec(updateSQL).WithArgs("120.50", false, sqlmock.AnyArg(), testProduct.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(selectSQL).WithArgs(testProduct.ID, 1).WillReturnRows(productRows)
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1,"price":120.50,"is_available":false}`)))
+	handlerCtx.UpdateProduct(w, r)
+
+	actualResp := model.Product{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, updatedProduct.Price, actualResp.Price)
+}
+
+func TestUpdateProductNotFound(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`^UPDATE \"products\" SET .+`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1,"name":"new name"}`)))
+	handlerCtx.UpdateProduct(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateProductNothingToUpdate(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1}`)))
+	handlerCtx.UpdateProduct(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDeleteProductSoftDelete(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	softDeleteSQL := `^UPDATE \"products\" SET \"deleted_at\"=\$1 WHERE \"products\"\.\"id\" = \$2 AND \"products\"\.\"deleted_at\" IS NULL`
+	mock.ExpectBegin()
+	mock.ExpectExec(softDeleteSQL).WithArgs(sqlmock.AnyArg(), testProduct.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1}`)))
+	handlerCtx.DeleteProduct(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestListProductsWithFilters(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	productRows, _ := util.ObjectToRows(testProduct)
+	listSQL := `^SELECT \* FROM \"products\" WHERE search_vector @@ websearch_to_tsquery\('simple', \$1\) AND \"products\"\.\"price\" >= \$2 AND \"products\"\.\"price\" <= \$3 AND \"products\"\.\"is_available\" = \$4 AND \"products\"\.\"deleted_at\" IS NULL ORDER BY ts_rank\(search_vector, websearch_to_tsquery\('simple', \$5\)\) DESC, \"products\"\.\"id\" LIMIT \$6 OFFSET \$7`
+	countSQL := `^SELECT count\(\*\) FROM \"products\" WHERE search_vector @@ .+`
+	mock.ExpectQuery(listSQL).WithArgs("test", "50.00", "150.00", true, "test", 1, 1).WillReturnRows(productRows)
+	mock.ExpectQuery(countSQL).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	w := httptest.NewRecorder()
+	reqBody := `{"query":"test","min_price":50,"max_price":150,"is_available":true,"page":2,"page_size":1}`
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(reqBody)))
+	handlerCtx.ListProducts(w, r)
+
+	actualResp := ListProductsResponse{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(2), actualResp.Total)
+	assert.Equal(t, 2, actualResp.Page)
+	assert.Len(t, actualResp.Products, 1)
+	assert.Equal(t, testProduct.Name, actualResp.Products[0].Name)
+}
+
+func TestListProductsInvalidPriceRange(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"min_price":100,"max_price":50}`)))
+	handlerCtx.ListProducts(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestQueryProductBreadcrumbs(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	categoryId := uint(4)
+	productInCategory := testProduct
+	productInCategory.CategoryId = &categoryId
+	productRows, _ := util.ObjectToRows(productInCategory)
+	selectProductSQL := `^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" \= .* .* LIMIT .*`
+	selectPricesSQL := `^SELECT \* FROM \"product_prices\" WHERE .+`
+	selectCategorySQL := `^SELECT \* FROM \"categories\" WHERE \"categories\"\.\"id\" = \$1 ORDER BY .+ LIMIT .*`
+	selectAncestorsSQL := `^SELECT \* FROM \"categories\" WHERE \"categories\"\.\"id\" IN \(\$1,\$2\)`
+	mock.ExpectQuery(selectProductSQL).WithArgs(testProduct.ID, 1).WillReturnRows(productRows)
+	mock.ExpectQuery(selectPricesSQL).WithArgs(testProduct.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(selectCategorySQL).WithArgs(categoryId, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "path"}).AddRow(4, "Phones", 1, "/1/4/"))
+	mock.ExpectQuery(selectAncestorsSQL).WithArgs(1, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "path"}).
+			AddRow(4, "Phones", 1, "/1/4/").
+			AddRow(1, "Electronics", nil, "/1/"))
+	mock.ExpectQuery(selectTagsSQL).WithArgs(testProduct.ID).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectQuery(selectVariantsSQL).WithArgs(testProduct.ID).WillReturnRows(sqlmock.NewRows(variantColumns))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"id":1}`)))
+	handlerCtx.QueryProduct(w, r)
+
+	actualResp := model.Product{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, actualResp.Breadcrumbs, 2)
+	assert.Equal(t, "Electronics", actualResp.Breadcrumbs[0].Name)
+	assert.Equal(t, "Phones", actualResp.Breadcrumbs[1].Name)
+}
+
+func TestListProductsByCategoryAndTag(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	selectCategorySQL := `^SELECT \* FROM \"categories\" WHERE \"categories\"\.\"id\" = \$1 ORDER BY .+ LIMIT .*`
+	listSQL := `^SELECT \* FROM \"products\" WHERE \"products\"\.\"category_id\" IN \(SELECT \"categories\"\.\"id\" FROM \"categories\" WHERE \"categories\"\.\"path\" LIKE \$1\) AND \"products\"\.\"id\" IN \(SELECT \"product_tags\"\.\"product_id\" FROM \"product_tags\" INNER JOIN \"tags\" ON \"tags\"\.\"id\" = \"product_tags\"\.\"tag_id\" WHERE \"tags\"\.\"name\" = \$2\) AND \"products\"\.\"deleted_at\" IS NULL ORDER BY \"products\"\.\"id\" LIMIT \$3`
+	productRows, _ := util.ObjectToRows(testProduct)
+	mock.ExpectQuery(selectCategorySQL).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "path"}).AddRow(1, "Electronics", "/1/"))
+	mock.ExpectQuery(listSQL).WithArgs("/1/%", "sale", util.DEFAULT_PAGE_SIZE).WillReturnRows(productRows)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", bytes.NewBuffer([]byte(`{"category_id":1,"tag":" Sale "}`)))
+	handlerCtx.ListProducts(w, r)
+
+	actualResp := ListProductsResponse{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1), actualResp.Total)
+}
+
+func TestCreateVariantsSuccess(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" = \$1 .+`).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "T-shirt"))
+	mock.ExpectQuery(`^SELECT \* FROM \"variants\" WHERE \"variants\"\.\"product_id\" = \$1`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(1, 1, "TS-S-RED", []byte(`{"color":"red","size":"S"}`), nil, 2))
+	mock.ExpectQuery(`^INSERT INTO \"variants\" .+ VALUES .+`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(3))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"product_id":1,"variants":[
+		{"sku":" TS-M-RED ","options":{"size":"M","color":"red"},"price":12.50,"stock":5},
+		{"sku":"TS-M-BLUE","options":{"size":"M","color":"blue"},"stock":3}]}`)))
+	handlerCtx.CreateVariants(w, r)
+
+	actualResp := make([]model.Variant, 0)
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, len(actualResp))
+	assert.Equal(t, "TS-M-RED", actualResp[0].Sku)
+	assert.Equal(t, model.NewMoney(12, 50), *actualResp[0].Price)
+	assert.Nil(t, actualResp[1].Price)
+}
+
+func TestCreateVariantsDuplicateOptions(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" = \$1 .+`).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "T-shirt"))
+	mock.ExpectQuery(`^SELECT \* FROM \"variants\" WHERE \"variants\"\.\"product_id\" = \$1`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(1, 1, "TS-M-RED", []byte(`{"color":"red","size":"M"}`), nil, 2))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"product_id":1,"variants":[
+		{"sku":"TS-M-RED-2","options":{"size":"M","color":"red"},"stock":5}]}`)))
+	handlerCtx.CreateVariants(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateVariantsInvalid(t *testing.T) {
+	sqlDB, _, _ := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"product_id":1,"variants":[
+		{"sku":"","options":{},"stock":-1}]}`)))
+	handlerCtx.CreateVariants(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "The 1 variant SKU is required.The 1 variant options are required.The 1 variant stock is invalid.", strings.TrimSpace(w.Body.String()))
+}
+
+func TestUpdateVariantStockRestocks(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+	restocked := make([]uint, 0)
+	handlerCtx.SetRestockHook(func(c context.Context, productId uint) {
+		restocked = append(restocked, productId)
+	})
+
+	selectSQL := `^SELECT \* FROM \"variants\" WHERE \"variants\"\.\"id\" = \$1`
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectSQL).WithArgs(7, 1).WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 1, "TS-M-RED", "{}", nil, 0))
+	mock.ExpectExec(`^UPDATE \"variants\" SET \"stock\"=\$1,\"updated_at\"=\$2 WHERE \"variants\"\.\"id\" = \$3`).
+		WithArgs(5, sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(selectSQL).WithArgs(7, 1).WillReturnRows(sqlmock.NewRows(variantColumns).AddRow(7, 1, "TS-M-RED", "{}", nil, 5))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(`{"id":7,"stock":5}`)))
+	handlerCtx.UpdateVariant(w, r)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []uint{1}, restocked)
+}
@@ -0,0 +1,235 @@
+package product
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+	"net/http"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"strings"
+)
+
+type CreateVariantsRequest struct {
+	ProductId uint            `json:"product_id"`
+	Variants  []model.Variant `json:"variants"`
+}
+
+// UpdateVariantRequest Only the given fields are updated, the SKU can't be changed
+type UpdateVariantRequest struct {
+	ID      uint           `json:"id"`
+	Options *model.Options `json:"options,omitempty"`
+	Price   *model.Money   `json:"price,omitempty"`
+	Stock   *int           `json:"stock,omitempty"`
+}
+
+// CreateVariants Create SKUs of a product
+func (ctx *HandlerContext) CreateVariants(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := CreateVariantsRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ProductId == 0 {
+		http.Error(w, "Product ID is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Variants) == 0 {
+		http.Error(w, "Variants are required", http.StatusBadRequest)
+		return
+	}
+	validationErr := ""
+	skus := make(map[string]bool)
+	for i := range req.Variants {
+		variant := &req.Variants[i]
+		variant.ID = 0
+		variant.ProductId = req.ProductId
+		variant.Sku = strings.TrimSpace(variant.Sku)
+		if variant.Sku == "" {
+			validationErr += fmt.Sprintf("The %d variant SKU is required.", i+1)
+		} else if skus[variant.Sku] {
+			validationErr += fmt.Sprintf("The %d variant SKU is duplicated.", i+1)
+		}
+		skus[variant.Sku] = true
+		validationErr += validateVariant(variant, fmt.Sprintf("The %d variant", i+1))
+	}
+	if validationErr != "" {
+		http.Error(w, validationErr, http.StatusBadRequest)
+		return
+	}
+
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		if _, errTx := tx.Product.WithContext(r.Context()).Where(tx.Product.ID.Eq(req.ProductId)).First(); errTx != nil {
+			return gorm.ErrRecordNotFound
+		}
+		existing, errTx := tx.Variant.WithContext(r.Context()).Where(tx.Variant.ProductId.Eq(req.ProductId)).Find()
+		if errTx != nil {
+			return errTx
+		}
+		if errTx = checkDuplicateOptions(existing, req.Variants); errTx != nil {
+			return errTx
+		}
+		return tx.Variant.WithContext(r.Context()).Create(variantsOfPtr(req.Variants)...)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errDuplicateOptions) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Variants were created", "product_id", req.ProductId, "count", len(req.Variants))
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(req.Variants)
+	w.Write(respBody)
+}
+
+// UpdateVariant Update options, price or stock of a SKU
+func (ctx *HandlerContext) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := UpdateVariantRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "Variant ID is required", http.StatusBadRequest)
+		return
+	}
+	variantTable := ctx.db.Variant
+	updates := make([]field.AssignExpr, 0)
+	validated := model.Variant{Price: req.Price}
+	if req.Options != nil {
+		validated.Options = *req.Options
+		updates = append(updates, variantTable.Options.Value(*req.Options))
+	}
+	if req.Price != nil {
+		updates = append(updates, variantTable.Price.Value(*req.Price))
+	}
+	if req.Stock != nil {
+		validated.Stock = *req.Stock
+		updates = append(updates, variantTable.Stock.Value(*req.Stock))
+	}
+	if validationErr := validateVariant(&validated, "The variant"); validationErr != "" {
+		http.Error(w, validationErr, http.StatusBadRequest)
+		return
+	}
+	if len(updates) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var variantInfo *model.Variant
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		current, errTx := tx.Variant.WithContext(r.Context()).Where(tx.Variant.ID.Eq(req.ID)).First()
+		if errTx != nil {
+			return gorm.ErrRecordNotFound
+		}
+		if req.Options != nil {
+			siblings, errTx := tx.Variant.WithContext(r.Context()).Where(tx.Variant.ProductId.Eq(current.ProductId), tx.Variant.ID.Neq(req.ID)).Find()
+			if errTx != nil {
+				return errTx
+			}
+			if errTx = checkDuplicateOptions(siblings, []model.Variant{{Sku: current.Sku, Options: *req.Options}}); errTx != nil {
+				return errTx
+			}
+		}
+		if _, errTx = tx.Variant.WithContext(r.Context()).Where(tx.Variant.ID.Eq(req.ID)).UpdateSimple(updates...); errTx != nil {
+			return errTx
+		}
+		variantInfo, errTx = tx.Variant.WithContext(r.Context()).Where(tx.Variant.ID.Eq(req.ID)).First()
+		return errTx
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Variant not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errDuplicateOptions) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Variant was updated", "variant_id", req.ID)
+	if req.Stock != nil && variantInfo.Stock > 0 {
+		ctx.restocked(r.Context(), variantInfo.ProductId)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("content-type", "application/json")
+	respBody, _ := json.Marshal(*variantInfo)
+	w.Write(respBody)
+}
+
+// Validation errors of a variant, empty when it is valid. Nil options are not validated.
+func validateVariant(variant *model.Variant, subject string) string {
+	validationErr := ""
+	if variant.Options != nil && len(variant.Options) == 0 {
+		validationErr += subject + " options are required."
+	}
+	for name, value := range variant.Options {
+		if strings.TrimSpace(name) == "" || strings.TrimSpace(value) == "" {
+			validationErr += subject + " option names and values must not be empty."
+			break
+		}
+	}
+	if variant.Price != nil && *variant.Price < 0 {
+		validationErr += subject + " price is invalid."
+	}
+	if variant.Stock < 0 {
+		validationErr += subject + " stock is invalid."
+	}
+	return validationErr
+}
+
+var errDuplicateOptions = errors.New("duplicate variant options")
+
+// Variants of the same product must differ in options
+func checkDuplicateOptions(existing []*model.Variant, variants []model.Variant) error {
+	keys := make(map[string]string)
+	for _, variant := range existing {
+		keys[variant.Options.Key()] = variant.Sku
+	}
+	for _, variant := range variants {
+		if sku, ok := keys[variant.Options.Key()]; ok {
+			return fmt.Errorf("%w: SKU %s has the same options as SKU %s", errDuplicateOptions, variant.Sku, sku)
+		}
+		keys[variant.Options.Key()] = variant.Sku
+	}
+	return nil
+}
+
+func variantsOfPtr(variants []model.Variant) []*model.Variant {
+	ptrs := make([]*model.Variant, 0, len(variants))
+	for i := range variants {
+		ptrs = append(ptrs, &variants[i])
+	}
+	return ptrs
+}
@@ -0,0 +1,259 @@
+package promotion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+	"net/http"
+	"order_system/custom/currency"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"strings"
+	"time"
+)
+
+type HandlerContext struct {
+	db *dal.Query
+}
+
+// CreateCouponRequest A PERCENT coupon requires percent, a FIXED coupon amount_off. The coupon is active unless is_active is false
+type CreateCouponRequest struct {
+	Code               string      `json:"code"`
+	DiscountType       string      `json:"discount_type"`
+	Percent            int         `json:"percent,omitempty"`
+	AmountOff          model.Money `json:"amount_off,omitempty"`
+	MinSpend           model.Money `json:"min_spend,omitempty"`
+	Currency           string      `json:"currency,omitempty"`
+	MaxUses            *int        `json:"max_uses,omitempty"`
+	MaxUsesPerCustomer *int        `json:"max_uses_per_customer,omitempty"`
+	StartsAt           *time.Time  `json:"startsTime,omitempty"`
+	EndsAt             *time.Time  `json:"endsTime,omitempty"`
+	ProductIds         []uint      `json:"product_ids,omitempty"`
+	CategoryIds        []uint      `json:"category_ids,omitempty"`
+	IsActive           *bool       `json:"is_active,omitempty"`
+}
+
+// UpdateCouponRequest Only the given fields are updated, the discount of a coupon can't be changed
+type UpdateCouponRequest struct {
+	ID                 uint       `json:"id"`
+	IsActive           *bool      `json:"is_active,omitempty"`
+	MaxUses            *int       `json:"max_uses,omitempty"`
+	MaxUsesPerCustomer *int       `json:"max_uses_per_customer,omitempty"`
+	EndsAt             *time.Time `json:"endsTime,omitempty"`
+}
+
+// ListCouponsRequest Active only lists active coupons within their validity window
+type ListCouponsRequest struct {
+	ActiveOnly bool `json:"active_only,omitempty"`
+	Page       int  `json:"page"`
+	PageSize   int  `json:"page_size"`
+}
+
+type ListCouponsResponse struct {
+	Coupons  []*model.Coupon `json:"coupons"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+}
+
+func (ctx *HandlerContext) InitialHandlerContext(db *dal.Query) {
+	ctx.db = db
+}
+
+// Validate the discount, limits and validity window of a new coupon
+func (req *CreateCouponRequest) validate() error {
+	req.Code = NormalizeCode(req.Code)
+	req.DiscountType = strings.ToUpper(req.DiscountType)
+	if req.Currency == "" {
+		req.Currency = currency.DEFAULT_CURRENCY
+	}
+	switch {
+	case req.Code == "" || strings.ContainsAny(req.Code, " \t"):
+		return errors.New("Coupon code is required and must not contain spaces")
+	case req.DiscountType == DISCOUNT_TYPE_PERCENT && (req.Percent < 1 || req.Percent > 100 || req.AmountOff != 0):
+		return errors.New("Percent coupon requires a percent between 1 and 100")
+	case req.DiscountType == DISCOUNT_TYPE_FIXED && (req.AmountOff <= 0 || req.Percent != 0):
+		return errors.New("Fixed coupon requires a positive amount_off")
+	case req.DiscountType != DISCOUNT_TYPE_PERCENT && req.DiscountType != DISCOUNT_TYPE_FIXED:
+		return fmt.Errorf("Discount type must be %s or %s", DISCOUNT_TYPE_PERCENT, DISCOUNT_TYPE_FIXED)
+	case req.MinSpend < 0:
+		return errors.New("Min spend must not be negative")
+	case !currency.IsValidCode(req.Currency):
+		return errors.New("Invalid currency " + req.Currency)
+	case req.MaxUses != nil && *req.MaxUses < 1, req.MaxUsesPerCustomer != nil && *req.MaxUsesPerCustomer < 1:
+		return errors.New("Usage limits must be positive")
+	case req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt):
+		return errors.New("Coupon must end after it starts")
+	}
+	return nil
+}
+
+// CreateCoupon Create a coupon code, scoped to products and categories when they are given
+func (ctx *HandlerContext) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := CreateCouponRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if err = req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	newCoupon := model.Coupon{
+		Code:               req.Code,
+		DiscountType:       req.DiscountType,
+		Percent:            req.Percent,
+		AmountOff:          req.AmountOff,
+		MinSpend:           req.MinSpend,
+		Currency:           req.Currency,
+		MaxUses:            req.MaxUses,
+		MaxUsesPerCustomer: req.MaxUsesPerCustomer,
+		StartsAt:           req.StartsAt,
+		EndsAt:             req.EndsAt,
+		ProductIds:         req.ProductIds,
+		CategoryIds:        req.CategoryIds,
+		IsActive:           req.IsActive == nil || *req.IsActive,
+	}
+	if err = ctx.db.Coupon.WithContext(r.Context()).Create(&newCoupon); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Coupon was created", "coupon_id", newCoupon.ID, "code", newCoupon.Code)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(newCoupon)
+	w.Write(respBody)
+}
+
+// UpdateCoupon Activate or deactivate a coupon, change its usage limits or end
+func (ctx *HandlerContext) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodPost}, w, r) {
+		return
+	}
+
+	req := UpdateCouponRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if req.ID == 0 {
+		http.Error(w, "Coupon id is required", http.StatusBadRequest)
+		return
+	}
+	couponTable := ctx.db.Coupon
+	updates := make([]field.AssignExpr, 0)
+	if req.IsActive != nil {
+		updates = append(updates, couponTable.IsActive.Value(*req.IsActive))
+	}
+	if req.MaxUses != nil {
+		if *req.MaxUses < 1 {
+			http.Error(w, "Usage limits must be positive", http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, couponTable.MaxUses.Value(*req.MaxUses))
+	}
+	if req.MaxUsesPerCustomer != nil {
+		if *req.MaxUsesPerCustomer < 1 {
+			http.Error(w, "Usage limits must be positive", http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, couponTable.MaxUsesPerCustomer.Value(*req.MaxUsesPerCustomer))
+	}
+	if req.EndsAt != nil {
+		updates = append(updates, couponTable.EndsAt.Value(*req.EndsAt))
+	}
+	if len(updates) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	var couponInfo *model.Coupon
+	err = ctx.db.Transaction(func(tx *dal.Query) error {
+		result, errTx := tx.Coupon.WithContext(r.Context()).Where(tx.Coupon.ID.Eq(req.ID)).UpdateSimple(updates...)
+		if errTx != nil {
+			return errTx
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		couponInfo, errTx = tx.Coupon.WithContext(r.Context()).Where(tx.Coupon.ID.Eq(req.ID)).First()
+		return errTx
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Coupon not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.GetLogger(r.Context()).Info("Coupon was updated", "coupon_id", req.ID)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(*couponInfo)
+	w.Write(respBody)
+}
+
+// ListCoupons List coupons by id, with their used count
+func (ctx *HandlerContext) ListCoupons(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	req := ListCouponsRequest{}
+	err := util.FetchReqObject(r, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate payload
+	if err = util.NormalizePage(&req.Page, &req.PageSize); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	couponTable := ctx.db.Coupon
+	query := couponTable.WithContext(r.Context()).Order(couponTable.ID)
+	if req.ActiveOnly {
+		now := time.Now()
+		query = query.Where(couponTable.IsActive.Is(true),
+			field.Or(couponTable.StartsAt.IsNull(), couponTable.StartsAt.Lte(now)),
+			field.Or(couponTable.EndsAt.IsNull(), couponTable.EndsAt.Gt(now)))
+	}
+
+	coupons, total, errDb := query.FindByPage((req.Page-1)*req.PageSize, req.PageSize)
+	if errDb != nil {
+		http.Error(w, errDb.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(ListCouponsResponse{
+		Coupons:  coupons,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+	w.Write(respBody)
+}
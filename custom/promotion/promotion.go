@@ -0,0 +1,195 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order_system/constants"
+	"order_system/custom/category"
+	"order_system/custom/currency"
+	"order_system/dal"
+	"order_system/model"
+	"strings"
+	"time"
+)
+
+// Discount Types
+const DISCOUNT_TYPE_PERCENT = "PERCENT"
+const DISCOUNT_TYPE_FIXED = "FIXED"
+
+// NormalizeCode Coupon codes are case insensitive, they are stored uppercased
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// CheckValid Check the coupon is active and within its validity window
+func CheckValid(coupon *model.Coupon, now time.Time) error {
+	switch {
+	case !coupon.IsActive:
+		return errors.New(constants.COUPON_NOT_APPLICABLE + ": coupon is inactive")
+	case coupon.StartsAt != nil && now.Before(*coupon.StartsAt):
+		return errors.New(constants.COUPON_NOT_APPLICABLE + ": coupon is not valid yet")
+	case coupon.EndsAt != nil && !now.Before(*coupon.EndsAt):
+		return errors.New(constants.COUPON_NOT_APPLICABLE + ": coupon has expired")
+	}
+	return nil
+}
+
+// Discount Amount off the eligible lines in the order currency, never more than the lines amount
+func Discount(coupon *model.Coupon, rates *currency.RateTable, eligible []*model.OrderLine, orderCurrency string) (model.Money, error) {
+	var total model.Money
+	for _, line := range eligible {
+		total = total.Add(line.Amount.Sub(line.DiscountAmount))
+	}
+	if coupon.DiscountType == DISCOUNT_TYPE_PERCENT {
+		return total.MulRatio(int64(coupon.Percent), 100), nil
+	}
+	amountOff, err := rates.Convert(coupon.AmountOff, coupon.Currency, orderCurrency)
+	if err != nil {
+		return 0, err
+	}
+	return min(amountOff, total), nil
+}
+
+// Allocate Split the discount to the lines in proportion to their amounts, the last line takes the rounding difference
+func Allocate(discount model.Money, lines []*model.OrderLine) {
+	var total model.Money
+	for _, line := range lines {
+		total = total.Add(line.Amount)
+	}
+	rest := discount
+	for i, line := range lines {
+		share := rest
+		if i < len(lines)-1 {
+			share = discount.MulRatio(line.Amount.MinorUnits(), total.MinorUnits())
+		}
+		line.DiscountAmount = line.DiscountAmount.Add(share)
+		rest = rest.Sub(share)
+	}
+}
+
+// Apply Redeem a coupon on a new order: validate it for the customer and the order lines, allocate its discount to the eligible lines
+// and count the use. The order amount is reduced by the discount, the returned discount is saved once the order is created.
+func Apply(c context.Context, tx *dal.Query, rates *currency.RateTable, code string, order *model.Order, lines []*model.OrderLine, now time.Time) (*model.OrderDiscount, error) {
+	coupon, err := tx.Coupon.WithContext(c).Where(tx.Coupon.Code.Eq(NormalizeCode(code))).First()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q", constants.COUPON_NOT_FOUND, code)
+	}
+	if err = CheckValid(coupon, now); err != nil {
+		return nil, err
+	}
+	minSpend, err := rates.Convert(coupon.MinSpend, coupon.Currency, order.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if order.Subtotal < minSpend {
+		return nil, fmt.Errorf("%s: minimum spend is %s %s", constants.COUPON_NOT_APPLICABLE, minSpend, order.Currency)
+	}
+	eligible, err := eligibleLines(c, tx, coupon, lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(eligible) == 0 {
+		return nil, errors.New(constants.COUPON_NOT_APPLICABLE + ": no eligible items")
+	}
+
+	// Count the use first, the row lock serializes redemptions of the coupon so concurrent orders can't exceed the limits
+	query := tx.Coupon.WithContext(c).Where(tx.Coupon.ID.Eq(coupon.ID))
+	if coupon.MaxUses != nil {
+		query = query.Where(tx.Coupon.UsedCount.Lt(*coupon.MaxUses))
+	}
+	result, err := query.UpdateSimple(tx.Coupon.UsedCount.Add(1))
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New(constants.COUPON_LIMIT_REACHED)
+	}
+	if coupon.MaxUsesPerCustomer != nil {
+		used, err := tx.OrderDiscount.WithContext(c).
+			Where(tx.OrderDiscount.CouponId.Eq(coupon.ID), tx.OrderDiscount.CustomerId.Eq(order.CustomerId), tx.OrderDiscount.VoidedAt.IsNull()).Count()
+		if err != nil {
+			return nil, err
+		}
+		if used >= int64(*coupon.MaxUsesPerCustomer) {
+			return nil, errors.New(constants.COUPON_LIMIT_REACHED + ": already used by the customer")
+		}
+	}
+
+	amount, err := Discount(coupon, rates, eligible, order.Currency)
+	if err != nil {
+		return nil, err
+	}
+	Allocate(amount, eligible)
+	order.DiscountAmount = order.DiscountAmount.Add(amount)
+	order.Amount = order.Amount.Sub(amount)
+	return &model.OrderDiscount{
+		CouponId:   coupon.ID,
+		CustomerId: order.CustomerId,
+		Code:       coupon.Code,
+		Amount:     amount,
+	}, nil
+}
+
+// Release Give back the coupon uses of a canceled or failed order: its discounts are voided and the used count of their coupons decremented.
+// The order keeps its discount amounts, a released discount isn't released again. Returns the count of released discounts.
+func Release(c context.Context, tx *dal.Query, orderId uint, now time.Time) (int, error) {
+	voided := make([]model.OrderDiscount, 0)
+	_, err := tx.OrderDiscount.WithContext(c).Returning(&voided, "coupon_id").
+		Where(tx.OrderDiscount.OrderId.Eq(orderId), tx.OrderDiscount.VoidedAt.IsNull()).
+		UpdateSimple(tx.OrderDiscount.VoidedAt.Value(now))
+	if err != nil {
+		return 0, err
+	}
+	for _, discount := range voided {
+		_, err = tx.Coupon.WithContext(c).Where(tx.Coupon.ID.Eq(discount.CouponId), tx.Coupon.UsedCount.Gt(0)).
+			UpdateSimple(tx.Coupon.UsedCount.Sub(1))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(voided), nil
+}
+
+// Lines of the products in the coupon scope, or all lines when the coupon isn't scoped
+func eligibleLines(c context.Context, tx *dal.Query, coupon *model.Coupon, lines []*model.OrderLine) ([]*model.OrderLine, error) {
+	if len(coupon.ProductIds) == 0 && len(coupon.CategoryIds) == 0 {
+		return lines, nil
+	}
+	inCategory, err := productsInCategories(c, tx, coupon.CategoryIds, lines)
+	if err != nil {
+		return nil, err
+	}
+	eligible := make([]*model.OrderLine, 0, len(lines))
+	for _, line := range lines {
+		if coupon.ProductIds.Contains(line.ProductId) || inCategory[line.ProductId] {
+			eligible = append(eligible, line)
+		}
+	}
+	return eligible, nil
+}
+
+// Products of the lines in one of the categories or their sub categories
+func productsInCategories(c context.Context, tx *dal.Query, categoryIds model.IdList, lines []*model.OrderLine) (map[uint]bool, error) {
+	inCategory := make(map[uint]bool)
+	if len(categoryIds) == 0 {
+		return inCategory, nil
+	}
+	productIds := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		productIds = append(productIds, line.ProductId)
+	}
+	paths, err := category.ProductCategoryPaths(c, tx, productIds)
+	if err != nil {
+		return nil, err
+	}
+	// The path of a category has the ids of all its ancestors
+	for productId, path := range paths {
+		for _, id := range path {
+			if categoryIds.Contains(id) {
+				inCategory[productId] = true
+			}
+		}
+	}
+	return inCategory, nil
+}
@@ -0,0 +1,118 @@
+package promotion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"net/http"
+	"net/http/httptest"
+	"order_system/custom/currency"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"testing"
+	"time"
+)
+
+func TestAllocate(t *testing.T) {
+	lines := []*model.OrderLine{
+		{ID: 1, Amount: model.NewMoney(10, 0)},
+		{ID: 2, Amount: model.NewMoney(10, 0)},
+		{ID: 3, Amount: model.NewMoney(10, 0)},
+	}
+	Allocate(model.NewMoney(10, 0), lines)
+
+	// The last line takes the rounding difference
+	assert.Equal(t, model.NewMoney(3, 33), lines[0].DiscountAmount)
+	assert.Equal(t, model.NewMoney(3, 33), lines[1].DiscountAmount)
+	assert.Equal(t, model.NewMoney(3, 34), lines[2].DiscountAmount)
+}
+
+func TestDiscount(t *testing.T) {
+	rates := currency.DefaultRateTable()
+	lines := []*model.OrderLine{{Amount: model.NewMoney(40, 0)}, {Amount: model.NewMoney(19, 99)}}
+
+	amount, err := Discount(&model.Coupon{DiscountType: DISCOUNT_TYPE_PERCENT, Percent: 15}, rates, lines, "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, model.NewMoney(9, 0), amount)
+
+	// A fixed discount is never more than the eligible lines
+	amount, err = Discount(&model.Coupon{DiscountType: DISCOUNT_TYPE_FIXED, AmountOff: model.NewMoney(100, 0), Currency: "USD"}, rates, lines, "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, model.NewMoney(59, 99), amount)
+}
+
+func TestCheckValid(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tomorrow := now.Add(24 * time.Hour)
+
+	assert.Nil(t, CheckValid(&model.Coupon{IsActive: true, EndsAt: &tomorrow}, now))
+	assert.ErrorContains(t, CheckValid(&model.Coupon{IsActive: false}, now), "inactive")
+	assert.ErrorContains(t, CheckValid(&model.Coupon{IsActive: true, StartsAt: &tomorrow}, now), "not valid yet")
+	assert.ErrorContains(t, CheckValid(&model.Coupon{IsActive: true, EndsAt: &now}, now), "expired")
+}
+
+func TestEligibleLinesByCategory(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+
+	// Product 3 is in sub category 4 of the scoped category 1, product 5 has no category
+	mock.ExpectQuery(`^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" IN \(\$1,\$2\) AND \"products\"\.\"category_id\" IS NOT NULL`).
+		WithArgs(3, 5).WillReturnRows(sqlmock.NewRows([]string{"id", "category_id"}).AddRow(3, 4))
+	mock.ExpectQuery(`^SELECT \* FROM \"categories\" WHERE \"categories\"\.\"id\" = \$1`).
+		WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"id", "path"}).AddRow(4, "/1/4/"))
+
+	lines := []*model.OrderLine{{ID: 11, ProductId: 3}, {ID: 12, ProductId: 5}}
+	eligible, err := eligibleLines(context.Background(), dal.Q, &model.Coupon{CategoryIds: model.IdList{1}}, lines)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.OrderLine{lines[0]}, eligible)
+}
+
+func TestCreateCoupon(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^INSERT INTO \"coupons\" .+`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(
+		`{"code":" welcome5 ","discount_type":"fixed","amount_off":5,"min_spend":20,"max_uses_per_customer":1,"product_ids":[3]}`)))
+	handlerCtx.CreateCoupon(w, r)
+
+	actualResp := model.Coupon{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "WELCOME5", actualResp.Code)
+	assert.Equal(t, "USD", actualResp.Currency)
+	assert.True(t, actualResp.IsActive)
+	assert.Equal(t, model.IdList{3}, actualResp.ProductIds)
+}
+
+func TestCreateCouponInvalid(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	for _, body := range []string{
+		`{"code":"HALF","discount_type":"PERCENT","percent":150}`,
+		`{"code":"FREE","discount_type":"FIXED"}`,
+		`{"code":"TWO WORDS","discount_type":"PERCENT","percent":10}`,
+		`{"code":"LATER","discount_type":"PERCENT","percent":10,"startsTime":"2026-03-02T00:00:00Z","endsTime":"2026-03-01T00:00:00Z"}`,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "http://localhosts", bytes.NewBuffer([]byte(body)))
+		handlerCtx.CreateCoupon(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,101 @@
+package reservation
+
+import (
+	"encoding/json"
+	"net/http"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/dal"
+)
+
+type HandlerContext struct {
+	db *dal.Query
+}
+
+// StockLevel Available and reserved stock of a SKU, or of a product without variants
+type StockLevel struct {
+	ProductId uint    `json:"product_id"`
+	VariantId *uint   `json:"variant_id,omitempty"`
+	Sku       *string `json:"sku,omitempty"`
+	Available int     `json:"available"`
+	Reserved  int     `json:"reserved"`
+}
+
+// StockMetrics Reserved vs available stock. Available is the stock of SKUs which can be ordered,
+// products without variants are single items and only listed while they are reserved.
+type StockMetrics struct {
+	Available int `json:"available"`
+	Reserved  int `json:"reserved"`
+	// Count of reservations by state
+	Reservations map[string]int64 `json:"reservations"`
+	Levels       []StockLevel     `json:"levels"`
+}
+
+func (ctx *HandlerContext) InitialHandlerContext(db *dal.Query) {
+	ctx.db = db
+}
+
+// StockMetrics Report reserved vs available stock by SKU and the count of reservations by state
+func (ctx *HandlerContext) StockMetrics(w http.ResponseWriter, r *http.Request) {
+	// Validate http method
+	if !util.IsAllowHttpMethod([]string{http.MethodGet}, w, r) {
+		return
+	}
+
+	reservationTable := ctx.db.StockReservation
+	held, errDB := reservationTable.WithContext(r.Context()).Where(reservationTable.State.Eq(constants.RESERVATION_STATE_RESERVED)).Find()
+	if errDB != nil {
+		util.GetLogger(r.Context()).Error(errDB.Error())
+		http.Error(w, errDB.Error(), http.StatusInternalServerError)
+		return
+	}
+	var counts []struct {
+		State int8
+		Count int64
+	}
+	errDB = reservationTable.WithContext(r.Context()).Select(reservationTable.State, reservationTable.ID.Count().As("count")).Group(reservationTable.State).Scan(&counts)
+	if errDB != nil {
+		util.GetLogger(r.Context()).Error(errDB.Error())
+		http.Error(w, errDB.Error(), http.StatusInternalServerError)
+		return
+	}
+	variants, errDB := ctx.db.Variant.WithContext(r.Context()).Order(ctx.db.Variant.ID).Find()
+	if errDB != nil {
+		util.GetLogger(r.Context()).Error(errDB.Error())
+		http.Error(w, errDB.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metrics := StockMetrics{Reservations: map[string]int64{}, Levels: make([]StockLevel, 0, len(variants))}
+	for _, count := range counts {
+		metrics.Reservations[stateCodeToString(count.State)] = count.Count
+	}
+	variantLevels := make(map[uint]int, len(variants))
+	for _, variant := range variants {
+		variantLevels[variant.ID] = len(metrics.Levels)
+		metrics.Levels = append(metrics.Levels, StockLevel{ProductId: variant.ProductId, VariantId: &variant.ID, Sku: &variant.Sku, Available: variant.Stock})
+		metrics.Available += variant.Stock
+	}
+	productLevels := make(map[uint]int)
+	for _, reservation := range held {
+		metrics.Reserved += reservation.Quantity
+		if reservation.VariantId != nil {
+			if i, ok := variantLevels[*reservation.VariantId]; ok {
+				metrics.Levels[i].Reserved += reservation.Quantity
+			}
+			continue
+		}
+		i, ok := productLevels[reservation.ProductId]
+		if !ok {
+			i = len(metrics.Levels)
+			productLevels[reservation.ProductId] = i
+			metrics.Levels = append(metrics.Levels, StockLevel{ProductId: reservation.ProductId})
+		}
+		metrics.Levels[i].Reserved += reservation.Quantity
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	respBody, _ := json.Marshal(metrics)
+	w.Write(respBody)
+}
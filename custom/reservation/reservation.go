@@ -0,0 +1,103 @@
+package reservation
+
+import (
+	"context"
+	"order_system/constants"
+	"order_system/dal"
+	"order_system/model"
+	"time"
+)
+
+// Reserve Hold the stock taken by the order lines until expiresAt, the stock is taken when the order is created.
+// Backordered lines took no stock and aren't reserved.
+func Reserve(c context.Context, tx *dal.Query, orderId uint, lines []*model.OrderLine, expiresAt time.Time) ([]*model.StockReservation, error) {
+	reservations := make([]*model.StockReservation, 0, len(lines))
+	for _, line := range lines {
+		if line.Backordered {
+			continue
+		}
+		reservations = append(reservations, &model.StockReservation{
+			OrderId:   orderId,
+			ProductId: line.ProductId,
+			VariantId: line.VariantId,
+			Quantity:  line.Quantity,
+			State:     constants.RESERVATION_STATE_RESERVED,
+			ExpiresAt: expiresAt,
+		})
+	}
+	if len(reservations) == 0 {
+		return reservations, nil
+	}
+	if err := tx.StockReservation.WithContext(c).Create(reservations...); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// Sell Convert the held reservations of a paid order into a sale, the stock stays taken
+func Sell(c context.Context, tx *dal.Query, orderId uint) error {
+	_, err := tx.StockReservation.WithContext(c).
+		Where(tx.StockReservation.OrderId.Eq(orderId), tx.StockReservation.State.Eq(constants.RESERVATION_STATE_RESERVED)).
+		UpdateSimple(tx.StockReservation.State.Value(constants.RESERVATION_STATE_SOLD))
+	return err
+}
+
+// Release Return the stock of the held reservations of an order, state is RELEASED or EXPIRED.
+// The stock of a SKU is incremented, a product without variants is available again. Returns the count of released reservations.
+func Release(c context.Context, tx *dal.Query, orderId uint, state int8) (int, error) {
+	return release(c, tx, orderId, constants.RESERVATION_STATE_RESERVED, state)
+}
+
+// ReleaseSold Return the stock sold to an authorized backorder which is canceled before it is filled, state is RELEASED.
+// Returns the count of released reservations.
+func ReleaseSold(c context.Context, tx *dal.Query, orderId uint, state int8) (int, error) {
+	return release(c, tx, orderId, constants.RESERVATION_STATE_SOLD, state)
+}
+
+// Move the reservations of an order from one state to another and return their stock
+func release(c context.Context, tx *dal.Query, orderId uint, from int8, to int8) (int, error) {
+	released := make([]model.StockReservation, 0)
+	_, err := tx.StockReservation.WithContext(c).Returning(&released, "product_id", "variant_id", "quantity").
+		Where(tx.StockReservation.OrderId.Eq(orderId), tx.StockReservation.State.Eq(from)).
+		UpdateSimple(tx.StockReservation.State.Value(to))
+	if err != nil {
+		return 0, err
+	}
+	for _, reservation := range released {
+		if reservation.VariantId != nil {
+			_, err = tx.Variant.WithContext(c).Where(tx.Variant.ID.Eq(*reservation.VariantId)).UpdateSimple(tx.Variant.Stock.Add(reservation.Quantity))
+		} else {
+			_, err = tx.Product.WithContext(c).Where(tx.Product.ID.Eq(reservation.ProductId)).UpdateSimple(tx.Product.IsAvailable.Value(true))
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(released), nil
+}
+
+// ExpiredOrders Ids of the orders holding reservations past their expiry
+func ExpiredOrders(c context.Context, db *dal.Query, now time.Time) ([]uint, error) {
+	orderIds := make([]uint, 0)
+	err := db.StockReservation.WithContext(c).Distinct(db.StockReservation.OrderId).
+		Where(db.StockReservation.State.Eq(constants.RESERVATION_STATE_RESERVED), db.StockReservation.ExpiresAt.Lt(now)).
+		Pluck(db.StockReservation.OrderId, &orderIds)
+	if err != nil {
+		return nil, err
+	}
+	return orderIds, nil
+}
+
+func stateCodeToString(state int8) string {
+	switch state {
+	case constants.RESERVATION_STATE_RESERVED:
+		return "RESERVED"
+	case constants.RESERVATION_STATE_SOLD:
+		return "SOLD"
+	case constants.RESERVATION_STATE_RELEASED:
+		return "RELEASED"
+	case constants.RESERVATION_STATE_EXPIRED:
+		return "EXPIRED"
+	}
+	return "UNKNOWN"
+}
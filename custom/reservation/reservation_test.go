@@ -0,0 +1,119 @@
+package reservation
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"net/http"
+	"net/http/httptest"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+
+	variantId := uint(7)
+	sku := "TS-M-RED"
+	expiresAt := time.Now().Add(15 * time.Minute)
+	lines := []*model.OrderLine{
+		{ProductId: 3, Quantity: 1},
+		{ProductId: 4, VariantId: &variantId, Sku: &sku, Quantity: 2},
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^INSERT INTO \"stock_reservations\" .+ VALUES .+`).
+		WithArgs(1, 3, nil, 1, constants.RESERVATION_STATE_RESERVED, expiresAt, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			1, 4, variantId, 2, constants.RESERVATION_STATE_RESERVED, expiresAt, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+
+	reservations, err := Reserve(context.Background(), dal.Q, 1, lines, expiresAt)
+	assert.Nil(t, err)
+	assert.Len(t, reservations, 2)
+	assert.Equal(t, uint(2), reservations[1].ID)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRelease(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`^UPDATE \"stock_reservations\" SET \"state\"=\$1,\"updated_at\"=\$2 WHERE \"stock_reservations\"\.\"order_id\" = \$3 AND \"stock_reservations\"\.\"state\" = \$4 RETURNING \"product_id\",\"variant_id\",\"quantity\"`).
+		WithArgs(constants.RESERVATION_STATE_EXPIRED, sqlmock.AnyArg(), 1, constants.RESERVATION_STATE_RESERVED).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"}).AddRow(3, nil, 1).AddRow(4, 7, 2))
+	mock.ExpectExec(`^UPDATE \"products\" SET \"is_available\"=\$1,\"updated_at\"=\$2 WHERE \"products\"\.\"id\" = \$3`).
+		WithArgs(true, sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^UPDATE \"variants\" SET \"stock\"=\"variants\"\.\"stock\"\+\$1,\"updated_at\"=\$2 WHERE \"variants\"\.\"id\" = \$3`).
+		WithArgs(2, sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var released int
+	err := dal.Q.Transaction(func(tx *dal.Query) error {
+		var errTx error
+		released, errTx = Release(context.Background(), tx, 1, constants.RESERVATION_STATE_EXPIRED)
+		return errTx
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, released)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestExpiredOrders(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(`^SELECT DISTINCT \"stock_reservations\"\.\"order_id\" FROM \"stock_reservations\" WHERE \"stock_reservations\"\.\"state\" = \$1 AND \"stock_reservations\"\.\"expires_at\" < \$2`).
+		WithArgs(constants.RESERVATION_STATE_RESERVED, now).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id"}).AddRow(1).AddRow(5))
+
+	orderIds, err := ExpiredOrders(context.Background(), dal.Q, now)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{1, 5}, orderIds)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestStockMetrics(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	handlerCtx := HandlerContext{}
+	handlerCtx.InitialHandlerContext(dal.Q)
+
+	mock.ExpectQuery(`^SELECT \* FROM \"stock_reservations\" WHERE \"stock_reservations\"\.\"state\" = \$1`).
+		WithArgs(constants.RESERVATION_STATE_RESERVED).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "variant_id", "quantity", "state"}).
+			AddRow(1, 1, 3, nil, 1, constants.RESERVATION_STATE_RESERVED).
+			AddRow(2, 1, 4, 7, 2, constants.RESERVATION_STATE_RESERVED).
+			AddRow(3, 2, 4, 7, 1, constants.RESERVATION_STATE_RESERVED))
+	mock.ExpectQuery(`^SELECT \"stock_reservations\"\.\"state\",COUNT\(\"stock_reservations\"\.\"id\"\) AS \"count\" FROM \"stock_reservations\" GROUP BY \"stock_reservations\"\.\"state\"`).
+		WillReturnRows(sqlmock.NewRows([]string{"state", "count"}).
+			AddRow(constants.RESERVATION_STATE_RESERVED, 3).
+			AddRow(constants.RESERVATION_STATE_SOLD, 10).
+			AddRow(constants.RESERVATION_STATE_EXPIRED, 2))
+	mock.ExpectQuery(`^SELECT \* FROM \"variants\" ORDER BY \"variants\"\.\"id\"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "sku", "stock"}).AddRow(7, 4, "TS-M-RED", 5).AddRow(8, 4, "TS-L-RED", 0))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://localhosts", nil)
+	handlerCtx.StockMetrics(w, r)
+
+	actualResp := StockMetrics{}
+	json.Unmarshal(w.Body.Bytes(), &actualResp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 5, actualResp.Available)
+	assert.Equal(t, 4, actualResp.Reserved)
+	assert.Equal(t, map[string]int64{"RESERVED": 3, "SOLD": 10, "EXPIRED": 2}, actualResp.Reservations)
+	assert.Len(t, actualResp.Levels, 3)
+	assert.Equal(t, 5, actualResp.Levels[0].Available)
+	assert.Equal(t, 3, actualResp.Levels[0].Reserved)
+	assert.Equal(t, 0, actualResp.Levels[1].Reserved)
+	assert.Equal(t, StockLevel{ProductId: 3, Reserved: 1}, actualResp.Levels[2])
+}
@@ -0,0 +1,219 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const DECISION_APPROVE = "APPROVE"
+const DECISION_REVIEW = "REVIEW"
+const DECISION_DECLINE = "DECLINE"
+
+// Approved payments of a customer are limited within a rolling day
+const DAILY_WINDOW = 24 * time.Hour
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// A decline wins over a review, which wins over an approval
+var severity = map[string]int{
+	DECISION_APPROVE: 0,
+	DECISION_REVIEW:  1,
+	DECISION_DECLINE: 2,
+}
+
+// Velocity More than MaxPayments payments of a customer within WindowMinutes are declined, or reviewed by Decision
+type Velocity struct {
+	MaxPayments   int    `yaml:"max_payments"`
+	WindowMinutes int    `yaml:"window_minutes"`
+	Decision      string `yaml:"decision"`
+}
+
+// Blocklist Payments of the customers, or billed to the countries, are declined
+type Blocklist struct {
+	CustomerIds []uint   `yaml:"customer_ids"`
+	Countries   []string `yaml:"countries"`
+}
+
+// RuleTable Amounts are in the base currency, a zero amount or count disables its rule
+type RuleTable struct {
+	// Payments above the amount are reviewed
+	ReviewAmount float64 `yaml:"review_amount"`
+	// Approved payments of a customer within a day, the limits of customers override it
+	DailyLimit          float64          `yaml:"daily_limit"`
+	CustomerDailyLimits map[uint]float64 `yaml:"customer_daily_limits"`
+	Velocity            Velocity         `yaml:"velocity"`
+	Blocklist           Blocklist        `yaml:"blocklist"`
+}
+
+// Decision Outcome of the risk rules, with the reasons of every rule which declined or reviewed the payment
+type Decision struct {
+	Action  string   `json:"action"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// LoadRuleFile Read a yaml rule table, an empty rule table approves every payment when fileName is empty
+func LoadRuleFile(fileName string) (*RuleTable, error) {
+	if fileName == "" {
+		return &RuleTable{}, nil
+	}
+	table := &RuleTable{}
+	if err := util.LoadYamlFile("risk rule file", fileName, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Validate Check amounts, the velocity window and blocked countries, the velocity decision defaults to DECLINE
+func (t *RuleTable) Validate() error {
+	errs := make([]error, 0)
+	checkAmount := func(name string, amount float64) {
+		if amount < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %v", name, amount))
+		}
+	}
+	checkAmount("review_amount", t.ReviewAmount)
+	checkAmount("daily_limit", t.DailyLimit)
+	for customerId, limit := range t.CustomerDailyLimits {
+		checkAmount(fmt.Sprintf("daily limit of customer %d", customerId), limit)
+	}
+	if t.Velocity.MaxPayments < 0 {
+		errs = append(errs, fmt.Errorf("velocity max_payments must not be negative, got %d", t.Velocity.MaxPayments))
+	}
+	if t.Velocity.MaxPayments > 0 && t.Velocity.WindowMinutes <= 0 {
+		errs = append(errs, fmt.Errorf("velocity window_minutes must be positive, got %d", t.Velocity.WindowMinutes))
+	}
+	t.Velocity.Decision = strings.ToUpper(t.Velocity.Decision)
+	if t.Velocity.Decision == "" {
+		t.Velocity.Decision = DECISION_DECLINE
+	}
+	if t.Velocity.Decision != DECISION_DECLINE && t.Velocity.Decision != DECISION_REVIEW {
+		errs = append(errs, fmt.Errorf("velocity decision must be %s or %s, got %q", DECISION_DECLINE, DECISION_REVIEW, t.Velocity.Decision))
+	}
+	for i, country := range t.Blocklist.Countries {
+		t.Blocklist.Countries[i] = strings.ToUpper(country)
+		if !countryCode.MatchString(t.Blocklist.Countries[i]) {
+			errs = append(errs, fmt.Errorf("invalid blocked country %q", country))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DailyLimitOf Daily limit of a customer in the base currency, zero when it is unlimited
+func (t *RuleTable) DailyLimitOf(customerId uint) model.Money {
+	if limit, ok := t.CustomerDailyLimits[customerId]; ok {
+		return model.MoneyFromFloat(limit)
+	}
+	return model.MoneyFromFloat(t.DailyLimit)
+}
+
+func (t *RuleTable) isBlockedCustomer(customerId uint) bool {
+	for _, blocked := range t.Blocklist.CustomerIds {
+		if blocked == customerId {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *RuleTable) isBlockedCountry(address *model.AddressSnapshot) bool {
+	if address == nil {
+		return false
+	}
+	for _, blocked := range t.Blocklist.Countries {
+		if strings.EqualFold(blocked, address.Country) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Decision) add(action string, reason string) {
+	if severity[action] > severity[d.Action] {
+		d.Action = action
+	}
+	d.Reasons = append(d.Reasons, reason)
+}
+
+// Result Payment result of a declined or reviewed payment with its reasons, empty when it is approved
+func (d *Decision) Result() string {
+	switch d.Action {
+	case DECISION_DECLINE:
+		return "Declined: " + strings.Join(d.Reasons, "; ")
+	case DECISION_REVIEW:
+		return "Review: " + strings.Join(d.Reasons, "; ")
+	}
+	return ""
+}
+
+// ReasonsOf Reasons of a declined or reviewed payment from its payment result
+func ReasonsOf(result string) []string {
+	_, reasons, found := strings.Cut(result, ": ")
+	if !found || reasons == "" {
+		return nil
+	}
+	return strings.Split(reasons, "; ")
+}
+
+// Evaluate Decide on a new payment by the rules and the payment limit, both in the base currency.
+// Daily limits and velocity are checked with the earlier payments of the customer, payments without a customer skip them.
+// Retried attempts of a payment don't count for velocity.
+func Evaluate(c context.Context, db *dal.Query, rules *RuleTable, payment *model.Payment, billing *model.AddressSnapshot, paymentLimit model.Money, now time.Time) (*Decision, error) {
+	decision := &Decision{Action: DECISION_APPROVE}
+	if payment.CustomerId != 0 && rules.isBlockedCustomer(payment.CustomerId) {
+		decision.add(DECISION_DECLINE, fmt.Sprintf("customer %d is blocked", payment.CustomerId))
+	}
+	if rules.isBlockedCountry(billing) {
+		decision.add(DECISION_DECLINE, fmt.Sprintf("billing country %s is blocked", strings.ToUpper(billing.Country)))
+	}
+	if paymentLimit > 0 && payment.BaseAmount > paymentLimit {
+		decision.add(DECISION_DECLINE, constants.EXCEED_PAYMENT_LIMIT)
+	}
+	reviewAmount := model.MoneyFromFloat(rules.ReviewAmount)
+	if reviewAmount > 0 && payment.BaseAmount > reviewAmount {
+		decision.add(DECISION_REVIEW, fmt.Sprintf("amount %s is above the review threshold %s", payment.BaseAmount, reviewAmount))
+	}
+	if payment.CustomerId == 0 {
+		return decision, nil
+	}
+
+	paymentTable := db.Payment
+	if dailyLimit := rules.DailyLimitOf(payment.CustomerId); dailyLimit > 0 {
+		var spent struct {
+			Total model.Money
+		}
+		err := paymentTable.WithContext(c).Select(paymentTable.BaseAmount.Sum().As("total")).
+			Where(paymentTable.CustomerId.Eq(payment.CustomerId), paymentTable.ID.Neq(payment.ID),
+				paymentTable.State.In(constants.PAYMENT_STATE_SUCCESS, constants.PAYMENT_STATE_AUTHORIZED),
+				paymentTable.CreatedAt.Gte(now.Add(-DAILY_WINDOW))).
+			Scan(&spent)
+		if err != nil {
+			return nil, err
+		}
+		if spent.Total.Add(payment.BaseAmount) > dailyLimit {
+			decision.add(DECISION_DECLINE, fmt.Sprintf("%s: %s of %s spent today", constants.EXCEED_DAILY_LIMIT, spent.Total, dailyLimit))
+		}
+	}
+	if rules.Velocity.MaxPayments > 0 {
+		window := time.Duration(rules.Velocity.WindowMinutes) * time.Minute
+		count, err := paymentTable.WithContext(c).
+			Where(paymentTable.CustomerId.Eq(payment.CustomerId), paymentTable.ID.Neq(payment.ID),
+				paymentTable.State.Neq(constants.PAYMENT_STATE_REFUND), paymentTable.Attempt.Eq(1),
+				paymentTable.CreatedAt.Gte(now.Add(-window))).
+			Count()
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(rules.Velocity.MaxPayments) {
+			decision.add(rules.Velocity.Decision, fmt.Sprintf("%d payments within %d minutes", count+1, rules.Velocity.WindowMinutes))
+		}
+	}
+	return decision, nil
+}
@@ -0,0 +1,161 @@
+package risk
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"order_system/constants"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testRules = `
+review_amount: 500
+daily_limit: 1000
+customer_daily_limits:
+  7: 5000
+velocity:
+  max_payments: 3
+  window_minutes: 10
+  decision: "review"
+blocklist:
+  customer_ids: [13]
+  countries: ["kp"]
+`
+
+var (
+	sumPaymentsSQL   = `^SELECT SUM\(\"payments\"\.\"base_amount\"\) AS \"total\" FROM \"payments\" WHERE \"payments\"\.\"customer_id\" = \$1 AND \"payments\"\.\"id\" <> \$2 AND \"payments\"\.\"state\" IN \(\$3,\$4\) AND \"payments\"\.\"created_at\" >= \$5`
+	countPaymentsSQL = `^SELECT count\(\*\) FROM \"payments\" WHERE \"payments\"\.\"customer_id\" = \$1 AND \"payments\"\.\"id\" <> \$2 AND \"payments\"\.\"state\" <> \$3 AND \"payments\"\.\"attempt\" = \$4 AND \"payments\"\.\"created_at\" >= \$5`
+)
+
+func TestLoadRuleFile(t *testing.T) {
+	table, err := LoadRuleFile(util.WriteTestFile(t, "risk_rules.yaml", testRules))
+	assert.Nil(t, err)
+	assert.Equal(t, DECISION_REVIEW, table.Velocity.Decision)
+	assert.Equal(t, []string{"KP"}, table.Blocklist.Countries)
+	assert.Equal(t, model.NewMoney(5000, 0), table.DailyLimitOf(7))
+	assert.Equal(t, model.NewMoney(1000, 0), table.DailyLimitOf(2))
+
+	table, err = LoadRuleFile("")
+	assert.Nil(t, err)
+	assert.Equal(t, model.Money(0), table.DailyLimitOf(2))
+}
+
+func TestLoadRuleFileRepoFile(t *testing.T) {
+	_, err := LoadRuleFile("../../config/risk_rules.yaml")
+	assert.Nil(t, err)
+}
+
+func TestLoadRuleFileInvalid(t *testing.T) {
+	_, err := LoadRuleFile(util.WriteTestFile(t, "risk_rules.yaml", `
+review_amount: -1
+customer_daily_limits:
+  7: -5
+velocity:
+  max_payments: 3
+  decision: "block"
+blocklist:
+  countries: ["KOR"]
+`))
+	assert.ErrorContains(t, err, "review_amount must not be negative, got -1")
+	assert.ErrorContains(t, err, "daily limit of customer 7 must not be negative, got -5")
+	assert.ErrorContains(t, err, "velocity window_minutes must be positive, got 0")
+	assert.ErrorContains(t, err, `velocity decision must be DECLINE or REVIEW, got "BLOCK"`)
+	assert.ErrorContains(t, err, `invalid blocked country "KOR"`)
+
+	_, err = LoadRuleFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestEvaluateWithoutCustomer(t *testing.T) {
+	table, _ := LoadRuleFile(util.WriteTestFile(t, "risk_rules.yaml", testRules))
+	payment := &model.Payment{ID: 1, BaseAmount: model.NewMoney(600, 0)}
+
+	decision, err := Evaluate(context.Background(), dal.Q, table, payment, nil, model.NewMoney(1000, 0), time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, DECISION_REVIEW, decision.Action)
+	assert.Equal(t, "Review: amount 600.00 is above the review threshold 500.00", decision.Result())
+
+	payment.BaseAmount = model.NewMoney(1200, 0)
+	decision, _ = Evaluate(context.Background(), dal.Q, table, payment, &model.AddressSnapshot{Country: "KP"}, model.NewMoney(1000, 0), time.Now())
+	assert.Equal(t, DECISION_DECLINE, decision.Action)
+	assert.Equal(t, []string{
+		"billing country KP is blocked",
+		constants.EXCEED_PAYMENT_LIMIT,
+		"amount 1200.00 is above the review threshold 500.00",
+	}, decision.Reasons)
+
+	decision, _ = Evaluate(context.Background(), dal.Q, &RuleTable{}, payment, nil, 0, time.Now())
+	assert.Equal(t, DECISION_APPROVE, decision.Action)
+	assert.Equal(t, "", decision.Result())
+}
+
+func TestReasonsOf(t *testing.T) {
+	decision := &Decision{Action: DECISION_REVIEW, Reasons: []string{"customer 2 spent 900.00: over 500.00", "4 payments within 10 minutes"}}
+	assert.Equal(t, decision.Reasons, ReasonsOf(decision.Result()))
+	assert.Nil(t, ReasonsOf("Succeed"))
+}
+
+func TestEvaluateBlockedCustomer(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	table, _ := LoadRuleFile(util.WriteTestFile(t, "risk_rules.yaml", testRules))
+
+	mock.ExpectQuery(sumPaymentsSQL).WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(nil))
+	mock.ExpectQuery(countPaymentsSQL).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	payment := &model.Payment{ID: 1, CustomerId: 13, BaseAmount: model.NewMoney(10, 0)}
+	decision, err := Evaluate(context.Background(), dal.Q, table, payment, nil, 0, time.Now())
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "Declined: customer 13 is blocked", decision.Result())
+}
+
+func TestEvaluateDailyLimit(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	table, _ := LoadRuleFile(util.WriteTestFile(t, "risk_rules.yaml", testRules))
+	now := time.Now()
+
+	mock.ExpectQuery(sumPaymentsSQL).
+		WithArgs(2, 9, constants.PAYMENT_STATE_SUCCESS, constants.PAYMENT_STATE_AUTHORIZED, now.Add(-DAILY_WINDOW)).
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow("900.00"))
+	mock.ExpectQuery(countPaymentsSQL).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	payment := &model.Payment{ID: 9, CustomerId: 2, BaseAmount: model.NewMoney(150, 0)}
+	decision, err := Evaluate(context.Background(), dal.Q, table, payment, nil, 0, now)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, DECISION_DECLINE, decision.Action)
+	assert.Equal(t, []string{constants.EXCEED_DAILY_LIMIT + ": 900.00 of 1000.00 spent today"}, decision.Reasons)
+
+	// The limit of the customer overrides the daily limit
+	mock.ExpectQuery(sumPaymentsSQL).WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow("900.00"))
+	mock.ExpectQuery(countPaymentsSQL).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	payment.CustomerId = 7
+	decision, err = Evaluate(context.Background(), dal.Q, table, payment, nil, 0, now)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, DECISION_APPROVE, decision.Action)
+}
+
+func TestEvaluateVelocity(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	table, _ := LoadRuleFile(util.WriteTestFile(t, "risk_rules.yaml", testRules))
+	now := time.Now()
+
+	mock.ExpectQuery(sumPaymentsSQL).WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow("100.00"))
+	mock.ExpectQuery(countPaymentsSQL).WithArgs(2, 9, constants.PAYMENT_STATE_REFUND, 1, now.Add(-10*time.Minute)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	payment := &model.Payment{ID: 9, CustomerId: 2, BaseAmount: model.NewMoney(10, 0)}
+	decision, err := Evaluate(context.Background(), dal.Q, table, payment, nil, 0, now)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "Review: 4 payments within 10 minutes", decision.Result())
+}
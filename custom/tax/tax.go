@@ -0,0 +1,180 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"order_system/custom/category"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tax rates are applied with 6 decimal places, same as the order_taxes.rate column
+const RATE_SCALE = 1000000
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Jurisdiction Tax rule of a country, or of a region of the country which takes precedence
+type Jurisdiction struct {
+	Country string  `yaml:"country"`
+	Region  string  `yaml:"region,omitempty"`
+	Name    string  `yaml:"name"`
+	Rate    float64 `yaml:"rate"`
+	// Prices include the tax, e.g. VAT, otherwise the tax is added to the order amount
+	PricesIncludeTax bool `yaml:"prices_include_tax"`
+	// Rates of categories and their sub categories, the most specific category wins
+	CategoryRates map[uint]float64 `yaml:"category_rates"`
+}
+
+// RuleTable Jurisdictions keyed by the country and region of the shipping address
+type RuleTable struct {
+	Jurisdictions []Jurisdiction `yaml:"jurisdictions"`
+}
+
+// LoadRuleFile Read a yaml rule table, an empty rule table charges no tax when fileName is empty
+func LoadRuleFile(fileName string) (*RuleTable, error) {
+	if fileName == "" {
+		return &RuleTable{}, nil
+	}
+	table := &RuleTable{}
+	if err := util.LoadYamlFile("tax rule file", fileName, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Validate Check country codes and rates, a country and region has at most one jurisdiction
+func (t *RuleTable) Validate() error {
+	errs := make([]error, 0)
+	keys := make(map[string]bool)
+	checkRate := func(name string, rate float64) {
+		if rate < 0 || rate > 1 || math.IsNaN(rate) {
+			errs = append(errs, fmt.Errorf("%s rate must be between 0 and 1, got %v", name, rate))
+		}
+	}
+	for i := range t.Jurisdictions {
+		j := &t.Jurisdictions[i]
+		j.Country = strings.ToUpper(j.Country)
+		if !countryCode.MatchString(j.Country) {
+			errs = append(errs, fmt.Errorf("invalid country %q", j.Country))
+		}
+		if j.Name == "" {
+			errs = append(errs, fmt.Errorf("name of %s is required", j.key()))
+		}
+		if keys[j.key()] {
+			errs = append(errs, fmt.Errorf("duplicated jurisdiction %s", j.key()))
+		}
+		keys[j.key()] = true
+		checkRate(j.key(), j.Rate)
+		for categoryId, rate := range j.CategoryRates {
+			checkRate(fmt.Sprintf("%s category %d", j.key(), categoryId), rate)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (j *Jurisdiction) key() string {
+	if j.Region == "" {
+		return j.Country
+	}
+	return j.Country + "-" + j.Region
+}
+
+// Match Jurisdiction of the address region, or of its country. Nil when the address is nil or no rule matches
+func (t *RuleTable) Match(address *model.AddressSnapshot) *Jurisdiction {
+	if address == nil {
+		return nil
+	}
+	var match *Jurisdiction
+	for i := range t.Jurisdictions {
+		j := &t.Jurisdictions[i]
+		if !strings.EqualFold(j.Country, address.Country) {
+			continue
+		}
+		if j.Region == "" && match == nil {
+			match = j
+		}
+		if j.Region != "" && address.Region != nil && strings.EqualFold(j.Region, *address.Region) {
+			return j
+		}
+	}
+	return match
+}
+
+// RateOf Rate of a product in the category path from the root, the most specific category rate wins
+func (j *Jurisdiction) RateOf(categoryPath []uint) float64 {
+	for i := len(categoryPath) - 1; i >= 0; i-- {
+		if rate, ok := j.CategoryRates[categoryPath[i]]; ok {
+			return rate
+		}
+	}
+	return j.Rate
+}
+
+// LineTax Tax of a discounted line amount, extracted from the amount when it includes the tax
+func LineTax(amount model.Money, rate float64, included bool) model.Money {
+	scaled := int64(math.Round(rate * RATE_SCALE))
+	if included {
+		return amount.MulRatio(scaled, RATE_SCALE+scaled)
+	}
+	return amount.MulRatio(scaled, RATE_SCALE)
+}
+
+// Apply Tax the discounted order lines by the jurisdiction of the shipping address. Tax exclusive prices add the tax to the
+// order amount and the lines, the returned tax lines per rate are saved once the order is created.
+func Apply(c context.Context, tx *dal.Query, rules *RuleTable, order *model.Order, lines []*model.OrderLine) ([]*model.OrderTax, error) {
+	jurisdiction := rules.Match(order.ShippingAddress)
+	if jurisdiction == nil {
+		return nil, nil
+	}
+	paths := make(map[uint][]uint)
+	if len(jurisdiction.CategoryRates) > 0 {
+		productIds := make([]uint, 0, len(lines))
+		for _, line := range lines {
+			productIds = append(productIds, line.ProductId)
+		}
+		var err error
+		if paths, err = category.ProductCategoryPaths(c, tx, productIds); err != nil {
+			return nil, err
+		}
+	}
+
+	order.PricesIncludeTax = jurisdiction.PricesIncludeTax
+	taxByRate := make(map[float64]*model.OrderTax)
+	for _, line := range lines {
+		rate := jurisdiction.RateOf(paths[line.ProductId])
+		if rate == 0 {
+			continue
+		}
+		taxable := line.Amount.Sub(line.DiscountAmount)
+		amount := LineTax(taxable, rate, jurisdiction.PricesIncludeTax)
+		if jurisdiction.PricesIncludeTax {
+			taxable = taxable.Sub(amount)
+		} else {
+			line.TaxAmount = amount
+			order.Amount = order.Amount.Add(amount)
+		}
+		order.TaxAmount = order.TaxAmount.Add(amount)
+
+		orderTax, ok := taxByRate[rate]
+		if !ok {
+			orderTax = &model.OrderTax{Jurisdiction: jurisdiction.Name, Rate: rate, Included: jurisdiction.PricesIncludeTax}
+			taxByRate[rate] = orderTax
+		}
+		orderTax.TaxableAmount = orderTax.TaxableAmount.Add(taxable)
+		orderTax.Amount = orderTax.Amount.Add(amount)
+	}
+	taxes := make([]*model.OrderTax, 0, len(taxByRate))
+	for _, orderTax := range taxByRate {
+		taxes = append(taxes, orderTax)
+	}
+	sort.Slice(taxes, func(i, k int) bool {
+		return taxes[i].Rate > taxes[k].Rate
+	})
+	return taxes, nil
+}
@@ -0,0 +1,149 @@
+package tax
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"order_system/custom/util"
+	"order_system/dal"
+	"order_system/model"
+	"path/filepath"
+	"testing"
+)
+
+const testRules = `
+jurisdictions:
+  - country: "us"
+    name: "US"
+    rate: 0
+  - country: "US"
+    region: "CA"
+    name: "California sales tax"
+    rate: 0.0725
+  - country: "DE"
+    name: "Germany VAT"
+    rate: 0.19
+    prices_include_tax: true
+    category_rates:
+      2: 0.07
+`
+
+func TestLoadRuleFile(t *testing.T) {
+	table, err := LoadRuleFile(util.WriteTestFile(t, "tax_rules.yaml", testRules))
+	assert.Nil(t, err)
+	assert.Len(t, table.Jurisdictions, 3)
+	assert.Equal(t, "US", table.Jurisdictions[0].Country)
+
+	table, err = LoadRuleFile("")
+	assert.Nil(t, err)
+	assert.Nil(t, table.Match(&model.AddressSnapshot{Country: "US"}))
+}
+
+func TestLoadRuleFileRepoFile(t *testing.T) {
+	_, err := LoadRuleFile("../../config/tax_rules.yaml")
+	assert.Nil(t, err)
+}
+
+func TestLoadRuleFileInvalid(t *testing.T) {
+	_, err := LoadRuleFile(util.WriteTestFile(t, "tax_rules.yaml", `
+jurisdictions:
+  - country: "USA"
+    name: "US"
+    rate: 7.25
+  - country: "DE"
+    rate: 0.19
+    category_rates:
+      2: -1
+  - country: "DE"
+    name: "Germany VAT"
+`))
+	assert.ErrorContains(t, err, `invalid country "USA"`)
+	assert.ErrorContains(t, err, "USA rate must be between 0 and 1, got 7.25")
+	assert.ErrorContains(t, err, "name of DE is required")
+	assert.ErrorContains(t, err, "DE category 2 rate must be between 0 and 1, got -1")
+	assert.ErrorContains(t, err, "duplicated jurisdiction DE")
+
+	_, err = LoadRuleFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestMatch(t *testing.T) {
+	table, _ := LoadRuleFile(util.WriteTestFile(t, "tax_rules.yaml", testRules))
+
+	assert.Equal(t, "California sales tax", table.Match(&model.AddressSnapshot{Country: "US", Region: util.GetStringPtr("ca")}).Name)
+	assert.Equal(t, "US", table.Match(&model.AddressSnapshot{Country: "US", Region: util.GetStringPtr("OR")}).Name)
+	assert.Equal(t, "US", table.Match(&model.AddressSnapshot{Country: "US"}).Name)
+	assert.Nil(t, table.Match(&model.AddressSnapshot{Country: "FR"}))
+	assert.Nil(t, table.Match(nil))
+}
+
+func TestLineTax(t *testing.T) {
+	assert.Equal(t, model.NewMoney(7, 25), LineTax(model.NewMoney(100, 0), 0.0725, false))
+	// 119.00 including 19% is 100.00 plus 19.00 tax
+	assert.Equal(t, model.NewMoney(19, 0), LineTax(model.NewMoney(119, 0), 0.19, true))
+}
+
+func TestApplyExclusive(t *testing.T) {
+	table, _ := LoadRuleFile(util.WriteTestFile(t, "tax_rules.yaml", testRules))
+	order := &model.Order{
+		ShippingAddress: &model.AddressSnapshot{Country: "US", Region: util.GetStringPtr("CA")},
+		Subtotal:        model.NewMoney(110, 0),
+		DiscountAmount:  model.NewMoney(10, 0),
+		Amount:          model.NewMoney(100, 0),
+	}
+	lines := []*model.OrderLine{
+		{ProductId: 3, Amount: model.NewMoney(60, 0), DiscountAmount: model.NewMoney(10, 0)},
+		{ProductId: 4, Amount: model.NewMoney(50, 0)},
+	}
+	taxes, err := Apply(context.Background(), dal.Q, table, order, lines)
+
+	// Tax is charged on the discounted lines, rounded per line, and added to the amount
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.OrderTax{{
+		Jurisdiction:  "California sales tax",
+		Rate:          0.0725,
+		TaxableAmount: model.NewMoney(100, 0),
+		Amount:        model.NewMoney(7, 26),
+	}}, taxes)
+	assert.Equal(t, model.NewMoney(3, 63), lines[0].TaxAmount)
+	assert.Equal(t, model.NewMoney(7, 26), order.TaxAmount)
+	assert.Equal(t, model.NewMoney(107, 26), order.Amount)
+	assert.False(t, order.PricesIncludeTax)
+}
+
+func TestApplyInclusiveCategoryRate(t *testing.T) {
+	sqlDB, _, mock := util.DbMock(t)
+	defer sqlDB.Close()
+	table, _ := LoadRuleFile(util.WriteTestFile(t, "tax_rules.yaml", testRules))
+
+	// Product 3 is in sub category 5 of the reduced rate category 2
+	mock.ExpectQuery(`^SELECT \* FROM \"products\" WHERE \"products\"\.\"id\" IN \(\$1,\$2\) AND \"products\"\.\"category_id\" IS NOT NULL`).
+		WithArgs(3, 4).WillReturnRows(sqlmock.NewRows([]string{"id", "category_id"}).AddRow(3, 5))
+	mock.ExpectQuery(`^SELECT \* FROM \"categories\" WHERE \"categories\"\.\"id\" = \$1`).
+		WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"id", "path"}).AddRow(5, "/2/5/"))
+
+	order := &model.Order{
+		ShippingAddress: &model.AddressSnapshot{Country: "DE"},
+		Subtotal:        model.NewMoney(226, 0),
+		Amount:          model.NewMoney(226, 0),
+	}
+	lines := []*model.OrderLine{
+		{ProductId: 3, Amount: model.NewMoney(107, 0)},
+		{ProductId: 4, Amount: model.NewMoney(119, 0)},
+	}
+	taxes, err := Apply(context.Background(), dal.Q, table, order, lines)
+
+	// Tax is included in the prices, the amount doesn't change
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Nil(t, err)
+	assert.Len(t, taxes, 2)
+	assert.Equal(t, 0.19, taxes[0].Rate)
+	assert.Equal(t, model.NewMoney(100, 0), taxes[0].TaxableAmount)
+	assert.Equal(t, 0.07, taxes[1].Rate)
+	assert.Equal(t, model.NewMoney(7, 0), taxes[1].Amount)
+	assert.True(t, taxes[1].Included)
+	assert.Equal(t, model.NewMoney(26, 0), order.TaxAmount)
+	assert.Equal(t, model.NewMoney(226, 0), order.Amount)
+	assert.Equal(t, model.Money(0), lines[0].TaxAmount)
+	assert.True(t, order.PricesIncludeTax)
+}
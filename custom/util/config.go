@@ -0,0 +1,351 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Every config field can be overridden by env var ORDER_SYSTEM_<YAML PATH>, e.g. ORDER_SYSTEM_POSTGRES_PASSWORD.
+// ORDER_SYSTEM_<YAML PATH>_FILE or a "file://<path>" value of a secret field reads the value from a secret file instead.
+const CONFIG_ENV_PREFIX = "ORDER_SYSTEM_"
+const CONFIG_FILE_REF_PREFIX = "file://"
+const CONFIG_REDACTED = "******"
+
+type DbConfig struct {
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password" secret:"true"`
+	Database    string `yaml:"database"`
+	AutoMigrate bool   `yaml:"auto_migrate"`
+}
+
+type HealthConfig struct {
+	CheckTimeoutSeconds   int    `yaml:"check_timeout_seconds"`
+	QueueBacklogThreshold int    `yaml:"queue_backlog_threshold"`
+	OrderHealthUrl        string `yaml:"order_health_url"`
+	PaymentHealthUrl      string `yaml:"payment_health_url"`
+}
+
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Exporter    string  `yaml:"exporter"`
+	FileDir     string  `yaml:"file_dir"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// CurrencyConfig Rate file is reloaded with the config, only the default currency is supported when it is empty
+type CurrencyConfig struct {
+	RateFile string `yaml:"rate_file"`
+}
+
+// TaxConfig Rule file is reloaded with the config, no tax is charged when it is empty
+type TaxConfig struct {
+	RuleFile string `yaml:"rule_file"`
+}
+
+// RiskConfig Rule file is reloaded with the config, only the payment limit is checked when it is empty
+type RiskConfig struct {
+	RuleFile string `yaml:"rule_file"`
+}
+
+const FULFILLMENT_PROVIDER_SIMULATOR = "simulator"
+const FULFILLMENT_PROVIDER_MANUAL = "manual"
+
+// FulfillmentConfig The simulator ships and delivers every order after the delays,
+// the manual provider leaves shipments to warehouses posting shipment updates. Provider defaults to the simulator.
+type FulfillmentConfig struct {
+	Provider                string `yaml:"provider"`
+	SimulatorShipSeconds    int    `yaml:"simulator_ship_seconds"`
+	SimulatorDeliverSeconds int    `yaml:"simulator_deliver_seconds"`
+	// The simulator reports orders of these SKUs out of stock
+	SimulatorOutOfStockSkus []string `yaml:"simulator_out_of_stock_skus"`
+}
+
+// NotificationConfig Customer notifications are posted to the webhook, or logged when it is empty
+type NotificationConfig struct {
+	WebhookUrl     string `yaml:"webhook_url"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+}
+
+// RuntimeConfig Values can be changed without restart, see ConfigWatcher
+type RuntimeConfig struct {
+	// The config watcher polls with the interval read on startup
+	ReloadIntervalSeconds   int     `yaml:"reload_interval_seconds" reload:"false"`
+	PaymentLimit            float64 `yaml:"payment_limit"`
+	PaymentApiRetryCount    int     `yaml:"payment_api_retry_count"`
+	OrderCallbackRetryCount int     `yaml:"order_callback_retry_count"`
+	FulfillmentRetryCount   int     `yaml:"fulfillment_retry_count"`
+	// Delay before the first retry of a payment api call or a payment attempt, it doubles with every retry up to the max backoff
+	RetryBackoffMs    int `yaml:"retry_backoff_ms"`
+	RetryMaxBackoffMs int `yaml:"retry_max_backoff_ms"`
+	// Payment attempts after a transient gateway error, hard declines aren't retried
+	PaymentAttemptRetryCount int `yaml:"payment_attempt_retry_count"`
+	// Unpaid orders are canceled and their stock released when their reservations are held for this long
+	ReservationTtlMinutes int `yaml:"reservation_ttl_minutes"`
+	// Open carts which aren't changed for this long expire
+	CartTtlMinutes           int `yaml:"cart_ttl_minutes"`
+	OrderWorkerConcurrency   int `yaml:"order_worker_concurrency"`
+	PaymentWorkerConcurrency int `yaml:"payment_worker_concurrency"`
+}
+
+// ServerConfig Fields tagged reload:"true" (or within a tagged struct) are applied by config reloads, others only on startup
+type ServerConfig struct {
+	Order_port                 int                `yaml:"order_port"`
+	Payment_port               int                `yaml:"payment_port"`
+	Postgres                   DbConfig           `yaml:"postgres"`
+	Order_payment_callback_url string             `yaml:"order_payment_callback_url" reload:"true"`
+	Payment_message_queue_url  string             `yaml:"payment_message_queue_url" reload:"true"`
+	Payment_refund_url         string             `yaml:"payment_refund_url" reload:"true"`
+	Payment_capture_url        string             `yaml:"payment_capture_url" reload:"true"`
+	Payment_void_url           string             `yaml:"payment_void_url" reload:"true"`
+	Health                     HealthConfig       `yaml:"health"`
+	Tracing                    TracingConfig      `yaml:"tracing"`
+	Currency                   CurrencyConfig     `yaml:"currency" reload:"true"`
+	Tax                        TaxConfig          `yaml:"tax" reload:"true"`
+	Risk                       RiskConfig         `yaml:"risk" reload:"true"`
+	Fulfillment                FulfillmentConfig  `yaml:"fulfillment"`
+	Notification               NotificationConfig `yaml:"notification"`
+	Runtime                    RuntimeConfig      `yaml:"runtime" reload:"true"`
+}
+
+// LoadConfig Read config file, apply env var overrides and secret files, then validate the result
+func LoadConfig(fileName string) (*ServerConfig, error) {
+	c := &ServerConfig{}
+	err := ReadYamlFile("config file", fileName, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = applyConfigOverrides(reflect.ValueOf(c).Elem(), ""); err != nil {
+		return nil, err
+	}
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate Check all fields and report every invalid one
+func (c *ServerConfig) Validate() error {
+	errs := make([]error, 0)
+	checkPort := func(name string, port int) {
+		if port <= 0 || port > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be between 1 and 65535, got %d", name, port))
+		}
+	}
+	checkRequired := func(name string, value string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+	checkUrl := func(name string, value string) {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an absolute http(s) url, got %q", name, value))
+		}
+	}
+
+	checkPort("order_port", c.Order_port)
+	checkPort("payment_port", c.Payment_port)
+	checkRequired("postgres.host", c.Postgres.Host)
+	checkPort("postgres.port", c.Postgres.Port)
+	checkRequired("postgres.username", c.Postgres.Username)
+	checkRequired("postgres.database", c.Postgres.Database)
+	checkUrl("order_payment_callback_url", c.Order_payment_callback_url)
+	checkUrl("payment_message_queue_url", c.Payment_message_queue_url)
+	checkUrl("payment_refund_url", c.Payment_refund_url)
+	checkUrl("payment_capture_url", c.Payment_capture_url)
+	checkUrl("payment_void_url", c.Payment_void_url)
+	if c.Health.CheckTimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("health.check_timeout_seconds must be positive, got %d", c.Health.CheckTimeoutSeconds))
+	}
+	if c.Health.QueueBacklogThreshold < 0 {
+		errs = append(errs, fmt.Errorf("health.queue_backlog_threshold must not be negative, got %d", c.Health.QueueBacklogThreshold))
+	}
+	checkUrl("health.order_health_url", c.Health.OrderHealthUrl)
+	checkUrl("health.payment_health_url", c.Health.PaymentHealthUrl)
+	if c.Tracing.Enabled {
+		switch c.Tracing.Exporter {
+		case TRACING_EXPORTER_STDOUT:
+		case TRACING_EXPORTER_FILE:
+			checkRequired("tracing.file_dir", c.Tracing.FileDir)
+		default:
+			errs = append(errs, fmt.Errorf("tracing.exporter must be %q or %q, got %q", TRACING_EXPORTER_STDOUT, TRACING_EXPORTER_FILE, c.Tracing.Exporter))
+		}
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			errs = append(errs, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio))
+		}
+	}
+
+	switch c.Fulfillment.Provider {
+	case "", FULFILLMENT_PROVIDER_SIMULATOR, FULFILLMENT_PROVIDER_MANUAL:
+	default:
+		errs = append(errs, fmt.Errorf("fulfillment.provider must be %q or %q, got %q", FULFILLMENT_PROVIDER_SIMULATOR, FULFILLMENT_PROVIDER_MANUAL, c.Fulfillment.Provider))
+	}
+	if c.Fulfillment.SimulatorShipSeconds < 0 || c.Fulfillment.SimulatorDeliverSeconds < 0 {
+		errs = append(errs, errors.New("fulfillment simulator delays must not be negative"))
+	}
+	if c.Notification.WebhookUrl != "" {
+		checkUrl("notification.webhook_url", c.Notification.WebhookUrl)
+	}
+	if c.Notification.TimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("notification.timeout_seconds must not be negative, got %d", c.Notification.TimeoutSeconds))
+	}
+
+	if c.Runtime.ReloadIntervalSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("runtime.reload_interval_seconds must be positive, got %d", c.Runtime.ReloadIntervalSeconds))
+	}
+	if c.Runtime.ReservationTtlMinutes <= 0 {
+		errs = append(errs, fmt.Errorf("runtime.reservation_ttl_minutes must be positive, got %d", c.Runtime.ReservationTtlMinutes))
+	}
+	if c.Runtime.CartTtlMinutes <= 0 {
+		errs = append(errs, fmt.Errorf("runtime.cart_ttl_minutes must be positive, got %d", c.Runtime.CartTtlMinutes))
+	}
+	if c.Runtime.PaymentLimit <= 0 {
+		errs = append(errs, fmt.Errorf("runtime.payment_limit must be positive, got %v", c.Runtime.PaymentLimit))
+	}
+	checkNotNegative := func(name string, value int) {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", name, value))
+		}
+	}
+	checkNotNegative("runtime.payment_api_retry_count", c.Runtime.PaymentApiRetryCount)
+	checkNotNegative("runtime.order_callback_retry_count", c.Runtime.OrderCallbackRetryCount)
+	checkNotNegative("runtime.fulfillment_retry_count", c.Runtime.FulfillmentRetryCount)
+	checkNotNegative("runtime.retry_backoff_ms", c.Runtime.RetryBackoffMs)
+	checkNotNegative("runtime.retry_max_backoff_ms", c.Runtime.RetryMaxBackoffMs)
+	checkNotNegative("runtime.payment_attempt_retry_count", c.Runtime.PaymentAttemptRetryCount)
+	checkNotNegative("runtime.order_worker_concurrency", c.Runtime.OrderWorkerConcurrency)
+	checkNotNegative("runtime.payment_worker_concurrency", c.Runtime.PaymentWorkerConcurrency)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Redacted Effective config keyed by yaml names, secret fields are masked
+func (c *ServerConfig) Redacted() map[string]interface{} {
+	return redactConfig(reflect.ValueOf(c).Elem())
+}
+
+func redactConfig(v reflect.Value) map[string]interface{} {
+	result := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := configFieldName(field)
+		if name == "" {
+			continue
+		}
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			result[name] = redactConfig(v.Field(i))
+		case field.Tag.Get("secret") == "true":
+			result[name] = CONFIG_REDACTED
+		default:
+			result[name] = v.Field(i).Interface()
+		}
+	}
+	return result
+}
+
+// Walk through all fields and apply env var and secret file overrides, only secret fields are read from files
+func applyConfigOverrides(v reflect.Value, prefix string) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := configFieldName(field)
+		if name == "" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyConfigOverrides(v.Field(i), path); err != nil {
+				return err
+			}
+			continue
+		}
+
+		envName := CONFIG_ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+		secret := field.Tag.Get("secret") == "true"
+		value, found := os.LookupEnv(envName)
+		if fileName, ok := os.LookupEnv(envName + "_FILE"); ok && secret {
+			value, found = CONFIG_FILE_REF_PREFIX+fileName, true
+		}
+		if !found && secret {
+			value, found = v.Field(i).String(), true
+		}
+		if !found {
+			continue
+		}
+		if secret && strings.HasPrefix(value, CONFIG_FILE_REF_PREFIX) {
+			content, err := os.ReadFile(strings.TrimPrefix(value, CONFIG_FILE_REF_PREFIX))
+			if err != nil {
+				return fmt.Errorf("read secret file of %s failed: %w", path, err)
+			}
+			value = strings.TrimSpace(string(content))
+		}
+		if err := setConfigValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid value of %s (%s): %w", path, envName, err)
+		}
+	}
+	return nil
+}
+
+func setConfigValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// Comma separated items, an empty value is an empty slice
+		items := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setConfigValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Kind())
+	}
+	return nil
+}
+
+func configFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
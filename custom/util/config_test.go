@@ -0,0 +1,269 @@
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfigYaml = `
+order_port: 8088
+payment_port: 8089
+postgres:
+  host: "localhost"
+  port: 5432
+  username: "postgres"
+  password: "password"
+  database: "order_system"
+order_payment_callback_url: "http://localhost:8088/order/payment_callback"
+payment_message_queue_url: "http://localhost:8089/payment/new_payment"
+payment_refund_url: "http://localhost:8089/payment/refund"
+payment_capture_url: "http://localhost:8089/payment/capture"
+payment_void_url: "http://localhost:8089/payment/void"
+health:
+  check_timeout_seconds: 2
+  queue_backlog_threshold: 100
+  order_health_url: "http://localhost:8088/healthz"
+  payment_health_url: "http://localhost:8089/healthz"
+tracing:
+  enabled: false
+runtime:
+  reload_interval_seconds: 10
+  payment_limit: 1000
+  payment_api_retry_count: 1
+  order_callback_retry_count: 1
+  reservation_ttl_minutes: 15
+  cart_ttl_minutes: 60
+  order_worker_concurrency: 10
+  payment_worker_concurrency: 10
+`
+
+func TestLoadConfigSuccess(t *testing.T) {
+	c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 8088, c.Order_port)
+	assert.Equal(t, "password", c.Postgres.Password)
+	assert.Equal(t, 100, c.Health.QueueBacklogThreshold)
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestLoadConfigUnknownKey(t *testing.T) {
+	misspelled := strings.Replace(testConfigYaml, "payment_limit:", "payment_limt:", 1)
+	_, err := LoadConfig(WriteTestFile(t, "config.yaml", misspelled))
+
+	assert.ErrorContains(t, err, "field payment_limt not found")
+}
+
+func TestLoadConfigRepoFile(t *testing.T) {
+	_, err := LoadConfig(filepath.Join("..", "..", "config", "config.yaml"))
+	assert.Nil(t, err)
+}
+
+func TestLoadConfigEnvOverride(t *testing.T) {
+	t.Setenv("ORDER_SYSTEM_ORDER_PORT", "9000")
+	t.Setenv("ORDER_SYSTEM_POSTGRES_HOST", "db.internal")
+	t.Setenv("ORDER_SYSTEM_TRACING_ENABLED", "true")
+	t.Setenv("ORDER_SYSTEM_TRACING_EXPORTER", "stdout")
+	t.Setenv("ORDER_SYSTEM_TRACING_SAMPLE_RATIO", "0.5")
+	c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 9000, c.Order_port)
+	assert.Equal(t, "db.internal", c.Postgres.Host)
+	assert.True(t, c.Tracing.Enabled)
+	assert.Equal(t, 0.5, c.Tracing.SampleRatio)
+}
+
+func TestLoadConfigEnvOverrideSlice(t *testing.T) {
+	t.Setenv("ORDER_SYSTEM_FULFILLMENT_SIMULATOR_OUT_OF_STOCK_SKUS", "SKU-1, SKU-2,,")
+	c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"SKU-1", "SKU-2"}, c.Fulfillment.SimulatorOutOfStockSkus)
+
+	t.Setenv("ORDER_SYSTEM_FULFILLMENT_SIMULATOR_OUT_OF_STOCK_SKUS", "")
+	c, err = LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+	assert.Nil(t, err)
+	assert.Empty(t, c.Fulfillment.SimulatorOutOfStockSkus)
+}
+
+func TestLoadConfigInvalidEnvValue(t *testing.T) {
+	t.Setenv("ORDER_SYSTEM_ORDER_PORT", "not a number")
+	_, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+
+	assert.ErrorContains(t, err, "ORDER_SYSTEM_ORDER_PORT")
+}
+
+func TestLoadConfigSecretFile(t *testing.T) {
+	t.Run("env file", func(t *testing.T) {
+		t.Setenv("ORDER_SYSTEM_POSTGRES_PASSWORD_FILE", WriteTestFile(t, "password", "env-secret\n"))
+		c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+		assert.Nil(t, err)
+		assert.Equal(t, "env-secret", c.Postgres.Password)
+	})
+
+	t.Run("file reference", func(t *testing.T) {
+		t.Setenv("ORDER_SYSTEM_POSTGRES_PASSWORD", "file://"+WriteTestFile(t, "password", "ref-secret"))
+		c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+		assert.Nil(t, err)
+		assert.Equal(t, "ref-secret", c.Postgres.Password)
+	})
+
+	// Fields which aren't secret are never read from files
+	t.Run("not secret", func(t *testing.T) {
+		fileName := WriteTestFile(t, "host", "db.internal")
+		t.Setenv("ORDER_SYSTEM_POSTGRES_HOST", "file://"+fileName)
+		t.Setenv("ORDER_SYSTEM_POSTGRES_DATABASE_FILE", fileName)
+		c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+		assert.Nil(t, err)
+		assert.Equal(t, "file://"+fileName, c.Postgres.Host)
+		assert.Equal(t, "order_system", c.Postgres.Database)
+	})
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	t.Setenv("ORDER_SYSTEM_PAYMENT_PORT", "70000")
+	t.Setenv("ORDER_SYSTEM_POSTGRES_HOST", "")
+	t.Setenv("ORDER_SYSTEM_PAYMENT_MESSAGE_QUEUE_URL", "payment_api:8089")
+	t.Setenv("ORDER_SYSTEM_FULFILLMENT_PROVIDER", "drone")
+	t.Setenv("ORDER_SYSTEM_NOTIFICATION_WEBHOOK_URL", "notify.internal/hooks")
+	_, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+
+	assert.ErrorContains(t, err, "payment_port must be between 1 and 65535")
+	assert.ErrorContains(t, err, "postgres.host is required")
+	assert.ErrorContains(t, err, "payment_message_queue_url must be an absolute http(s) url")
+	assert.ErrorContains(t, err, `fulfillment.provider must be "simulator" or "manual", got "drone"`)
+	assert.ErrorContains(t, err, "notification.webhook_url must be an absolute http(s) url")
+}
+
+func TestConfigRedacted(t *testing.T) {
+	c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+	assert.Nil(t, err)
+
+	redacted := c.Redacted()
+	assert.Equal(t, CONFIG_REDACTED, redacted["postgres"].(map[string]interface{})["password"])
+	assert.Equal(t, "localhost", redacted["postgres"].(map[string]interface{})["host"])
+	assert.Equal(t, 8088, redacted["order_port"])
+}
+
+func TestConfigWatcherReload(t *testing.T) {
+	fileName := WriteTestFile(t, "config.yaml", testConfigYaml)
+	c, err := LoadConfig(fileName)
+	assert.Nil(t, err)
+	watcher := NewConfigWatcher(fileName, c)
+	var applied *ServerConfig
+	watcher.Subscribe(func(newConfig *ServerConfig) {
+		applied = newConfig
+	})
+	assert.Equal(t, c, applied)
+
+	// Valid change is swapped in
+	t.Setenv("ORDER_SYSTEM_RUNTIME_PAYMENT_LIMIT", "2000")
+	assert.Nil(t, watcher.Reload())
+	assert.Equal(t, 2000.0, watcher.Current().Runtime.PaymentLimit)
+	assert.Equal(t, 2000.0, applied.Runtime.PaymentLimit)
+
+	// Invalid change is rejected and current config is kept
+	t.Setenv("ORDER_SYSTEM_RUNTIME_PAYMENT_LIMIT", "-1")
+	assert.Error(t, watcher.Reload())
+	assert.Equal(t, 2000.0, watcher.Current().Runtime.PaymentLimit)
+}
+
+// A file reloaded on SIGHUP isn't reloaded again by polling
+func TestConfigWatcherReloadRecordsModTime(t *testing.T) {
+	fileName := WriteTestFile(t, "config.yaml", testConfigYaml)
+	c, err := LoadConfig(fileName)
+	assert.Nil(t, err)
+	watcher := NewConfigWatcher(fileName, c)
+	assert.False(t, watcher.fileChanged())
+
+	modTime := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(fileName, modTime, modTime))
+	assert.Nil(t, watcher.Reload())
+	assert.False(t, watcher.fileChanged())
+
+	modTime = modTime.Add(time.Minute)
+	assert.Nil(t, os.Chtimes(fileName, modTime, modTime))
+	assert.True(t, watcher.fileChanged())
+	assert.False(t, watcher.fileChanged())
+}
+
+func TestConfigWatcherReloadStartupOnly(t *testing.T) {
+	fileName := WriteTestFile(t, "config.yaml", testConfigYaml)
+	c, err := LoadConfig(fileName)
+	assert.Nil(t, err)
+	watcher := NewConfigWatcher(fileName, c)
+	applied := 0
+	watcher.Subscribe(func(newConfig *ServerConfig) {
+		applied++
+	})
+
+	// Startup only fields keep their current values, runtime fields are applied
+	t.Setenv("ORDER_SYSTEM_ORDER_PORT", "9000")
+	t.Setenv("ORDER_SYSTEM_POSTGRES_PASSWORD", "new-secret")
+	t.Setenv("ORDER_SYSTEM_RUNTIME_RELOAD_INTERVAL_SECONDS", "30")
+	t.Setenv("ORDER_SYSTEM_RUNTIME_PAYMENT_LIMIT", "2000")
+	assert.Nil(t, watcher.Reload())
+	assert.Equal(t, 8088, watcher.Current().Order_port)
+	assert.Equal(t, "password", watcher.Current().Postgres.Password)
+	assert.Equal(t, 10, watcher.Current().Runtime.ReloadIntervalSeconds)
+	assert.Equal(t, 2000.0, watcher.Current().Runtime.PaymentLimit)
+	assert.Equal(t, 2, applied)
+
+	// Nothing is applied when only startup fields changed
+	t.Setenv("ORDER_SYSTEM_ORDER_PORT", "9001")
+	assert.Nil(t, watcher.Reload())
+	assert.Equal(t, 2, applied)
+}
+
+func TestKeepStartupFields(t *testing.T) {
+	c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+	assert.Nil(t, err)
+	newConfig := *c
+	newConfig.Order_port = 9000
+	newConfig.Postgres.Password = "new-secret"
+	newConfig.Payment_refund_url = "http://payment:8089/payment/refund"
+
+	assert.Equal(t, []string{"order_port: 8088 -> 9000", "postgres.password: changed"}, keepStartupFields(c, &newConfig))
+	assert.Equal(t, 8088, newConfig.Order_port)
+	assert.Equal(t, "http://payment:8089/payment/refund", newConfig.Payment_refund_url)
+}
+
+func TestDiffConfig(t *testing.T) {
+	c, err := LoadConfig(WriteTestFile(t, "config.yaml", testConfigYaml))
+	assert.Nil(t, err)
+	newConfig := *c
+	newConfig.Runtime.OrderWorkerConcurrency = 20
+	newConfig.Postgres.Password = "new password"
+
+	diff := DiffConfig(c, &newConfig)
+	assert.Equal(t, []string{
+		"postgres.password: changed",
+		"runtime.order_worker_concurrency: 10 -> 20",
+	}, diff)
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(1)
+	limiter.Acquire()
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire()
+		close(acquired)
+	}()
+
+	// Raising the limit unblocks the waiting worker
+	limiter.SetLimit(2)
+	<-acquired
+	assert.Equal(t, 2, limiter.InUse())
+	limiter.Release()
+	limiter.Release()
+	assert.Equal(t, 0, limiter.InUse())
+}
@@ -0,0 +1,203 @@
+package util
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ConfigWatcher Reload config file when it changes or SIGHUP is received, and notify subscribers with the new config
+type ConfigWatcher struct {
+	fileName    string
+	current     atomic.Pointer[ServerConfig]
+	modTime     time.Time
+	mu          sync.Mutex
+	subscribers []func(*ServerConfig)
+}
+
+func NewConfigWatcher(fileName string, c *ServerConfig) *ConfigWatcher {
+	cw := &ConfigWatcher{
+		fileName: fileName,
+	}
+	cw.current.Store(c)
+	if info, err := os.Stat(fileName); err == nil {
+		cw.modTime = info.ModTime()
+	}
+	return cw
+}
+
+// Current The latest valid config
+func (cw *ConfigWatcher) Current() *ServerConfig {
+	return cw.current.Load()
+}
+
+// Subscribe Register a function which is called with the current config immediately and every time config is reloaded
+func (cw *ConfigWatcher) Subscribe(fn func(*ServerConfig)) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.subscribers = append(cw.subscribers, fn)
+	fn(cw.Current())
+}
+
+// Reload Load and validate config file, swap it in only when it is valid.
+// Changes of fields which are only read on startup need a restart, they are logged and the current values are kept.
+// The modification time of a loaded file is recorded, the file isn't reloaded by polling until it changes again.
+func (cw *ConfigWatcher) Reload() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	// Taken before loading, a change while loading is reloaded on the next poll
+	info, statErr := os.Stat(cw.fileName)
+	newConfig, err := LoadConfig(cw.fileName)
+	if err != nil {
+		slog.Error("Reload config failed, keep using current config", "error", err.Error())
+		return err
+	}
+	if statErr == nil && info.ModTime().After(cw.modTime) {
+		cw.modTime = info.ModTime()
+	}
+	if restartChanges := keepStartupFields(cw.Current(), newConfig); len(restartChanges) > 0 {
+		slog.Warn("Config changes need a restart and are not applied", "changes", restartChanges)
+	}
+	diff := DiffConfig(cw.Current(), newConfig)
+	if len(diff) == 0 {
+		slog.Info("Config reloaded without changes")
+		return nil
+	}
+	cw.current.Store(newConfig)
+	for _, fn := range cw.subscribers {
+		fn(newConfig)
+	}
+	slog.Info("Config reloaded", "changes", diff)
+	return nil
+}
+
+// Watch Poll config file modification time and listen to SIGHUP, blocks until stop is closed
+func (cw *ConfigWatcher) Watch(interval time.Duration, stop <-chan struct{}) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-sighup:
+			slog.Info("Received SIGHUP, reloading config")
+			cw.Reload()
+		case <-ticker.C:
+			if !cw.fileChanged() {
+				continue
+			}
+			slog.Info("Config file changed, reloading config", "file", cw.fileName)
+			cw.Reload()
+		}
+	}
+}
+
+// Whether the config file was modified since it was last loaded or polled, an invalid file is reloaded once per change
+func (cw *ConfigWatcher) fileChanged() bool {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	info, err := os.Stat(cw.fileName)
+	if err != nil || !info.ModTime().After(cw.modTime) {
+		return false
+	}
+	cw.modTime = info.ModTime()
+	return true
+}
+
+// DiffConfig Describe changed fields as "<yaml path>: <old> -> <new>", secret values are not revealed
+func DiffConfig(oldConfig *ServerConfig, newConfig *ServerConfig) []string {
+	oldValues := make(map[string]configValue)
+	newValues := make(map[string]configValue)
+	flattenConfig(reflect.ValueOf(oldConfig).Elem(), "", oldValues)
+	flattenConfig(reflect.ValueOf(newConfig).Elem(), "", newValues)
+
+	diff := make([]string, 0)
+	for path, oldValue := range oldValues {
+		newValue := newValues[path]
+		if reflect.DeepEqual(oldValue.value, newValue.value) {
+			continue
+		}
+		diff = append(diff, describeConfigChange(path, oldValue.secret, oldValue.value, newValue.value))
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+func describeConfigChange(path string, secret bool, oldValue interface{}, newValue interface{}) string {
+	if secret {
+		return fmt.Sprintf("%s: changed", path)
+	}
+	return fmt.Sprintf("%s: %v -> %v", path, oldValue, newValue)
+}
+
+// Reset fields of the new config which aren't reloadable to their current values, returns their changes
+func keepStartupFields(current *ServerConfig, newConfig *ServerConfig) []string {
+	changes := make([]string, 0)
+	keepStartupValues(reflect.ValueOf(current).Elem(), reflect.ValueOf(newConfig).Elem(), "", false, &changes)
+	sort.Strings(changes)
+	return changes
+}
+
+func keepStartupValues(current reflect.Value, newValue reflect.Value, prefix string, reloadable bool, changes *[]string) {
+	for i := 0; i < current.NumField(); i++ {
+		field := current.Type().Field(i)
+		name := configFieldName(field)
+		if name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fieldReloadable := reloadable
+		if tag, ok := field.Tag.Lookup("reload"); ok {
+			fieldReloadable = tag == "true"
+		}
+		if field.Type.Kind() == reflect.Struct {
+			keepStartupValues(current.Field(i), newValue.Field(i), name, fieldReloadable, changes)
+			continue
+		}
+		if fieldReloadable || reflect.DeepEqual(current.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+		*changes = append(*changes, describeConfigChange(name, field.Tag.Get("secret") == "true", current.Field(i).Interface(), newValue.Field(i).Interface()))
+		newValue.Field(i).Set(current.Field(i))
+	}
+}
+
+type configValue struct {
+	value  interface{}
+	secret bool
+}
+
+func flattenConfig(v reflect.Value, prefix string, values map[string]configValue) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := configFieldName(field)
+		if name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if field.Type.Kind() == reflect.Struct {
+			flattenConfig(v.Field(i), name, values)
+			continue
+		}
+		values[name] = configValue{
+			value:  v.Field(i).Interface(),
+			secret: field.Tag.Get("secret") == "true",
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"sync"
+)
+
+// Limiter A semaphore limiting concurrent workers, the limit can be changed while running. Limit <= 0 means unlimited.
+type Limiter struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int
+	inUse int
+}
+
+func NewLimiter(limit int) *Limiter {
+	l := &Limiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.cond.Broadcast()
+}
+
+// Acquire Block until a slot is available
+func (l *Limiter) Acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.limit > 0 && l.inUse >= l.limit {
+		l.cond.Wait()
+	}
+	l.inUse++
+}
+
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inUse--
+	l.cond.Broadcast()
+}
+
+func (l *Limiter) InUse() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse
+}
@@ -0,0 +1,114 @@
+package util
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// Longest request id accepted from callers
+const MAX_REQUEST_ID_LENGTH = 64
+
+type requestIdKey struct{}
+
+// InitLogger Set a JSON structured logger as default logger
+func InitLogger(service string) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil)).With("service", service)
+	slog.SetDefault(logger)
+}
+
+// NewRequestId Generate a random request id
+func NewRequestId() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
+
+// ContextWithRequestId Attach the request id to context
+func ContextWithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// GetRequestId Fetch request id from context, returns empty string if absent
+func GetRequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// GetLogger Default logger with request id and trace id of the context
+func GetLogger(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if requestId := GetRequestId(ctx); requestId != "" {
+		logger = logger.With("request_id", requestId)
+	}
+	if ctx != nil {
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+			logger = logger.With("trace_id", spanCtx.TraceID().String(), "span_id", spanCtx.SpanID().String())
+		}
+	}
+	return logger
+}
+
+// SetRequestIdHeader Propagate request id of the context to outgoing http request
+func SetRequestIdHeader(ctx context.Context, r *http.Request) {
+	if requestId := GetRequestId(ctx); requestId != "" {
+		r.Header.Set(REQUEST_ID_HEADER, requestId)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sr *statusRecorder) WriteHeader(statusCode int) {
+	sr.statusCode = statusCode
+	sr.ResponseWriter.WriteHeader(statusCode)
+}
+
+// RequestIdMiddleware Accept X-Request-ID from caller or assign a new one, and log every request.
+// A missing, too long or unsafe request id is replaced by a new one.
+func RequestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(REQUEST_ID_HEADER)
+		if !isValidRequestId(requestId) {
+			requestId = NewRequestId()
+		}
+		w.Header().Set(REQUEST_ID_HEADER, requestId)
+		ctx := ContextWithRequestId(r.Context(), requestId)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		GetLogger(ctx).Info("Http request handled",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.statusCode,
+			"duration_ms", time.Since(start).Milliseconds())
+	})
+}
+
+// A request id from a caller is logged and passed on to other services, it is limited to letters, digits, '-', '_' and '.'
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for _, r := range requestId {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
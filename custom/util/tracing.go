@@ -0,0 +1,112 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Spans are written to stdout in the human readable stdouttrace format, or to a file in the OTLP JSON file format
+const TRACING_EXPORTER_STDOUT = "stdout"
+const TRACING_EXPORTER_FILE = "file"
+
+// HttpClient Client of the outgoing calls between services, its spans and trace context follow the global tracer provider and propagator
+var HttpClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+
+// InitTracer Setup global tracer provider and W3C trace context propagator, returns a function flushing spans on exit
+func InitTracer(service string, cfg TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+	switch cfg.Exporter {
+	case TRACING_EXPORTER_STDOUT, "":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TRACING_EXPORTER_FILE:
+		if err := os.MkdirAll(cfg.FileDir, 0755); err != nil {
+			return nil, err
+		}
+		fileName := filepath.Join(cfg.FileDir, fmt.Sprintf("%s_traces.jsonl", service))
+		file, err = os.OpenFile(fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = otlptrace.New(context.Background(), &otlpFileClient{writer: file})
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sampleRatio := cfg.SampleRatio
+	if sampleRatio <= 0 {
+		sampleRatio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(c context.Context) error {
+		err := provider.Shutdown(c)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// TracingMiddleware Start a server span for every request, named by its path
+func TracingMiddleware(service string, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, service, otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+		return r.Method + " " + r.URL.Path
+	}))
+}
+
+// otlpFileClient Write every exported batch of spans as one line of OTLP JSON, an ExportTraceServiceRequest
+type otlpFileClient struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (c *otlpFileClient) Start(ctx context.Context) error {
+	return nil
+}
+
+func (c *otlpFileClient) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (c *otlpFileClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	line, err := protojson.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.writer.Write(append(line, '\n'))
+	return err
+}
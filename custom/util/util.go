@@ -1,51 +1,40 @@
 package util
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
-	"github.com/romana/rlog"
+	"fmt"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
-	"gopkg.in/yaml.v3"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"io"
-	"log"
 	"net/http"
 	"order_system/dal"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
-type DbConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	Database string `yaml:"database"`
-}
-
-type ServerConfig struct {
-	Order_port                 int      `yaml:"order_port"`
-	Payment_port               int      `yaml:"payment_port"`
-	Postgres                   DbConfig `yaml:"postgres"`
-	Order_payment_callback_url string   `yaml:"order_payment_callback_url"`
-	Payment_message_queue_url  string   `yaml:"payment_message_queue_url"`
-}
+const DEFAULT_PAGE_SIZE = 20
+const MAX_PAGE_SIZE = 100
 
-func (c *ServerConfig) GetConf(fileName string) *ServerConfig {
-	yamlFile, err := os.ReadFile(fileName)
-	if err != nil {
-		log.Printf("Read yaml file %s failed: %s ", fileName, err.Error())
+// NormalizePage Default the page to 1 and the page size to DEFAULT_PAGE_SIZE, fails when the page size exceeds MAX_PAGE_SIZE
+func NormalizePage(page *int, pageSize *int) error {
+	if *page <= 0 {
+		*page = 1
 	}
-	err = yaml.Unmarshal(yamlFile, c)
-	if err != nil {
-		log.Fatalf("Unmarshal: %v", err)
+	if *pageSize <= 0 {
+		*pageSize = DEFAULT_PAGE_SIZE
 	}
-
-	return c
+	if *pageSize > MAX_PAGE_SIZE {
+		return fmt.Errorf("Page size must not exceed %d", MAX_PAGE_SIZE)
+	}
+	return nil
 }
 
 func IsAllowHttpMethod(methods []string, w http.ResponseWriter, r *http.Request) bool {
@@ -65,13 +54,13 @@ func FetchReqObject(r *http.Request, reqObj interface{}) error {
 	reqBody, err := io.ReadAll(r.Body)
 	if err != nil {
 		errInfo := "Read request body failed" + err.Error()
-		rlog.Error(errInfo)
+		GetLogger(r.Context()).Error(errInfo)
 		return errors.New(errInfo)
 	}
 	err = json.Unmarshal(reqBody, reqObj)
 	if err != nil {
 		errInfo := "Unmarshal request body failed" + err.Error()
-		rlog.Error(errInfo)
+		GetLogger(r.Context()).Error(errInfo)
 		return errors.New(errInfo)
 	}
 	return nil
@@ -81,6 +70,33 @@ func GetStringPtr(s string) *string {
 	return &s
 }
 
+// Backoff Delay before a retry, the base delay doubles with every retry up to maxDelay. A zero maxDelay doesn't cap it.
+func Backoff(retry int, base time.Duration, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < retry && (maxDelay <= 0 || delay < maxDelay); i++ {
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// SleepContext Wait for the delay, fails with the error of the context when it is done before
+func SleepContext(c context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return c.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
 // DbMock For unit test usage
 func DbMock(t *testing.T) (*sql.DB, *gorm.DB, sqlmock.Sqlmock) {
 	sqldb, mock, err := sqlmock.New()
@@ -102,6 +118,15 @@ func DbMock(t *testing.T) (*sql.DB, *gorm.DB, sqlmock.Sqlmock) {
 	return sqldb, gormdb, mock
 }
 
+// WriteTestFile Write a file into a temp dir of the test, for unit test usage
+func WriteTestFile(t *testing.T, name string, content string) string {
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
 // ObjectToRows For unit test usage
 func ObjectToRows(object interface{}) (*sqlmock.Rows, error) {
 	buf, err := json.Marshal(object)
@@ -0,0 +1,110 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 200*time.Millisecond, Backoff(1, 200*time.Millisecond, time.Second))
+	assert.Equal(t, 800*time.Millisecond, Backoff(3, 200*time.Millisecond, time.Second))
+	assert.Equal(t, time.Second, Backoff(4, 200*time.Millisecond, time.Second))
+	assert.Equal(t, time.Second, Backoff(100, 200*time.Millisecond, time.Second))
+	assert.Equal(t, 1600*time.Millisecond, Backoff(4, 200*time.Millisecond, 0))
+	assert.Equal(t, time.Duration(0), Backoff(3, 0, time.Second))
+}
+
+func TestSleepContext(t *testing.T) {
+	assert.Nil(t, SleepContext(context.Background(), time.Millisecond))
+
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, SleepContext(c, time.Hour), context.Canceled)
+	assert.ErrorIs(t, SleepContext(c, 0), context.Canceled)
+}
+
+func TestRequestIdMiddleware(t *testing.T) {
+	var requestId string
+	handler := RequestIdMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = GetRequestId(r.Context())
+	}))
+	for header, accepted := range map[string]bool{
+		"caller-id_1.2": true,
+		"":              false,
+		strings.Repeat("a", MAX_REQUEST_ID_LENGTH+1): false,
+		"id\ninjected":   false,
+		"id with spaces": false,
+		"<script>":       false,
+		strings.Repeat("a", MAX_REQUEST_ID_LENGTH): true,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://localhosts", nil)
+		r.Header.Set(REQUEST_ID_HEADER, header)
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, accepted, requestId == header, header)
+		assert.NotEmpty(t, requestId)
+		assert.Equal(t, requestId, w.Header().Get(REQUEST_ID_HEADER))
+	}
+}
+
+func TestInitTracerOtlpFile(t *testing.T) {
+	fileDir := t.TempDir()
+	tracerProvider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	shutdown, err := InitTracer("test_api", TracingConfig{Enabled: true, Exporter: TRACING_EXPORTER_FILE, FileDir: fileDir})
+	assert.Nil(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "create order")
+	span.End()
+	assert.Nil(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(filepath.Join(fileDir, "test_api_traces.jsonl"))
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 1)
+	request := &coltracepb.ExportTraceServiceRequest{}
+	assert.Nil(t, protojson.Unmarshal([]byte(lines[0]), request))
+	assert.Equal(t, "create order", request.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+	assert.Equal(t, "test_api", request.ResourceSpans[0].Resource.Attributes[0].Value.GetStringValue())
+}
+
+type testYamlFile struct {
+	Limit int `yaml:"limit"`
+}
+
+func (f *testYamlFile) Validate() error {
+	if f.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	return nil
+}
+
+func TestLoadYamlFile(t *testing.T) {
+	out := &testYamlFile{}
+	assert.Nil(t, LoadYamlFile("test file", WriteTestFile(t, "test.yaml", "limit: 3\n"), out))
+	assert.Equal(t, 3, out.Limit)
+
+	err := LoadYamlFile("test file", WriteTestFile(t, "test.yaml", "limt: 3\n"), &testYamlFile{})
+	assert.ErrorContains(t, err, "parse test file")
+	assert.ErrorContains(t, err, "field limt not found")
+
+	err = LoadYamlFile("test file", WriteTestFile(t, "test.yaml", "limit: -1\n"), &testYamlFile{})
+	assert.ErrorContains(t, err, "invalid test file")
+	assert.ErrorContains(t, err, "limit must not be negative")
+
+	assert.ErrorContains(t, LoadYamlFile("test file", filepath.Join(t.TempDir(), "missing.yaml"), &testYamlFile{}), "read test file")
+}
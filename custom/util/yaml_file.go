@@ -0,0 +1,40 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+)
+
+// Validator Values which are checked once they are loaded from a yaml file
+type Validator interface {
+	Validate() error
+}
+
+// ReadYamlFile Read a yaml file of the kind (e.g. "tax rule file") into out. Unknown keys are rejected, a misspelled key would silently keep its default otherwise.
+func ReadYamlFile(kind string, fileName string, out interface{}) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("read %s %s failed: %w", kind, fileName, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse %s %s failed: %w", kind, fileName, err)
+	}
+	return nil
+}
+
+// LoadYamlFile Read a yaml file of the kind into out, then validate it
+func LoadYamlFile(kind string, fileName string, out Validator) error {
+	if err := ReadYamlFile(kind, fileName, out); err != nil {
+		return err
+	}
+	if err := out.Validate(); err != nil {
+		return fmt.Errorf("invalid %s %s: %w", kind, fileName, err)
+	}
+	return nil
+}
@@ -33,6 +33,7 @@ func newCustomer(db *gorm.DB, opts ...gen.DOOption) customer {
 	_customer.Address = field.NewString(tableName, "address")
 	_customer.CreatedAt = field.NewTime(tableName, "created_at")
 	_customer.UpdatedAt = field.NewTime(tableName, "updated_at")
+	_customer.DeletedAt = field.NewField(tableName, "deleted_at")
 
 	_customer.fillFieldMap()
 
@@ -49,6 +50,7 @@ type customer struct {
 	Address   field.String
 	CreatedAt field.Time
 	UpdatedAt field.Time
+	DeletedAt field.Field
 
 	fieldMap map[string]field.Expr
 }
@@ -71,6 +73,7 @@ func (c *customer) updateTableName(table string) *customer {
 	c.Address = field.NewString(table, "address")
 	c.CreatedAt = field.NewTime(table, "created_at")
 	c.UpdatedAt = field.NewTime(table, "updated_at")
+	c.DeletedAt = field.NewField(table, "deleted_at")
 
 	c.fillFieldMap()
 
@@ -87,13 +90,14 @@ func (c *customer) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (c *customer) fillFieldMap() {
-	c.fieldMap = make(map[string]field.Expr, 6)
+	c.fieldMap = make(map[string]field.Expr, 7)
 	c.fieldMap["id"] = c.ID
 	c.fieldMap["name"] = c.Name
 	c.fieldMap["email"] = c.Email
 	c.fieldMap["address"] = c.Address
 	c.fieldMap["created_at"] = c.CreatedAt
 	c.fieldMap["updated_at"] = c.UpdatedAt
+	c.fieldMap["deleted_at"] = c.DeletedAt
 }
 
 func (c customer) clone(db *gorm.DB) customer {
@@ -16,49 +16,134 @@ import (
 )
 
 var (
-	Q        = new(Query)
-	Customer *customer
-	Order    *order
-	Payment  *payment
-	Product  *product
+	Q                = new(Query)
+	Address          *address
+	Cart             *cart
+	CartItem         *cartItem
+	Category         *category
+	Coupon           *coupon
+	Customer         *customer
+	Order            *order
+	OrderDiscount    *orderDiscount
+	OrderLine        *orderLine
+	OrderTax         *orderTax
+	Payment          *payment
+	PaymentAction    *paymentAction
+	PaymentReview    *paymentReview
+	Product          *product
+	ProductPrice     *productPrice
+	ProductTag       *productTag
+	Refund           *refund
+	Shipment         *shipment
+	StockReservation *stockReservation
+	Tag              *tag
+	Variant          *variant
 )
 
 func SetDefault(db *gorm.DB, opts ...gen.DOOption) {
 	*Q = *Use(db, opts...)
+	Address = &Q.Address
+	Cart = &Q.Cart
+	CartItem = &Q.CartItem
+	Category = &Q.Category
+	Coupon = &Q.Coupon
 	Customer = &Q.Customer
 	Order = &Q.Order
+	OrderDiscount = &Q.OrderDiscount
+	OrderLine = &Q.OrderLine
+	OrderTax = &Q.OrderTax
 	Payment = &Q.Payment
+	PaymentAction = &Q.PaymentAction
+	PaymentReview = &Q.PaymentReview
 	Product = &Q.Product
+	ProductPrice = &Q.ProductPrice
+	ProductTag = &Q.ProductTag
+	Refund = &Q.Refund
+	Shipment = &Q.Shipment
+	StockReservation = &Q.StockReservation
+	Tag = &Q.Tag
+	Variant = &Q.Variant
 }
 
 func Use(db *gorm.DB, opts ...gen.DOOption) *Query {
 	return &Query{
-		db:       db,
-		Customer: newCustomer(db, opts...),
-		Order:    newOrder(db, opts...),
-		Payment:  newPayment(db, opts...),
-		Product:  newProduct(db, opts...),
+		db:               db,
+		Address:          newAddress(db, opts...),
+		Cart:             newCart(db, opts...),
+		CartItem:         newCartItem(db, opts...),
+		Category:         newCategory(db, opts...),
+		Coupon:           newCoupon(db, opts...),
+		Customer:         newCustomer(db, opts...),
+		Order:            newOrder(db, opts...),
+		OrderDiscount:    newOrderDiscount(db, opts...),
+		OrderLine:        newOrderLine(db, opts...),
+		OrderTax:         newOrderTax(db, opts...),
+		Payment:          newPayment(db, opts...),
+		PaymentAction:    newPaymentAction(db, opts...),
+		PaymentReview:    newPaymentReview(db, opts...),
+		Product:          newProduct(db, opts...),
+		ProductPrice:     newProductPrice(db, opts...),
+		ProductTag:       newProductTag(db, opts...),
+		Refund:           newRefund(db, opts...),
+		Shipment:         newShipment(db, opts...),
+		StockReservation: newStockReservation(db, opts...),
+		Tag:              newTag(db, opts...),
+		Variant:          newVariant(db, opts...),
 	}
 }
 
 type Query struct {
 	db *gorm.DB
 
-	Customer customer
-	Order    order
-	Payment  payment
-	Product  product
+	Address          address
+	Cart             cart
+	CartItem         cartItem
+	Category         category
+	Coupon           coupon
+	Customer         customer
+	Order            order
+	OrderDiscount    orderDiscount
+	OrderLine        orderLine
+	OrderTax         orderTax
+	Payment          payment
+	PaymentAction    paymentAction
+	PaymentReview    paymentReview
+	Product          product
+	ProductPrice     productPrice
+	ProductTag       productTag
+	Refund           refund
+	Shipment         shipment
+	StockReservation stockReservation
+	Tag              tag
+	Variant          variant
 }
 
 func (q *Query) Available() bool { return q.db != nil }
 
 func (q *Query) clone(db *gorm.DB) *Query {
 	return &Query{
-		db:       db,
-		Customer: q.Customer.clone(db),
-		Order:    q.Order.clone(db),
-		Payment:  q.Payment.clone(db),
-		Product:  q.Product.clone(db),
+		db:               db,
+		Address:          q.Address.clone(db),
+		Cart:             q.Cart.clone(db),
+		CartItem:         q.CartItem.clone(db),
+		Category:         q.Category.clone(db),
+		Coupon:           q.Coupon.clone(db),
+		Customer:         q.Customer.clone(db),
+		Order:            q.Order.clone(db),
+		OrderDiscount:    q.OrderDiscount.clone(db),
+		OrderLine:        q.OrderLine.clone(db),
+		OrderTax:         q.OrderTax.clone(db),
+		Payment:          q.Payment.clone(db),
+		PaymentAction:    q.PaymentAction.clone(db),
+		PaymentReview:    q.PaymentReview.clone(db),
+		Product:          q.Product.clone(db),
+		ProductPrice:     q.ProductPrice.clone(db),
+		ProductTag:       q.ProductTag.clone(db),
+		Refund:           q.Refund.clone(db),
+		Shipment:         q.Shipment.clone(db),
+		StockReservation: q.StockReservation.clone(db),
+		Tag:              q.Tag.clone(db),
+		Variant:          q.Variant.clone(db),
 	}
 }
 
@@ -72,27 +157,78 @@ func (q *Query) WriteDB() *Query {
 
 func (q *Query) ReplaceDB(db *gorm.DB) *Query {
 	return &Query{
-		db:       db,
-		Customer: q.Customer.replaceDB(db),
-		Order:    q.Order.replaceDB(db),
-		Payment:  q.Payment.replaceDB(db),
-		Product:  q.Product.replaceDB(db),
+		db:               db,
+		Address:          q.Address.replaceDB(db),
+		Cart:             q.Cart.replaceDB(db),
+		CartItem:         q.CartItem.replaceDB(db),
+		Category:         q.Category.replaceDB(db),
+		Coupon:           q.Coupon.replaceDB(db),
+		Customer:         q.Customer.replaceDB(db),
+		Order:            q.Order.replaceDB(db),
+		OrderDiscount:    q.OrderDiscount.replaceDB(db),
+		OrderLine:        q.OrderLine.replaceDB(db),
+		OrderTax:         q.OrderTax.replaceDB(db),
+		Payment:          q.Payment.replaceDB(db),
+		PaymentAction:    q.PaymentAction.replaceDB(db),
+		PaymentReview:    q.PaymentReview.replaceDB(db),
+		Product:          q.Product.replaceDB(db),
+		ProductPrice:     q.ProductPrice.replaceDB(db),
+		ProductTag:       q.ProductTag.replaceDB(db),
+		Refund:           q.Refund.replaceDB(db),
+		Shipment:         q.Shipment.replaceDB(db),
+		StockReservation: q.StockReservation.replaceDB(db),
+		Tag:              q.Tag.replaceDB(db),
+		Variant:          q.Variant.replaceDB(db),
 	}
 }
 
 type queryCtx struct {
-	Customer ICustomerDo
-	Order    IOrderDo
-	Payment  IPaymentDo
-	Product  IProductDo
+	Address          IAddressDo
+	Cart             ICartDo
+	CartItem         ICartItemDo
+	Category         ICategoryDo
+	Coupon           ICouponDo
+	Customer         ICustomerDo
+	Order            IOrderDo
+	OrderDiscount    IOrderDiscountDo
+	OrderLine        IOrderLineDo
+	OrderTax         IOrderTaxDo
+	Payment          IPaymentDo
+	PaymentAction    IPaymentActionDo
+	PaymentReview    IPaymentReviewDo
+	Product          IProductDo
+	ProductPrice     IProductPriceDo
+	ProductTag       IProductTagDo
+	Refund           IRefundDo
+	Shipment         IShipmentDo
+	StockReservation IStockReservationDo
+	Tag              ITagDo
+	Variant          IVariantDo
 }
 
 func (q *Query) WithContext(ctx context.Context) *queryCtx {
 	return &queryCtx{
-		Customer: q.Customer.WithContext(ctx),
-		Order:    q.Order.WithContext(ctx),
-		Payment:  q.Payment.WithContext(ctx),
-		Product:  q.Product.WithContext(ctx),
+		Address:          q.Address.WithContext(ctx),
+		Cart:             q.Cart.WithContext(ctx),
+		CartItem:         q.CartItem.WithContext(ctx),
+		Category:         q.Category.WithContext(ctx),
+		Coupon:           q.Coupon.WithContext(ctx),
+		Customer:         q.Customer.WithContext(ctx),
+		Order:            q.Order.WithContext(ctx),
+		OrderDiscount:    q.OrderDiscount.WithContext(ctx),
+		OrderLine:        q.OrderLine.WithContext(ctx),
+		OrderTax:         q.OrderTax.WithContext(ctx),
+		Payment:          q.Payment.WithContext(ctx),
+		PaymentAction:    q.PaymentAction.WithContext(ctx),
+		PaymentReview:    q.PaymentReview.WithContext(ctx),
+		Product:          q.Product.WithContext(ctx),
+		ProductPrice:     q.ProductPrice.WithContext(ctx),
+		ProductTag:       q.ProductTag.WithContext(ctx),
+		Refund:           q.Refund.WithContext(ctx),
+		Shipment:         q.Shipment.WithContext(ctx),
+		StockReservation: q.StockReservation.WithContext(ctx),
+		Tag:              q.Tag.WithContext(ctx),
+		Variant:          q.Variant.WithContext(ctx),
 	}
 }
 
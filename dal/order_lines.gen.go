@@ -0,0 +1,440 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dal
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"order_system/model"
+)
+
+func newOrderLine(db *gorm.DB, opts ...gen.DOOption) orderLine {
+	_orderLine := orderLine{}
+
+	_orderLine.orderLineDo.UseDB(db, opts...)
+	_orderLine.orderLineDo.UseModel(&model.OrderLine{})
+
+	tableName := _orderLine.orderLineDo.TableName()
+	_orderLine.ALL = field.NewAsterisk(tableName)
+	_orderLine.ID = field.NewUint(tableName, "id")
+	_orderLine.OrderId = field.NewUint(tableName, "order_id")
+	_orderLine.ProductId = field.NewUint(tableName, "product_id")
+	_orderLine.VariantId = field.NewUint(tableName, "variant_id")
+	_orderLine.Sku = field.NewString(tableName, "sku")
+	_orderLine.Quantity = field.NewInt(tableName, "quantity")
+	_orderLine.UnitPrice = field.NewField(tableName, "unit_price")
+	_orderLine.Amount = field.NewField(tableName, "amount")
+	_orderLine.DiscountAmount = field.NewField(tableName, "discount_amount")
+	_orderLine.TaxAmount = field.NewField(tableName, "tax_amount")
+	_orderLine.FulfilledQuantity = field.NewInt(tableName, "fulfilled_quantity")
+	_orderLine.CanceledQuantity = field.NewInt(tableName, "canceled_quantity")
+	_orderLine.RefundedAmount = field.NewField(tableName, "refunded_amount")
+	_orderLine.Backordered = field.NewBool(tableName, "backordered")
+	_orderLine.CreatedAt = field.NewTime(tableName, "created_at")
+	_orderLine.UpdatedAt = field.NewTime(tableName, "updated_at")
+
+	_orderLine.fillFieldMap()
+
+	return _orderLine
+}
+
+type orderLine struct {
+	orderLineDo
+
+	ALL               field.Asterisk
+	ID                field.Uint
+	OrderId           field.Uint
+	ProductId         field.Uint
+	VariantId         field.Uint
+	Sku               field.String
+	Quantity          field.Int
+	UnitPrice         field.Field
+	Amount            field.Field
+	DiscountAmount    field.Field
+	TaxAmount         field.Field
+	FulfilledQuantity field.Int
+	CanceledQuantity  field.Int
+	RefundedAmount    field.Field
+	Backordered       field.Bool
+	CreatedAt         field.Time
+	UpdatedAt         field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (o orderLine) Table(newTableName string) *orderLine {
+	o.orderLineDo.UseTable(newTableName)
+	return o.updateTableName(newTableName)
+}
+
+func (o orderLine) As(alias string) *orderLine {
+	o.orderLineDo.DO = *(o.orderLineDo.As(alias).(*gen.DO))
+	return o.updateTableName(alias)
+}
+
+func (o *orderLine) updateTableName(table string) *orderLine {
+	o.ALL = field.NewAsterisk(table)
+	o.ID = field.NewUint(table, "id")
+	o.OrderId = field.NewUint(table, "order_id")
+	o.ProductId = field.NewUint(table, "product_id")
+	o.VariantId = field.NewUint(table, "variant_id")
+	o.Sku = field.NewString(table, "sku")
+	o.Quantity = field.NewInt(table, "quantity")
+	o.UnitPrice = field.NewField(table, "unit_price")
+	o.Amount = field.NewField(table, "amount")
+	o.DiscountAmount = field.NewField(table, "discount_amount")
+	o.TaxAmount = field.NewField(table, "tax_amount")
+	o.FulfilledQuantity = field.NewInt(table, "fulfilled_quantity")
+	o.CanceledQuantity = field.NewInt(table, "canceled_quantity")
+	o.RefundedAmount = field.NewField(table, "refunded_amount")
+	o.Backordered = field.NewBool(table, "backordered")
+	o.CreatedAt = field.NewTime(table, "created_at")
+	o.UpdatedAt = field.NewTime(table, "updated_at")
+
+	o.fillFieldMap()
+
+	return o
+}
+
+func (o *orderLine) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := o.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (o *orderLine) fillFieldMap() {
+	o.fieldMap = make(map[string]field.Expr, 16)
+	o.fieldMap["id"] = o.ID
+	o.fieldMap["order_id"] = o.OrderId
+	o.fieldMap["product_id"] = o.ProductId
+	o.fieldMap["variant_id"] = o.VariantId
+	o.fieldMap["sku"] = o.Sku
+	o.fieldMap["quantity"] = o.Quantity
+	o.fieldMap["unit_price"] = o.UnitPrice
+	o.fieldMap["amount"] = o.Amount
+	o.fieldMap["discount_amount"] = o.DiscountAmount
+	o.fieldMap["tax_amount"] = o.TaxAmount
+	o.fieldMap["fulfilled_quantity"] = o.FulfilledQuantity
+	o.fieldMap["canceled_quantity"] = o.CanceledQuantity
+	o.fieldMap["refunded_amount"] = o.RefundedAmount
+	o.fieldMap["backordered"] = o.Backordered
+	o.fieldMap["created_at"] = o.CreatedAt
+	o.fieldMap["updated_at"] = o.UpdatedAt
+}
+
+func (o orderLine) clone(db *gorm.DB) orderLine {
+	o.orderLineDo.ReplaceConnPool(db.Statement.ConnPool)
+	return o
+}
+
+func (o orderLine) replaceDB(db *gorm.DB) orderLine {
+	o.orderLineDo.ReplaceDB(db)
+	return o
+}
+
+type orderLineDo struct{ gen.DO }
+
+type IOrderLineDo interface {
+	gen.SubQuery
+	Debug() IOrderLineDo
+	WithContext(ctx context.Context) IOrderLineDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IOrderLineDo
+	WriteDB() IOrderLineDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IOrderLineDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IOrderLineDo
+	Not(conds ...gen.Condition) IOrderLineDo
+	Or(conds ...gen.Condition) IOrderLineDo
+	Select(conds ...field.Expr) IOrderLineDo
+	Where(conds ...gen.Condition) IOrderLineDo
+	Order(conds ...field.Expr) IOrderLineDo
+	Distinct(cols ...field.Expr) IOrderLineDo
+	Omit(cols ...field.Expr) IOrderLineDo
+	Join(table schema.Tabler, on ...field.Expr) IOrderLineDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IOrderLineDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IOrderLineDo
+	Group(cols ...field.Expr) IOrderLineDo
+	Having(conds ...gen.Condition) IOrderLineDo
+	Limit(limit int) IOrderLineDo
+	Offset(offset int) IOrderLineDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IOrderLineDo
+	Unscoped() IOrderLineDo
+	Create(values ...*model.OrderLine) error
+	CreateInBatches(values []*model.OrderLine, batchSize int) error
+	Save(values ...*model.OrderLine) error
+	First() (*model.OrderLine, error)
+	Take() (*model.OrderLine, error)
+	Last() (*model.OrderLine, error)
+	Find() ([]*model.OrderLine, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.OrderLine, err error)
+	FindInBatches(result *[]*model.OrderLine, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*model.OrderLine) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IOrderLineDo
+	Assign(attrs ...field.AssignExpr) IOrderLineDo
+	Joins(fields ...field.RelationField) IOrderLineDo
+	Preload(fields ...field.RelationField) IOrderLineDo
+	FirstOrInit() (*model.OrderLine, error)
+	FirstOrCreate() (*model.OrderLine, error)
+	FindByPage(offset int, limit int) (result []*model.OrderLine, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IOrderLineDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (o orderLineDo) Debug() IOrderLineDo {
+	return o.withDO(o.DO.Debug())
+}
+
+func (o orderLineDo) WithContext(ctx context.Context) IOrderLineDo {
+	return o.withDO(o.DO.WithContext(ctx))
+}
+
+func (o orderLineDo) ReadDB() IOrderLineDo {
+	return o.Clauses(dbresolver.Read)
+}
+
+func (o orderLineDo) WriteDB() IOrderLineDo {
+	return o.Clauses(dbresolver.Write)
+}
+
+func (o orderLineDo) Session(config *gorm.Session) IOrderLineDo {
+	return o.withDO(o.DO.Session(config))
+}
+
+func (o orderLineDo) Clauses(conds ...clause.Expression) IOrderLineDo {
+	return o.withDO(o.DO.Clauses(conds...))
+}
+
+func (o orderLineDo) Returning(value interface{}, columns ...string) IOrderLineDo {
+	return o.withDO(o.DO.Returning(value, columns...))
+}
+
+func (o orderLineDo) Not(conds ...gen.Condition) IOrderLineDo {
+	return o.withDO(o.DO.Not(conds...))
+}
+
+func (o orderLineDo) Or(conds ...gen.Condition) IOrderLineDo {
+	return o.withDO(o.DO.Or(conds...))
+}
+
+func (o orderLineDo) Select(conds ...field.Expr) IOrderLineDo {
+	return o.withDO(o.DO.Select(conds...))
+}
+
+func (o orderLineDo) Where(conds ...gen.Condition) IOrderLineDo {
+	return o.withDO(o.DO.Where(conds...))
+}
+
+func (o orderLineDo) Order(conds ...field.Expr) IOrderLineDo {
+	return o.withDO(o.DO.Order(conds...))
+}
+
+func (o orderLineDo) Distinct(cols ...field.Expr) IOrderLineDo {
+	return o.withDO(o.DO.Distinct(cols...))
+}
+
+func (o orderLineDo) Omit(cols ...field.Expr) IOrderLineDo {
+	return o.withDO(o.DO.Omit(cols...))
+}
+
+func (o orderLineDo) Join(table schema.Tabler, on ...field.Expr) IOrderLineDo {
+	return o.withDO(o.DO.Join(table, on...))
+}
+
+func (o orderLineDo) LeftJoin(table schema.Tabler, on ...field.Expr) IOrderLineDo {
+	return o.withDO(o.DO.LeftJoin(table, on...))
+}
+
+func (o orderLineDo) RightJoin(table schema.Tabler, on ...field.Expr) IOrderLineDo {
+	return o.withDO(o.DO.RightJoin(table, on...))
+}
+
+func (o orderLineDo) Group(cols ...field.Expr) IOrderLineDo {
+	return o.withDO(o.DO.Group(cols...))
+}
+
+func (o orderLineDo) Having(conds ...gen.Condition) IOrderLineDo {
+	return o.withDO(o.DO.Having(conds...))
+}
+
+func (o orderLineDo) Limit(limit int) IOrderLineDo {
+	return o.withDO(o.DO.Limit(limit))
+}
+
+func (o orderLineDo) Offset(offset int) IOrderLineDo {
+	return o.withDO(o.DO.Offset(offset))
+}
+
+func (o orderLineDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IOrderLineDo {
+	return o.withDO(o.DO.Scopes(funcs...))
+}
+
+func (o orderLineDo) Unscoped() IOrderLineDo {
+	return o.withDO(o.DO.Unscoped())
+}
+
+func (o orderLineDo) Create(values ...*model.OrderLine) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return o.DO.Create(values)
+}
+
+func (o orderLineDo) CreateInBatches(values []*model.OrderLine, batchSize int) error {
+	return o.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (o orderLineDo) Save(values ...*model.OrderLine) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return o.DO.Save(values)
+}
+
+func (o orderLineDo) First() (*model.OrderLine, error) {
+	if result, err := o.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderLine), nil
+	}
+}
+
+func (o orderLineDo) Take() (*model.OrderLine, error) {
+	if result, err := o.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderLine), nil
+	}
+}
+
+func (o orderLineDo) Last() (*model.OrderLine, error) {
+	if result, err := o.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderLine), nil
+	}
+}
+
+func (o orderLineDo) Find() ([]*model.OrderLine, error) {
+	result, err := o.DO.Find()
+	return result.([]*model.OrderLine), err
+}
+
+func (o orderLineDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.OrderLine, err error) {
+	buf := make([]*model.OrderLine, 0, batchSize)
+	err = o.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (o orderLineDo) FindInBatches(result *[]*model.OrderLine, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return o.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (o orderLineDo) Attrs(attrs ...field.AssignExpr) IOrderLineDo {
+	return o.withDO(o.DO.Attrs(attrs...))
+}
+
+func (o orderLineDo) Assign(attrs ...field.AssignExpr) IOrderLineDo {
+	return o.withDO(o.DO.Assign(attrs...))
+}
+
+func (o orderLineDo) Joins(fields ...field.RelationField) IOrderLineDo {
+	for _, _f := range fields {
+		o = *o.withDO(o.DO.Joins(_f))
+	}
+	return &o
+}
+
+func (o orderLineDo) Preload(fields ...field.RelationField) IOrderLineDo {
+	for _, _f := range fields {
+		o = *o.withDO(o.DO.Preload(_f))
+	}
+	return &o
+}
+
+func (o orderLineDo) FirstOrInit() (*model.OrderLine, error) {
+	if result, err := o.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderLine), nil
+	}
+}
+
+func (o orderLineDo) FirstOrCreate() (*model.OrderLine, error) {
+	if result, err := o.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderLine), nil
+	}
+}
+
+func (o orderLineDo) FindByPage(offset int, limit int) (result []*model.OrderLine, count int64, err error) {
+	result, err = o.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = o.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (o orderLineDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = o.Count()
+	if err != nil {
+		return
+	}
+
+	err = o.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (o orderLineDo) Scan(result interface{}) (err error) {
+	return o.DO.Scan(result)
+}
+
+func (o orderLineDo) Delete(models ...*model.OrderLine) (result gen.ResultInfo, err error) {
+	return o.DO.Delete(models)
+}
+
+func (o *orderLineDo) withDO(do gen.Dao) *orderLineDo {
+	o.DO = *do.(*gen.DO)
+	return o
+}
@@ -0,0 +1,408 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dal
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"order_system/model"
+)
+
+func newOrderTax(db *gorm.DB, opts ...gen.DOOption) orderTax {
+	_orderTax := orderTax{}
+
+	_orderTax.orderTaxDo.UseDB(db, opts...)
+	_orderTax.orderTaxDo.UseModel(&model.OrderTax{})
+
+	tableName := _orderTax.orderTaxDo.TableName()
+	_orderTax.ALL = field.NewAsterisk(tableName)
+	_orderTax.ID = field.NewUint(tableName, "id")
+	_orderTax.OrderId = field.NewUint(tableName, "order_id")
+	_orderTax.Jurisdiction = field.NewString(tableName, "jurisdiction")
+	_orderTax.Rate = field.NewFloat64(tableName, "rate")
+	_orderTax.TaxableAmount = field.NewField(tableName, "taxable_amount")
+	_orderTax.Amount = field.NewField(tableName, "amount")
+	_orderTax.Included = field.NewBool(tableName, "included")
+	_orderTax.CreatedAt = field.NewTime(tableName, "created_at")
+
+	_orderTax.fillFieldMap()
+
+	return _orderTax
+}
+
+type orderTax struct {
+	orderTaxDo
+
+	ALL           field.Asterisk
+	ID            field.Uint
+	OrderId       field.Uint
+	Jurisdiction  field.String
+	Rate          field.Float64
+	TaxableAmount field.Field
+	Amount        field.Field
+	Included      field.Bool
+	CreatedAt     field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (o orderTax) Table(newTableName string) *orderTax {
+	o.orderTaxDo.UseTable(newTableName)
+	return o.updateTableName(newTableName)
+}
+
+func (o orderTax) As(alias string) *orderTax {
+	o.orderTaxDo.DO = *(o.orderTaxDo.As(alias).(*gen.DO))
+	return o.updateTableName(alias)
+}
+
+func (o *orderTax) updateTableName(table string) *orderTax {
+	o.ALL = field.NewAsterisk(table)
+	o.ID = field.NewUint(table, "id")
+	o.OrderId = field.NewUint(table, "order_id")
+	o.Jurisdiction = field.NewString(table, "jurisdiction")
+	o.Rate = field.NewFloat64(table, "rate")
+	o.TaxableAmount = field.NewField(table, "taxable_amount")
+	o.Amount = field.NewField(table, "amount")
+	o.Included = field.NewBool(table, "included")
+	o.CreatedAt = field.NewTime(table, "created_at")
+
+	o.fillFieldMap()
+
+	return o
+}
+
+func (o *orderTax) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := o.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (o *orderTax) fillFieldMap() {
+	o.fieldMap = make(map[string]field.Expr, 8)
+	o.fieldMap["id"] = o.ID
+	o.fieldMap["order_id"] = o.OrderId
+	o.fieldMap["jurisdiction"] = o.Jurisdiction
+	o.fieldMap["rate"] = o.Rate
+	o.fieldMap["taxable_amount"] = o.TaxableAmount
+	o.fieldMap["amount"] = o.Amount
+	o.fieldMap["included"] = o.Included
+	o.fieldMap["created_at"] = o.CreatedAt
+}
+
+func (o orderTax) clone(db *gorm.DB) orderTax {
+	o.orderTaxDo.ReplaceConnPool(db.Statement.ConnPool)
+	return o
+}
+
+func (o orderTax) replaceDB(db *gorm.DB) orderTax {
+	o.orderTaxDo.ReplaceDB(db)
+	return o
+}
+
+type orderTaxDo struct{ gen.DO }
+
+type IOrderTaxDo interface {
+	gen.SubQuery
+	Debug() IOrderTaxDo
+	WithContext(ctx context.Context) IOrderTaxDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IOrderTaxDo
+	WriteDB() IOrderTaxDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IOrderTaxDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IOrderTaxDo
+	Not(conds ...gen.Condition) IOrderTaxDo
+	Or(conds ...gen.Condition) IOrderTaxDo
+	Select(conds ...field.Expr) IOrderTaxDo
+	Where(conds ...gen.Condition) IOrderTaxDo
+	Order(conds ...field.Expr) IOrderTaxDo
+	Distinct(cols ...field.Expr) IOrderTaxDo
+	Omit(cols ...field.Expr) IOrderTaxDo
+	Join(table schema.Tabler, on ...field.Expr) IOrderTaxDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IOrderTaxDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IOrderTaxDo
+	Group(cols ...field.Expr) IOrderTaxDo
+	Having(conds ...gen.Condition) IOrderTaxDo
+	Limit(limit int) IOrderTaxDo
+	Offset(offset int) IOrderTaxDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IOrderTaxDo
+	Unscoped() IOrderTaxDo
+	Create(values ...*model.OrderTax) error
+	CreateInBatches(values []*model.OrderTax, batchSize int) error
+	Save(values ...*model.OrderTax) error
+	First() (*model.OrderTax, error)
+	Take() (*model.OrderTax, error)
+	Last() (*model.OrderTax, error)
+	Find() ([]*model.OrderTax, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.OrderTax, err error)
+	FindInBatches(result *[]*model.OrderTax, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*model.OrderTax) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IOrderTaxDo
+	Assign(attrs ...field.AssignExpr) IOrderTaxDo
+	Joins(fields ...field.RelationField) IOrderTaxDo
+	Preload(fields ...field.RelationField) IOrderTaxDo
+	FirstOrInit() (*model.OrderTax, error)
+	FirstOrCreate() (*model.OrderTax, error)
+	FindByPage(offset int, limit int) (result []*model.OrderTax, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IOrderTaxDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (o orderTaxDo) Debug() IOrderTaxDo {
+	return o.withDO(o.DO.Debug())
+}
+
+func (o orderTaxDo) WithContext(ctx context.Context) IOrderTaxDo {
+	return o.withDO(o.DO.WithContext(ctx))
+}
+
+func (o orderTaxDo) ReadDB() IOrderTaxDo {
+	return o.Clauses(dbresolver.Read)
+}
+
+func (o orderTaxDo) WriteDB() IOrderTaxDo {
+	return o.Clauses(dbresolver.Write)
+}
+
+func (o orderTaxDo) Session(config *gorm.Session) IOrderTaxDo {
+	return o.withDO(o.DO.Session(config))
+}
+
+func (o orderTaxDo) Clauses(conds ...clause.Expression) IOrderTaxDo {
+	return o.withDO(o.DO.Clauses(conds...))
+}
+
+func (o orderTaxDo) Returning(value interface{}, columns ...string) IOrderTaxDo {
+	return o.withDO(o.DO.Returning(value, columns...))
+}
+
+func (o orderTaxDo) Not(conds ...gen.Condition) IOrderTaxDo {
+	return o.withDO(o.DO.Not(conds...))
+}
+
+func (o orderTaxDo) Or(conds ...gen.Condition) IOrderTaxDo {
+	return o.withDO(o.DO.Or(conds...))
+}
+
+func (o orderTaxDo) Select(conds ...field.Expr) IOrderTaxDo {
+	return o.withDO(o.DO.Select(conds...))
+}
+
+func (o orderTaxDo) Where(conds ...gen.Condition) IOrderTaxDo {
+	return o.withDO(o.DO.Where(conds...))
+}
+
+func (o orderTaxDo) Order(conds ...field.Expr) IOrderTaxDo {
+	return o.withDO(o.DO.Order(conds...))
+}
+
+func (o orderTaxDo) Distinct(cols ...field.Expr) IOrderTaxDo {
+	return o.withDO(o.DO.Distinct(cols...))
+}
+
+func (o orderTaxDo) Omit(cols ...field.Expr) IOrderTaxDo {
+	return o.withDO(o.DO.Omit(cols...))
+}
+
+func (o orderTaxDo) Join(table schema.Tabler, on ...field.Expr) IOrderTaxDo {
+	return o.withDO(o.DO.Join(table, on...))
+}
+
+func (o orderTaxDo) LeftJoin(table schema.Tabler, on ...field.Expr) IOrderTaxDo {
+	return o.withDO(o.DO.LeftJoin(table, on...))
+}
+
+func (o orderTaxDo) RightJoin(table schema.Tabler, on ...field.Expr) IOrderTaxDo {
+	return o.withDO(o.DO.RightJoin(table, on...))
+}
+
+func (o orderTaxDo) Group(cols ...field.Expr) IOrderTaxDo {
+	return o.withDO(o.DO.Group(cols...))
+}
+
+func (o orderTaxDo) Having(conds ...gen.Condition) IOrderTaxDo {
+	return o.withDO(o.DO.Having(conds...))
+}
+
+func (o orderTaxDo) Limit(limit int) IOrderTaxDo {
+	return o.withDO(o.DO.Limit(limit))
+}
+
+func (o orderTaxDo) Offset(offset int) IOrderTaxDo {
+	return o.withDO(o.DO.Offset(offset))
+}
+
+func (o orderTaxDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IOrderTaxDo {
+	return o.withDO(o.DO.Scopes(funcs...))
+}
+
+func (o orderTaxDo) Unscoped() IOrderTaxDo {
+	return o.withDO(o.DO.Unscoped())
+}
+
+func (o orderTaxDo) Create(values ...*model.OrderTax) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return o.DO.Create(values)
+}
+
+func (o orderTaxDo) CreateInBatches(values []*model.OrderTax, batchSize int) error {
+	return o.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (o orderTaxDo) Save(values ...*model.OrderTax) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return o.DO.Save(values)
+}
+
+func (o orderTaxDo) First() (*model.OrderTax, error) {
+	if result, err := o.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderTax), nil
+	}
+}
+
+func (o orderTaxDo) Take() (*model.OrderTax, error) {
+	if result, err := o.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderTax), nil
+	}
+}
+
+func (o orderTaxDo) Last() (*model.OrderTax, error) {
+	if result, err := o.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderTax), nil
+	}
+}
+
+func (o orderTaxDo) Find() ([]*model.OrderTax, error) {
+	result, err := o.DO.Find()
+	return result.([]*model.OrderTax), err
+}
+
+func (o orderTaxDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.OrderTax, err error) {
+	buf := make([]*model.OrderTax, 0, batchSize)
+	err = o.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (o orderTaxDo) FindInBatches(result *[]*model.OrderTax, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return o.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (o orderTaxDo) Attrs(attrs ...field.AssignExpr) IOrderTaxDo {
+	return o.withDO(o.DO.Attrs(attrs...))
+}
+
+func (o orderTaxDo) Assign(attrs ...field.AssignExpr) IOrderTaxDo {
+	return o.withDO(o.DO.Assign(attrs...))
+}
+
+func (o orderTaxDo) Joins(fields ...field.RelationField) IOrderTaxDo {
+	for _, _f := range fields {
+		o = *o.withDO(o.DO.Joins(_f))
+	}
+	return &o
+}
+
+func (o orderTaxDo) Preload(fields ...field.RelationField) IOrderTaxDo {
+	for _, _f := range fields {
+		o = *o.withDO(o.DO.Preload(_f))
+	}
+	return &o
+}
+
+func (o orderTaxDo) FirstOrInit() (*model.OrderTax, error) {
+	if result, err := o.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderTax), nil
+	}
+}
+
+func (o orderTaxDo) FirstOrCreate() (*model.OrderTax, error) {
+	if result, err := o.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.OrderTax), nil
+	}
+}
+
+func (o orderTaxDo) FindByPage(offset int, limit int) (result []*model.OrderTax, count int64, err error) {
+	result, err = o.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = o.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (o orderTaxDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = o.Count()
+	if err != nil {
+		return
+	}
+
+	err = o.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (o orderTaxDo) Scan(result interface{}) (err error) {
+	return o.DO.Scan(result)
+}
+
+func (o orderTaxDo) Delete(models ...*model.OrderTax) (result gen.ResultInfo, err error) {
+	return o.DO.Delete(models)
+}
+
+func (o *orderTaxDo) withDO(do gen.Dao) *orderTaxDo {
+	o.DO = *do.(*gen.DO)
+	return o
+}
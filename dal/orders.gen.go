@@ -30,7 +30,19 @@ func newOrder(db *gorm.DB, opts ...gen.DOOption) order {
 	_order.ID = field.NewUint(tableName, "id")
 	_order.CustomerId = field.NewUint(tableName, "customer_id")
 	_order.ProductId = field.NewUint(tableName, "product_id")
-	_order.Amount = field.NewFloat64(tableName, "amount")
+	_order.VariantId = field.NewUint(tableName, "variant_id")
+	_order.Sku = field.NewString(tableName, "sku")
+	_order.ShippingAddress = field.NewField(tableName, "shipping_address")
+	_order.BillingAddress = field.NewField(tableName, "billing_address")
+	_order.Subtotal = field.NewField(tableName, "subtotal")
+	_order.DiscountAmount = field.NewField(tableName, "discount_amount")
+	_order.TaxAmount = field.NewField(tableName, "tax_amount")
+	_order.PricesIncludeTax = field.NewBool(tableName, "prices_include_tax")
+	_order.Amount = field.NewField(tableName, "amount")
+	_order.RefundedAmount = field.NewField(tableName, "refunded_amount")
+	_order.Currency = field.NewString(tableName, "currency")
+	_order.ExchangeRate = field.NewFloat64(tableName, "exchange_rate")
+	_order.Backordered = field.NewBool(tableName, "backordered")
 	_order.State = field.NewInt8(tableName, "state")
 	_order.FailReason = field.NewString(tableName, "fail_reason")
 	_order.CreatedAt = field.NewTime(tableName, "created_at")
@@ -44,15 +56,27 @@ func newOrder(db *gorm.DB, opts ...gen.DOOption) order {
 type order struct {
 	orderDo
 
-	ALL        field.Asterisk
-	ID         field.Uint
-	CustomerId field.Uint
-	ProductId  field.Uint
-	Amount     field.Float64
-	State      field.Int8
-	FailReason field.String
-	CreatedAt  field.Time
-	UpdatedAt  field.Time
+	ALL              field.Asterisk
+	ID               field.Uint
+	CustomerId       field.Uint
+	ProductId        field.Uint
+	VariantId        field.Uint
+	Sku              field.String
+	ShippingAddress  field.Field
+	BillingAddress   field.Field
+	Subtotal         field.Field
+	DiscountAmount   field.Field
+	TaxAmount        field.Field
+	PricesIncludeTax field.Bool
+	Amount           field.Field
+	RefundedAmount   field.Field
+	Currency         field.String
+	ExchangeRate     field.Float64
+	Backordered      field.Bool
+	State            field.Int8
+	FailReason       field.String
+	CreatedAt        field.Time
+	UpdatedAt        field.Time
 
 	fieldMap map[string]field.Expr
 }
@@ -72,7 +96,19 @@ func (o *order) updateTableName(table string) *order {
 	o.ID = field.NewUint(table, "id")
 	o.CustomerId = field.NewUint(table, "customer_id")
 	o.ProductId = field.NewUint(table, "product_id")
-	o.Amount = field.NewFloat64(table, "amount")
+	o.VariantId = field.NewUint(table, "variant_id")
+	o.Sku = field.NewString(table, "sku")
+	o.ShippingAddress = field.NewField(table, "shipping_address")
+	o.BillingAddress = field.NewField(table, "billing_address")
+	o.Subtotal = field.NewField(table, "subtotal")
+	o.DiscountAmount = field.NewField(table, "discount_amount")
+	o.TaxAmount = field.NewField(table, "tax_amount")
+	o.PricesIncludeTax = field.NewBool(table, "prices_include_tax")
+	o.Amount = field.NewField(table, "amount")
+	o.RefundedAmount = field.NewField(table, "refunded_amount")
+	o.Currency = field.NewString(table, "currency")
+	o.ExchangeRate = field.NewFloat64(table, "exchange_rate")
+	o.Backordered = field.NewBool(table, "backordered")
 	o.State = field.NewInt8(table, "state")
 	o.FailReason = field.NewString(table, "fail_reason")
 	o.CreatedAt = field.NewTime(table, "created_at")
@@ -93,11 +129,23 @@ func (o *order) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (o *order) fillFieldMap() {
-	o.fieldMap = make(map[string]field.Expr, 8)
+	o.fieldMap = make(map[string]field.Expr, 20)
 	o.fieldMap["id"] = o.ID
 	o.fieldMap["customer_id"] = o.CustomerId
 	o.fieldMap["product_id"] = o.ProductId
+	o.fieldMap["variant_id"] = o.VariantId
+	o.fieldMap["sku"] = o.Sku
+	o.fieldMap["shipping_address"] = o.ShippingAddress
+	o.fieldMap["billing_address"] = o.BillingAddress
+	o.fieldMap["subtotal"] = o.Subtotal
+	o.fieldMap["discount_amount"] = o.DiscountAmount
+	o.fieldMap["tax_amount"] = o.TaxAmount
+	o.fieldMap["prices_include_tax"] = o.PricesIncludeTax
 	o.fieldMap["amount"] = o.Amount
+	o.fieldMap["refunded_amount"] = o.RefundedAmount
+	o.fieldMap["currency"] = o.Currency
+	o.fieldMap["exchange_rate"] = o.ExchangeRate
+	o.fieldMap["backordered"] = o.Backordered
 	o.fieldMap["state"] = o.State
 	o.fieldMap["fail_reason"] = o.FailReason
 	o.fieldMap["created_at"] = o.CreatedAt
@@ -0,0 +1,404 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dal
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"order_system/model"
+)
+
+func newPaymentAction(db *gorm.DB, opts ...gen.DOOption) paymentAction {
+	_paymentAction := paymentAction{}
+
+	_paymentAction.paymentActionDo.UseDB(db, opts...)
+	_paymentAction.paymentActionDo.UseModel(&model.PaymentAction{})
+
+	tableName := _paymentAction.paymentActionDo.TableName()
+	_paymentAction.ALL = field.NewAsterisk(tableName)
+	_paymentAction.ID = field.NewUint(tableName, "id")
+	_paymentAction.OrderId = field.NewUint(tableName, "order_id")
+	_paymentAction.Action = field.NewString(tableName, "action")
+	_paymentAction.State = field.NewInt8(tableName, "state")
+	_paymentAction.Result = field.NewString(tableName, "result")
+	_paymentAction.CreatedAt = field.NewTime(tableName, "created_at")
+	_paymentAction.UpdatedAt = field.NewTime(tableName, "updated_at")
+
+	_paymentAction.fillFieldMap()
+
+	return _paymentAction
+}
+
+type paymentAction struct {
+	paymentActionDo
+
+	ALL       field.Asterisk
+	ID        field.Uint
+	OrderId   field.Uint
+	Action    field.String
+	State     field.Int8
+	Result    field.String
+	CreatedAt field.Time
+	UpdatedAt field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (p paymentAction) Table(newTableName string) *paymentAction {
+	p.paymentActionDo.UseTable(newTableName)
+	return p.updateTableName(newTableName)
+}
+
+func (p paymentAction) As(alias string) *paymentAction {
+	p.paymentActionDo.DO = *(p.paymentActionDo.As(alias).(*gen.DO))
+	return p.updateTableName(alias)
+}
+
+func (p *paymentAction) updateTableName(table string) *paymentAction {
+	p.ALL = field.NewAsterisk(table)
+	p.ID = field.NewUint(table, "id")
+	p.OrderId = field.NewUint(table, "order_id")
+	p.Action = field.NewString(table, "action")
+	p.State = field.NewInt8(table, "state")
+	p.Result = field.NewString(table, "result")
+	p.CreatedAt = field.NewTime(table, "created_at")
+	p.UpdatedAt = field.NewTime(table, "updated_at")
+
+	p.fillFieldMap()
+
+	return p
+}
+
+func (p *paymentAction) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := p.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (p *paymentAction) fillFieldMap() {
+	p.fieldMap = make(map[string]field.Expr, 7)
+	p.fieldMap["id"] = p.ID
+	p.fieldMap["order_id"] = p.OrderId
+	p.fieldMap["action"] = p.Action
+	p.fieldMap["state"] = p.State
+	p.fieldMap["result"] = p.Result
+	p.fieldMap["created_at"] = p.CreatedAt
+	p.fieldMap["updated_at"] = p.UpdatedAt
+}
+
+func (p paymentAction) clone(db *gorm.DB) paymentAction {
+	p.paymentActionDo.ReplaceConnPool(db.Statement.ConnPool)
+	return p
+}
+
+func (p paymentAction) replaceDB(db *gorm.DB) paymentAction {
+	p.paymentActionDo.ReplaceDB(db)
+	return p
+}
+
+type paymentActionDo struct{ gen.DO }
+
+type IPaymentActionDo interface {
+	gen.SubQuery
+	Debug() IPaymentActionDo
+	WithContext(ctx context.Context) IPaymentActionDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IPaymentActionDo
+	WriteDB() IPaymentActionDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IPaymentActionDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IPaymentActionDo
+	Not(conds ...gen.Condition) IPaymentActionDo
+	Or(conds ...gen.Condition) IPaymentActionDo
+	Select(conds ...field.Expr) IPaymentActionDo
+	Where(conds ...gen.Condition) IPaymentActionDo
+	Order(conds ...field.Expr) IPaymentActionDo
+	Distinct(cols ...field.Expr) IPaymentActionDo
+	Omit(cols ...field.Expr) IPaymentActionDo
+	Join(table schema.Tabler, on ...field.Expr) IPaymentActionDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IPaymentActionDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IPaymentActionDo
+	Group(cols ...field.Expr) IPaymentActionDo
+	Having(conds ...gen.Condition) IPaymentActionDo
+	Limit(limit int) IPaymentActionDo
+	Offset(offset int) IPaymentActionDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IPaymentActionDo
+	Unscoped() IPaymentActionDo
+	Create(values ...*model.PaymentAction) error
+	CreateInBatches(values []*model.PaymentAction, batchSize int) error
+	Save(values ...*model.PaymentAction) error
+	First() (*model.PaymentAction, error)
+	Take() (*model.PaymentAction, error)
+	Last() (*model.PaymentAction, error)
+	Find() ([]*model.PaymentAction, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.PaymentAction, err error)
+	FindInBatches(result *[]*model.PaymentAction, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*model.PaymentAction) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IPaymentActionDo
+	Assign(attrs ...field.AssignExpr) IPaymentActionDo
+	Joins(fields ...field.RelationField) IPaymentActionDo
+	Preload(fields ...field.RelationField) IPaymentActionDo
+	FirstOrInit() (*model.PaymentAction, error)
+	FirstOrCreate() (*model.PaymentAction, error)
+	FindByPage(offset int, limit int) (result []*model.PaymentAction, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IPaymentActionDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (p paymentActionDo) Debug() IPaymentActionDo {
+	return p.withDO(p.DO.Debug())
+}
+
+func (p paymentActionDo) WithContext(ctx context.Context) IPaymentActionDo {
+	return p.withDO(p.DO.WithContext(ctx))
+}
+
+func (p paymentActionDo) ReadDB() IPaymentActionDo {
+	return p.Clauses(dbresolver.Read)
+}
+
+func (p paymentActionDo) WriteDB() IPaymentActionDo {
+	return p.Clauses(dbresolver.Write)
+}
+
+func (p paymentActionDo) Session(config *gorm.Session) IPaymentActionDo {
+	return p.withDO(p.DO.Session(config))
+}
+
+func (p paymentActionDo) Clauses(conds ...clause.Expression) IPaymentActionDo {
+	return p.withDO(p.DO.Clauses(conds...))
+}
+
+func (p paymentActionDo) Returning(value interface{}, columns ...string) IPaymentActionDo {
+	return p.withDO(p.DO.Returning(value, columns...))
+}
+
+func (p paymentActionDo) Not(conds ...gen.Condition) IPaymentActionDo {
+	return p.withDO(p.DO.Not(conds...))
+}
+
+func (p paymentActionDo) Or(conds ...gen.Condition) IPaymentActionDo {
+	return p.withDO(p.DO.Or(conds...))
+}
+
+func (p paymentActionDo) Select(conds ...field.Expr) IPaymentActionDo {
+	return p.withDO(p.DO.Select(conds...))
+}
+
+func (p paymentActionDo) Where(conds ...gen.Condition) IPaymentActionDo {
+	return p.withDO(p.DO.Where(conds...))
+}
+
+func (p paymentActionDo) Order(conds ...field.Expr) IPaymentActionDo {
+	return p.withDO(p.DO.Order(conds...))
+}
+
+func (p paymentActionDo) Distinct(cols ...field.Expr) IPaymentActionDo {
+	return p.withDO(p.DO.Distinct(cols...))
+}
+
+func (p paymentActionDo) Omit(cols ...field.Expr) IPaymentActionDo {
+	return p.withDO(p.DO.Omit(cols...))
+}
+
+func (p paymentActionDo) Join(table schema.Tabler, on ...field.Expr) IPaymentActionDo {
+	return p.withDO(p.DO.Join(table, on...))
+}
+
+func (p paymentActionDo) LeftJoin(table schema.Tabler, on ...field.Expr) IPaymentActionDo {
+	return p.withDO(p.DO.LeftJoin(table, on...))
+}
+
+func (p paymentActionDo) RightJoin(table schema.Tabler, on ...field.Expr) IPaymentActionDo {
+	return p.withDO(p.DO.RightJoin(table, on...))
+}
+
+func (p paymentActionDo) Group(cols ...field.Expr) IPaymentActionDo {
+	return p.withDO(p.DO.Group(cols...))
+}
+
+func (p paymentActionDo) Having(conds ...gen.Condition) IPaymentActionDo {
+	return p.withDO(p.DO.Having(conds...))
+}
+
+func (p paymentActionDo) Limit(limit int) IPaymentActionDo {
+	return p.withDO(p.DO.Limit(limit))
+}
+
+func (p paymentActionDo) Offset(offset int) IPaymentActionDo {
+	return p.withDO(p.DO.Offset(offset))
+}
+
+func (p paymentActionDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IPaymentActionDo {
+	return p.withDO(p.DO.Scopes(funcs...))
+}
+
+func (p paymentActionDo) Unscoped() IPaymentActionDo {
+	return p.withDO(p.DO.Unscoped())
+}
+
+func (p paymentActionDo) Create(values ...*model.PaymentAction) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return p.DO.Create(values)
+}
+
+func (p paymentActionDo) CreateInBatches(values []*model.PaymentAction, batchSize int) error {
+	return p.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (p paymentActionDo) Save(values ...*model.PaymentAction) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return p.DO.Save(values)
+}
+
+func (p paymentActionDo) First() (*model.PaymentAction, error) {
+	if result, err := p.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentAction), nil
+	}
+}
+
+func (p paymentActionDo) Take() (*model.PaymentAction, error) {
+	if result, err := p.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentAction), nil
+	}
+}
+
+func (p paymentActionDo) Last() (*model.PaymentAction, error) {
+	if result, err := p.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentAction), nil
+	}
+}
+
+func (p paymentActionDo) Find() ([]*model.PaymentAction, error) {
+	result, err := p.DO.Find()
+	return result.([]*model.PaymentAction), err
+}
+
+func (p paymentActionDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.PaymentAction, err error) {
+	buf := make([]*model.PaymentAction, 0, batchSize)
+	err = p.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (p paymentActionDo) FindInBatches(result *[]*model.PaymentAction, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return p.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (p paymentActionDo) Attrs(attrs ...field.AssignExpr) IPaymentActionDo {
+	return p.withDO(p.DO.Attrs(attrs...))
+}
+
+func (p paymentActionDo) Assign(attrs ...field.AssignExpr) IPaymentActionDo {
+	return p.withDO(p.DO.Assign(attrs...))
+}
+
+func (p paymentActionDo) Joins(fields ...field.RelationField) IPaymentActionDo {
+	for _, _f := range fields {
+		p = *p.withDO(p.DO.Joins(_f))
+	}
+	return &p
+}
+
+func (p paymentActionDo) Preload(fields ...field.RelationField) IPaymentActionDo {
+	for _, _f := range fields {
+		p = *p.withDO(p.DO.Preload(_f))
+	}
+	return &p
+}
+
+func (p paymentActionDo) FirstOrInit() (*model.PaymentAction, error) {
+	if result, err := p.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentAction), nil
+	}
+}
+
+func (p paymentActionDo) FirstOrCreate() (*model.PaymentAction, error) {
+	if result, err := p.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentAction), nil
+	}
+}
+
+func (p paymentActionDo) FindByPage(offset int, limit int) (result []*model.PaymentAction, count int64, err error) {
+	result, err = p.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = p.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (p paymentActionDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = p.Count()
+	if err != nil {
+		return
+	}
+
+	err = p.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (p paymentActionDo) Scan(result interface{}) (err error) {
+	return p.DO.Scan(result)
+}
+
+func (p paymentActionDo) Delete(models ...*model.PaymentAction) (result gen.ResultInfo, err error) {
+	return p.DO.Delete(models)
+}
+
+func (p *paymentActionDo) withDO(do gen.Dao) *paymentActionDo {
+	p.DO = *do.(*gen.DO)
+	return p
+}
@@ -0,0 +1,408 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dal
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"order_system/model"
+)
+
+func newPaymentReview(db *gorm.DB, opts ...gen.DOOption) paymentReview {
+	_paymentReview := paymentReview{}
+
+	_paymentReview.paymentReviewDo.UseDB(db, opts...)
+	_paymentReview.paymentReviewDo.UseModel(&model.PaymentReview{})
+
+	tableName := _paymentReview.paymentReviewDo.TableName()
+	_paymentReview.ALL = field.NewAsterisk(tableName)
+	_paymentReview.ID = field.NewUint(tableName, "id")
+	_paymentReview.PaymentId = field.NewUint(tableName, "payment_id")
+	_paymentReview.OrderId = field.NewUint(tableName, "order_id")
+	_paymentReview.Decision = field.NewString(tableName, "decision")
+	_paymentReview.Reviewer = field.NewString(tableName, "reviewer")
+	_paymentReview.Note = field.NewString(tableName, "note")
+	_paymentReview.Reasons = field.NewString(tableName, "reasons")
+	_paymentReview.CreatedAt = field.NewTime(tableName, "created_at")
+
+	_paymentReview.fillFieldMap()
+
+	return _paymentReview
+}
+
+type paymentReview struct {
+	paymentReviewDo
+
+	ALL       field.Asterisk
+	ID        field.Uint
+	PaymentId field.Uint
+	OrderId   field.Uint
+	Decision  field.String
+	Reviewer  field.String
+	Note      field.String
+	Reasons   field.String
+	CreatedAt field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (p paymentReview) Table(newTableName string) *paymentReview {
+	p.paymentReviewDo.UseTable(newTableName)
+	return p.updateTableName(newTableName)
+}
+
+func (p paymentReview) As(alias string) *paymentReview {
+	p.paymentReviewDo.DO = *(p.paymentReviewDo.As(alias).(*gen.DO))
+	return p.updateTableName(alias)
+}
+
+func (p *paymentReview) updateTableName(table string) *paymentReview {
+	p.ALL = field.NewAsterisk(table)
+	p.ID = field.NewUint(table, "id")
+	p.PaymentId = field.NewUint(table, "payment_id")
+	p.OrderId = field.NewUint(table, "order_id")
+	p.Decision = field.NewString(table, "decision")
+	p.Reviewer = field.NewString(table, "reviewer")
+	p.Note = field.NewString(table, "note")
+	p.Reasons = field.NewString(table, "reasons")
+	p.CreatedAt = field.NewTime(table, "created_at")
+
+	p.fillFieldMap()
+
+	return p
+}
+
+func (p *paymentReview) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := p.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (p *paymentReview) fillFieldMap() {
+	p.fieldMap = make(map[string]field.Expr, 8)
+	p.fieldMap["id"] = p.ID
+	p.fieldMap["payment_id"] = p.PaymentId
+	p.fieldMap["order_id"] = p.OrderId
+	p.fieldMap["decision"] = p.Decision
+	p.fieldMap["reviewer"] = p.Reviewer
+	p.fieldMap["note"] = p.Note
+	p.fieldMap["reasons"] = p.Reasons
+	p.fieldMap["created_at"] = p.CreatedAt
+}
+
+func (p paymentReview) clone(db *gorm.DB) paymentReview {
+	p.paymentReviewDo.ReplaceConnPool(db.Statement.ConnPool)
+	return p
+}
+
+func (p paymentReview) replaceDB(db *gorm.DB) paymentReview {
+	p.paymentReviewDo.ReplaceDB(db)
+	return p
+}
+
+type paymentReviewDo struct{ gen.DO }
+
+type IPaymentReviewDo interface {
+	gen.SubQuery
+	Debug() IPaymentReviewDo
+	WithContext(ctx context.Context) IPaymentReviewDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IPaymentReviewDo
+	WriteDB() IPaymentReviewDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IPaymentReviewDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IPaymentReviewDo
+	Not(conds ...gen.Condition) IPaymentReviewDo
+	Or(conds ...gen.Condition) IPaymentReviewDo
+	Select(conds ...field.Expr) IPaymentReviewDo
+	Where(conds ...gen.Condition) IPaymentReviewDo
+	Order(conds ...field.Expr) IPaymentReviewDo
+	Distinct(cols ...field.Expr) IPaymentReviewDo
+	Omit(cols ...field.Expr) IPaymentReviewDo
+	Join(table schema.Tabler, on ...field.Expr) IPaymentReviewDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IPaymentReviewDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IPaymentReviewDo
+	Group(cols ...field.Expr) IPaymentReviewDo
+	Having(conds ...gen.Condition) IPaymentReviewDo
+	Limit(limit int) IPaymentReviewDo
+	Offset(offset int) IPaymentReviewDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IPaymentReviewDo
+	Unscoped() IPaymentReviewDo
+	Create(values ...*model.PaymentReview) error
+	CreateInBatches(values []*model.PaymentReview, batchSize int) error
+	Save(values ...*model.PaymentReview) error
+	First() (*model.PaymentReview, error)
+	Take() (*model.PaymentReview, error)
+	Last() (*model.PaymentReview, error)
+	Find() ([]*model.PaymentReview, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.PaymentReview, err error)
+	FindInBatches(result *[]*model.PaymentReview, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*model.PaymentReview) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IPaymentReviewDo
+	Assign(attrs ...field.AssignExpr) IPaymentReviewDo
+	Joins(fields ...field.RelationField) IPaymentReviewDo
+	Preload(fields ...field.RelationField) IPaymentReviewDo
+	FirstOrInit() (*model.PaymentReview, error)
+	FirstOrCreate() (*model.PaymentReview, error)
+	FindByPage(offset int, limit int) (result []*model.PaymentReview, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IPaymentReviewDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (p paymentReviewDo) Debug() IPaymentReviewDo {
+	return p.withDO(p.DO.Debug())
+}
+
+func (p paymentReviewDo) WithContext(ctx context.Context) IPaymentReviewDo {
+	return p.withDO(p.DO.WithContext(ctx))
+}
+
+func (p paymentReviewDo) ReadDB() IPaymentReviewDo {
+	return p.Clauses(dbresolver.Read)
+}
+
+func (p paymentReviewDo) WriteDB() IPaymentReviewDo {
+	return p.Clauses(dbresolver.Write)
+}
+
+func (p paymentReviewDo) Session(config *gorm.Session) IPaymentReviewDo {
+	return p.withDO(p.DO.Session(config))
+}
+
+func (p paymentReviewDo) Clauses(conds ...clause.Expression) IPaymentReviewDo {
+	return p.withDO(p.DO.Clauses(conds...))
+}
+
+func (p paymentReviewDo) Returning(value interface{}, columns ...string) IPaymentReviewDo {
+	return p.withDO(p.DO.Returning(value, columns...))
+}
+
+func (p paymentReviewDo) Not(conds ...gen.Condition) IPaymentReviewDo {
+	return p.withDO(p.DO.Not(conds...))
+}
+
+func (p paymentReviewDo) Or(conds ...gen.Condition) IPaymentReviewDo {
+	return p.withDO(p.DO.Or(conds...))
+}
+
+func (p paymentReviewDo) Select(conds ...field.Expr) IPaymentReviewDo {
+	return p.withDO(p.DO.Select(conds...))
+}
+
+func (p paymentReviewDo) Where(conds ...gen.Condition) IPaymentReviewDo {
+	return p.withDO(p.DO.Where(conds...))
+}
+
+func (p paymentReviewDo) Order(conds ...field.Expr) IPaymentReviewDo {
+	return p.withDO(p.DO.Order(conds...))
+}
+
+func (p paymentReviewDo) Distinct(cols ...field.Expr) IPaymentReviewDo {
+	return p.withDO(p.DO.Distinct(cols...))
+}
+
+func (p paymentReviewDo) Omit(cols ...field.Expr) IPaymentReviewDo {
+	return p.withDO(p.DO.Omit(cols...))
+}
+
+func (p paymentReviewDo) Join(table schema.Tabler, on ...field.Expr) IPaymentReviewDo {
+	return p.withDO(p.DO.Join(table, on...))
+}
+
+func (p paymentReviewDo) LeftJoin(table schema.Tabler, on ...field.Expr) IPaymentReviewDo {
+	return p.withDO(p.DO.LeftJoin(table, on...))
+}
+
+func (p paymentReviewDo) RightJoin(table schema.Tabler, on ...field.Expr) IPaymentReviewDo {
+	return p.withDO(p.DO.RightJoin(table, on...))
+}
+
+func (p paymentReviewDo) Group(cols ...field.Expr) IPaymentReviewDo {
+	return p.withDO(p.DO.Group(cols...))
+}
+
+func (p paymentReviewDo) Having(conds ...gen.Condition) IPaymentReviewDo {
+	return p.withDO(p.DO.Having(conds...))
+}
+
+func (p paymentReviewDo) Limit(limit int) IPaymentReviewDo {
+	return p.withDO(p.DO.Limit(limit))
+}
+
+func (p paymentReviewDo) Offset(offset int) IPaymentReviewDo {
+	return p.withDO(p.DO.Offset(offset))
+}
+
+func (p paymentReviewDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IPaymentReviewDo {
+	return p.withDO(p.DO.Scopes(funcs...))
+}
+
+func (p paymentReviewDo) Unscoped() IPaymentReviewDo {
+	return p.withDO(p.DO.Unscoped())
+}
+
+func (p paymentReviewDo) Create(values ...*model.PaymentReview) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return p.DO.Create(values)
+}
+
+func (p paymentReviewDo) CreateInBatches(values []*model.PaymentReview, batchSize int) error {
+	return p.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (p paymentReviewDo) Save(values ...*model.PaymentReview) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return p.DO.Save(values)
+}
+
+func (p paymentReviewDo) First() (*model.PaymentReview, error) {
+	if result, err := p.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentReview), nil
+	}
+}
+
+func (p paymentReviewDo) Take() (*model.PaymentReview, error) {
+	if result, err := p.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentReview), nil
+	}
+}
+
+func (p paymentReviewDo) Last() (*model.PaymentReview, error) {
+	if result, err := p.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentReview), nil
+	}
+}
+
+func (p paymentReviewDo) Find() ([]*model.PaymentReview, error) {
+	result, err := p.DO.Find()
+	return result.([]*model.PaymentReview), err
+}
+
+func (p paymentReviewDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.PaymentReview, err error) {
+	buf := make([]*model.PaymentReview, 0, batchSize)
+	err = p.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (p paymentReviewDo) FindInBatches(result *[]*model.PaymentReview, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return p.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (p paymentReviewDo) Attrs(attrs ...field.AssignExpr) IPaymentReviewDo {
+	return p.withDO(p.DO.Attrs(attrs...))
+}
+
+func (p paymentReviewDo) Assign(attrs ...field.AssignExpr) IPaymentReviewDo {
+	return p.withDO(p.DO.Assign(attrs...))
+}
+
+func (p paymentReviewDo) Joins(fields ...field.RelationField) IPaymentReviewDo {
+	for _, _f := range fields {
+		p = *p.withDO(p.DO.Joins(_f))
+	}
+	return &p
+}
+
+func (p paymentReviewDo) Preload(fields ...field.RelationField) IPaymentReviewDo {
+	for _, _f := range fields {
+		p = *p.withDO(p.DO.Preload(_f))
+	}
+	return &p
+}
+
+func (p paymentReviewDo) FirstOrInit() (*model.PaymentReview, error) {
+	if result, err := p.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentReview), nil
+	}
+}
+
+func (p paymentReviewDo) FirstOrCreate() (*model.PaymentReview, error) {
+	if result, err := p.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.PaymentReview), nil
+	}
+}
+
+func (p paymentReviewDo) FindByPage(offset int, limit int) (result []*model.PaymentReview, count int64, err error) {
+	result, err = p.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = p.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (p paymentReviewDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = p.Count()
+	if err != nil {
+		return
+	}
+
+	err = p.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (p paymentReviewDo) Scan(result interface{}) (err error) {
+	return p.DO.Scan(result)
+}
+
+func (p paymentReviewDo) Delete(models ...*model.PaymentReview) (result gen.ResultInfo, err error) {
+	return p.DO.Delete(models)
+}
+
+func (p *paymentReviewDo) withDO(do gen.Dao) *paymentReviewDo {
+	p.DO = *do.(*gen.DO)
+	return p
+}
@@ -29,9 +29,15 @@ func newPayment(db *gorm.DB, opts ...gen.DOOption) payment {
 	_payment.ALL = field.NewAsterisk(tableName)
 	_payment.ID = field.NewUint(tableName, "id")
 	_payment.OrderId = field.NewUint(tableName, "order_id")
-	_payment.Amount = field.NewFloat64(tableName, "amount")
+	_payment.CustomerId = field.NewUint(tableName, "customer_id")
+	_payment.Amount = field.NewField(tableName, "amount")
+	_payment.Currency = field.NewString(tableName, "currency")
+	_payment.BaseAmount = field.NewField(tableName, "base_amount")
 	_payment.State = field.NewInt8(tableName, "state")
 	_payment.PaymentResult = field.NewString(tableName, "payment_result")
+	_payment.AuthorizeOnly = field.NewBool(tableName, "authorize_only")
+	_payment.Attempt = field.NewInt(tableName, "attempt")
+	_payment.RefundId = field.NewUint(tableName, "refund_id")
 	_payment.IsNotifiedOrder = field.NewBool(tableName, "is_notified_order")
 	_payment.CreatedAt = field.NewTime(tableName, "created_at")
 	_payment.UpdatedAt = field.NewTime(tableName, "updated_at")
@@ -47,9 +53,15 @@ type payment struct {
 	ALL             field.Asterisk
 	ID              field.Uint
 	OrderId         field.Uint
-	Amount          field.Float64
+	CustomerId      field.Uint
+	Amount          field.Field
+	Currency        field.String
+	BaseAmount      field.Field
 	State           field.Int8
 	PaymentResult   field.String
+	AuthorizeOnly   field.Bool
+	Attempt         field.Int
+	RefundId        field.Uint
 	IsNotifiedOrder field.Bool
 	CreatedAt       field.Time
 	UpdatedAt       field.Time
@@ -71,9 +83,15 @@ func (p *payment) updateTableName(table string) *payment {
 	p.ALL = field.NewAsterisk(table)
 	p.ID = field.NewUint(table, "id")
 	p.OrderId = field.NewUint(table, "order_id")
-	p.Amount = field.NewFloat64(table, "amount")
+	p.CustomerId = field.NewUint(table, "customer_id")
+	p.Amount = field.NewField(table, "amount")
+	p.Currency = field.NewString(table, "currency")
+	p.BaseAmount = field.NewField(table, "base_amount")
 	p.State = field.NewInt8(table, "state")
 	p.PaymentResult = field.NewString(table, "payment_result")
+	p.AuthorizeOnly = field.NewBool(table, "authorize_only")
+	p.Attempt = field.NewInt(table, "attempt")
+	p.RefundId = field.NewUint(table, "refund_id")
 	p.IsNotifiedOrder = field.NewBool(table, "is_notified_order")
 	p.CreatedAt = field.NewTime(table, "created_at")
 	p.UpdatedAt = field.NewTime(table, "updated_at")
@@ -93,12 +111,18 @@ func (p *payment) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (p *payment) fillFieldMap() {
-	p.fieldMap = make(map[string]field.Expr, 8)
+	p.fieldMap = make(map[string]field.Expr, 14)
 	p.fieldMap["id"] = p.ID
 	p.fieldMap["order_id"] = p.OrderId
+	p.fieldMap["customer_id"] = p.CustomerId
 	p.fieldMap["amount"] = p.Amount
+	p.fieldMap["currency"] = p.Currency
+	p.fieldMap["base_amount"] = p.BaseAmount
 	p.fieldMap["state"] = p.State
 	p.fieldMap["payment_result"] = p.PaymentResult
+	p.fieldMap["authorize_only"] = p.AuthorizeOnly
+	p.fieldMap["attempt"] = p.Attempt
+	p.fieldMap["refund_id"] = p.RefundId
 	p.fieldMap["is_notified_order"] = p.IsNotifiedOrder
 	p.fieldMap["created_at"] = p.CreatedAt
 	p.fieldMap["updated_at"] = p.UpdatedAt
@@ -0,0 +1,400 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dal
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"order_system/model"
+)
+
+func newProductPrice(db *gorm.DB, opts ...gen.DOOption) productPrice {
+	_productPrice := productPrice{}
+
+	_productPrice.productPriceDo.UseDB(db, opts...)
+	_productPrice.productPriceDo.UseModel(&model.ProductPrice{})
+
+	tableName := _productPrice.productPriceDo.TableName()
+	_productPrice.ALL = field.NewAsterisk(tableName)
+	_productPrice.ID = field.NewUint(tableName, "id")
+	_productPrice.ProductId = field.NewUint(tableName, "product_id")
+	_productPrice.Currency = field.NewString(tableName, "currency")
+	_productPrice.Price = field.NewField(tableName, "price")
+	_productPrice.CreatedAt = field.NewTime(tableName, "created_at")
+	_productPrice.UpdatedAt = field.NewTime(tableName, "updated_at")
+
+	_productPrice.fillFieldMap()
+
+	return _productPrice
+}
+
+type productPrice struct {
+	productPriceDo
+
+	ALL       field.Asterisk
+	ID        field.Uint
+	ProductId field.Uint
+	Currency  field.String
+	Price     field.Field
+	CreatedAt field.Time
+	UpdatedAt field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (p productPrice) Table(newTableName string) *productPrice {
+	p.productPriceDo.UseTable(newTableName)
+	return p.updateTableName(newTableName)
+}
+
+func (p productPrice) As(alias string) *productPrice {
+	p.productPriceDo.DO = *(p.productPriceDo.As(alias).(*gen.DO))
+	return p.updateTableName(alias)
+}
+
+func (p *productPrice) updateTableName(table string) *productPrice {
+	p.ALL = field.NewAsterisk(table)
+	p.ID = field.NewUint(table, "id")
+	p.ProductId = field.NewUint(table, "product_id")
+	p.Currency = field.NewString(table, "currency")
+	p.Price = field.NewField(table, "price")
+	p.CreatedAt = field.NewTime(table, "created_at")
+	p.UpdatedAt = field.NewTime(table, "updated_at")
+
+	p.fillFieldMap()
+
+	return p
+}
+
+func (p *productPrice) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := p.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (p *productPrice) fillFieldMap() {
+	p.fieldMap = make(map[string]field.Expr, 6)
+	p.fieldMap["id"] = p.ID
+	p.fieldMap["product_id"] = p.ProductId
+	p.fieldMap["currency"] = p.Currency
+	p.fieldMap["price"] = p.Price
+	p.fieldMap["created_at"] = p.CreatedAt
+	p.fieldMap["updated_at"] = p.UpdatedAt
+}
+
+func (p productPrice) clone(db *gorm.DB) productPrice {
+	p.productPriceDo.ReplaceConnPool(db.Statement.ConnPool)
+	return p
+}
+
+func (p productPrice) replaceDB(db *gorm.DB) productPrice {
+	p.productPriceDo.ReplaceDB(db)
+	return p
+}
+
+type productPriceDo struct{ gen.DO }
+
+type IProductPriceDo interface {
+	gen.SubQuery
+	Debug() IProductPriceDo
+	WithContext(ctx context.Context) IProductPriceDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IProductPriceDo
+	WriteDB() IProductPriceDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IProductPriceDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IProductPriceDo
+	Not(conds ...gen.Condition) IProductPriceDo
+	Or(conds ...gen.Condition) IProductPriceDo
+	Select(conds ...field.Expr) IProductPriceDo
+	Where(conds ...gen.Condition) IProductPriceDo
+	Order(conds ...field.Expr) IProductPriceDo
+	Distinct(cols ...field.Expr) IProductPriceDo
+	Omit(cols ...field.Expr) IProductPriceDo
+	Join(table schema.Tabler, on ...field.Expr) IProductPriceDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IProductPriceDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IProductPriceDo
+	Group(cols ...field.Expr) IProductPriceDo
+	Having(conds ...gen.Condition) IProductPriceDo
+	Limit(limit int) IProductPriceDo
+	Offset(offset int) IProductPriceDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IProductPriceDo
+	Unscoped() IProductPriceDo
+	Create(values ...*model.ProductPrice) error
+	CreateInBatches(values []*model.ProductPrice, batchSize int) error
+	Save(values ...*model.ProductPrice) error
+	First() (*model.ProductPrice, error)
+	Take() (*model.ProductPrice, error)
+	Last() (*model.ProductPrice, error)
+	Find() ([]*model.ProductPrice, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.ProductPrice, err error)
+	FindInBatches(result *[]*model.ProductPrice, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*model.ProductPrice) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IProductPriceDo
+	Assign(attrs ...field.AssignExpr) IProductPriceDo
+	Joins(fields ...field.RelationField) IProductPriceDo
+	Preload(fields ...field.RelationField) IProductPriceDo
+	FirstOrInit() (*model.ProductPrice, error)
+	FirstOrCreate() (*model.ProductPrice, error)
+	FindByPage(offset int, limit int) (result []*model.ProductPrice, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IProductPriceDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (p productPriceDo) Debug() IProductPriceDo {
+	return p.withDO(p.DO.Debug())
+}
+
+func (p productPriceDo) WithContext(ctx context.Context) IProductPriceDo {
+	return p.withDO(p.DO.WithContext(ctx))
+}
+
+func (p productPriceDo) ReadDB() IProductPriceDo {
+	return p.Clauses(dbresolver.Read)
+}
+
+func (p productPriceDo) WriteDB() IProductPriceDo {
+	return p.Clauses(dbresolver.Write)
+}
+
+func (p productPriceDo) Session(config *gorm.Session) IProductPriceDo {
+	return p.withDO(p.DO.Session(config))
+}
+
+func (p productPriceDo) Clauses(conds ...clause.Expression) IProductPriceDo {
+	return p.withDO(p.DO.Clauses(conds...))
+}
+
+func (p productPriceDo) Returning(value interface{}, columns ...string) IProductPriceDo {
+	return p.withDO(p.DO.Returning(value, columns...))
+}
+
+func (p productPriceDo) Not(conds ...gen.Condition) IProductPriceDo {
+	return p.withDO(p.DO.Not(conds...))
+}
+
+func (p productPriceDo) Or(conds ...gen.Condition) IProductPriceDo {
+	return p.withDO(p.DO.Or(conds...))
+}
+
+func (p productPriceDo) Select(conds ...field.Expr) IProductPriceDo {
+	return p.withDO(p.DO.Select(conds...))
+}
+
+func (p productPriceDo) Where(conds ...gen.Condition) IProductPriceDo {
+	return p.withDO(p.DO.Where(conds...))
+}
+
+func (p productPriceDo) Order(conds ...field.Expr) IProductPriceDo {
+	return p.withDO(p.DO.Order(conds...))
+}
+
+func (p productPriceDo) Distinct(cols ...field.Expr) IProductPriceDo {
+	return p.withDO(p.DO.Distinct(cols...))
+}
+
+func (p productPriceDo) Omit(cols ...field.Expr) IProductPriceDo {
+	return p.withDO(p.DO.Omit(cols...))
+}
+
+func (p productPriceDo) Join(table schema.Tabler, on ...field.Expr) IProductPriceDo {
+	return p.withDO(p.DO.Join(table, on...))
+}
+
+func (p productPriceDo) LeftJoin(table schema.Tabler, on ...field.Expr) IProductPriceDo {
+	return p.withDO(p.DO.LeftJoin(table, on...))
+}
+
+func (p productPriceDo) RightJoin(table schema.Tabler, on ...field.Expr) IProductPriceDo {
+	return p.withDO(p.DO.RightJoin(table, on...))
+}
+
+func (p productPriceDo) Group(cols ...field.Expr) IProductPriceDo {
+	return p.withDO(p.DO.Group(cols...))
+}
+
+func (p productPriceDo) Having(conds ...gen.Condition) IProductPriceDo {
+	return p.withDO(p.DO.Having(conds...))
+}
+
+func (p productPriceDo) Limit(limit int) IProductPriceDo {
+	return p.withDO(p.DO.Limit(limit))
+}
+
+func (p productPriceDo) Offset(offset int) IProductPriceDo {
+	return p.withDO(p.DO.Offset(offset))
+}
+
+func (p productPriceDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IProductPriceDo {
+	return p.withDO(p.DO.Scopes(funcs...))
+}
+
+func (p productPriceDo) Unscoped() IProductPriceDo {
+	return p.withDO(p.DO.Unscoped())
+}
+
+func (p productPriceDo) Create(values ...*model.ProductPrice) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return p.DO.Create(values)
+}
+
+func (p productPriceDo) CreateInBatches(values []*model.ProductPrice, batchSize int) error {
+	return p.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (p productPriceDo) Save(values ...*model.ProductPrice) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return p.DO.Save(values)
+}
+
+func (p productPriceDo) First() (*model.ProductPrice, error) {
+	if result, err := p.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ProductPrice), nil
+	}
+}
+
+func (p productPriceDo) Take() (*model.ProductPrice, error) {
+	if result, err := p.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ProductPrice), nil
+	}
+}
+
+func (p productPriceDo) Last() (*model.ProductPrice, error) {
+	if result, err := p.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ProductPrice), nil
+	}
+}
+
+func (p productPriceDo) Find() ([]*model.ProductPrice, error) {
+	result, err := p.DO.Find()
+	return result.([]*model.ProductPrice), err
+}
+
+func (p productPriceDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.ProductPrice, err error) {
+	buf := make([]*model.ProductPrice, 0, batchSize)
+	err = p.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (p productPriceDo) FindInBatches(result *[]*model.ProductPrice, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return p.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (p productPriceDo) Attrs(attrs ...field.AssignExpr) IProductPriceDo {
+	return p.withDO(p.DO.Attrs(attrs...))
+}
+
+func (p productPriceDo) Assign(attrs ...field.AssignExpr) IProductPriceDo {
+	return p.withDO(p.DO.Assign(attrs...))
+}
+
+func (p productPriceDo) Joins(fields ...field.RelationField) IProductPriceDo {
+	for _, _f := range fields {
+		p = *p.withDO(p.DO.Joins(_f))
+	}
+	return &p
+}
+
+func (p productPriceDo) Preload(fields ...field.RelationField) IProductPriceDo {
+	for _, _f := range fields {
+		p = *p.withDO(p.DO.Preload(_f))
+	}
+	return &p
+}
+
+func (p productPriceDo) FirstOrInit() (*model.ProductPrice, error) {
+	if result, err := p.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ProductPrice), nil
+	}
+}
+
+func (p productPriceDo) FirstOrCreate() (*model.ProductPrice, error) {
+	if result, err := p.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ProductPrice), nil
+	}
+}
+
+func (p productPriceDo) FindByPage(offset int, limit int) (result []*model.ProductPrice, count int64, err error) {
+	result, err = p.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = p.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (p productPriceDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = p.Count()
+	if err != nil {
+		return
+	}
+
+	err = p.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (p productPriceDo) Scan(result interface{}) (err error) {
+	return p.DO.Scan(result)
+}
+
+func (p productPriceDo) Delete(models ...*model.ProductPrice) (result gen.ResultInfo, err error) {
+	return p.DO.Delete(models)
+}
+
+func (p *productPriceDo) withDO(do gen.Dao) *productPriceDo {
+	p.DO = *do.(*gen.DO)
+	return p
+}
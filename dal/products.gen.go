@@ -30,10 +30,14 @@ func newProduct(db *gorm.DB, opts ...gen.DOOption) product {
 	_product.ID = field.NewUint(tableName, "id")
 	_product.Name = field.NewString(tableName, "name")
 	_product.Description = field.NewString(tableName, "description")
-	_product.Price = field.NewFloat64(tableName, "price")
+	_product.Price = field.NewField(tableName, "price")
+	_product.Currency = field.NewString(tableName, "currency")
 	_product.IsAvailable = field.NewBool(tableName, "is_available")
+	_product.AllowBackorder = field.NewBool(tableName, "allow_backorder")
+	_product.CategoryId = field.NewUint(tableName, "category_id")
 	_product.CreatedAt = field.NewTime(tableName, "created_at")
 	_product.UpdatedAt = field.NewTime(tableName, "updated_at")
+	_product.DeletedAt = field.NewField(tableName, "deleted_at")
 
 	_product.fillFieldMap()
 
@@ -43,14 +47,18 @@ func newProduct(db *gorm.DB, opts ...gen.DOOption) product {
 type product struct {
 	productDo
 
-	ALL         field.Asterisk
-	ID          field.Uint
-	Name        field.String
-	Description field.String
-	Price       field.Float64
-	IsAvailable field.Bool
-	CreatedAt   field.Time
-	UpdatedAt   field.Time
+	ALL            field.Asterisk
+	ID             field.Uint
+	Name           field.String
+	Description    field.String
+	Price          field.Field
+	Currency       field.String
+	IsAvailable    field.Bool
+	AllowBackorder field.Bool
+	CategoryId     field.Uint
+	CreatedAt      field.Time
+	UpdatedAt      field.Time
+	DeletedAt      field.Field
 
 	fieldMap map[string]field.Expr
 }
@@ -70,10 +78,14 @@ func (p *product) updateTableName(table string) *product {
 	p.ID = field.NewUint(table, "id")
 	p.Name = field.NewString(table, "name")
 	p.Description = field.NewString(table, "description")
-	p.Price = field.NewFloat64(table, "price")
+	p.Price = field.NewField(table, "price")
+	p.Currency = field.NewString(table, "currency")
 	p.IsAvailable = field.NewBool(table, "is_available")
+	p.AllowBackorder = field.NewBool(table, "allow_backorder")
+	p.CategoryId = field.NewUint(table, "category_id")
 	p.CreatedAt = field.NewTime(table, "created_at")
 	p.UpdatedAt = field.NewTime(table, "updated_at")
+	p.DeletedAt = field.NewField(table, "deleted_at")
 
 	p.fillFieldMap()
 
@@ -90,14 +102,18 @@ func (p *product) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (p *product) fillFieldMap() {
-	p.fieldMap = make(map[string]field.Expr, 7)
+	p.fieldMap = make(map[string]field.Expr, 11)
 	p.fieldMap["id"] = p.ID
 	p.fieldMap["name"] = p.Name
 	p.fieldMap["description"] = p.Description
 	p.fieldMap["price"] = p.Price
+	p.fieldMap["currency"] = p.Currency
 	p.fieldMap["is_available"] = p.IsAvailable
+	p.fieldMap["allow_backorder"] = p.AllowBackorder
+	p.fieldMap["category_id"] = p.CategoryId
 	p.fieldMap["created_at"] = p.CreatedAt
 	p.fieldMap["updated_at"] = p.UpdatedAt
+	p.fieldMap["deleted_at"] = p.DeletedAt
 }
 
 func (p product) clone(db *gorm.DB) product {
@@ -0,0 +1,416 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dal
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"order_system/model"
+)
+
+func newShipment(db *gorm.DB, opts ...gen.DOOption) shipment {
+	_shipment := shipment{}
+
+	_shipment.shipmentDo.UseDB(db, opts...)
+	_shipment.shipmentDo.UseModel(&model.Shipment{})
+
+	tableName := _shipment.shipmentDo.TableName()
+	_shipment.ALL = field.NewAsterisk(tableName)
+	_shipment.ID = field.NewUint(tableName, "id")
+	_shipment.OrderId = field.NewUint(tableName, "order_id")
+	_shipment.Carrier = field.NewString(tableName, "carrier")
+	_shipment.TrackingNumber = field.NewString(tableName, "tracking_number")
+	_shipment.Items = field.NewField(tableName, "items")
+	_shipment.State = field.NewInt8(tableName, "state")
+	_shipment.ShippedAt = field.NewTime(tableName, "shipped_at")
+	_shipment.DeliveredAt = field.NewTime(tableName, "delivered_at")
+	_shipment.CreatedAt = field.NewTime(tableName, "created_at")
+	_shipment.UpdatedAt = field.NewTime(tableName, "updated_at")
+
+	_shipment.fillFieldMap()
+
+	return _shipment
+}
+
+type shipment struct {
+	shipmentDo
+
+	ALL            field.Asterisk
+	ID             field.Uint
+	OrderId        field.Uint
+	Carrier        field.String
+	TrackingNumber field.String
+	Items          field.Field
+	State          field.Int8
+	ShippedAt      field.Time
+	DeliveredAt    field.Time
+	CreatedAt      field.Time
+	UpdatedAt      field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (s shipment) Table(newTableName string) *shipment {
+	s.shipmentDo.UseTable(newTableName)
+	return s.updateTableName(newTableName)
+}
+
+func (s shipment) As(alias string) *shipment {
+	s.shipmentDo.DO = *(s.shipmentDo.As(alias).(*gen.DO))
+	return s.updateTableName(alias)
+}
+
+func (s *shipment) updateTableName(table string) *shipment {
+	s.ALL = field.NewAsterisk(table)
+	s.ID = field.NewUint(table, "id")
+	s.OrderId = field.NewUint(table, "order_id")
+	s.Carrier = field.NewString(table, "carrier")
+	s.TrackingNumber = field.NewString(table, "tracking_number")
+	s.Items = field.NewField(table, "items")
+	s.State = field.NewInt8(table, "state")
+	s.ShippedAt = field.NewTime(table, "shipped_at")
+	s.DeliveredAt = field.NewTime(table, "delivered_at")
+	s.CreatedAt = field.NewTime(table, "created_at")
+	s.UpdatedAt = field.NewTime(table, "updated_at")
+
+	s.fillFieldMap()
+
+	return s
+}
+
+func (s *shipment) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := s.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (s *shipment) fillFieldMap() {
+	s.fieldMap = make(map[string]field.Expr, 10)
+	s.fieldMap["id"] = s.ID
+	s.fieldMap["order_id"] = s.OrderId
+	s.fieldMap["carrier"] = s.Carrier
+	s.fieldMap["tracking_number"] = s.TrackingNumber
+	s.fieldMap["items"] = s.Items
+	s.fieldMap["state"] = s.State
+	s.fieldMap["shipped_at"] = s.ShippedAt
+	s.fieldMap["delivered_at"] = s.DeliveredAt
+	s.fieldMap["created_at"] = s.CreatedAt
+	s.fieldMap["updated_at"] = s.UpdatedAt
+}
+
+func (s shipment) clone(db *gorm.DB) shipment {
+	s.shipmentDo.ReplaceConnPool(db.Statement.ConnPool)
+	return s
+}
+
+func (s shipment) replaceDB(db *gorm.DB) shipment {
+	s.shipmentDo.ReplaceDB(db)
+	return s
+}
+
+type shipmentDo struct{ gen.DO }
+
+type IShipmentDo interface {
+	gen.SubQuery
+	Debug() IShipmentDo
+	WithContext(ctx context.Context) IShipmentDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IShipmentDo
+	WriteDB() IShipmentDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IShipmentDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IShipmentDo
+	Not(conds ...gen.Condition) IShipmentDo
+	Or(conds ...gen.Condition) IShipmentDo
+	Select(conds ...field.Expr) IShipmentDo
+	Where(conds ...gen.Condition) IShipmentDo
+	Order(conds ...field.Expr) IShipmentDo
+	Distinct(cols ...field.Expr) IShipmentDo
+	Omit(cols ...field.Expr) IShipmentDo
+	Join(table schema.Tabler, on ...field.Expr) IShipmentDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IShipmentDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IShipmentDo
+	Group(cols ...field.Expr) IShipmentDo
+	Having(conds ...gen.Condition) IShipmentDo
+	Limit(limit int) IShipmentDo
+	Offset(offset int) IShipmentDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IShipmentDo
+	Unscoped() IShipmentDo
+	Create(values ...*model.Shipment) error
+	CreateInBatches(values []*model.Shipment, batchSize int) error
+	Save(values ...*model.Shipment) error
+	First() (*model.Shipment, error)
+	Take() (*model.Shipment, error)
+	Last() (*model.Shipment, error)
+	Find() ([]*model.Shipment, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.Shipment, err error)
+	FindInBatches(result *[]*model.Shipment, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*model.Shipment) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IShipmentDo
+	Assign(attrs ...field.AssignExpr) IShipmentDo
+	Joins(fields ...field.RelationField) IShipmentDo
+	Preload(fields ...field.RelationField) IShipmentDo
+	FirstOrInit() (*model.Shipment, error)
+	FirstOrCreate() (*model.Shipment, error)
+	FindByPage(offset int, limit int) (result []*model.Shipment, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IShipmentDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (s shipmentDo) Debug() IShipmentDo {
+	return s.withDO(s.DO.Debug())
+}
+
+func (s shipmentDo) WithContext(ctx context.Context) IShipmentDo {
+	return s.withDO(s.DO.WithContext(ctx))
+}
+
+func (s shipmentDo) ReadDB() IShipmentDo {
+	return s.Clauses(dbresolver.Read)
+}
+
+func (s shipmentDo) WriteDB() IShipmentDo {
+	return s.Clauses(dbresolver.Write)
+}
+
+func (s shipmentDo) Session(config *gorm.Session) IShipmentDo {
+	return s.withDO(s.DO.Session(config))
+}
+
+func (s shipmentDo) Clauses(conds ...clause.Expression) IShipmentDo {
+	return s.withDO(s.DO.Clauses(conds...))
+}
+
+func (s shipmentDo) Returning(value interface{}, columns ...string) IShipmentDo {
+	return s.withDO(s.DO.Returning(value, columns...))
+}
+
+func (s shipmentDo) Not(conds ...gen.Condition) IShipmentDo {
+	return s.withDO(s.DO.Not(conds...))
+}
+
+func (s shipmentDo) Or(conds ...gen.Condition) IShipmentDo {
+	return s.withDO(s.DO.Or(conds...))
+}
+
+func (s shipmentDo) Select(conds ...field.Expr) IShipmentDo {
+	return s.withDO(s.DO.Select(conds...))
+}
+
+func (s shipmentDo) Where(conds ...gen.Condition) IShipmentDo {
+	return s.withDO(s.DO.Where(conds...))
+}
+
+func (s shipmentDo) Order(conds ...field.Expr) IShipmentDo {
+	return s.withDO(s.DO.Order(conds...))
+}
+
+func (s shipmentDo) Distinct(cols ...field.Expr) IShipmentDo {
+	return s.withDO(s.DO.Distinct(cols...))
+}
+
+func (s shipmentDo) Omit(cols ...field.Expr) IShipmentDo {
+	return s.withDO(s.DO.Omit(cols...))
+}
+
+func (s shipmentDo) Join(table schema.Tabler, on ...field.Expr) IShipmentDo {
+	return s.withDO(s.DO.Join(table, on...))
+}
+
+func (s shipmentDo) LeftJoin(table schema.Tabler, on ...field.Expr) IShipmentDo {
+	return s.withDO(s.DO.LeftJoin(table, on...))
+}
+
+func (s shipmentDo) RightJoin(table schema.Tabler, on ...field.Expr) IShipmentDo {
+	return s.withDO(s.DO.RightJoin(table, on...))
+}
+
+func (s shipmentDo) Group(cols ...field.Expr) IShipmentDo {
+	return s.withDO(s.DO.Group(cols...))
+}
+
+func (s shipmentDo) Having(conds ...gen.Condition) IShipmentDo {
+	return s.withDO(s.DO.Having(conds...))
+}
+
+func (s shipmentDo) Limit(limit int) IShipmentDo {
+	return s.withDO(s.DO.Limit(limit))
+}
+
+func (s shipmentDo) Offset(offset int) IShipmentDo {
+	return s.withDO(s.DO.Offset(offset))
+}
+
+func (s shipmentDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IShipmentDo {
+	return s.withDO(s.DO.Scopes(funcs...))
+}
+
+func (s shipmentDo) Unscoped() IShipmentDo {
+	return s.withDO(s.DO.Unscoped())
+}
+
+func (s shipmentDo) Create(values ...*model.Shipment) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return s.DO.Create(values)
+}
+
+func (s shipmentDo) CreateInBatches(values []*model.Shipment, batchSize int) error {
+	return s.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (s shipmentDo) Save(values ...*model.Shipment) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return s.DO.Save(values)
+}
+
+func (s shipmentDo) First() (*model.Shipment, error) {
+	if result, err := s.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Shipment), nil
+	}
+}
+
+func (s shipmentDo) Take() (*model.Shipment, error) {
+	if result, err := s.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Shipment), nil
+	}
+}
+
+func (s shipmentDo) Last() (*model.Shipment, error) {
+	if result, err := s.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Shipment), nil
+	}
+}
+
+func (s shipmentDo) Find() ([]*model.Shipment, error) {
+	result, err := s.DO.Find()
+	return result.([]*model.Shipment), err
+}
+
+func (s shipmentDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.Shipment, err error) {
+	buf := make([]*model.Shipment, 0, batchSize)
+	err = s.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (s shipmentDo) FindInBatches(result *[]*model.Shipment, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return s.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (s shipmentDo) Attrs(attrs ...field.AssignExpr) IShipmentDo {
+	return s.withDO(s.DO.Attrs(attrs...))
+}
+
+func (s shipmentDo) Assign(attrs ...field.AssignExpr) IShipmentDo {
+	return s.withDO(s.DO.Assign(attrs...))
+}
+
+func (s shipmentDo) Joins(fields ...field.RelationField) IShipmentDo {
+	for _, _f := range fields {
+		s = *s.withDO(s.DO.Joins(_f))
+	}
+	return &s
+}
+
+func (s shipmentDo) Preload(fields ...field.RelationField) IShipmentDo {
+	for _, _f := range fields {
+		s = *s.withDO(s.DO.Preload(_f))
+	}
+	return &s
+}
+
+func (s shipmentDo) FirstOrInit() (*model.Shipment, error) {
+	if result, err := s.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Shipment), nil
+	}
+}
+
+func (s shipmentDo) FirstOrCreate() (*model.Shipment, error) {
+	if result, err := s.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Shipment), nil
+	}
+}
+
+func (s shipmentDo) FindByPage(offset int, limit int) (result []*model.Shipment, count int64, err error) {
+	result, err = s.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = s.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (s shipmentDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = s.Count()
+	if err != nil {
+		return
+	}
+
+	err = s.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (s shipmentDo) Scan(result interface{}) (err error) {
+	return s.DO.Scan(result)
+}
+
+func (s shipmentDo) Delete(models ...*model.Shipment) (result gen.ResultInfo, err error) {
+	return s.DO.Delete(models)
+}
+
+func (s *shipmentDo) withDO(do gen.Dao) *shipmentDo {
+	s.DO = *do.(*gen.DO)
+	return s
+}
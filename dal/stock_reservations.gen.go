@@ -0,0 +1,412 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dal
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"order_system/model"
+)
+
+func newStockReservation(db *gorm.DB, opts ...gen.DOOption) stockReservation {
+	_stockReservation := stockReservation{}
+
+	_stockReservation.stockReservationDo.UseDB(db, opts...)
+	_stockReservation.stockReservationDo.UseModel(&model.StockReservation{})
+
+	tableName := _stockReservation.stockReservationDo.TableName()
+	_stockReservation.ALL = field.NewAsterisk(tableName)
+	_stockReservation.ID = field.NewUint(tableName, "id")
+	_stockReservation.OrderId = field.NewUint(tableName, "order_id")
+	_stockReservation.ProductId = field.NewUint(tableName, "product_id")
+	_stockReservation.VariantId = field.NewUint(tableName, "variant_id")
+	_stockReservation.Quantity = field.NewInt(tableName, "quantity")
+	_stockReservation.State = field.NewInt8(tableName, "state")
+	_stockReservation.ExpiresAt = field.NewTime(tableName, "expires_at")
+	_stockReservation.CreatedAt = field.NewTime(tableName, "created_at")
+	_stockReservation.UpdatedAt = field.NewTime(tableName, "updated_at")
+
+	_stockReservation.fillFieldMap()
+
+	return _stockReservation
+}
+
+type stockReservation struct {
+	stockReservationDo
+
+	ALL       field.Asterisk
+	ID        field.Uint
+	OrderId   field.Uint
+	ProductId field.Uint
+	VariantId field.Uint
+	Quantity  field.Int
+	State     field.Int8
+	ExpiresAt field.Time
+	CreatedAt field.Time
+	UpdatedAt field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (s stockReservation) Table(newTableName string) *stockReservation {
+	s.stockReservationDo.UseTable(newTableName)
+	return s.updateTableName(newTableName)
+}
+
+func (s stockReservation) As(alias string) *stockReservation {
+	s.stockReservationDo.DO = *(s.stockReservationDo.As(alias).(*gen.DO))
+	return s.updateTableName(alias)
+}
+
+func (s *stockReservation) updateTableName(table string) *stockReservation {
+	s.ALL = field.NewAsterisk(table)
+	s.ID = field.NewUint(table, "id")
+	s.OrderId = field.NewUint(table, "order_id")
+	s.ProductId = field.NewUint(table, "product_id")
+	s.VariantId = field.NewUint(table, "variant_id")
+	s.Quantity = field.NewInt(table, "quantity")
+	s.State = field.NewInt8(table, "state")
+	s.ExpiresAt = field.NewTime(table, "expires_at")
+	s.CreatedAt = field.NewTime(table, "created_at")
+	s.UpdatedAt = field.NewTime(table, "updated_at")
+
+	s.fillFieldMap()
+
+	return s
+}
+
+func (s *stockReservation) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := s.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (s *stockReservation) fillFieldMap() {
+	s.fieldMap = make(map[string]field.Expr, 9)
+	s.fieldMap["id"] = s.ID
+	s.fieldMap["order_id"] = s.OrderId
+	s.fieldMap["product_id"] = s.ProductId
+	s.fieldMap["variant_id"] = s.VariantId
+	s.fieldMap["quantity"] = s.Quantity
+	s.fieldMap["state"] = s.State
+	s.fieldMap["expires_at"] = s.ExpiresAt
+	s.fieldMap["created_at"] = s.CreatedAt
+	s.fieldMap["updated_at"] = s.UpdatedAt
+}
+
+func (s stockReservation) clone(db *gorm.DB) stockReservation {
+	s.stockReservationDo.ReplaceConnPool(db.Statement.ConnPool)
+	return s
+}
+
+func (s stockReservation) replaceDB(db *gorm.DB) stockReservation {
+	s.stockReservationDo.ReplaceDB(db)
+	return s
+}
+
+type stockReservationDo struct{ gen.DO }
+
+type IStockReservationDo interface {
+	gen.SubQuery
+	Debug() IStockReservationDo
+	WithContext(ctx context.Context) IStockReservationDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IStockReservationDo
+	WriteDB() IStockReservationDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IStockReservationDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IStockReservationDo
+	Not(conds ...gen.Condition) IStockReservationDo
+	Or(conds ...gen.Condition) IStockReservationDo
+	Select(conds ...field.Expr) IStockReservationDo
+	Where(conds ...gen.Condition) IStockReservationDo
+	Order(conds ...field.Expr) IStockReservationDo
+	Distinct(cols ...field.Expr) IStockReservationDo
+	Omit(cols ...field.Expr) IStockReservationDo
+	Join(table schema.Tabler, on ...field.Expr) IStockReservationDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IStockReservationDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IStockReservationDo
+	Group(cols ...field.Expr) IStockReservationDo
+	Having(conds ...gen.Condition) IStockReservationDo
+	Limit(limit int) IStockReservationDo
+	Offset(offset int) IStockReservationDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IStockReservationDo
+	Unscoped() IStockReservationDo
+	Create(values ...*model.StockReservation) error
+	CreateInBatches(values []*model.StockReservation, batchSize int) error
+	Save(values ...*model.StockReservation) error
+	First() (*model.StockReservation, error)
+	Take() (*model.StockReservation, error)
+	Last() (*model.StockReservation, error)
+	Find() ([]*model.StockReservation, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.StockReservation, err error)
+	FindInBatches(result *[]*model.StockReservation, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*model.StockReservation) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IStockReservationDo
+	Assign(attrs ...field.AssignExpr) IStockReservationDo
+	Joins(fields ...field.RelationField) IStockReservationDo
+	Preload(fields ...field.RelationField) IStockReservationDo
+	FirstOrInit() (*model.StockReservation, error)
+	FirstOrCreate() (*model.StockReservation, error)
+	FindByPage(offset int, limit int) (result []*model.StockReservation, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IStockReservationDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (s stockReservationDo) Debug() IStockReservationDo {
+	return s.withDO(s.DO.Debug())
+}
+
+func (s stockReservationDo) WithContext(ctx context.Context) IStockReservationDo {
+	return s.withDO(s.DO.WithContext(ctx))
+}
+
+func (s stockReservationDo) ReadDB() IStockReservationDo {
+	return s.Clauses(dbresolver.Read)
+}
+
+func (s stockReservationDo) WriteDB() IStockReservationDo {
+	return s.Clauses(dbresolver.Write)
+}
+
+func (s stockReservationDo) Session(config *gorm.Session) IStockReservationDo {
+	return s.withDO(s.DO.Session(config))
+}
+
+func (s stockReservationDo) Clauses(conds ...clause.Expression) IStockReservationDo {
+	return s.withDO(s.DO.Clauses(conds...))
+}
+
+func (s stockReservationDo) Returning(value interface{}, columns ...string) IStockReservationDo {
+	return s.withDO(s.DO.Returning(value, columns...))
+}
+
+func (s stockReservationDo) Not(conds ...gen.Condition) IStockReservationDo {
+	return s.withDO(s.DO.Not(conds...))
+}
+
+func (s stockReservationDo) Or(conds ...gen.Condition) IStockReservationDo {
+	return s.withDO(s.DO.Or(conds...))
+}
+
+func (s stockReservationDo) Select(conds ...field.Expr) IStockReservationDo {
+	return s.withDO(s.DO.Select(conds...))
+}
+
+func (s stockReservationDo) Where(conds ...gen.Condition) IStockReservationDo {
+	return s.withDO(s.DO.Where(conds...))
+}
+
+func (s stockReservationDo) Order(conds ...field.Expr) IStockReservationDo {
+	return s.withDO(s.DO.Order(conds...))
+}
+
+func (s stockReservationDo) Distinct(cols ...field.Expr) IStockReservationDo {
+	return s.withDO(s.DO.Distinct(cols...))
+}
+
+func (s stockReservationDo) Omit(cols ...field.Expr) IStockReservationDo {
+	return s.withDO(s.DO.Omit(cols...))
+}
+
+func (s stockReservationDo) Join(table schema.Tabler, on ...field.Expr) IStockReservationDo {
+	return s.withDO(s.DO.Join(table, on...))
+}
+
+func (s stockReservationDo) LeftJoin(table schema.Tabler, on ...field.Expr) IStockReservationDo {
+	return s.withDO(s.DO.LeftJoin(table, on...))
+}
+
+func (s stockReservationDo) RightJoin(table schema.Tabler, on ...field.Expr) IStockReservationDo {
+	return s.withDO(s.DO.RightJoin(table, on...))
+}
+
+func (s stockReservationDo) Group(cols ...field.Expr) IStockReservationDo {
+	return s.withDO(s.DO.Group(cols...))
+}
+
+func (s stockReservationDo) Having(conds ...gen.Condition) IStockReservationDo {
+	return s.withDO(s.DO.Having(conds...))
+}
+
+func (s stockReservationDo) Limit(limit int) IStockReservationDo {
+	return s.withDO(s.DO.Limit(limit))
+}
+
+func (s stockReservationDo) Offset(offset int) IStockReservationDo {
+	return s.withDO(s.DO.Offset(offset))
+}
+
+func (s stockReservationDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IStockReservationDo {
+	return s.withDO(s.DO.Scopes(funcs...))
+}
+
+func (s stockReservationDo) Unscoped() IStockReservationDo {
+	return s.withDO(s.DO.Unscoped())
+}
+
+func (s stockReservationDo) Create(values ...*model.StockReservation) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return s.DO.Create(values)
+}
+
+func (s stockReservationDo) CreateInBatches(values []*model.StockReservation, batchSize int) error {
+	return s.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (s stockReservationDo) Save(values ...*model.StockReservation) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return s.DO.Save(values)
+}
+
+func (s stockReservationDo) First() (*model.StockReservation, error) {
+	if result, err := s.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.StockReservation), nil
+	}
+}
+
+func (s stockReservationDo) Take() (*model.StockReservation, error) {
+	if result, err := s.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.StockReservation), nil
+	}
+}
+
+func (s stockReservationDo) Last() (*model.StockReservation, error) {
+	if result, err := s.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.StockReservation), nil
+	}
+}
+
+func (s stockReservationDo) Find() ([]*model.StockReservation, error) {
+	result, err := s.DO.Find()
+	return result.([]*model.StockReservation), err
+}
+
+func (s stockReservationDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.StockReservation, err error) {
+	buf := make([]*model.StockReservation, 0, batchSize)
+	err = s.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (s stockReservationDo) FindInBatches(result *[]*model.StockReservation, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return s.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (s stockReservationDo) Attrs(attrs ...field.AssignExpr) IStockReservationDo {
+	return s.withDO(s.DO.Attrs(attrs...))
+}
+
+func (s stockReservationDo) Assign(attrs ...field.AssignExpr) IStockReservationDo {
+	return s.withDO(s.DO.Assign(attrs...))
+}
+
+func (s stockReservationDo) Joins(fields ...field.RelationField) IStockReservationDo {
+	for _, _f := range fields {
+		s = *s.withDO(s.DO.Joins(_f))
+	}
+	return &s
+}
+
+func (s stockReservationDo) Preload(fields ...field.RelationField) IStockReservationDo {
+	for _, _f := range fields {
+		s = *s.withDO(s.DO.Preload(_f))
+	}
+	return &s
+}
+
+func (s stockReservationDo) FirstOrInit() (*model.StockReservation, error) {
+	if result, err := s.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.StockReservation), nil
+	}
+}
+
+func (s stockReservationDo) FirstOrCreate() (*model.StockReservation, error) {
+	if result, err := s.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.StockReservation), nil
+	}
+}
+
+func (s stockReservationDo) FindByPage(offset int, limit int) (result []*model.StockReservation, count int64, err error) {
+	result, err = s.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = s.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (s stockReservationDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = s.Count()
+	if err != nil {
+		return
+	}
+
+	err = s.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (s stockReservationDo) Scan(result interface{}) (err error) {
+	return s.DO.Scan(result)
+}
+
+func (s stockReservationDo) Delete(models ...*model.StockReservation) (result gen.ResultInfo, err error) {
+	return s.DO.Delete(models)
+}
+
+func (s *stockReservationDo) withDO(do gen.Dao) *stockReservationDo {
+	s.DO = *do.(*gen.DO)
+	return s
+}
@@ -0,0 +1,408 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dal
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"order_system/model"
+)
+
+func newVariant(db *gorm.DB, opts ...gen.DOOption) variant {
+	_variant := variant{}
+
+	_variant.variantDo.UseDB(db, opts...)
+	_variant.variantDo.UseModel(&model.Variant{})
+
+	tableName := _variant.variantDo.TableName()
+	_variant.ALL = field.NewAsterisk(tableName)
+	_variant.ID = field.NewUint(tableName, "id")
+	_variant.ProductId = field.NewUint(tableName, "product_id")
+	_variant.Sku = field.NewString(tableName, "sku")
+	_variant.Options = field.NewField(tableName, "options")
+	_variant.Price = field.NewField(tableName, "price")
+	_variant.Stock = field.NewInt(tableName, "stock")
+	_variant.CreatedAt = field.NewTime(tableName, "created_at")
+	_variant.UpdatedAt = field.NewTime(tableName, "updated_at")
+
+	_variant.fillFieldMap()
+
+	return _variant
+}
+
+type variant struct {
+	variantDo
+
+	ALL       field.Asterisk
+	ID        field.Uint
+	ProductId field.Uint
+	Sku       field.String
+	Options   field.Field
+	Price     field.Field
+	Stock     field.Int
+	CreatedAt field.Time
+	UpdatedAt field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (v variant) Table(newTableName string) *variant {
+	v.variantDo.UseTable(newTableName)
+	return v.updateTableName(newTableName)
+}
+
+func (v variant) As(alias string) *variant {
+	v.variantDo.DO = *(v.variantDo.As(alias).(*gen.DO))
+	return v.updateTableName(alias)
+}
+
+func (v *variant) updateTableName(table string) *variant {
+	v.ALL = field.NewAsterisk(table)
+	v.ID = field.NewUint(table, "id")
+	v.ProductId = field.NewUint(table, "product_id")
+	v.Sku = field.NewString(table, "sku")
+	v.Options = field.NewField(table, "options")
+	v.Price = field.NewField(table, "price")
+	v.Stock = field.NewInt(table, "stock")
+	v.CreatedAt = field.NewTime(table, "created_at")
+	v.UpdatedAt = field.NewTime(table, "updated_at")
+
+	v.fillFieldMap()
+
+	return v
+}
+
+func (v *variant) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := v.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (v *variant) fillFieldMap() {
+	v.fieldMap = make(map[string]field.Expr, 8)
+	v.fieldMap["id"] = v.ID
+	v.fieldMap["product_id"] = v.ProductId
+	v.fieldMap["sku"] = v.Sku
+	v.fieldMap["options"] = v.Options
+	v.fieldMap["price"] = v.Price
+	v.fieldMap["stock"] = v.Stock
+	v.fieldMap["created_at"] = v.CreatedAt
+	v.fieldMap["updated_at"] = v.UpdatedAt
+}
+
+func (v variant) clone(db *gorm.DB) variant {
+	v.variantDo.ReplaceConnPool(db.Statement.ConnPool)
+	return v
+}
+
+func (v variant) replaceDB(db *gorm.DB) variant {
+	v.variantDo.ReplaceDB(db)
+	return v
+}
+
+type variantDo struct{ gen.DO }
+
+type IVariantDo interface {
+	gen.SubQuery
+	Debug() IVariantDo
+	WithContext(ctx context.Context) IVariantDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IVariantDo
+	WriteDB() IVariantDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IVariantDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IVariantDo
+	Not(conds ...gen.Condition) IVariantDo
+	Or(conds ...gen.Condition) IVariantDo
+	Select(conds ...field.Expr) IVariantDo
+	Where(conds ...gen.Condition) IVariantDo
+	Order(conds ...field.Expr) IVariantDo
+	Distinct(cols ...field.Expr) IVariantDo
+	Omit(cols ...field.Expr) IVariantDo
+	Join(table schema.Tabler, on ...field.Expr) IVariantDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IVariantDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IVariantDo
+	Group(cols ...field.Expr) IVariantDo
+	Having(conds ...gen.Condition) IVariantDo
+	Limit(limit int) IVariantDo
+	Offset(offset int) IVariantDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IVariantDo
+	Unscoped() IVariantDo
+	Create(values ...*model.Variant) error
+	CreateInBatches(values []*model.Variant, batchSize int) error
+	Save(values ...*model.Variant) error
+	First() (*model.Variant, error)
+	Take() (*model.Variant, error)
+	Last() (*model.Variant, error)
+	Find() ([]*model.Variant, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.Variant, err error)
+	FindInBatches(result *[]*model.Variant, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*model.Variant) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IVariantDo
+	Assign(attrs ...field.AssignExpr) IVariantDo
+	Joins(fields ...field.RelationField) IVariantDo
+	Preload(fields ...field.RelationField) IVariantDo
+	FirstOrInit() (*model.Variant, error)
+	FirstOrCreate() (*model.Variant, error)
+	FindByPage(offset int, limit int) (result []*model.Variant, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IVariantDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (v variantDo) Debug() IVariantDo {
+	return v.withDO(v.DO.Debug())
+}
+
+func (v variantDo) WithContext(ctx context.Context) IVariantDo {
+	return v.withDO(v.DO.WithContext(ctx))
+}
+
+func (v variantDo) ReadDB() IVariantDo {
+	return v.Clauses(dbresolver.Read)
+}
+
+func (v variantDo) WriteDB() IVariantDo {
+	return v.Clauses(dbresolver.Write)
+}
+
+func (v variantDo) Session(config *gorm.Session) IVariantDo {
+	return v.withDO(v.DO.Session(config))
+}
+
+func (v variantDo) Clauses(conds ...clause.Expression) IVariantDo {
+	return v.withDO(v.DO.Clauses(conds...))
+}
+
+func (v variantDo) Returning(value interface{}, columns ...string) IVariantDo {
+	return v.withDO(v.DO.Returning(value, columns...))
+}
+
+func (v variantDo) Not(conds ...gen.Condition) IVariantDo {
+	return v.withDO(v.DO.Not(conds...))
+}
+
+func (v variantDo) Or(conds ...gen.Condition) IVariantDo {
+	return v.withDO(v.DO.Or(conds...))
+}
+
+func (v variantDo) Select(conds ...field.Expr) IVariantDo {
+	return v.withDO(v.DO.Select(conds...))
+}
+
+func (v variantDo) Where(conds ...gen.Condition) IVariantDo {
+	return v.withDO(v.DO.Where(conds...))
+}
+
+func (v variantDo) Order(conds ...field.Expr) IVariantDo {
+	return v.withDO(v.DO.Order(conds...))
+}
+
+func (v variantDo) Distinct(cols ...field.Expr) IVariantDo {
+	return v.withDO(v.DO.Distinct(cols...))
+}
+
+func (v variantDo) Omit(cols ...field.Expr) IVariantDo {
+	return v.withDO(v.DO.Omit(cols...))
+}
+
+func (v variantDo) Join(table schema.Tabler, on ...field.Expr) IVariantDo {
+	return v.withDO(v.DO.Join(table, on...))
+}
+
+func (v variantDo) LeftJoin(table schema.Tabler, on ...field.Expr) IVariantDo {
+	return v.withDO(v.DO.LeftJoin(table, on...))
+}
+
+func (v variantDo) RightJoin(table schema.Tabler, on ...field.Expr) IVariantDo {
+	return v.withDO(v.DO.RightJoin(table, on...))
+}
+
+func (v variantDo) Group(cols ...field.Expr) IVariantDo {
+	return v.withDO(v.DO.Group(cols...))
+}
+
+func (v variantDo) Having(conds ...gen.Condition) IVariantDo {
+	return v.withDO(v.DO.Having(conds...))
+}
+
+func (v variantDo) Limit(limit int) IVariantDo {
+	return v.withDO(v.DO.Limit(limit))
+}
+
+func (v variantDo) Offset(offset int) IVariantDo {
+	return v.withDO(v.DO.Offset(offset))
+}
+
+func (v variantDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IVariantDo {
+	return v.withDO(v.DO.Scopes(funcs...))
+}
+
+func (v variantDo) Unscoped() IVariantDo {
+	return v.withDO(v.DO.Unscoped())
+}
+
+func (v variantDo) Create(values ...*model.Variant) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return v.DO.Create(values)
+}
+
+func (v variantDo) CreateInBatches(values []*model.Variant, batchSize int) error {
+	return v.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (v variantDo) Save(values ...*model.Variant) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return v.DO.Save(values)
+}
+
+func (v variantDo) First() (*model.Variant, error) {
+	if result, err := v.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Variant), nil
+	}
+}
+
+func (v variantDo) Take() (*model.Variant, error) {
+	if result, err := v.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Variant), nil
+	}
+}
+
+func (v variantDo) Last() (*model.Variant, error) {
+	if result, err := v.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Variant), nil
+	}
+}
+
+func (v variantDo) Find() ([]*model.Variant, error) {
+	result, err := v.DO.Find()
+	return result.([]*model.Variant), err
+}
+
+func (v variantDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.Variant, err error) {
+	buf := make([]*model.Variant, 0, batchSize)
+	err = v.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (v variantDo) FindInBatches(result *[]*model.Variant, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return v.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (v variantDo) Attrs(attrs ...field.AssignExpr) IVariantDo {
+	return v.withDO(v.DO.Attrs(attrs...))
+}
+
+func (v variantDo) Assign(attrs ...field.AssignExpr) IVariantDo {
+	return v.withDO(v.DO.Assign(attrs...))
+}
+
+func (v variantDo) Joins(fields ...field.RelationField) IVariantDo {
+	for _, _f := range fields {
+		v = *v.withDO(v.DO.Joins(_f))
+	}
+	return &v
+}
+
+func (v variantDo) Preload(fields ...field.RelationField) IVariantDo {
+	for _, _f := range fields {
+		v = *v.withDO(v.DO.Preload(_f))
+	}
+	return &v
+}
+
+func (v variantDo) FirstOrInit() (*model.Variant, error) {
+	if result, err := v.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Variant), nil
+	}
+}
+
+func (v variantDo) FirstOrCreate() (*model.Variant, error) {
+	if result, err := v.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Variant), nil
+	}
+}
+
+func (v variantDo) FindByPage(offset int, limit int) (result []*model.Variant, count int64, err error) {
+	result, err = v.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = v.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (v variantDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = v.Count()
+	if err != nil {
+		return
+	}
+
+	err = v.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (v variantDo) Scan(result interface{}) (err error) {
+	return v.DO.Scan(result)
+}
+
+func (v variantDo) Delete(models ...*model.Variant) (result gen.ResultInfo, err error) {
+	return v.DO.Delete(models)
+}
+
+func (v *variantDo) withDO(do gen.Dao) *variantDo {
+	v.DO = *do.(*gen.DO)
+	return v
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// AddressSnapshot Copy of an address taken when an order is created, stored as jsonb
+type AddressSnapshot struct {
+	AddressId  uint    `json:"address_id"`
+	Line1      string  `json:"line1"`
+	Line2      *string `json:"line2,omitempty"`
+	City       string  `json:"city"`
+	Region     *string `json:"region,omitempty"`
+	PostalCode string  `json:"postal_code"`
+	Country    string  `json:"country"`
+}
+
+// Snapshot Copy of the address for an order
+func (a *Address) Snapshot() *AddressSnapshot {
+	return &AddressSnapshot{
+		AddressId:  a.ID,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
+
+func (a AddressSnapshot) Value() (driver.Value, error) {
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+func (a *AddressSnapshot) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	}
+	return errors.New(fmt.Sprintf("cannot scan %T into AddressSnapshot", src))
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// IdList Ids stored as a jsonb array
+type IdList []uint
+
+func (ids IdList) Contains(id uint) bool {
+	return slices.Contains(ids, id)
+}
+
+func (ids IdList) Value() (driver.Value, error) {
+	if ids == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(ids)
+	return string(b), err
+}
+
+func (ids *IdList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, ids)
+	case string:
+		return json.Unmarshal([]byte(v), ids)
+	}
+	return errors.New(fmt.Sprintf("cannot scan %T into IdList", src))
+}
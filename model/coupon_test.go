@@ -0,0 +1,40 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIdList(t *testing.T) {
+	value, err := IdList(nil).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", value)
+
+	ids := IdList{}
+	assert.Nil(t, ids.Scan([]byte(`[3,7]`)))
+	assert.True(t, ids.Contains(7))
+	assert.False(t, ids.Contains(4))
+	assert.Error(t, ids.Scan(42))
+}
+
+func TestOrderLineNetAmount(t *testing.T) {
+	line := OrderLine{Quantity: 3, Amount: NewMoney(30, 0), DiscountAmount: NewMoney(3, 0)}
+	assert.Equal(t, NewMoney(9, 0), line.NetAmount(1))
+	assert.Equal(t, NewMoney(27, 0), line.NetAmount(3))
+
+	line.TaxAmount = NewMoney(2, 70)
+	assert.Equal(t, NewMoney(9, 90), line.NetAmount(1))
+}
+
+func TestOrderLineCancel(t *testing.T) {
+	// The last unit makes up the rounding of the earlier ones
+	line := OrderLine{Quantity: 3, Amount: NewMoney(10, 0)}
+	assert.Equal(t, NewMoney(3, 33), line.Cancel(1))
+	assert.Equal(t, NewMoney(3, 34), line.Cancel(1))
+	assert.Equal(t, NewMoney(3, 33), line.Cancel(1))
+	assert.Equal(t, NewMoney(10, 0), line.RefundedAmount)
+	assert.Equal(t, 0, line.Remaining())
+
+	line = OrderLine{Quantity: 3, Amount: NewMoney(10, 0), FulfilledQuantity: 1}
+	assert.Equal(t, NewMoney(6, 67), line.Cancel(2))
+}
@@ -1,50 +1,366 @@
 package model
 
 import (
+	"gorm.io/gorm"
 	"time"
 )
 
 var ALL_ORDER_TABLES []interface{} = []interface{}{
-	Customer{}, Product{}, Order{}, Payment{},
+	Customer{}, Address{}, Category{}, Product{}, ProductPrice{}, Variant{}, Tag{}, ProductTag{}, Order{}, OrderLine{}, Payment{}, Shipment{}, Coupon{}, OrderDiscount{}, OrderTax{}, Cart{}, CartItem{}, StockReservation{}, PaymentReview{}, Refund{}, PaymentAction{},
 }
 
 type Customer struct {
-	ID        uint      `json:"id" gorm:"auto_increment;primary_key"`
-	Name      string    `json:"name" gorm:"index;unique;not null"`
-	Email     *string   `json:"email,omitempty"`
+	ID   uint   `json:"id" gorm:"auto_increment;primary_key"`
+	Name string `json:"name" gorm:"index;not null"`
+	// Stored lowercased, unique among customers which are not deleted
+	Email *string `json:"email,omitempty" gorm:"uniqueIndex:idx_customers_email,where:deleted_at IS NULL"`
+	// Free text address kept for compatibility, orders use the structured Addresses
 	Address   *string   `json:"address,omitempty"`
 	CreatedAt time.Time `json:"createdTime"`
 	UpdatedAt time.Time `json:"updatedTime"`
+	// Soft deleted customers are excluded from queries and can't place orders
+	DeletedAt gorm.DeletedAt `json:"deletedTime,omitempty" gorm:"index"`
+}
+
+// Address A saved address of a customer, a customer has at most one default billing and one default shipping address
+type Address struct {
+	ID         uint    `json:"id" gorm:"auto_increment;primary_key"`
+	CustomerId uint    `json:"customer_id" gorm:"index;not null"`
+	Line1      string  `json:"line1" gorm:"not null"`
+	Line2      *string `json:"line2,omitempty"`
+	City       string  `json:"city" gorm:"not null"`
+	Region     *string `json:"region,omitempty"`
+	PostalCode string  `json:"postal_code" gorm:"not null"`
+	// ISO 3166-1 alpha-2 code
+	Country           string    `json:"country" gorm:"type:char(2);not null"`
+	IsDefaultBilling  bool      `json:"is_default_billing" gorm:"not null"`
+	IsDefaultShipping bool      `json:"is_default_shipping" gorm:"not null"`
+	CreatedAt         time.Time `json:"createdTime"`
+	UpdatedAt         time.Time `json:"updatedTime"`
 }
 
 type Product struct {
-	ID          uint      `json:"id" gorm:"auto_increment;primary_key"`
-	Name        string    `json:"name" gorm:"index;unique;not null"`
-	Description *string   `json:"description,omitempty"`
-	Price       float64   `json:"price" gorm:"type:decimal(10,2); not null"`
-	IsAvailable bool      `json:"is_available" gorm:"not null"`
+	ID uint `json:"id" gorm:"auto_increment;primary_key"`
+	// Unique among products which are not deleted
+	Name        string  `json:"name" gorm:"index;uniqueIndex:idx_products_name_not_deleted,where:deleted_at IS NULL;not null"`
+	Description *string `json:"description,omitempty"`
+	Price       Money   `json:"price" gorm:"type:decimal(10,2); not null"`
+	Currency    string  `json:"currency" gorm:"type:char(3);not null;default:USD"`
+	IsAvailable bool    `json:"is_available" gorm:"not null"`
+	// Orders are accepted as backorders or pre-orders when the product is out of stock or not available yet
+	AllowBackorder bool      `json:"allow_backorder" gorm:"not null;default:false"`
+	CategoryId     *uint     `json:"category_id,omitempty" gorm:"index"`
+	CreatedAt      time.Time `json:"createdTime"`
+	UpdatedAt      time.Time `json:"updatedTime"`
+	// Soft deleted products are excluded from queries and can't be ordered
+	DeletedAt gorm.DeletedAt `json:"deletedTime,omitempty" gorm:"index"`
+	// Prices in other currencies, take precedence over converting Price with exchange rates
+	Prices []ProductPrice `json:"prices,omitempty" gorm:"-"`
+	// Category path from the root down to the product category
+	Breadcrumbs []Category `json:"breadcrumbs,omitempty" gorm:"-"`
+	Tags        []string   `json:"tags,omitempty" gorm:"-"`
+	// Orderable SKUs of the product, a product with variants can only be ordered by SKU
+	Variants []Variant `json:"variants,omitempty" gorm:"-"`
+}
+
+// Variant A SKU of a product, e.g. size M in red, with its own price and stock
+type Variant struct {
+	ID        uint    `json:"id" gorm:"auto_increment;primary_key"`
+	ProductId uint    `json:"product_id" gorm:"index;not null"`
+	Sku       string  `json:"sku" gorm:"uniqueIndex;not null"`
+	Options   Options `json:"options" gorm:"type:jsonb;not null"`
+	// Price in the product currency, the product price and price list apply when it is null
+	Price     *Money    `json:"price,omitempty" gorm:"type:decimal(10,2)"`
+	Stock     int       `json:"stock" gorm:"not null"`
+	CreatedAt time.Time `json:"createdTime"`
+	UpdatedAt time.Time `json:"updatedTime"`
+}
+
+type Category struct {
+	ID       uint   `json:"id" gorm:"auto_increment;primary_key"`
+	Name     string `json:"name" gorm:"not null"`
+	ParentId *uint  `json:"parent_id,omitempty" gorm:"index"`
+	// Materialized path of ids from the root, e.g. /1/4/ for category 4 under category 1
+	Path      string    `json:"path" gorm:"index;not null"`
+	CreatedAt time.Time `json:"createdTime"`
+	UpdatedAt time.Time `json:"updatedTime"`
+}
+
+type Tag struct {
+	ID        uint      `json:"id" gorm:"auto_increment;primary_key"`
+	Name      string    `json:"name" gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time `json:"createdTime"`
+}
+
+type ProductTag struct {
+	ProductId uint `json:"product_id" gorm:"primaryKey;autoIncrement:false"`
+	TagId     uint `json:"tag_id" gorm:"primaryKey;autoIncrement:false;index"`
+}
+
+type ProductPrice struct {
+	ID        uint      `json:"id" gorm:"auto_increment;primary_key"`
+	ProductId uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_product_prices_product_currency"`
+	Currency  string    `json:"currency" gorm:"type:char(3);not null;uniqueIndex:idx_product_prices_product_currency"`
+	Price     Money     `json:"price" gorm:"type:decimal(10,2); not null"`
+	CreatedAt time.Time `json:"createdTime"`
+	UpdatedAt time.Time `json:"updatedTime"`
+}
+
+type Order struct {
+	ID         uint `json:"id" gorm:"auto_increment;primary_key"`
+	CustomerId uint `json:"customer_id" gorm:"index;"`
+	// Product, variant and SKU of the first line, see Lines for all ordered items
+	ProductId uint    `json:"product_id" gorm:"index;not null"`
+	VariantId *uint   `json:"variant_id,omitempty" gorm:"index"`
+	Sku       *string `json:"sku,omitempty"`
+	// Address snapshots, later changes of the customer addresses don't affect the order
+	ShippingAddress *AddressSnapshot `json:"shipping_address,omitempty" gorm:"type:jsonb"`
+	BillingAddress  *AddressSnapshot `json:"billing_address,omitempty" gorm:"type:jsonb"`
+	// Sum of the line amounts, Amount is the subtotal plus tax less the discount
+	Subtotal       Money `json:"subtotal" gorm:"type:decimal(10,2);not null;default:0"`
+	DiscountAmount Money `json:"discount_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	TaxAmount      Money `json:"tax_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	// Tax is included in the line prices instead of added to the amount, e.g. VAT
+	PricesIncludeTax bool  `json:"prices_include_tax" gorm:"not null;default:false"`
+	Amount           Money `json:"amount" gorm:"type:decimal(10,2); not null"`
+	// Refunded for order lines which can't be fulfilled
+	RefundedAmount Money  `json:"refunded_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	Currency       string `json:"currency" gorm:"type:char(3);not null;default:USD"`
+	// Exchange rate snapshot of Currency per 1 unit of the base currency when the order was created
+	ExchangeRate float64 `json:"exchange_rate" gorm:"type:decimal(18,8);not null;default:1"`
+	// Ordered on backorder, the payment is authorized and captured when the backordered lines are in stock
+	Backordered bool        `json:"backordered" gorm:"not null;default:false"`
+	State       int8        `json:"state"`
+	FailReason  *string     `json:"fail_reason,omitempty"`
+	CreatedAt   time.Time   `json:"createdTime"`
+	UpdatedAt   time.Time   `json:"updatedTime"`
+	Lines       []OrderLine `json:"lines,omitempty" gorm:"-"`
+	Shipments   []Shipment  `json:"shipments,omitempty" gorm:"-"`
+	// Coupons redeemed on the order
+	Discounts []OrderDiscount `json:"discounts,omitempty" gorm:"-"`
+	Taxes     []OrderTax      `json:"taxes,omitempty" gorm:"-"`
+}
+
+// OrderLine An ordered product or SKU, shipped in one or several shipments
+type OrderLine struct {
+	ID        uint    `json:"id" gorm:"auto_increment;primary_key"`
+	OrderId   uint    `json:"order_id" gorm:"index;not null"`
+	ProductId uint    `json:"product_id" gorm:"not null"`
+	VariantId *uint   `json:"variant_id,omitempty"`
+	Sku       *string `json:"sku,omitempty"`
+	Quantity  int     `json:"quantity" gorm:"not null"`
+	// Prices in the order currency
+	UnitPrice Money `json:"unit_price" gorm:"type:decimal(10,2);not null"`
+	Amount    Money `json:"amount" gorm:"type:decimal(10,2);not null"`
+	// Share of the order discount, refunds of the line are net of it
+	DiscountAmount Money `json:"discount_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	// Tax added to the line amount, zero when the price includes tax
+	TaxAmount         Money `json:"tax_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	FulfilledQuantity int   `json:"fulfilled_quantity" gorm:"not null"`
+	// Quantity which can't be fulfilled and was refunded
+	CanceledQuantity int `json:"canceled_quantity" gorm:"not null"`
+	// Refunds of the canceled quantity, they add up to its net amount
+	RefundedAmount Money `json:"refunded_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	// Waiting for stock, the line took no stock yet
+	Backordered bool      `json:"backordered" gorm:"not null;default:false"`
 	CreatedAt   time.Time `json:"createdTime"`
 	UpdatedAt   time.Time `json:"updatedTime"`
 }
 
-type Order struct {
-	ID         uint      `json:"id" gorm:"auto_increment;primary_key"`
-	CustomerId uint      `json:"customer_id" gorm:"index;"`
-	ProductId  uint      `json:"product_id" gorm:"index;not null"`
-	Amount     float64   `json:"amount" gorm:"type:decimal(10,2); not null"`
-	State      int8      `json:"state"`
-	FailReason *string   `json:"fail_reason,omitempty"`
-	CreatedAt  time.Time `json:"createdTime"`
-	UpdatedAt  time.Time `json:"updatedTime"`
+// Remaining Quantity which is neither fulfilled nor canceled
+func (line *OrderLine) Remaining() int {
+	return line.Quantity - line.FulfilledQuantity - line.CanceledQuantity
+}
+
+// NetAmount Amount paid for a quantity of the line, after its share of the discount and with the tax added to it
+func (line *OrderLine) NetAmount(quantity int) Money {
+	return line.Amount.Sub(line.DiscountAmount).Add(line.TaxAmount).MulRatio(int64(quantity), int64(line.Quantity))
+}
+
+// Cancel Cancel a quantity of the line and return its refund, the net amount of the canceled quantity less the earlier refunds.
+// Rounding of earlier partial cancellations is made up, the refunds of a fully canceled line add up to its net amount.
+func (line *OrderLine) Cancel(quantity int) Money {
+	line.CanceledQuantity += quantity
+	refund := line.NetAmount(line.CanceledQuantity).Sub(line.RefundedAmount)
+	line.RefundedAmount = line.RefundedAmount.Add(refund)
+	return refund
 }
 
 type Payment struct {
-	ID              uint      `json:"id" gorm:"auto_increment;primary_key"`
-	OrderId         uint      `json:"order_id" gorm:"index;not null"`
-	Amount          float64   `json:"amount" gorm:"type:decimal(10,2); not null"`
-	State           int8      `json:"state" gorm:"not null"`
-	PaymentResult   *string   `json:"payment_result,omitempty"`
+	ID         uint   `json:"id" gorm:"auto_increment;primary_key"`
+	OrderId    uint   `json:"order_id" gorm:"index;not null"`
+	CustomerId uint   `json:"customer_id,omitempty" gorm:"index"`
+	Amount     Money  `json:"amount" gorm:"type:decimal(10,2); not null"`
+	Currency   string `json:"currency" gorm:"type:char(3);not null;default:USD"`
+	// Amount in the base currency, customer limits of the risk rules are checked with it
+	BaseAmount    Money   `json:"base_amount" gorm:"type:decimal(10,2);not null;default:0"`
+	State         int8    `json:"state" gorm:"not null"`
+	PaymentResult *string `json:"payment_result,omitempty"`
+	// Payment of a backorder, it is only authorized and captured when the order is in stock
+	AuthorizeOnly bool `json:"authorize_only" gorm:"not null;default:false"`
+	// Gateway attempt of the order payment, a transient gateway error is retried by a new attempt
+	Attempt int `json:"attempt" gorm:"not null;default:1"`
+	// Refund of Order system which a REFUND payment was made for, a refund is made once however often it is requested
+	RefundId        *uint     `json:"refund_id,omitempty" gorm:"uniqueIndex"`
 	IsNotifiedOrder bool      `json:"is_notified_order" gorm:"not null"`
 	CreatedAt       time.Time `json:"createdTime"`
 	UpdatedAt       time.Time `json:"updatedTime"`
 }
+
+// Refund Refund of an order saved with the cancellation it is made for, then made through the payment API.
+// Its id is the idempotency key of the payment API, a PENDING refund is requested again until it is COMPLETED.
+type Refund struct {
+	ID         uint      `json:"id" gorm:"auto_increment;primary_key"`
+	OrderId    uint      `json:"order_id" gorm:"index;not null"`
+	CustomerId uint      `json:"customer_id" gorm:"not null"`
+	Amount     Money     `json:"amount" gorm:"type:decimal(10,2);not null"`
+	Currency   string    `json:"currency" gorm:"type:char(3);not null"`
+	Reason     string    `json:"reason" gorm:"not null"`
+	State      int8      `json:"state" gorm:"not null"`
+	CreatedAt  time.Time `json:"createdTime"`
+	UpdatedAt  time.Time `json:"updatedTime"`
+}
+
+// PaymentAction Capture or void of the authorized payment of a backorder, saved with the order state change it is made for, then made through the payment API.
+// A PENDING action is requested again until it is COMPLETED, or FAILED when the payment API has no authorized payment to act on.
+type PaymentAction struct {
+	ID      uint `json:"id" gorm:"auto_increment;primary_key"`
+	OrderId uint `json:"order_id" gorm:"index;not null"`
+	// CAPTURE or VOID
+	Action    string    `json:"action" gorm:"not null"`
+	State     int8      `json:"state" gorm:"not null"`
+	Result    *string   `json:"result,omitempty"`
+	CreatedAt time.Time `json:"createdTime"`
+	UpdatedAt time.Time `json:"updatedTime"`
+}
+
+// PaymentReview Audit of a reviewer decision on a payment held for review by the risk rules
+type PaymentReview struct {
+	ID        uint    `json:"id" gorm:"auto_increment;primary_key"`
+	PaymentId uint    `json:"payment_id" gorm:"index;not null"`
+	OrderId   uint    `json:"order_id" gorm:"not null"`
+	Decision  string  `json:"decision" gorm:"not null"`
+	Reviewer  string  `json:"reviewer" gorm:"index;not null"`
+	Note      *string `json:"note,omitempty"`
+	// Risk reasons the payment was held for
+	Reasons   *string   `json:"reasons,omitempty"`
+	CreatedAt time.Time `json:"createdTime"`
+}
+
+// Shipment Parcel of an order handed over to a fulfillment provider, tracked until it is delivered
+type Shipment struct {
+	ID             uint          `json:"id" gorm:"auto_increment;primary_key"`
+	OrderId        uint          `json:"order_id" gorm:"index;not null"`
+	Carrier        *string       `json:"carrier,omitempty"`
+	TrackingNumber *string       `json:"tracking_number,omitempty"`
+	Items          ShipmentItems `json:"items" gorm:"type:jsonb;not null"`
+	State          int8          `json:"state" gorm:"not null"`
+	ShippedAt      *time.Time    `json:"shippedTime,omitempty"`
+	DeliveredAt    *time.Time    `json:"deliveredTime,omitempty"`
+	CreatedAt      time.Time     `json:"createdTime"`
+	UpdatedAt      time.Time     `json:"updatedTime"`
+}
+
+// Coupon A discount code, a percentage or a fixed amount off the eligible order lines
+type Coupon struct {
+	ID uint `json:"id" gorm:"auto_increment;primary_key"`
+	// Stored uppercased
+	Code string `json:"code" gorm:"uniqueIndex;not null"`
+	// PERCENT or FIXED
+	DiscountType string `json:"discount_type" gorm:"not null"`
+	// Percentage off the eligible lines of PERCENT coupons
+	Percent int `json:"percent,omitempty" gorm:"not null;default:0"`
+	// Amount off the eligible lines of FIXED coupons, in Currency
+	AmountOff Money `json:"amount_off" gorm:"type:decimal(10,2);not null;default:0"`
+	// Minimum subtotal of the order, in Currency
+	MinSpend Money `json:"min_spend" gorm:"type:decimal(10,2);not null;default:0"`
+	// Currency of AmountOff and MinSpend, they are converted to the order currency with the exchange rates
+	Currency string `json:"currency" gorm:"type:char(3);not null;default:USD"`
+	// Usage limits, unlimited when null
+	MaxUses            *int `json:"max_uses,omitempty"`
+	MaxUsesPerCustomer *int `json:"max_uses_per_customer,omitempty"`
+	UsedCount          int  `json:"used_count" gorm:"not null;default:0"`
+	// Validity window, open ended when null
+	StartsAt *time.Time `json:"startsTime,omitempty"`
+	EndsAt   *time.Time `json:"endsTime,omitempty"`
+	// The coupon applies to these products and categories with their sub categories, or to every line when both are empty
+	ProductIds  IdList    `json:"product_ids,omitempty" gorm:"type:jsonb;not null"`
+	CategoryIds IdList    `json:"category_ids,omitempty" gorm:"type:jsonb;not null"`
+	IsActive    bool      `json:"is_active" gorm:"not null"`
+	CreatedAt   time.Time `json:"createdTime"`
+	UpdatedAt   time.Time `json:"updatedTime"`
+}
+
+// OrderDiscount A coupon redeemed on an order, allocated to its lines
+type OrderDiscount struct {
+	ID         uint `json:"id" gorm:"auto_increment;primary_key"`
+	OrderId    uint `json:"order_id" gorm:"index;not null"`
+	CouponId   uint `json:"coupon_id" gorm:"index:idx_order_discounts_coupon_customer;not null"`
+	CustomerId uint `json:"customer_id" gorm:"index:idx_order_discounts_coupon_customer;not null"`
+	// Code snapshot, in the order currency
+	Code      string    `json:"code" gorm:"not null"`
+	Amount    Money     `json:"amount" gorm:"type:decimal(10,2);not null"`
+	CreatedAt time.Time `json:"createdTime"`
+	// Set when the order is canceled or its payment fails, the use of the coupon is given back then
+	VoidedAt *time.Time `json:"voidedTime,omitempty"`
+}
+
+// OrderTax Tax of an order at the rate of a jurisdiction, in the order currency
+type OrderTax struct {
+	ID           uint    `json:"id" gorm:"auto_increment;primary_key"`
+	OrderId      uint    `json:"order_id" gorm:"index;not null"`
+	Jurisdiction string  `json:"jurisdiction" gorm:"not null"`
+	Rate         float64 `json:"rate" gorm:"type:decimal(7,6);not null"`
+	// Discounted line amounts the rate applies to, without the tax
+	TaxableAmount Money `json:"taxable_amount" gorm:"type:decimal(10,2);not null"`
+	Amount        Money `json:"amount" gorm:"type:decimal(10,2);not null"`
+	// Tax included in the line prices
+	Included  bool      `json:"included" gorm:"not null"`
+	CreatedAt time.Time `json:"createdTime"`
+}
+
+// Cart Basket of a customer, a customer has at most one open cart which expires when it isn't changed
+type Cart struct {
+	ID         uint `json:"id" gorm:"auto_increment;primary_key"`
+	CustomerId uint `json:"customer_id" gorm:"not null;uniqueIndex:idx_carts_customer_open,where:state = 0"`
+	// Currency of the item prices and the order, defaults to the currency of the first product
+	Currency string `json:"currency" gorm:"type:char(3);not null"`
+	State    int8   `json:"state" gorm:"not null"`
+	// Order created at checkout
+	OrderId   *uint      `json:"order_id,omitempty"`
+	ExpiresAt time.Time  `json:"expiresTime" gorm:"index;not null"`
+	CreatedAt time.Time  `json:"createdTime"`
+	UpdatedAt time.Time  `json:"updatedTime"`
+	Items     []CartItem `json:"items" gorm:"-"`
+}
+
+// CartItem A product or SKU in a cart, its price is validated again when the cart is queried or checked out
+type CartItem struct {
+	ID        uint    `json:"id" gorm:"auto_increment;primary_key"`
+	CartId    uint    `json:"cart_id" gorm:"index;not null"`
+	ProductId uint    `json:"product_id" gorm:"not null"`
+	VariantId *uint   `json:"variant_id,omitempty"`
+	Sku       *string `json:"sku,omitempty"`
+	Quantity  int     `json:"quantity" gorm:"not null"`
+	// Price in the cart currency when the item was added or last validated
+	UnitPrice Money     `json:"unit_price" gorm:"type:decimal(10,2);not null"`
+	CreatedAt time.Time `json:"createdTime"`
+	UpdatedAt time.Time `json:"updatedTime"`
+	// Validation result, the price changed since it was last seen or the item can't be ordered
+	PriceChanged bool   `json:"price_changed,omitempty" gorm:"-"`
+	Unavailable  string `json:"unavailable,omitempty" gorm:"-"`
+}
+
+// StockReservation Stock taken by an order line, held until the order is paid.
+// The stock is returned when the payment fails, the order is canceled or the reservation expires.
+type StockReservation struct {
+	ID        uint      `json:"id" gorm:"auto_increment;primary_key"`
+	OrderId   uint      `json:"order_id" gorm:"index;not null"`
+	ProductId uint      `json:"product_id" gorm:"not null"`
+	VariantId *uint     `json:"variant_id,omitempty"`
+	Quantity  int       `json:"quantity" gorm:"not null"`
+	State     int8      `json:"state" gorm:"not null;index:idx_stock_reservations_state_expires,priority:1"`
+	ExpiresAt time.Time `json:"expiresTime" gorm:"not null;index:idx_stock_reservations_state_expires,priority:2"`
+	CreatedAt time.Time `json:"createdTime"`
+	UpdatedAt time.Time `json:"updatedTime"`
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Money Amount in minor units (cents), avoids float rounding errors.
+// It is stored as decimal(10,2) and rendered as a decimal number like 10.00 in JSON.
+type Money int64
+
+const MONEY_SCALE = 100
+
+// NewMoney Build money from major and minor units, e.g. NewMoney(10, 50) is 10.50
+func NewMoney(major int64, minor int64) Money {
+	if major < 0 {
+		return Money(major*MONEY_SCALE - minor)
+	}
+	return Money(major*MONEY_SCALE + minor)
+}
+
+// MoneyFromFloat Convert a float to money, rounded to the nearest minor unit
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * MONEY_SCALE))
+}
+
+// ParseMoney Parse a decimal string exactly, fails when it has more than 2 decimal places
+func ParseMoney(s string) (Money, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid money amount %q", s)
+	}
+	rat.Mul(rat, big.NewRat(MONEY_SCALE, 1))
+	if !rat.IsInt() {
+		return 0, fmt.Errorf("money amount %q has more than 2 decimal places", s)
+	}
+	if !rat.Num().IsInt64() {
+		return 0, fmt.Errorf("money amount %q is out of range", s)
+	}
+	return Money(rat.Num().Int64()), nil
+}
+
+func (m Money) MinorUnits() int64 {
+	return int64(m)
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / MONEY_SCALE
+}
+
+func (m Money) String() string {
+	sign := ""
+	units := int64(m)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/MONEY_SCALE, units%MONEY_SCALE)
+}
+
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+// Mul Multiply by a quantity
+func (m Money) Mul(quantity int64) Money {
+	return m * Money(quantity)
+}
+
+// MulRatio Multiply by numerator/denominator, rounded half away from zero
+func (m Money) MulRatio(numerator int64, denominator int64) Money {
+	if denominator == 0 {
+		return 0
+	}
+	product := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(numerator))
+	quotient, remainder := new(big.Int).QuoRem(product, big.NewInt(denominator), new(big.Int))
+	// Round half away from zero
+	if new(big.Int).Abs(new(big.Int).Mul(remainder, big.NewInt(2))).Cmp(new(big.Int).Abs(big.NewInt(denominator))) >= 0 {
+		if (product.Sign() < 0) != (denominator < 0) {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return Money(quotient.Int64())
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON Accept both number and string, e.g. 10.5 or "10.50"
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		*m, err = ParseMoney(string(v))
+	case string:
+		*m, err = ParseMoney(v)
+	case float64:
+		*m = MoneyFromFloat(v)
+	case int64:
+		*m = Money(v * MONEY_SCALE)
+	default:
+		err = errors.New(fmt.Sprintf("cannot scan %T into Money", src))
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	m, err := ParseMoney("10.5")
+	assert.Nil(t, err)
+	assert.Equal(t, Money(1050), m)
+
+	m, err = ParseMoney("-0.01")
+	assert.Nil(t, err)
+	assert.Equal(t, Money(-1), m)
+
+	_, err = ParseMoney("10.005")
+	assert.Error(t, err)
+
+	_, err = ParseMoney("abc")
+	assert.Error(t, err)
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "10.50", NewMoney(10, 50).String())
+	assert.Equal(t, "-10.05", NewMoney(-10, 5).String())
+	assert.Equal(t, "0.00", Money(0).String())
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	// 0.1 + 0.2 is exactly 0.3 in minor units
+	assert.Equal(t, NewMoney(0, 30), NewMoney(0, 10).Add(NewMoney(0, 20)))
+	assert.Equal(t, NewMoney(30, 0), NewMoney(10, 0).Mul(3))
+	// 10.01 * 1/2 = 5.005, rounded half away from zero
+	assert.Equal(t, NewMoney(5, 1), NewMoney(10, 1).MulRatio(1, 2))
+	assert.Equal(t, NewMoney(-5, 1), NewMoney(-10, 1).MulRatio(1, 2))
+	assert.Equal(t, NewMoney(3, 33), NewMoney(10, 0).MulRatio(1, 3))
+}
+
+func TestMoneyJSON(t *testing.T) {
+	product := Product{Price: NewMoney(19, 99)}
+	data, err := json.Marshal(product)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"price":19.99`)
+
+	actual := Product{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"price":19.99}`), &actual))
+	assert.Equal(t, NewMoney(19, 99), actual.Price)
+	assert.Nil(t, json.Unmarshal([]byte(`{"price":"0.10"}`), &actual))
+	assert.Equal(t, NewMoney(0, 10), actual.Price)
+	assert.Error(t, json.Unmarshal([]byte(`{"price":1.001}`), &actual))
+}
+
+func TestMoneyScan(t *testing.T) {
+	var m Money
+	assert.Nil(t, m.Scan([]byte("100.10")))
+	assert.Equal(t, NewMoney(100, 10), m)
+	assert.Nil(t, m.Scan(0.3))
+	assert.Equal(t, NewMoney(0, 30), m)
+	assert.Nil(t, m.Scan(int64(5)))
+	assert.Equal(t, NewMoney(5, 0), m)
+	assert.Error(t, m.Scan(true))
+
+	value, err := NewMoney(1, 5).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "1.05", value)
+}
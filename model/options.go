@@ -0,0 +1,48 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Options Option attributes of a variant like {"size": "M", "color": "red"}, stored as jsonb
+type Options map[string]string
+
+// Key Canonical form of the options, equal options have the same key regardless of the order
+func (o Options) Key() string {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+o[name])
+	}
+	return strings.Join(pairs, ";")
+}
+
+func (o Options) Value() (driver.Value, error) {
+	if o == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(o)
+	return string(b), err
+}
+
+func (o *Options) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*o = Options{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, o)
+	case string:
+		return json.Unmarshal([]byte(v), o)
+	}
+	return errors.New(fmt.Sprintf("cannot scan %T into Options", src))
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOptionsKey(t *testing.T) {
+	assert.Equal(t, Options{"size": "M", "color": "red"}.Key(), Options{"color": "red", "size": "M"}.Key())
+	assert.NotEqual(t, Options{"size": "M"}.Key(), Options{"size": "L"}.Key())
+
+	o := Options{}
+	assert.Nil(t, o.Scan([]byte(`{"size":"M"}`)))
+	assert.Equal(t, Options{"size": "M"}, o)
+}
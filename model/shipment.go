@@ -0,0 +1,71 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type ShipmentItem struct {
+	LineId    uint    `json:"line_id,omitempty"`
+	ProductId uint    `json:"product_id"`
+	VariantId *uint   `json:"variant_id,omitempty"`
+	Sku       *string `json:"sku,omitempty"`
+	Quantity  int     `json:"quantity"`
+}
+
+// ShipmentItems Items in a shipment, stored as jsonb
+type ShipmentItems []ShipmentItem
+
+// ShipmentItemsOf Remaining quantity of the order lines, lines which are done are left out
+func ShipmentItemsOf(lines []*OrderLine) ShipmentItems {
+	items := make(ShipmentItems, 0, len(lines))
+	for _, line := range lines {
+		if line.Remaining() <= 0 {
+			continue
+		}
+		items = append(items, ShipmentItem{
+			LineId:    line.ID,
+			ProductId: line.ProductId,
+			VariantId: line.VariantId,
+			Sku:       line.Sku,
+			Quantity:  line.Remaining(),
+		})
+	}
+	return items
+}
+
+// Without Items less the quantity of the other items of the same lines, items with no quantity left are left out
+func (items ShipmentItems) Without(other ShipmentItems) ShipmentItems {
+	quantities := make(map[uint]int, len(other))
+	for _, item := range other {
+		quantities[item.LineId] += item.Quantity
+	}
+	remaining := make(ShipmentItems, 0, len(items))
+	for _, item := range items {
+		item.Quantity -= quantities[item.LineId]
+		if item.Quantity > 0 {
+			remaining = append(remaining, item)
+		}
+	}
+	return remaining
+}
+
+func (items ShipmentItems) Value() (driver.Value, error) {
+	if items == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(items)
+	return string(b), err
+}
+
+func (items *ShipmentItems) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, items)
+	case string:
+		return json.Unmarshal([]byte(v), items)
+	}
+	return errors.New(fmt.Sprintf("cannot scan %T into ShipmentItems", src))
+}
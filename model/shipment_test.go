@@ -0,0 +1,24 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestShipmentItemsOf(t *testing.T) {
+	items := ShipmentItemsOf([]*OrderLine{
+		{ID: 1, ProductId: 3, Quantity: 1, FulfilledQuantity: 1},
+		{ID: 2, ProductId: 4, Quantity: 5, FulfilledQuantity: 1, CanceledQuantity: 2},
+	})
+	assert.Equal(t, ShipmentItems{{LineId: 2, ProductId: 4, Quantity: 2}}, items)
+
+	scanned := ShipmentItems{}
+	assert.Nil(t, scanned.Scan([]byte(`[{"line_id":2,"product_id":4,"quantity":2}]`)))
+	assert.Equal(t, items, scanned)
+}
+
+func TestShipmentItemsWithout(t *testing.T) {
+	items := ShipmentItems{{LineId: 1, ProductId: 3, Quantity: 2}, {LineId: 2, ProductId: 4, Quantity: 2}}
+	assert.Equal(t, ShipmentItems{{LineId: 2, ProductId: 4, Quantity: 1}}, items.Without(ShipmentItems{{LineId: 1, Quantity: 2}, {LineId: 2, Quantity: 1}}))
+	assert.Equal(t, items, items.Without(nil))
+}